@@ -0,0 +1,107 @@
+// Command backfill imports historical impressions from CSV or NDJSON files straight into the
+// configured storage, without going through the HTTP API:
+//
+//	go run ./cmd/backfill -config config.yml impressions.csv
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"learning/cmd/config"
+	"learning/cmd/server"
+	"learning/internal/backfill"
+	"learning/internal/entities"
+	"learning/internal/export"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to the YAML config file (default \"config.yml\" if present)")
+	format := flag.String("format", "", "csv or ndjson (default from the file extension)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] FILE\n\nFILE may be - to read standard input.\n\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*configPath, *format, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "backfill:", err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath, format string, args []string) error {
+	if len(args) != 1 {
+		flag.Usage()
+		return errors.New("exactly one file is required")
+	}
+	path := args[0]
+
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = export.FormatCSV
+		case ".ndjson", ".jsonl":
+			format = export.FormatNDJSON
+		default:
+			return fmt.Errorf("cannot tell the format of %s, pass -format", path)
+		}
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	config.Set(cfg)
+	if cfg.Storage.Driver == "memory" {
+		return errors.New("the memory driver keeps nothing after this process exits, import through POST /api/v1/imports instead")
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	importer, closeStorage, err := server.OpenImporter(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	report, err := importer.Import(ctx, in, backfill.Options{
+		Format:   format,
+		TTL:      time.Duration(cfg.App.TTL) * time.Second,
+		MaxLines: cfg.Import.MaxLines,
+		Progress: func(p entities.ImportProgress) {
+			fmt.Fprintf(os.Stderr, "%s: %d lines read, %d rejected, %d of %d campaigns applied\n",
+				time.Since(started).Round(time.Second), p.Lines, p.Rejected, p.CampaignsApplied, p.Campaigns)
+		},
+	})
+
+	// The report is printed even on failure, as the campaigns it lists were applied
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(report); encodeErr != nil && err == nil {
+		err = encodeErr
+	}
+	return err
+}
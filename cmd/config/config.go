@@ -1,33 +1,494 @@
 package config
 
 import (
-	"github.com/ilyakaznacheev/cleanenv"
-	"log"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
-	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"go.uber.org/zap/zapcore"
 )
 
+// DefaultPath is the config file looked up in the working directory when no path is given
+const DefaultPath = "config.yml"
+
 // Config structure to hold configuration values
 type Config struct {
 	Server struct {
-		Port int `yaml:"port"`
+		Port           int                    `yaml:"port" env:"PORT" env-default:"8080" env-description:"HTTP listen port"`
+		RequestTimeout time.Duration          `yaml:"request_timeout" env:"REQUEST_TIMEOUT" env-default:"5s" env-description:"Default deadline for handling a request"`
+		Routes         map[string]RouteConfig `yaml:"routes"`
+		ExportTimeout  time.Duration          `yaml:"export_timeout" env:"EXPORT_TIMEOUT" env-default:"10m" env-description:"Deadline of CSV and NDJSON downloads, instead of the route timeout"`
+		// APIKeys are the keys of known publishers, rate limited by key instead of by IP
+		APIKeys             []string `yaml:"api_keys" env:"API_KEYS" env-separator:"," env-description:"Publisher keys sent as X-API-Key; other keys are ignored"`
+		RateLimitMaxClients int      `yaml:"rate_limit_max_clients" env:"RATE_LIMIT_MAX_CLIENTS" env-default:"100000" env-description:"Token buckets held at once; further clients share one per route"`
+		// AdminKeys open the /admin endpoints; without any they are disabled
+		AdminKeys []string `yaml:"admin_keys" env:"ADMIN_KEYS" env-separator:"," env-description:"Keys sent as X-Admin-Key to reach the /admin endpoints, disabled without any"`
 	} `yaml:"server"`
 	App struct {
-		TTL int `yaml:"ttl"`
+		TTL int `yaml:"ttl" env:"TTL" env-default:"3600" env-description:"Impression deduplication window in seconds"`
 	} `yaml:"app"`
+	Storage     StorageConfig     `yaml:"storage"`
+	Ingestion   IngestionConfig   `yaml:"ingestion"`
+	Events      EventsConfig      `yaml:"events"`
+	Traffic     TrafficConfig     `yaml:"traffic"`
+	Anomaly     AnomalyConfig     `yaml:"anomaly"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Stream      StreamConfig      `yaml:"stream"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Import      ImportConfig      `yaml:"import"`
+	Logging     LoggingConfig     `yaml:"logging"`
+}
+
+// TrafficConfig controls the invalid-traffic filters applied to impressions before they are counted
+type TrafficConfig struct {
+	Enabled           bool          `yaml:"enabled" env:"TRAFFIC_ENABLED" env-description:"Count impressions flagged by the filters as invalid instead of valid"`
+	BotUserAgents     []string      `yaml:"bot_user_agents" env:"TRAFFIC_BOT_USER_AGENTS" env-default:"bot,crawler,spider,slurp,headlesschrome,phantomjs,python-requests,scrapy" env-separator:"," env-description:"User-Agent substrings of known bots, case insensitive"`
+	IPDenylist        []string      `yaml:"ip_denylist" env:"TRAFFIC_IP_DENYLIST" env-separator:"," env-description:"Client IPs or CIDR ranges whose impressions are invalid"`
+	BurstLimit        int           `yaml:"burst_limit" env:"TRAFFIC_BURST_LIMIT" env-default:"60" env-description:"Impressions a user may send per burst window before the rest is invalid"`
+	BurstWindow       time.Duration `yaml:"burst_window" env:"TRAFFIC_BURST_WINDOW" env-default:"1m" env-description:"Length of the per-user burst window"`
+	TrustForwardedFor bool          `yaml:"trust_forwarded_for" env:"TRAFFIC_TRUST_FORWARDED_FOR" env-description:"Take the client IP from X-Forwarded-For, only behind a trusted proxy"`
+	TrustedProxies    int           `yaml:"trusted_proxies" env:"TRAFFIC_TRUSTED_PROXIES" env-default:"1" env-description:"Proxies in front of the service appending to X-Forwarded-For"`
+}
+
+// ForwardedHops is the number of X-Forwarded-For entries, counted from the right, appended by proxies
+// of the deployment, or 0 when the header is not trusted
+func (t *TrafficConfig) ForwardedHops() int {
+	if !t.TrustForwardedFor {
+		return 0
+	}
+	return t.TrustedProxies
+}
+
+// AnomalyConfig controls the detector watching the stored per-minute impression counts of every campaign
+type AnomalyConfig struct {
+	Enabled           bool          `yaml:"enabled" env:"ANOMALY_ENABLED" env-description:"Watch campaign traffic for spikes, drops and duplicate floods"`
+	Interval          time.Duration `yaml:"interval" env:"ANOMALY_INTERVAL" env-default:"1m" env-description:"How often the campaigns are evaluated"`
+	Baseline          time.Duration `yaml:"baseline" env:"ANOMALY_BASELINE" env-default:"30m" env-description:"History a spike or drop is compared against"`
+	SpikeFactor       float64       `yaml:"spike_factor" env:"ANOMALY_SPIKE_FACTOR" env-default:"3" env-description:"Multiple of the baseline rate a minute must reach to be a spike"`
+	DropAfter         time.Duration `yaml:"drop_after" env:"ANOMALY_DROP_AFTER" env-default:"5m" env-description:"Time without impressions after which an active campaign has dropped"`
+	DuplicateWindow   time.Duration `yaml:"duplicate_window" env:"ANOMALY_DUPLICATE_WINDOW" env-default:"5m" env-description:"Span over which the duplicate ratio is measured"`
+	MaxDuplicateRatio float64       `yaml:"max_duplicate_ratio" env:"ANOMALY_MAX_DUPLICATE_RATIO" env-default:"0.5" env-description:"Share of duplicates above which an alert is raised"`
+	MinVolume         float64       `yaml:"min_volume" env:"ANOMALY_MIN_VOLUME" env-default:"10" env-description:"Impressions per minute below which traffic is too thin to judge"`
+	Webhooks          []string      `yaml:"webhooks" env:"ANOMALY_WEBHOOKS" env-separator:"," env-description:"URLs receiving raised and resolved alerts"`
+	WebhookTimeout    time.Duration `yaml:"webhook_timeout" env:"ANOMALY_WEBHOOK_TIMEOUT" env-default:"5s" env-description:"Deadline of one webhook delivery"`
+}
+
+// WebhooksConfig controls the per-campaign milestone webhooks
+type WebhooksConfig struct {
+	Enabled             bool          `yaml:"enabled" env:"WEBHOOKS_ENABLED" env-description:"Serve webhook subscriptions and deliver campaign milestones"`
+	CheckInterval       time.Duration `yaml:"check_interval" env:"WEBHOOKS_CHECK_INTERVAL" env-default:"5s" env-description:"How often campaign totals and flight boundaries are checked"`
+	Workers             int           `yaml:"workers" env:"WEBHOOKS_WORKERS" env-default:"4" env-description:"Concurrent deliveries"`
+	Timeout             time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" env-default:"5s" env-description:"Deadline of one delivery attempt"`
+	MaxAttempts         int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"8" env-description:"Attempts before a payload goes to the dead-letter list"`
+	InitialBackoff      time.Duration `yaml:"initial_backoff" env:"WEBHOOKS_INITIAL_BACKOFF" env-default:"1s" env-description:"Wait after the first failed attempt, doubled after each further one"`
+	MaxBackoff          time.Duration `yaml:"max_backoff" env:"WEBHOOKS_MAX_BACKOFF" env-default:"5m" env-description:"Longest wait between two attempts"`
+	DeadLetterLimit     int           `yaml:"dead_letter_limit" env:"WEBHOOKS_DEAD_LETTER_LIMIT" env-default:"1000" env-description:"Undeliverable payloads kept per campaign for inspection"`
+	AllowPrivateTargets bool          `yaml:"allow_private_targets" env:"WEBHOOKS_ALLOW_PRIVATE_TARGETS" env-description:"Deliver to loopback, link-local and private addresses, for local development only"`
+}
+
+// StreamConfig controls the live stats streams
+type StreamConfig struct {
+	Interval       time.Duration `yaml:"interval" env:"STREAM_INTERVAL" env-default:"1s" env-description:"Shortest time between two updates of a stream"`
+	KeepAlive      time.Duration `yaml:"keep_alive" env:"STREAM_KEEP_ALIVE" env-default:"15s" env-description:"Time between keep-alive comments on an idle stream"`
+	MaxSubscribers int           `yaml:"max_subscribers" env:"STREAM_MAX_SUBSCRIBERS" env-default:"1000" env-description:"Open streams per instance; more are answered with 503"`
+}
+
+// IdempotencyConfig controls how long responses to POSTs with an Idempotency-Key are replayed
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h" env-description:"How long the first response to an idempotency key is replayed"`
+}
+
+// ImportConfig limits the imports of historical impressions
+type ImportConfig struct {
+	MaxUploadMB int `yaml:"max_upload_mb" env:"IMPORT_MAX_UPLOAD_MB" env-default:"256" env-description:"Largest import file accepted over HTTP"`
+	MaxLines    int `yaml:"max_lines" env:"IMPORT_MAX_LINES" env-default:"10000000" env-description:"Impressions one import may hold, as they are deduplicated in memory"`
+}
+
+// EventsConfig controls the raw impression log kept for auditing
+type EventsConfig struct {
+	Enabled          bool          `yaml:"enabled" env:"EVENTS_ENABLED" env-description:"Keep every counted impression in the event log"`
+	Dir              string        `yaml:"dir" env:"EVENTS_DIR" env-default:"events" env-description:"Directory holding the event log segments"`
+	SegmentMaxSizeMB int           `yaml:"segment_max_size_mb" env:"EVENTS_SEGMENT_MAX_SIZE_MB" env-default:"64" env-description:"Start a new segment at this size"`
+	SegmentMaxAge    time.Duration `yaml:"segment_max_age" env:"EVENTS_SEGMENT_MAX_AGE" env-default:"1h" env-description:"Start a new segment after this long"`
+	Retention        time.Duration `yaml:"retention" env:"EVENTS_RETENTION" env-default:"168h" env-description:"Delete segments last written longer ago than this"`
+}
+
+// IngestionConfig controls whether impressions are applied inline or through the async queue
+type IngestionConfig struct {
+	Mode             string        `yaml:"mode" env:"INGESTION_MODE" env-default:"sync" env-description:"Impression ingestion mode (sync, async)"`
+	QueueSize        int           `yaml:"queue_size" env:"INGESTION_QUEUE_SIZE" env-default:"10000" env-description:"Impressions buffered before the API answers 503"`
+	Workers          int           `yaml:"workers" env:"INGESTION_WORKERS" env-default:"4" env-description:"Goroutines applying queued impressions"`
+	BatchSize        int           `yaml:"batch_size" env:"INGESTION_BATCH_SIZE" env-default:"100" env-description:"Impressions applied to the repository at once"`
+	FlushInterval    time.Duration `yaml:"flush_interval" env:"INGESTION_FLUSH_INTERVAL" env-default:"50ms" env-description:"Longest wait for a batch to fill up"`
+	RetryAfter       time.Duration `yaml:"retry_after" env:"INGESTION_RETRY_AFTER" env-default:"1s" env-description:"Retry-After sent to clients when the queue is full"`
+	CampaignCacheTTL time.Duration `yaml:"campaign_cache_ttl" env:"INGESTION_CAMPAIGN_CACHE_TTL" env-default:"1m" env-description:"How long async mode remembers that a campaign exists before queueing its impressions"`
+	MaxClockSkew     time.Duration `yaml:"max_clock_skew" env:"INGESTION_MAX_CLOCK_SKEW" env-default:"5m" env-description:"How far in the future a client timestamp may be"`
+	LateAfter        time.Duration `yaml:"late_after" env:"INGESTION_LATE_AFTER" env-default:"1m" env-description:"Impressions arriving this long after they happened are counted as late"`
+	MaxLateness      time.Duration `yaml:"max_lateness" env:"INGESTION_MAX_LATENESS" env-default:"24h" env-description:"Impressions older than this are rejected as too old"`
+}
+
+// StorageConfig selects the repository backend
+type StorageConfig struct {
+	Driver        string        `yaml:"driver" env:"STORAGE_DRIVER" env-default:"memory" env-description:"Repository backend (memory, bolt, postgres, redis)"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"STORAGE_PURGE_INTERVAL" env-default:"10m" env-description:"How often persistent backends delete expired dedup entries"`
+	Bolt          struct {
+		Path string `yaml:"path" env:"STORAGE_BOLT_PATH" env-default:"impressions.db" env-description:"Database file of the bolt backend"`
+	} `yaml:"bolt"`
+	Postgres struct {
+		DSN          string `yaml:"dsn" env:"STORAGE_POSTGRES_DSN" env-description:"PostgreSQL connection string"`
+		Schema       string `yaml:"schema" env:"STORAGE_POSTGRES_SCHEMA" env-default:"public" env-description:"Schema holding the service tables"`
+		MaxOpenConns int    `yaml:"max_open_conns" env:"STORAGE_POSTGRES_MAX_OPEN_CONNS" env-default:"20" env-description:"Connection pool size"`
+	} `yaml:"postgres"`
+	Redis struct {
+		Addr            string        `yaml:"addr" env:"STORAGE_REDIS_ADDR" env-default:"localhost:6379" env-description:"Redis server address"`
+		Password        string        `yaml:"password" env:"STORAGE_REDIS_PASSWORD" env-description:"Redis password"`
+		DB              int           `yaml:"db" env:"STORAGE_REDIS_DB" env-description:"Redis database number"`
+		Prefix          string        `yaml:"prefix" env:"STORAGE_REDIS_PREFIX" env-default:"impression:" env-description:"Prefix of every key written by the service"`
+		BucketRetention time.Duration `yaml:"bucket_retention" env:"STORAGE_REDIS_BUCKET_RETENTION" env-default:"720h" env-description:"How long hourly counters are kept"`
+	} `yaml:"redis"`
+}
+
+// RouteConfig overrides server defaults for a single route pattern
+type RouteConfig struct {
+	Timeout   time.Duration   `yaml:"timeout"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig is a token bucket per client, identified by a known X-API-Key or its IP; a zero rate disables it
+type RateLimitConfig struct {
+	// Rate is the number of requests per second a client may sustain
+	Rate float64 `yaml:"rate"`
+	// Burst is the number of requests a client may send at once
+	Burst int `yaml:"burst"`
+}
+
+// RouteTimeout returns the deadline for route, falling back to server.request_timeout
+func (c *Config) RouteTimeout(route string) time.Duration {
+	if rc, ok := c.Server.Routes[route]; ok && rc.Timeout > 0 {
+		return rc.Timeout
+	}
+	return c.Server.RequestTimeout
 }
 
-// LoadConfig reads the configuration from file
-func LoadConfig() (*Config, error) {
+// LoggingConfig controls how the shared zap logger is built
+type LoggingConfig struct {
+	Level       string   `yaml:"level" env:"LOG_LEVEL" env-default:"info" env-description:"Minimum log level (debug, info, warn, error)"`
+	Encoding    string   `yaml:"encoding" env:"LOG_ENCODING" env-default:"json" env-description:"Log encoding (json, console)"`
+	OutputPaths []string `yaml:"output_paths" env:"LOG_OUTPUT_PATHS" env-default:"stderr" env-separator:"," env-description:"Log destinations: stdout, stderr or file paths"`
+	Sampling    struct {
+		Enabled    bool `yaml:"enabled" env:"LOG_SAMPLING_ENABLED" env-description:"Drop repeated log lines above the per-second budget"`
+		Initial    int  `yaml:"initial" env:"LOG_SAMPLING_INITIAL" env-default:"100" env-description:"Identical entries logged per second before sampling starts"`
+		Thereafter int  `yaml:"thereafter" env:"LOG_SAMPLING_THEREAFTER" env-default:"100" env-description:"Log every Nth identical entry once sampling started"`
+	} `yaml:"sampling"`
+	Rotation struct {
+		MaxSizeMB  int  `yaml:"max_size_mb" env:"LOG_ROTATION_MAX_SIZE_MB" env-description:"Rotate log files at this size, 0 disables rotation"`
+		MaxBackups int  `yaml:"max_backups" env:"LOG_ROTATION_MAX_BACKUPS" env-description:"Rotated files to keep, 0 keeps all"`
+		MaxAgeDays int  `yaml:"max_age_days" env:"LOG_ROTATION_MAX_AGE_DAYS" env-description:"Days to keep rotated files, 0 keeps them forever"`
+		Compress   bool `yaml:"compress" env:"LOG_ROTATION_COMPRESS" env-description:"Gzip rotated files"`
+	} `yaml:"rotation"`
+}
+
+// current holds the configuration shared by the running service
+var current atomic.Pointer[Config]
+
+// updates serialises Update and Watcher.Reload, so neither swaps in a copy that misses the other's change
+var updates sync.Mutex
+
+// LoadConfig reads the configuration from file, applies environment overrides and validates it.
+// An empty path falls back to DefaultPath, which may be missing, in which case only defaults and
+// environment variables are used. An explicitly given path must exist.
+func LoadConfig(path string) (*Config, error) {
 	var cfg Config
 
-	execPath, err := os.Getwd()
-	configPath := filepath.Join(execPath, "config.yml")
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath
+	}
+
+	_, statErr := os.Stat(path)
+	switch {
+	case statErr == nil:
+		if err := cleanenv.ReadConfig(path, &cfg); err != nil {
+			return nil, fmt.Errorf("read config file %s: %w", path, err)
+		}
+	case errors.Is(statErr, os.ErrNotExist) && !explicit:
+		if err := cleanenv.ReadEnv(&cfg); err != nil {
+			return nil, fmt.Errorf("read config from environment: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("open config file %s: %w", path, statErr)
+	}
 
-	err = cleanenv.ReadConfig(configPath, &cfg)
-	if err != nil {
-		log.Fatalf("❌ Failed to read config file: %v", err)
-		return nil, err
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 	return &cfg, nil
 }
+
+// Validate checks every value is within its accepted range
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.request_timeout must be positive, got %s", c.Server.RequestTimeout))
+	}
+	if c.Server.ExportTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.export_timeout must be positive, got %s", c.Server.ExportTimeout))
+	}
+	for route, rc := range c.Server.Routes {
+		if rc.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("server.routes[%s].timeout must not be negative, got %s", route, rc.Timeout))
+		}
+		if rl := rc.RateLimit; rl.Rate < 0 || (rl.Rate > 0 && rl.Burst < 1) {
+			errs = append(errs, fmt.Errorf("server.routes[%s].rate_limit needs a non-negative rate and a burst of at least 1 when enabled", route))
+		}
+	}
+	for _, key := range c.Server.APIKeys {
+		if key == "" {
+			errs = append(errs, errors.New("server.api_keys must not hold empty keys"))
+			break
+		}
+	}
+	for _, key := range c.Server.AdminKeys {
+		if key == "" {
+			errs = append(errs, errors.New("server.admin_keys must not hold empty keys"))
+			break
+		}
+	}
+	if c.Server.RateLimitMaxClients <= 0 {
+		errs = append(errs, fmt.Errorf("server.rate_limit_max_clients must be positive, got %d", c.Server.RateLimitMaxClients))
+	}
+	if c.App.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("app.ttl must be a positive number of seconds, got %d", c.App.TTL))
+	}
+	errs = append(errs, c.Storage.validate()...)
+	errs = append(errs, c.Ingestion.validate()...)
+	errs = append(errs, c.Events.validate()...)
+	errs = append(errs, c.Traffic.validate()...)
+	errs = append(errs, c.Anomaly.validate()...)
+	// The detector reads its windows from the stored minute buckets, which redis keeps 2h and the others a day
+	retention := 24 * time.Hour
+	if c.Storage.Driver == "redis" {
+		retention = 2 * time.Hour
+	}
+	if c.Anomaly.Enabled && c.Anomaly.Baseline+c.Anomaly.DropAfter > retention {
+		errs = append(errs, fmt.Errorf("anomaly.baseline plus anomaly.drop_after must not exceed the %s the %s driver keeps minute counts", retention, c.Storage.Driver))
+	}
+	errs = append(errs, c.Webhooks.validate()...)
+	if c.Stream.Interval <= 0 || c.Stream.KeepAlive <= 0 || c.Stream.MaxSubscribers <= 0 {
+		errs = append(errs, errors.New("stream.interval, stream.keep_alive and stream.max_subscribers must be positive"))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency.ttl must be positive, got %s", c.Idempotency.TTL))
+	}
+	if c.Import.MaxUploadMB <= 0 || c.Import.MaxLines <= 0 {
+		errs = append(errs, errors.New("import.max_upload_mb and import.max_lines must be positive"))
+	}
+	errs = append(errs, c.Logging.validate()...)
+	return errors.Join(errs...)
+}
+
+func (s *StorageConfig) validate() []error {
+	var errs []error
+	switch s.Driver {
+	case "memory":
+	case "bolt":
+		if s.Bolt.Path == "" {
+			errs = append(errs, errors.New("storage.bolt.path is required for the bolt driver"))
+		}
+	case "postgres":
+		if s.Postgres.DSN == "" {
+			errs = append(errs, errors.New("storage.postgres.dsn is required for the postgres driver"))
+		}
+		if s.Postgres.Schema == "" {
+			errs = append(errs, errors.New("storage.postgres.schema must not be empty"))
+		}
+		if s.Postgres.MaxOpenConns <= 0 {
+			errs = append(errs, fmt.Errorf("storage.postgres.max_open_conns must be positive, got %d", s.Postgres.MaxOpenConns))
+		}
+	case "redis":
+		if s.Redis.Addr == "" {
+			errs = append(errs, errors.New("storage.redis.addr is required for the redis driver"))
+		}
+		if s.Redis.DB < 0 {
+			errs = append(errs, fmt.Errorf("storage.redis.db must not be negative, got %d", s.Redis.DB))
+		}
+		if s.Redis.BucketRetention < 24*time.Hour {
+			errs = append(errs, fmt.Errorf("storage.redis.bucket_retention must cover at least 24h, got %s", s.Redis.BucketRetention))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.driver must be memory, bolt, postgres or redis, got %q", s.Driver))
+	}
+	if s.PurgeInterval <= 0 {
+		errs = append(errs, fmt.Errorf("storage.purge_interval must be positive, got %s", s.PurgeInterval))
+	}
+	return errs
+}
+
+func (i *IngestionConfig) validate() []error {
+	var errs []error
+	if i.Mode != "sync" && i.Mode != "async" {
+		errs = append(errs, fmt.Errorf("ingestion.mode must be sync or async, got %q", i.Mode))
+	}
+	if i.QueueSize <= 0 || i.Workers <= 0 || i.BatchSize <= 0 {
+		errs = append(errs, errors.New("ingestion.queue_size, ingestion.workers and ingestion.batch_size must be positive"))
+	}
+	if i.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("ingestion.flush_interval must be positive, got %s", i.FlushInterval))
+	}
+	if i.RetryAfter < time.Second {
+		errs = append(errs, fmt.Errorf("ingestion.retry_after must be at least 1s, got %s", i.RetryAfter))
+	}
+	if i.CampaignCacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("ingestion.campaign_cache_ttl must be positive, got %s", i.CampaignCacheTTL))
+	}
+	if i.MaxClockSkew <= 0 || i.LateAfter <= 0 {
+		errs = append(errs, errors.New("ingestion.max_clock_skew and ingestion.late_after must be positive"))
+	}
+	if i.MaxLateness <= i.LateAfter {
+		errs = append(errs, fmt.Errorf("ingestion.max_lateness must be longer than ingestion.late_after, got %s", i.MaxLateness))
+	}
+	return errs
+}
+
+func (e *EventsConfig) validate() []error {
+	var errs []error
+	if e.Enabled && e.Dir == "" {
+		errs = append(errs, errors.New("events.dir is required when the event log is enabled"))
+	}
+	if e.SegmentMaxSizeMB <= 0 {
+		errs = append(errs, fmt.Errorf("events.segment_max_size_mb must be positive, got %d", e.SegmentMaxSizeMB))
+	}
+	if e.SegmentMaxAge <= 0 || e.Retention <= 0 {
+		errs = append(errs, errors.New("events.segment_max_age and events.retention must be positive"))
+	}
+	return errs
+}
+
+func (t *TrafficConfig) validate() []error {
+	var errs []error
+	if t.BurstLimit <= 0 {
+		errs = append(errs, fmt.Errorf("traffic.burst_limit must be positive, got %d", t.BurstLimit))
+	}
+	if t.BurstWindow <= 0 {
+		errs = append(errs, fmt.Errorf("traffic.burst_window must be positive, got %s", t.BurstWindow))
+	}
+	if t.TrustedProxies < 1 {
+		errs = append(errs, fmt.Errorf("traffic.trusted_proxies must be at least 1, got %d", t.TrustedProxies))
+	}
+	for _, rule := range t.IPDenylist {
+		if _, err := netip.ParsePrefix(rule); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(rule); err != nil {
+			errs = append(errs, fmt.Errorf("traffic.ip_denylist entry %q is neither an IP nor a CIDR range", rule))
+		}
+	}
+	return errs
+}
+
+func (a *AnomalyConfig) validate() []error {
+	var errs []error
+	if a.Interval <= 0 || a.WebhookTimeout <= 0 {
+		errs = append(errs, errors.New("anomaly.interval and anomaly.webhook_timeout must be positive"))
+	}
+	if a.Baseline < time.Minute || a.DropAfter < time.Minute || a.DuplicateWindow < time.Minute {
+		errs = append(errs, errors.New("anomaly.baseline, anomaly.drop_after and anomaly.duplicate_window must be at least 1m"))
+	}
+	if a.SpikeFactor <= 1 {
+		errs = append(errs, fmt.Errorf("anomaly.spike_factor must be greater than 1, got %g", a.SpikeFactor))
+	}
+	if a.MaxDuplicateRatio <= 0 || a.MaxDuplicateRatio >= 1 {
+		errs = append(errs, fmt.Errorf("anomaly.max_duplicate_ratio must be between 0 and 1, got %g", a.MaxDuplicateRatio))
+	}
+	if a.MinVolume <= 0 {
+		errs = append(errs, fmt.Errorf("anomaly.min_volume must be positive, got %g", a.MinVolume))
+	}
+	for _, hook := range a.Webhooks {
+		if u, err := url.Parse(hook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("anomaly.webhooks entry %q must be an http or https URL", hook))
+		}
+	}
+	return errs
+}
+
+func (w *WebhooksConfig) validate() []error {
+	var errs []error
+	if w.CheckInterval <= 0 || w.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks.check_interval and webhooks.timeout must be positive"))
+	}
+	if w.Workers <= 0 || w.MaxAttempts <= 0 || w.DeadLetterLimit <= 0 {
+		errs = append(errs, errors.New("webhooks.workers, webhooks.max_attempts and webhooks.dead_letter_limit must be positive"))
+	}
+	if w.InitialBackoff <= 0 || w.MaxBackoff < w.InitialBackoff {
+		errs = append(errs, fmt.Errorf("webhooks.initial_backoff must be positive and at most webhooks.max_backoff, got %s and %s", w.InitialBackoff, w.MaxBackoff))
+	}
+	return errs
+}
+
+func (l *LoggingConfig) validate() []error {
+	var errs []error
+	if _, err := zapcore.ParseLevel(l.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %w", err))
+	}
+	if l.Encoding != "json" && l.Encoding != "console" {
+		errs = append(errs, fmt.Errorf("logging.encoding must be json or console, got %q", l.Encoding))
+	}
+	if len(l.OutputPaths) == 0 {
+		errs = append(errs, errors.New("logging.output_paths must list at least one destination"))
+	}
+	if l.Sampling.Enabled && (l.Sampling.Initial <= 0 || l.Sampling.Thereafter <= 0) {
+		errs = append(errs, errors.New("logging.sampling.initial and logging.sampling.thereafter must be positive"))
+	}
+	if l.Rotation.MaxSizeMB < 0 || l.Rotation.MaxBackups < 0 || l.Rotation.MaxAgeDays < 0 {
+		errs = append(errs, errors.New("logging.rotation values must not be negative"))
+	}
+	return errs
+}
+
+// Set makes cfg the configuration returned by Current
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+// Update applies fn to a copy of the live configuration and swaps the copy in, unless fn fails. It runs
+// one at a time with config reloads, including their OnReload callbacks, so side effects fn applies
+// together with the change, such as a new log level, are not undone by a reload racing with it.
+func Update(fn func(cfg *Config) error) error {
+	updates.Lock()
+	defer updates.Unlock()
+
+	cfg := *Current()
+	if err := fn(&cfg); err != nil {
+		return err
+	}
+	Set(&cfg)
+	return nil
+}
+
+// Current returns the configuration of the running service. Until one was set it is read from the
+// defaults and the environment, like LoadConfig without a file; an environment that does not make a
+// valid configuration is a programming or deployment error, so Current panics instead of guessing.
+func Current() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+
+	var cfg Config
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		panic(fmt.Sprintf("config: read config from environment: %v", err))
+	}
+	if err := cfg.Validate(); err != nil {
+		panic(fmt.Sprintf("config: invalid config: %v", err))
+	}
+	current.CompareAndSwap(nil, &cfg)
+	return current.Load()
+}
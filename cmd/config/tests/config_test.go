@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"learning/cmd/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("❌ Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaultsWithoutFile(t *testing.T) {
+	// The tests directory has no config.yml, so only defaults apply
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		t.Fatalf("❌ Expected defaults without a config file, got error: %v", err)
+	}
+	if cfg.Server.Port != 8080 || cfg.App.TTL != 3600 {
+		t.Errorf("❌ Expected default port 8080 and ttl 3600, got %d and %d", cfg.Server.Port, cfg.App.TTL)
+	}
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  port: 9090\napp:\n  ttl: 60\n")
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+	if cfg.Server.Port != 9090 || cfg.App.TTL != 60 {
+		t.Errorf("❌ Expected port 9090 and ttl 60, got %d and %d", cfg.Server.Port, cfg.App.TTL)
+	}
+}
+
+func TestLoadConfigEnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  port: 9090\napp:\n  ttl: 60\n")
+	t.Setenv("PORT", "7070")
+	t.Setenv("TTL", "120")
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+	if cfg.Server.Port != 7070 || cfg.App.TTL != 120 {
+		t.Errorf("❌ Expected env values 7070 and 120, got %d and %d", cfg.Server.Port, cfg.App.TTL)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          func(t *testing.T) string
+		expectedError string
+	}{
+		{"Missing Explicit File", func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing.yml") }, "open config file"},
+		{"Port Out Of Range", func(t *testing.T) string { return writeConfigFile(t, "server:\n  port: 70000\n") }, "server.port"},
+		{"Negative TTL", func(t *testing.T) string { return writeConfigFile(t, "app:\n  ttl: -5\n") }, "app.ttl"},
+		{"Negative Request Timeout", func(t *testing.T) string { return writeConfigFile(t, "server:\n  request_timeout: -1s\n") }, "server.request_timeout"},
+		{"Unknown Storage Driver", func(t *testing.T) string { return writeConfigFile(t, "storage:\n  driver: mongo\n") }, "storage.driver"},
+		{"Postgres Without DSN", func(t *testing.T) string { return writeConfigFile(t, "storage:\n  driver: postgres\n") }, "storage.postgres.dsn"},
+		{"Unknown Ingestion Mode", func(t *testing.T) string { return writeConfigFile(t, "ingestion:\n  mode: kafka\n") }, "ingestion.mode"},
+		{"Negative Event Retention", func(t *testing.T) string { return writeConfigFile(t, "events:\n  retention: -1h\n") }, "events.retention"},
+		{"Lateness Below Late Threshold", func(t *testing.T) string {
+			return writeConfigFile(t, "ingestion:\n  late_after: 1h\n  max_lateness: 30m\n")
+		}, "ingestion.max_lateness"},
+		{"Rate Limit Without Burst", func(t *testing.T) string {
+			return writeConfigFile(t, "server:\n  routes:\n    /api/v1/impressions:\n      rate_limit:\n        rate: 10\n")
+		}, "rate_limit"},
+		{"Bad Denylist Entry", func(t *testing.T) string { return writeConfigFile(t, "traffic:\n  ip_denylist: [10.0.0.0/33]\n") }, "traffic.ip_denylist"},
+		{"Duplicate Ratio Above One", func(t *testing.T) string { return writeConfigFile(t, "anomaly:\n  max_duplicate_ratio: 1.5\n") }, "anomaly.max_duplicate_ratio"},
+		{"Alert Webhook Not A URL", func(t *testing.T) string { return writeConfigFile(t, "anomaly:\n  webhooks: [ftp://example.com]\n") }, "anomaly.webhooks"},
+		{"Alert Windows Beyond Redis Retention", func(t *testing.T) string {
+			return writeConfigFile(t, "storage:\n  driver: redis\nanomaly:\n  enabled: true\n  baseline: 2h\n")
+		}, "anomaly.baseline plus anomaly.drop_after"},
+		{"Webhook Backoff Above Max", func(t *testing.T) string {
+			return writeConfigFile(t, "webhooks:\n  initial_backoff: 10m\n  max_backoff: 1m\n")
+		}, "webhooks.initial_backoff"},
+		{"Negative Webhook Attempts", func(t *testing.T) string { return writeConfigFile(t, "webhooks:\n  max_attempts: -1\n") }, "webhooks.max_attempts"},
+		{"Negative Stream Interval", func(t *testing.T) string { return writeConfigFile(t, "stream:\n  interval: -1s\n") }, "stream.interval"},
+		{"Negative Idempotency TTL", func(t *testing.T) string { return writeConfigFile(t, "idempotency:\n  ttl: -1h\n") }, "idempotency.ttl"},
+		{"Malformed YAML", func(t *testing.T) string { return writeConfigFile(t, "server: [\n") }, "read config file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := config.LoadConfig(test.path(t))
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("❌ Expected error containing %q, got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRouteTimeouts(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  request_timeout: 2s\n  routes:\n    /api/v1/impressions:\n      timeout: 250ms\n")
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+	if got := cfg.RouteTimeout("/api/v1/impressions"); got != 250*time.Millisecond {
+		t.Errorf("❌ Expected the route override of 250ms, got %s", got)
+	}
+	if got := cfg.RouteTimeout("/api/v1/campaigns"); got != 2*time.Second {
+		t.Errorf("❌ Expected the server default of 2s, got %s", got)
+	}
+}
+
+func TestCurrentPanicsOnInvalidEnvironment(t *testing.T) {
+	// Current keeps the first configuration for the whole process, so the invalid one is read in a child
+	if os.Getenv("CONFIG_TEST_CURRENT") == "1" {
+		defer func() {
+			if recovered := recover(); recovered == nil || !strings.Contains(recovered.(string), "server.port") {
+				os.Exit(3)
+			}
+			os.Exit(0)
+		}()
+		config.Current()
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCurrentPanicsOnInvalidEnvironment$")
+	cmd.Env = append(os.Environ(), "CONFIG_TEST_CURRENT=1", "PORT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("❌ Expected Current to panic naming server.port, got %v: %s", err, out)
+	}
+}
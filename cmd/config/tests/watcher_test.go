@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"learning/cmd/config"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWatcherReloadSwapsConfig(t *testing.T) {
+	path := writeConfigFile(t, "app:\n  ttl: 60\nlogging:\n  level: info\n")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+	config.Set(cfg)
+
+	watcher := config.NewWatcher(path, zap.NewNop())
+	var reloaded []string
+	watcher.OnReload(func(prev, next *config.Config) {
+		reloaded = config.Diff(prev, next)
+	})
+
+	if err := os.WriteFile(path, []byte("app:\n  ttl: 120\nlogging:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to rewrite config file: %v", err)
+	}
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("❌ Unexpected reload error: %v", err)
+	}
+
+	if config.Current().App.TTL != 120 || config.Current().Logging.Level != "debug" {
+		t.Errorf("❌ Expected ttl 120 and level debug, got %d and %s", config.Current().App.TTL, config.Current().Logging.Level)
+	}
+	expected := []string{"app.ttl: 60 -> 120", "logging.level: info -> debug"}
+	if strings.Join(reloaded, ",") != strings.Join(expected, ",") {
+		t.Errorf("❌ Expected diff %v, got %v", expected, reloaded)
+	}
+}
+
+func TestWatcherKeepsLevelChangedAtRuntime(t *testing.T) {
+	path := writeConfigFile(t, "app:\n  ttl: 60\nlogging:\n  level: info\n")
+	cfg, _ := config.LoadConfig(path)
+	config.Set(cfg)
+	watcher := config.NewWatcher(path, zap.NewNop())
+
+	// As set through /admin/log/level
+	changed := *config.Current()
+	changed.Logging.Level = "debug"
+	config.Set(&changed)
+
+	if err := os.WriteFile(path, []byte("app:\n  ttl: 120\nlogging:\n  level: info\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to rewrite config file: %v", err)
+	}
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("❌ Unexpected reload error: %v", err)
+	}
+	if config.Current().App.TTL != 120 || config.Current().Logging.Level != "debug" {
+		t.Errorf("❌ Expected ttl 120 and the runtime level debug, got %d and %s", config.Current().App.TTL, config.Current().Logging.Level)
+	}
+
+	// Changing the level in the file wins over the runtime level
+	if err := os.WriteFile(path, []byte("app:\n  ttl: 120\nlogging:\n  level: warn\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to rewrite config file: %v", err)
+	}
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("❌ Unexpected reload error: %v", err)
+	}
+	if config.Current().Logging.Level != "warn" {
+		t.Errorf("❌ Expected the level of the file, got %s", config.Current().Logging.Level)
+	}
+}
+
+func TestWatcherRejectsInvalidConfig(t *testing.T) {
+	path := writeConfigFile(t, "app:\n  ttl: 60\n")
+	cfg, _ := config.LoadConfig(path)
+	config.Set(cfg)
+
+	watcher := config.NewWatcher(path, zap.NewNop())
+	called := false
+	watcher.OnReload(func(prev, next *config.Config) { called = true })
+
+	if err := os.WriteFile(path, []byte("app:\n  ttl: -1\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to rewrite config file: %v", err)
+	}
+	if err := watcher.Reload(); err == nil {
+		t.Fatal("❌ Expected an invalid config to be rejected")
+	}
+
+	if config.Current() != cfg {
+		t.Error("❌ Expected the previous config to stay active")
+	}
+	if called {
+		t.Error("❌ Reload hooks must not run for a rejected config")
+	}
+}
+
+func TestWatcherDetectsFileChanges(t *testing.T) {
+	path := writeConfigFile(t, "app:\n  ttl: 60\n")
+	cfg, _ := config.LoadConfig(path)
+	config.Set(cfg)
+
+	watcher := config.NewWatcher(path, zap.NewNop())
+	watcher.Interval = 10 * time.Millisecond
+	done := make(chan struct{}, 1)
+	watcher.OnReload(func(prev, next *config.Config) { done <- struct{}{} })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Run(ctx)
+
+	if err := os.WriteFile(path, []byte("app:\n  ttl: 300\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to rewrite config file: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("❌ Expected the watcher to pick up the file change")
+	}
+	if config.Current().App.TTL != 300 {
+		t.Errorf("❌ Expected ttl 300, got %d", config.Current().App.TTL)
+	}
+}
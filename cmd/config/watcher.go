@@ -0,0 +1,168 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultWatchInterval is how often the watcher checks the config file for changes
+const DefaultWatchInterval = 2 * time.Second
+
+// Watcher reloads the config file on SIGHUP or when the file changes on disk
+type Watcher struct {
+	// Interval between file modification checks
+	Interval time.Duration
+
+	path   string
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	modTime   time.Time
+	size      int64
+	fileLevel string // logging.level of the file as last loaded
+	onReload  []func(prev, next *Config)
+}
+
+// NewWatcher creates a watcher for path, using the same path rules as LoadConfig
+func NewWatcher(path string, logger *zap.Logger) *Watcher {
+	w := &Watcher{
+		Interval: DefaultWatchInterval,
+		path:     path,
+		logger:   logger,
+	}
+	w.modTime, w.size = w.stat()
+	w.fileLevel = Current().Logging.Level
+	return w
+}
+
+// OnReload registers fn to be called after a new config has been swapped in. It must not call Update.
+func (w *Watcher) OnReload(fn func(prev, next *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onReload = append(w.onReload, fn)
+}
+
+// Run watches for SIGHUP and file changes until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.logger.Info("SIGHUP received, reloading config")
+			_ = w.Reload()
+		case <-ticker.C:
+			if w.changed() {
+				w.logger.Info("config file changed, reloading config")
+				_ = w.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads and validates the config file and swaps it in. An invalid config is
+// rejected and the previous one stays active.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.modTime, w.size = w.stat()
+
+	next, err := LoadConfig(w.path)
+	if err != nil {
+		w.logger.Error("config reload rejected, keeping previous config", zap.Error(err))
+		return err
+	}
+
+	updates.Lock()
+	defer updates.Unlock()
+	prev := Current()
+	// A level changed at runtime through the admin endpoint is kept until the file changes the level
+	fileLevel := next.Logging.Level
+	if fileLevel == w.fileLevel {
+		next.Logging.Level = prev.Logging.Level
+	}
+	w.fileLevel = fileLevel
+
+	changes := Diff(prev, next)
+	if len(changes) == 0 {
+		w.logger.Info("config reloaded, nothing changed")
+		return nil
+	}
+
+	Set(next)
+	w.logger.Info("config reloaded", zap.Strings("changes", changes))
+	if prev.Server.Port != next.Server.Port {
+		w.logger.Warn("server.port changes only take effect after a restart")
+	}
+
+	for _, fn := range w.onReload {
+		fn(prev, next)
+	}
+	return nil
+}
+
+func (w *Watcher) changed() bool {
+	modTime, size := w.stat()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !modTime.Equal(w.modTime) || size != w.size
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	path := w.path
+	if path == "" {
+		path = DefaultPath
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+// Diff lists every setting that differs between prev and next as "key: old -> new"
+func Diff(prev, next *Config) []string {
+	var changes []string
+	diffValues(reflect.ValueOf(*prev), reflect.ValueOf(*next), "", &changes)
+	return changes
+}
+
+func diffValues(prev, next reflect.Value, prefix string, changes *[]string) {
+	if prev.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(prev.Interface(), next.Interface()) {
+			*changes = append(*changes, fmt.Sprintf("%s: %v -> %v", prefix, prev.Interface(), next.Interface()))
+		}
+		return
+	}
+
+	for i := 0; i < prev.NumField(); i++ {
+		field := prev.Type().Field(i)
+		name := strings.SplitN(field.Tag.Get("yaml"), ",", 2)[0]
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		diffValues(prev.Field(i), next.Field(i), name, changes)
+	}
+}
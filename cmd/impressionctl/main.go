@@ -0,0 +1,18 @@
+// Command impressionctl operates the service through its HTTP API:
+//
+//	impressionctl campaigns list
+//	impressionctl -output json stats 4b5e8a1c-1b7e-4d52-9a47-2c0f3a6d9e10
+//
+// The endpoint and API key come from flags, IMPRESSIONCTL_* environment variables or a config file,
+// in that order of precedence.
+package main
+
+import (
+	"os"
+
+	"learning/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -1,47 +1,267 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"learning/cmd/config"
+	"learning/internal/anomaly"
+	"learning/internal/backfill"
+	"learning/internal/eventlog"
 	"learning/internal/handlers"
+	"learning/internal/idempotency"
+	"learning/internal/ingest"
 	"learning/internal/logger"
-	"learning/internal/repositories/memory"
+	"learning/internal/middleware"
+	"learning/internal/openapi"
+	"learning/internal/ratelimit"
+	"learning/internal/repositories"
+	"learning/internal/stream"
+	"learning/internal/traffic"
+	"learning/internal/webhooks"
 	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 )
 
 var SetupServer = setupServer
 
-func setupServer() http.Handler {
+// setupServer builds the router on top of the storage backend selected in the live config.
+// The returned function drains the ingestion queue, closes the event log and the storage, and must be
+// called on shutdown.
+func setupServer() (http.Handler, func() error, error) {
 	mux := http.NewServeMux()
 
-	// Initialize shared in-memory server
-	memServer := memory.NewServer()
+	store, err := openStorage(config.Current().Storage)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	// Released in reverse order on shutdown, so the storage outlives everything writing to it
+	closers := []func() error{store.close}
+	closeAll := func() error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			errs = append(errs, closers[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	impressions := store.impressions
+	var events handlers.ImpressionReader
+	if eventsCfg := config.Current().Events; eventsCfg.Enabled {
+		eventLog, err := eventlog.Open(eventlog.Options{
+			Dir:             eventsCfg.Dir,
+			SegmentMaxBytes: int64(eventsCfg.SegmentMaxSizeMB) << 20,
+			SegmentMaxAge:   eventsCfg.SegmentMaxAge,
+			Retention:       eventsCfg.Retention,
+			ClockSkew:       config.Current().Ingestion.MaxClockSkew,
+		})
+		if err != nil {
+			_ = closeAll()
+			return nil, nil, fmt.Errorf("open event log %s: %w", eventsCfg.Dir, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go purgeEvery(ctx, eventLog, config.Current().Storage.PurgeInterval, "expired event log segments")
+		closers = append(closers, func() error {
+			cancel()
+			return eventLog.Close()
+		})
+
+		impressions = eventlog.NewRecorder(impressions, eventLog, logger.InitLogger())
+		events = eventLog
+	}
+
+	var alerts handlers.AlertSource
+	if anomalyCfg := config.Current().Anomaly; anomalyCfg.Enabled {
+		webhooks := anomaly.NewWebhooks(anomalyCfg.Webhooks, anomalyCfg.WebhookTimeout, logger.InitLogger())
+		detector := anomaly.NewDetector(anomaly.Options{
+			Baseline:          anomalyCfg.Baseline,
+			SpikeFactor:       anomalyCfg.SpikeFactor,
+			DropAfter:         anomalyCfg.DropAfter,
+			DuplicateWindow:   anomalyCfg.DuplicateWindow,
+			MaxDuplicateRatio: anomalyCfg.MaxDuplicateRatio,
+			MinVolume:         anomalyCfg.MinVolume,
+		}, store.campaigns, store.stats, webhooks.Notify)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go detector.Run(ctx, anomalyCfg.Interval)
+		closers = append(closers, func() error {
+			cancel()
+			ctx, cancelDelivery := context.WithTimeout(context.Background(), anomalyCfg.WebhookTimeout)
+			defer cancelDelivery()
+			return webhooks.Close(ctx)
+		})
+
+		impressions = anomaly.NewMonitor(impressions, detector)
+		alerts = detector
+	}
+
+	// Live stats streams follow the impression outcomes and campaign updates through repository events;
+	// webhooks read the stored totals instead, so they see the impressions of every instance
+	emitter := repositories.NewEmitter()
+
+	var webhookRegistry handlers.WebhookRegistry
+	if webhooksCfg := config.Current().Webhooks; webhooksCfg.Enabled {
+		dispatcher := webhooks.NewDispatcher(webhooks.Options{
+			CheckInterval:       webhooksCfg.CheckInterval,
+			Workers:             webhooksCfg.Workers,
+			Timeout:             webhooksCfg.Timeout,
+			MaxAttempts:         webhooksCfg.MaxAttempts,
+			InitialBackoff:      webhooksCfg.InitialBackoff,
+			MaxBackoff:          webhooksCfg.MaxBackoff,
+			DeadLetterLimit:     webhooksCfg.DeadLetterLimit,
+			AllowPrivateTargets: webhooksCfg.AllowPrivateTargets,
+		}, store.campaigns, store.stats, store.webhooks, logger.InitLogger())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go dispatcher.Run(ctx)
+		closers = append(closers, func() error {
+			cancel()
+			ctx, cancelDelivery := context.WithTimeout(context.Background(), webhooksCfg.Timeout)
+			defer cancelDelivery()
+			return dispatcher.Close(ctx)
+		})
 
-	// Pass shared memory to repositories
-	campaignRepo := memory.NewInMemoryCampaignRepository(memServer)
-	impressionRepo := memory.NewInMemoryImpressionRepository(memServer)
-	statsRepo := memory.NewInMemoryStatsRepository(memServer)
+		webhookRegistry = dispatcher
+	}
+
+	streamCfg := config.Current().Stream
+	hub := stream.NewHub(store.stats, streamCfg.Interval, streamCfg.MaxSubscribers, logger.InitLogger())
+	if err := hub.Register(registry); err != nil {
+		_ = closeAll()
+		return nil, nil, fmt.Errorf("register stream metrics: %w", err)
+	}
+	emitter.Subscribe(hub.HandleEvent)
+	streamCtx, stopStreams := context.WithCancel(context.Background())
+	go hub.Run(streamCtx)
+	closers = append(closers, func() error {
+		stopStreams()
+		hub.Close()
+		return nil
+	})
+
+	impressions = repositories.NewEmittingImpressionRepository(impressions, emitter)
 
-	campaignHandler := handlers.NewCampaignHandler(campaignRepo)
-	impressionHandler := handlers.NewImpressionHandler(impressionRepo)
-	statsHandler := handlers.NewStatsHandler(statsRepo)
+	campaignHandler := handlers.NewCampaignHandler(repositories.NewEmittingCampaignRepository(store.campaigns, emitter))
+	impressionHandler := handlers.NewImpressionHandler(impressions)
+	if ingestion := config.Current().Ingestion; ingestion.Mode == "async" {
+		queue := ingest.NewQueue(impressions, ingest.Options{
+			QueueSize:     ingestion.QueueSize,
+			Workers:       ingestion.Workers,
+			BatchSize:     ingestion.BatchSize,
+			FlushInterval: ingestion.FlushInterval,
+			ApplyTimeout:  config.Current().Server.RequestTimeout,
+		}, logger.InitLogger())
+		if err := queue.Register(registry); err != nil {
+			_ = closeAll()
+			return nil, nil, fmt.Errorf("register queue metrics: %w", err)
+		}
+		known := ingest.NewKnownCampaigns(store.campaigns, ingestion.CampaignCacheTTL)
+		queue.OnCampaignCompleted(known.Completed)
+		queue.Start()
+
+		impressionHandler = handlers.NewAsyncImpressionHandler(impressions, queue, known)
+		closers = append(closers, func() error {
+			// Queued impressions still need the storage, so drain before closing it
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			return queue.Close(ctx)
+		})
+	}
+	if trafficCfg := config.Current().Traffic; trafficCfg.Enabled {
+		denylist, err := traffic.IPDenylist(trafficCfg.IPDenylist)
+		if err != nil {
+			_ = closeAll()
+			return nil, nil, err
+		}
+		bursts := traffic.NewBurstDetector(trafficCfg.BurstLimit, trafficCfg.BurstWindow)
+		ctx, cancel := context.WithCancel(context.Background())
+		go purgeEvery(ctx, bursts, config.Current().Storage.PurgeInterval, "expired burst windows")
+		closers = append(closers, func() error {
+			cancel()
+			return nil
+		})
+
+		impressionHandler.Filter = traffic.Chain{traffic.BotUserAgents(trafficCfg.BotUserAgents), denylist, bursts}
+	}
+	impressionLogHandler := handlers.NewImpressionLogHandler(events)
+	statsHandler := handlers.NewStatsHandler(store.stats)
+	alertsHandler := handlers.NewAlertsHandler(alerts)
+	webhookHandler := handlers.NewWebhookHandler(webhookRegistry)
+	statsStreamHandler := handlers.NewStatsStreamHandler(store.stats, hub)
+	pacingHandler := handlers.NewPacingHandler(store.campaigns, store.stats)
+	healthHandler := handlers.NewHealthHandler(store.campaigns)
+	importHandler := handlers.NewImportHandler(backfill.NewImporter(store.campaigns, repositories.NewEmittingBackfillRepository(store.backfill, emitter)))
 
-	mux.HandleFunc("/api/v1/campaigns", campaignHandler.CreateCampaignHandler)
-	mux.HandleFunc("/api/v1/impressions", impressionHandler.TrackImpressionHandler)
-	mux.HandleFunc("/api/v1/campaigns/stats/", func(w http.ResponseWriter, r *http.Request) {
-		statsHandler.GetCampaignStatsHandler(w, r)
+	limiter := ratelimit.NewLimiter()
+	if err := limiter.Register(registry); err != nil {
+		_ = closeAll()
+		return nil, nil, fmt.Errorf("register rate limit metrics: %w", err)
+	}
+
+	// Both in-memory stores are swept like the storage backends
+	idempotencyStore := idempotency.NewMemoryStore()
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	go purgeEvery(purgeCtx, idempotencyStore, config.Current().Storage.PurgeInterval, "expired idempotency keys")
+	go purgeEvery(purgeCtx, limiter, config.Current().Storage.PurgeInterval, "refilled rate limit buckets")
+	closers = append(closers, func() error {
+		stopPurge()
+		return nil
 	})
-	mux.HandleFunc("/", handlers.NotFoundHandler)
 
-	return mux
+	// Every route gets a request ID, an access log line and its rate limit
+	serve := func(route string, handler http.Handler) {
+		mux.Handle(route, middleware.RequestLogger(logger.InitLogger(), route, middleware.RateLimit(limiter, route, handler)))
+	}
+	// Unless it streams, a handler also gets idempotent POST retries and its configured deadline
+	bounded := func(route string, handler http.HandlerFunc) http.HandlerFunc {
+		return middleware.Idempotency(idempotencyStore, route, middleware.Timeout(route, handler)).ServeHTTP
+	}
+	handle := func(route string, handler http.HandlerFunc) {
+		serve(route, bounded(route, handler))
+	}
+
+	handle("/api/v1/campaigns", campaignHandler.CampaignsHandler)
+	handle("/api/v1/impressions", impressionHandler.TrackImpressionHandler)
+	handle("/api/v1/campaigns/stats/", statsHandler.GetCampaignStatsHandler)
+	const campaignRoute = "/api/v1/campaigns/"
+	serve(campaignRoute, handlers.CampaignSubresources(map[string]http.HandlerFunc{
+		"":             bounded(campaignRoute, campaignHandler.CampaignHandler),
+		"impressions":  bounded(campaignRoute, impressionLogHandler.ListImpressionsHandler),
+		"pacing":       bounded(campaignRoute, pacingHandler.GetPacingHandler),
+		"series":       bounded(campaignRoute, statsHandler.GetCampaignSeriesHandler),
+		"webhooks":     bounded(campaignRoute, webhookHandler.WebhooksHandler),
+		"webhooks/":    bounded(campaignRoute, webhookHandler.WebhooksHandler),
+		"stats/stream": statsStreamHandler.StreamStatsHandler,
+	}))
+	handle("/api/v1/alerts", alertsHandler.ListAlertsHandler)
+	handle("/api/v1/imports", importHandler.ImportImpressionsHandler)
+	handle("/api/v1/openapi.json", openapi.Handler)
+	handle("/health", healthHandler.GetHealthHandler)
+	handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP)
+	handle("/admin/log/level", middleware.AdminOnly(http.HandlerFunc(handlers.LogLevelHandler)).ServeHTTP)
+	handle("/", handlers.NotFoundHandler)
+
+	return mux, closeAll, nil
 }
 
-func Run(listenAndServe func() error) error {
+func Run(addr string, listenAndServe func() error) error {
 	logger.InitLogger()
 	defer logger.Sync()
 
-	logger.Log.Info("Server started on port 8080...")
+	logger.Log.Info("Server started", zap.String("addr", addr))
 
 	err := listenAndServe()
-	if err != nil {
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"learning/cmd/config"
+	"learning/internal/backfill"
+	"learning/internal/logger"
+	"learning/internal/repositories"
+	"learning/internal/repositories/bolt"
+	"learning/internal/repositories/memory"
+	"learning/internal/repositories/postgres"
+	"learning/internal/repositories/redis"
+)
+
+// storage holds the repositories of the configured backend and how to release it
+type storage struct {
+	campaigns   repositories.CampaignRepository
+	impressions repositories.ImpressionRepository
+	stats       repositories.StatsRepository
+	backfill    repositories.BackfillRepository
+	webhooks    repositories.WebhookRepository
+	close       func() error
+}
+
+// OpenImporter opens the configured storage for an import run outside the server. The returned
+// function closes the storage.
+func OpenImporter(cfg config.StorageConfig) (*backfill.Importer, func() error, error) {
+	store, err := openStorage(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return backfill.NewImporter(store.campaigns, store.backfill), store.close, nil
+}
+
+func openStorage(cfg config.StorageConfig) (*storage, error) {
+	switch cfg.Driver {
+	case "bolt":
+		db, err := bolt.Open(cfg.Bolt.Path)
+		if err != nil {
+			return nil, fmt.Errorf("open bolt database %s: %w", cfg.Bolt.Path, err)
+		}
+
+		impressionRepo := bolt.NewBoltImpressionRepository(db)
+		ctx, cancel := context.WithCancel(context.Background())
+		go purgeExpired(ctx, impressionRepo, cfg.PurgeInterval)
+
+		return &storage{
+			campaigns:   bolt.NewBoltCampaignRepository(db),
+			impressions: impressionRepo,
+			stats:       bolt.NewBoltStatsRepository(db),
+			backfill:    impressionRepo,
+			webhooks:    bolt.NewBoltWebhookRepository(db),
+			close: func() error {
+				cancel()
+				return db.Close()
+			},
+		}, nil
+	case "postgres":
+		openCtx, cancelOpen := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancelOpen()
+
+		db, err := postgres.Open(openCtx, cfg.Postgres.DSN, cfg.Postgres.Schema, cfg.Postgres.MaxOpenConns)
+		if err != nil {
+			return nil, fmt.Errorf("open postgres: %w", err)
+		}
+
+		impressionRepo := postgres.NewPostgresImpressionRepository(db)
+		ctx, cancel := context.WithCancel(context.Background())
+		go purgeExpired(ctx, impressionRepo, cfg.PurgeInterval)
+
+		return &storage{
+			campaigns:   postgres.NewPostgresCampaignRepository(db),
+			impressions: impressionRepo,
+			stats:       postgres.NewPostgresStatsRepository(db),
+			backfill:    impressionRepo,
+			webhooks:    postgres.NewPostgresWebhookRepository(db),
+			close: func() error {
+				cancel()
+				return db.Close()
+			},
+		}, nil
+	case "redis":
+		openCtx, cancelOpen := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelOpen()
+
+		client, err := redis.Open(openCtx, cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+		if err != nil {
+			return nil, fmt.Errorf("open redis: %w", err)
+		}
+
+		// Dedup keys expire on their own, so there is nothing to purge
+		keys := redis.Keys{Prefix: cfg.Redis.Prefix}
+		impressionRepo := redis.NewRedisImpressionRepository(client, keys, cfg.Redis.BucketRetention)
+		return &storage{
+			campaigns:   redis.NewRedisCampaignRepository(client, keys),
+			impressions: impressionRepo,
+			stats:       redis.NewRedisStatsRepository(client, keys),
+			backfill:    impressionRepo,
+			webhooks:    redis.NewRedisWebhookRepository(client, keys),
+			close:       client.Close,
+		}, nil
+	default:
+		// Initialize shared in-memory server
+		memServer := memory.NewServer()
+		impressionRepo := memory.NewInMemoryImpressionRepository(memServer)
+
+		// Pass shared memory to repositories
+		return &storage{
+			campaigns:   memory.NewInMemoryCampaignRepository(memServer),
+			impressions: impressionRepo,
+			stats:       memory.NewInMemoryStatsRepository(memServer),
+			backfill:    impressionRepo,
+			webhooks:    memory.NewInMemoryWebhookRepository(memServer),
+			close:       func() error { return nil },
+		}, nil
+	}
+}
+
+// expiringStore is implemented by stores whose entries outlive their retention until purged
+type expiringStore interface {
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+// purgeExpired periodically drops dedup entries that can no longer match
+func purgeExpired(ctx context.Context, repo expiringStore, interval time.Duration) {
+	purgeEvery(ctx, repo, interval, "expired dedup entries")
+}
+
+// purgeEvery calls PurgeExpired on every tick until ctx is done; what names the entries in logs
+func purgeEvery(ctx context.Context, repo expiringStore, interval time.Duration, what string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := repo.PurgeExpired(ctx)
+			if err != nil {
+				logger.InitLogger().Error("failed to purge "+what, zap.Error(err))
+				continue
+			}
+			logger.InitLogger().Debug("purged "+what, zap.Int("count", purged))
+		}
+	}
+}
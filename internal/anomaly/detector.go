@@ -0,0 +1,320 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+// Options holds the thresholds of the detection rules
+type Options struct {
+	// Baseline is how much history a spike or drop is compared against
+	Baseline time.Duration
+	// SpikeFactor is how many times the baseline rate a minute must reach to be a spike
+	SpikeFactor float64
+	// DropAfter is how long a campaign with baseline traffic must stay at zero to be a drop
+	DropAfter time.Duration
+	// DuplicateWindow is the span over which the duplicate ratio is measured
+	DuplicateWindow time.Duration
+	// MaxDuplicateRatio is the share of duplicates among all impressions above which an alert is raised
+	MaxDuplicateRatio float64
+	// MinVolume is the rate, in impressions per minute, below which traffic is too thin to judge
+	MinVolume float64
+}
+
+// minute counts the outcomes of one campaign in one minute, as seen by this instance
+type minute struct {
+	counted    int64
+	duplicates int64
+}
+
+// Detector raises alerts when the traffic of a campaign looks abnormal. Spikes and drops are judged on
+// the minute buckets of the stats repository, so every instance sees the traffic of all of them,
+// imports included, and the history survives restarts. No backend stores duplicates, so the duplicate
+// ratio is judged on the outcomes this instance saw, which a share of the traffic represents as well as
+// the whole. Only those outcomes and the current alerts are kept in memory.
+type Detector struct {
+	opts      Options
+	campaigns repositories.CampaignRepository
+	stats     repositories.StatsRepository
+	notify    func(entities.AlertEvent)
+
+	mu       sync.Mutex
+	outcomes map[string]map[int64]*minute // by campaign ID and minute
+	alerts   map[string]entities.Alert    // keyed by campaign ID and kind
+}
+
+// NewDetector judges the campaigns of campaigns in their flight on the minute buckets of stats and
+// reports raised and resolved alerts to notify, which must not block
+func NewDetector(opts Options, campaigns repositories.CampaignRepository, stats repositories.StatsRepository, notify func(entities.AlertEvent)) *Detector {
+	return &Detector{
+		opts:      opts,
+		campaigns: campaigns,
+		stats:     stats,
+		notify:    notify,
+		outcomes:  make(map[string]map[int64]*minute),
+		alerts:    make(map[string]entities.Alert),
+	}
+}
+
+// Record counts one impression of campaignID received at at, for the duplicate ratio
+func (d *Detector) Record(campaignID string, at time.Time, duplicate bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	minutes, ok := d.outcomes[campaignID]
+	if !ok {
+		minutes = make(map[int64]*minute)
+		d.outcomes[campaignID] = minutes
+	}
+	m := repositories.MinuteOf(at)
+	bucket, ok := minutes[m]
+	if !ok {
+		bucket = &minute{}
+		minutes[m] = bucket
+	}
+	if duplicate {
+		bucket.duplicates++
+	} else {
+		bucket.counted++
+	}
+}
+
+// Evaluate judges every campaign on the minutes completed before now, updates the current alerts and
+// notifies about the changes. When the campaigns or the buckets of one cannot be read, the spikes and
+// drops concerned are kept as they are until they can.
+func (d *Detector) Evaluate(ctx context.Context, now time.Time) {
+	last := repositories.MinuteOf(now) - 1
+
+	d.mu.Lock()
+	previous := d.alerts
+	d.mu.Unlock()
+
+	// The storage is read without the lock, so recording impressions never waits on it
+	current := make(map[string]entities.Alert)
+	err := d.campaigns.ListCampaigns(ctx, func(campaign entities.Campaign) error {
+		if !inFlight(campaign, now) {
+			return nil
+		}
+		alerts, err := d.judgeTraffic(ctx, campaign, last, now, previous)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			alerts = trafficAlerts(previous, campaign.ID)
+		}
+		for _, alert := range alerts {
+			current[alertKey(alert)] = alert
+		}
+		return nil
+	})
+	switch {
+	case ctx.Err() != nil:
+		return
+	case err != nil:
+		for key, alert := range previous {
+			if alert.Kind != entities.AlertDuplicateRatio {
+				current[key] = alert
+			}
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	window := minutes(d.opts.DuplicateWindow)
+	for id, outcomes := range d.outcomes {
+		for m := range outcomes {
+			if m <= last-window {
+				delete(outcomes, m)
+			}
+		}
+		if len(outcomes) == 0 {
+			delete(d.outcomes, id)
+			continue
+		}
+		if alert, ok := d.judgeDuplicates(id, outcomes, last, now); ok {
+			current[alertKey(alert)] = alert
+		}
+	}
+
+	for key, alert := range current {
+		if previous, ok := d.alerts[key]; ok {
+			// Still going on, keep when it started
+			alert.Since = previous.Since
+			current[key] = alert
+			continue
+		}
+		d.notify(entities.AlertEvent{Event: "alert.raised", At: now, Alert: alert})
+	}
+	for key, alert := range d.alerts {
+		if _, ok := current[key]; !ok {
+			d.notify(entities.AlertEvent{Event: "alert.resolved", At: now, Alert: alert})
+		}
+	}
+	d.alerts = current
+}
+
+// inFlight tells whether campaign is meant to deliver at now
+func inFlight(campaign entities.Campaign, now time.Time) bool {
+	return campaign.Status != entities.CampaignCompleted && !now.Before(campaign.StartTime) &&
+		(campaign.EndTime == nil || now.Before(*campaign.EndTime))
+}
+
+// trafficAlerts returns the spike and drop alerts of campaignID among alerts
+func trafficAlerts(alerts map[string]entities.Alert, campaignID string) []entities.Alert {
+	var kept []entities.Alert
+	for _, kind := range []string{entities.AlertSpike, entities.AlertDrop} {
+		if alert, ok := alerts[campaignID+"/"+kind]; ok {
+			kept = append(kept, alert)
+		}
+	}
+	return kept
+}
+
+// judgeTraffic applies the spike and drop rules to the stored minutes of a campaign in its flight, with
+// last being the newest complete minute. Both are only judged once the campaign was in its flight for a
+// full baseline, so a campaign starting up is neither.
+func (d *Detector) judgeTraffic(ctx context.Context, campaign entities.Campaign, last int64, now time.Time, previous map[string]entities.Alert) ([]entities.Alert, error) {
+	baseline := minutes(d.opts.Baseline)
+	dropAfter := minutes(d.opts.DropAfter)
+	first := min(last-baseline, last-dropAfter-baseline+1)
+	points, err := d.stats.GetCampaignSeries(ctx, entities.SeriesQuery{
+		CampaignID: campaign.ID,
+		Resolution: entities.ResolutionMinute,
+		From:       time.Unix(first*60, 0),
+		To:         time.Unix((last+1)*60, 0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int64, len(points))
+	for _, point := range points {
+		counts[repositories.MinuteOf(point.Start)] = point.Count
+	}
+	// The first minute wholly within the flight
+	started := repositories.MinuteOf(campaign.StartTime.Add(time.Minute - time.Nanosecond))
+
+	var alerts []entities.Alert
+	if spikeBase := rate(counts, last-baseline, last-1); started <= last-baseline && spikeBase > 0 {
+		latest := float64(counts[last])
+		threshold := d.opts.SpikeFactor * max(spikeBase, d.opts.MinVolume)
+		if latest >= threshold {
+			alerts = append(alerts, entities.Alert{
+				CampaignID: campaign.ID,
+				Kind:       entities.AlertSpike,
+				Message:    fmt.Sprintf("%.0f impressions in the last minute, %.1f per minute before", latest, spikeBase),
+				Value:      latest,
+				Threshold:  threshold,
+				Since:      now,
+			})
+		}
+	}
+
+	if silent := sum(counts, last-dropAfter+1, last) == 0; silent {
+		dropBase := rate(counts, last-dropAfter-baseline+1, last-dropAfter)
+		if open, ok := previous[campaign.ID+"/"+entities.AlertDrop]; ok {
+			// A drop stays open until traffic comes back, even once the baseline slid past the last of it
+			alerts = append(alerts, open)
+		} else if started <= last-dropAfter-baseline+1 && dropBase >= d.opts.MinVolume {
+			alerts = append(alerts, entities.Alert{
+				CampaignID: campaign.ID,
+				Kind:       entities.AlertDrop,
+				Message:    fmt.Sprintf("no impressions for %s after %.1f per minute", d.opts.DropAfter, dropBase),
+				Value:      0,
+				Threshold:  dropBase,
+				Since:      now,
+			})
+		}
+	}
+	return alerts, nil
+}
+
+// judgeDuplicates applies the duplicate ratio rule to the outcomes this instance saw of one campaign
+func (d *Detector) judgeDuplicates(id string, outcomes map[int64]*minute, last int64, now time.Time) (entities.Alert, bool) {
+	window := minutes(d.opts.DuplicateWindow)
+	var counted, duplicates int64
+	for m := last - window + 1; m <= last; m++ {
+		if bucket, ok := outcomes[m]; ok {
+			counted += bucket.counted
+			duplicates += bucket.duplicates
+		}
+	}
+	all := float64(counted + duplicates)
+	if all == 0 || all < d.opts.MinVolume*float64(window) {
+		return entities.Alert{}, false
+	}
+	ratio := float64(duplicates) / all
+	if ratio <= d.opts.MaxDuplicateRatio {
+		return entities.Alert{}, false
+	}
+	return entities.Alert{
+		CampaignID: id,
+		Kind:       entities.AlertDuplicateRatio,
+		Message:    fmt.Sprintf("%.0f%% of the impressions in the last %s were duplicates", ratio*100, d.opts.DuplicateWindow),
+		Value:      ratio,
+		Threshold:  d.opts.MaxDuplicateRatio,
+		Since:      now,
+	}, true
+}
+
+// Alerts returns the current alerts, newest first
+func (d *Detector) Alerts() []entities.Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	alerts := make([]entities.Alert, 0, len(d.alerts))
+	for _, alert := range d.alerts {
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].Since.Equal(alerts[j].Since) {
+			return alerts[i].Since.After(alerts[j].Since)
+		}
+		return alertKey(alerts[i]) < alertKey(alerts[j])
+	})
+	return alerts
+}
+
+// Run evaluates the campaigns every interval until ctx is done
+func (d *Detector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Evaluate(ctx, now)
+		}
+	}
+}
+
+func alertKey(alert entities.Alert) string {
+	return alert.CampaignID + "/" + alert.Kind
+}
+
+// minutes converts a window to whole minutes, at least one
+func minutes(d time.Duration) int64 {
+	return max(int64(d/time.Minute), 1)
+}
+
+// sum adds the counts of the minutes from first to last, both included
+func sum(counts map[int64]int64, first, last int64) int64 {
+	var total int64
+	for m := first; m <= last; m++ {
+		total += counts[m]
+	}
+	return total
+}
+
+// rate is the mean number of counted impressions per minute from first to last
+func rate(counts map[int64]int64, first, last int64) float64 {
+	return float64(sum(counts, first, last)) / float64(last-first+1)
+}
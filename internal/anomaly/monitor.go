@@ -0,0 +1,56 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+// Monitor wraps an impression repository and feeds the outcome of every impression to a detector, for the
+// duplicate ratio. Counted impressions and duplicates are recorded, rejected impressions are not.
+type Monitor struct {
+	repo     repositories.ImpressionRepository
+	detector *Detector
+}
+
+// NewMonitor records the impressions applied to repo in detector
+func NewMonitor(repo repositories.ImpressionRepository, detector *Detector) *Monitor {
+	return &Monitor{repo: repo, detector: detector}
+}
+
+func (m *Monitor) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	err, status := m.repo.TrackImpression(ctx, req)
+	m.record(req, err, time.Now())
+	return err, status
+}
+
+// TrackImpressions forwards the batch to the wrapped repository, one request at a time if it cannot batch
+func (m *Monitor) TrackImpressions(ctx context.Context, reqs []entities.TrackImpressionRequest) []error {
+	var errs []error
+	if batcher, ok := m.repo.(repositories.BatchImpressionRepository); ok {
+		errs = batcher.TrackImpressions(ctx, reqs)
+	} else {
+		errs = make([]error, len(reqs))
+		for i, req := range reqs {
+			errs[i], _ = m.repo.TrackImpression(ctx, req)
+		}
+	}
+
+	now := time.Now()
+	for i, err := range errs {
+		m.record(reqs[i], err, now)
+	}
+	return errs
+}
+
+func (m *Monitor) record(req entities.TrackImpressionRequest, err error, now time.Time) {
+	switch {
+	case err == nil:
+		m.detector.Record(req.CampaignID, now, false)
+	case errors.Is(err, repositories.ErrDuplicateImpression):
+		m.detector.Record(req.CampaignID, now, true)
+	}
+}
@@ -0,0 +1,343 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"learning/internal/anomaly"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"learning/internal/repositories/memory"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var options = anomaly.Options{
+	Baseline:          10 * time.Minute,
+	SpikeFactor:       3,
+	DropAfter:         3 * time.Minute,
+	DuplicateWindow:   2 * time.Minute,
+	MaxDuplicateRatio: 0.5,
+	MinVolume:         5,
+}
+
+// start is the first minute of traffic in every test, recent enough for the stored minute buckets
+var start = time.Now().UTC().Truncate(time.Minute).Add(-3 * time.Hour)
+
+var ctx = context.Background()
+
+// at is a moment in minute m, after which minute m-1 is the newest complete one
+func at(m int) time.Time {
+	return start.Add(time.Duration(m)*time.Minute + 30*time.Second)
+}
+
+// fixture is a detector over its own storage, which holds the traffic of every instance
+type fixture struct {
+	detector  *anomaly.Detector
+	campaigns repositories.CampaignRepository
+	stats     repositories.StatsRepository
+	backfill  repositories.BackfillRepository
+	events    []entities.AlertEvent
+}
+
+func newFixture() *fixture {
+	memServer := memory.NewServer()
+	f := &fixture{
+		campaigns: memory.NewInMemoryCampaignRepository(memServer),
+		stats:     memory.NewInMemoryStatsRepository(memServer),
+		backfill:  memory.NewInMemoryImpressionRepository(memServer),
+	}
+	f.detector = f.newDetector(f.stats)
+	return f
+}
+
+// newDetector is another detector over the storage of f, like one of another instance
+func (f *fixture) newDetector(stats repositories.StatsRepository) *anomaly.Detector {
+	return anomaly.NewDetector(options, f.campaigns, stats, func(event entities.AlertEvent) { f.events = append(f.events, event) })
+}
+
+// flight creates a campaign starting at startTime and ending at end, if set
+func (f *fixture) flight(t *testing.T, startTime time.Time, end *time.Time) string {
+	t.Helper()
+	campaign, err := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: t.Name(), StartTime: startTime, EndTime: end})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+	return campaign.ID
+}
+
+// store adds perMinute impressions of campaign to each of the stored minutes from first to last
+func (f *fixture) store(t *testing.T, campaign string, first, last, perMinute int) {
+	t.Helper()
+	b := repositories.Backfill{CampaignID: campaign, Minutes: make(map[int64]int64), Hours: make(map[int64]int64)}
+	for m := first; m <= last; m++ {
+		when := start.Add(time.Duration(m)*time.Minute + time.Second)
+		b.Minutes[repositories.MinuteOf(when)] += int64(perMinute)
+		b.Hours[repositories.HourOf(when)] += int64(perMinute)
+		b.Total += int64(perMinute)
+	}
+	if err := f.backfill.ApplyBackfill(ctx, b); err != nil {
+		t.Fatalf("❌ ApplyBackfill failed: %v", err)
+	}
+}
+
+// recordMinutes records perMinute outcomes of campaign in each of the minutes from first to last
+func recordMinutes(d *anomaly.Detector, campaign string, first, last, perMinute int, duplicate bool) {
+	for m := first; m <= last; m++ {
+		for i := 0; i < perMinute; i++ {
+			d.Record(campaign, start.Add(time.Duration(m)*time.Minute+time.Second), duplicate)
+		}
+	}
+}
+
+func TestDetectorRaisesAndResolvesSpike(t *testing.T) {
+	f := newFixture()
+	id := f.flight(t, start.Add(-time.Hour), nil)
+
+	f.store(t, id, 0, 9, 10)
+	f.store(t, id, 10, 10, 40)
+	f.detector.Evaluate(ctx, at(11))
+
+	alerts := f.detector.Alerts()
+	if len(alerts) != 1 || alerts[0].Kind != entities.AlertSpike || alerts[0].Value != 40 || alerts[0].Threshold != 30 {
+		t.Fatalf("❌ Expected one spike of 40 over 30, got %+v", alerts)
+	}
+	if len(f.events) != 1 || f.events[0].Event != "alert.raised" {
+		t.Fatalf("❌ Expected an alert.raised event, got %+v", f.events)
+	}
+
+	f.store(t, id, 11, 11, 10)
+	f.detector.Evaluate(ctx, at(12))
+	if alerts := f.detector.Alerts(); len(alerts) != 0 {
+		t.Errorf("❌ Expected the spike to be resolved, got %+v", alerts)
+	}
+	if len(f.events) != 2 || f.events[1].Event != "alert.resolved" || f.events[1].Alert.Kind != entities.AlertSpike {
+		t.Errorf("❌ Expected an alert.resolved event, got %+v", f.events)
+	}
+}
+
+func TestDetectorIgnoresCampaignWithoutFullBaseline(t *testing.T) {
+	f := newFixture()
+	id := f.flight(t, start, nil)
+
+	f.store(t, id, 0, 2, 1)
+	f.store(t, id, 3, 3, 100)
+	f.detector.Evaluate(ctx, at(4))
+
+	if alerts := f.detector.Alerts(); len(alerts) != 0 || len(f.events) != 0 {
+		t.Errorf("❌ Expected no alert for a campaign starting up, got %+v", alerts)
+	}
+}
+
+func TestDetectorJudgesTheTrafficOfEveryInstance(t *testing.T) {
+	f := newFixture()
+	id := f.flight(t, start.Add(-time.Hour), nil)
+
+	// Counted by other instances or imported, and judged by a detector that just started
+	f.store(t, id, 0, 9, 10)
+	f.store(t, id, 10, 10, 40)
+	for _, d := range []*anomaly.Detector{f.detector, f.newDetector(f.stats)} {
+		d.Evaluate(ctx, at(11))
+		if alerts := d.Alerts(); len(alerts) != 1 || alerts[0].Kind != entities.AlertSpike {
+			t.Errorf("❌ Expected every detector to see the spike, got %+v", alerts)
+		}
+	}
+
+	// Traffic one instance does not receive is no drop as long as the storage has it
+	f.store(t, id, 11, 14, 10)
+	f.detector.Evaluate(ctx, at(15))
+	if alerts := f.detector.Alerts(); len(alerts) != 0 {
+		t.Errorf("❌ Expected no alert while the campaign delivers, got %+v", alerts)
+	}
+}
+
+func TestDetectorRaisesDropWhileOtherCampaignsStayQuiet(t *testing.T) {
+	f := newFixture()
+
+	active := f.flight(t, start.Add(-time.Hour), nil)
+	f.store(t, active, 0, 9, 10)
+	// Too thin to judge, so going quiet is not a drop
+	f.store(t, f.flight(t, start.Add(-time.Hour), nil), 0, 9, 1)
+	f.detector.Evaluate(ctx, at(13))
+
+	alerts := f.detector.Alerts()
+	if len(alerts) != 1 || alerts[0].CampaignID != active || alerts[0].Kind != entities.AlertDrop {
+		t.Fatalf("❌ Expected one drop of the active campaign, got %+v", alerts)
+	}
+	since := alerts[0].Since
+
+	f.detector.Evaluate(ctx, at(14))
+	if alerts := f.detector.Alerts(); len(alerts) != 1 || !alerts[0].Since.Equal(since) {
+		t.Errorf("❌ Expected the ongoing drop to keep its start %v, got %+v", since, alerts)
+	}
+}
+
+func TestDetectorKeepsDropOpenUntilTrafficReturns(t *testing.T) {
+	f := newFixture()
+
+	id := f.flight(t, start.Add(-time.Hour), nil)
+	f.store(t, id, 0, 9, 10)
+	f.detector.Evaluate(ctx, at(13))
+
+	// Long after the baseline slid past the last traffic, the campaign is still delivering nothing
+	f.detector.Evaluate(ctx, at(60))
+	if alerts := f.detector.Alerts(); len(alerts) != 1 || alerts[0].Kind != entities.AlertDrop || alerts[0].Threshold != 10 {
+		t.Fatalf("❌ Expected the drop to stay open, got %+v", alerts)
+	}
+	if len(f.events) != 1 {
+		t.Fatalf("❌ Expected only the alert.raised event, got %+v", f.events)
+	}
+
+	f.store(t, id, 60, 60, 1)
+	f.detector.Evaluate(ctx, at(61))
+	if alerts := f.detector.Alerts(); len(alerts) != 0 {
+		t.Errorf("❌ Expected the drop to resolve once traffic came back, got %+v", alerts)
+	}
+	if len(f.events) != 2 || f.events[1].Event != "alert.resolved" {
+		t.Errorf("❌ Expected an alert.resolved event, got %+v", f.events)
+	}
+}
+
+func TestDetectorIgnoresSilenceOutsideFlight(t *testing.T) {
+	f := newFixture()
+
+	// Ending with its traffic is no drop
+	ended := start.Add(10 * time.Minute)
+	f.store(t, f.flight(t, start.Add(-time.Hour), &ended), 0, 9, 10)
+	// A drop resolves once the flight is over
+	ending := start.Add(20 * time.Minute)
+	dropped := f.flight(t, start.Add(-time.Hour), &ending)
+	f.store(t, dropped, 0, 9, 10)
+
+	f.detector.Evaluate(ctx, at(13))
+	if alerts := f.detector.Alerts(); len(alerts) != 1 || alerts[0].CampaignID != dropped {
+		t.Fatalf("❌ Expected only the campaign still in flight to drop, got %+v", alerts)
+	}
+	f.detector.Evaluate(ctx, at(20))
+	if alerts := f.detector.Alerts(); len(alerts) != 0 {
+		t.Errorf("❌ Expected the drop to resolve at the end of the flight, got %+v", alerts)
+	}
+}
+
+// failingStats fails every series read while fail is set
+type failingStats struct {
+	repositories.StatsRepository
+	fail bool
+}
+
+func (s *failingStats) GetCampaignSeries(ctx context.Context, q entities.SeriesQuery) ([]entities.SeriesPoint, error) {
+	if s.fail {
+		return nil, errors.New("storage unavailable")
+	}
+	return s.StatsRepository.GetCampaignSeries(ctx, q)
+}
+
+func TestDetectorKeepsAlertsWhileStorageFails(t *testing.T) {
+	f := newFixture()
+	stats := &failingStats{StatsRepository: f.stats}
+	d := f.newDetector(stats)
+
+	id := f.flight(t, start.Add(-time.Hour), nil)
+	f.store(t, id, 0, 9, 10)
+	d.Evaluate(ctx, at(13))
+
+	// Traffic came back, but the detector cannot tell yet
+	f.store(t, id, 13, 13, 10)
+	stats.fail = true
+	d.Evaluate(ctx, at(14))
+	if alerts := d.Alerts(); len(alerts) != 1 || alerts[0].Kind != entities.AlertDrop || len(f.events) != 1 {
+		t.Fatalf("❌ Expected the drop to be kept while the storage fails, got %+v", alerts)
+	}
+
+	stats.fail = false
+	d.Evaluate(ctx, at(14))
+	if alerts := d.Alerts(); len(alerts) != 0 {
+		t.Errorf("❌ Expected the drop to resolve once the storage answers, got %+v", alerts)
+	}
+}
+
+func TestDetectorRaisesDuplicateRatio(t *testing.T) {
+	f := newFixture()
+
+	recordMinutes(f.detector, "c1", 0, 1, 3, false)
+	recordMinutes(f.detector, "c1", 0, 1, 7, true)
+	f.detector.Evaluate(ctx, at(2))
+
+	alerts := f.detector.Alerts()
+	if len(alerts) != 1 || alerts[0].Kind != entities.AlertDuplicateRatio || alerts[0].Value != 0.7 {
+		t.Fatalf("❌ Expected a duplicate ratio alert of 0.7, got %+v", alerts)
+	}
+
+	// Below min_volume per minute the ratio is not judged
+	d := f.newDetector(f.stats)
+	recordMinutes(d, "c1", 0, 1, 4, true)
+	d.Evaluate(ctx, at(2))
+	if alerts := d.Alerts(); len(alerts) != 0 {
+		t.Errorf("❌ Expected no alert on thin traffic, got %+v", alerts)
+	}
+}
+
+func TestMonitorRecordsCountedAndDuplicateImpressions(t *testing.T) {
+	memServer := memory.NewServer()
+	watched := memory.NewInMemoryCampaignRepository(memServer)
+	campaign, _ := watched.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Watched", StartTime: time.Now().Add(-time.Hour)})
+
+	d := anomaly.NewDetector(anomaly.Options{
+		Baseline:          time.Minute,
+		SpikeFactor:       2,
+		DropAfter:         10 * time.Minute,
+		DuplicateWindow:   2 * time.Minute,
+		MaxDuplicateRatio: 0.4,
+		MinVolume:         1,
+	}, watched, memory.NewInMemoryStatsRepository(memServer), func(entities.AlertEvent) {})
+	monitor := anomaly.NewMonitor(memory.NewInMemoryImpressionRepository(memServer), d)
+
+	req := entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "u1", AdID: "a1"}
+	monitor.TrackImpression(ctx, req)
+	monitor.TrackImpressions(ctx, []entities.TrackImpressionRequest{req, req})
+	monitor.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: "unknown", UserID: "u1", AdID: "a1"})
+
+	// One counted and two duplicates, in the window even when recording straddled a minute boundary
+	d.Evaluate(ctx, time.Now().Add(time.Minute))
+	alerts := d.Alerts()
+	if len(alerts) != 1 || alerts[0].Kind != entities.AlertDuplicateRatio || alerts[0].CampaignID != campaign.ID {
+		t.Fatalf("❌ Expected a duplicate ratio alert of the watched campaign, got %+v", alerts)
+	}
+}
+
+func TestWebhooksDeliverEvents(t *testing.T) {
+	received := make(chan entities.AlertEvent, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event entities.AlertEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("❌ Webhook body is not an alert event: %v", err)
+		}
+		received <- event
+	}))
+	defer receiver.Close()
+
+	webhooks := anomaly.NewWebhooks([]string{receiver.URL}, time.Second, zap.NewNop())
+	webhooks.Notify(entities.AlertEvent{Event: "alert.raised", At: start, Alert: entities.Alert{CampaignID: "c1", Kind: entities.AlertDrop}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := webhooks.Close(ctx); err != nil {
+		t.Fatalf("❌ Failed to drain the webhooks: %v", err)
+	}
+
+	select {
+	case event := <-received:
+		if event.Event != "alert.raised" || event.Alert.CampaignID != "c1" || event.Alert.Kind != entities.AlertDrop {
+			t.Errorf("❌ Unexpected event delivered: %+v", event)
+		}
+	default:
+		t.Fatal("❌ Expected the event to be delivered before Close returned")
+	}
+
+	// Dropped silently once closed
+	webhooks.Notify(entities.AlertEvent{Event: "alert.resolved"})
+}
@@ -0,0 +1,112 @@
+package anomaly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"learning/internal/entities"
+)
+
+// webhookBacklog is how many undelivered events are buffered before new ones are dropped
+const webhookBacklog = 256
+
+// Webhooks posts alert events as JSON to every configured URL from a background goroutine, so the
+// detector never waits on a slow receiver
+type Webhooks struct {
+	urls   []string
+	client *http.Client
+	logger *zap.Logger
+	events chan entities.AlertEvent
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewWebhooks(urls []string, timeout time.Duration, logger *zap.Logger) *Webhooks {
+	w := &Webhooks{
+		urls:   urls,
+		client: &http.Client{Timeout: timeout},
+		logger: logger,
+		events: make(chan entities.AlertEvent, webhookBacklog),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Notify queues event for delivery; it is dropped with a warning when the backlog is full or after Close
+func (w *Webhooks) Notify(event entities.AlertEvent) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.events <- event:
+	default:
+		w.logger.Warn("alert webhook backlog full, event dropped",
+			zap.String("event", event.Event),
+			zap.String("campaign_id", event.Alert.CampaignID),
+			zap.String("kind", event.Alert.Kind),
+		)
+	}
+}
+
+func (w *Webhooks) run() {
+	defer close(w.done)
+	for event := range w.events {
+		body, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		for _, url := range w.urls {
+			if err := w.post(url, body); err != nil {
+				w.logger.Warn("alert webhook delivery failed", zap.String("url", url), zap.String("event", event.Event), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (w *Webhooks) post(url string, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits until the queued ones were delivered or ctx is done
+func (w *Webhooks) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.events)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,345 @@
+// Package backfill imports historical impressions, such as those of a previous counter, from CSV or
+// NDJSON files. Impressions are deduplicated against each other by when they happened instead of
+// against the live dedup state, and their counts are added to the time buckets they fall in. Every
+// campaign records the SHA-256 of the files applied to it, so importing a file again, like to retry a
+// failed import, skips the campaigns it was already applied to.
+package backfill
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/export"
+	"learning/internal/repositories"
+	"learning/internal/validators"
+)
+
+// MaxLineErrors is how many rejected lines a report lists
+const MaxLineErrors = 100
+
+// progressEvery is how many lines are read between two progress reports
+const progressEvery = 10000
+
+// maxLineBytes bounds one NDJSON line
+const maxLineBytes = 64 << 10
+
+// ErrInvalidFile is returned for files that cannot be read as a whole, like a CSV file without the
+// required columns; single bad lines are only rejected
+var ErrInvalidFile = errors.New("invalid import file")
+
+// ErrTooManyLines is returned once a file holds more than Options.MaxLines impressions
+var ErrTooManyLines = errors.New("import file holds too many impressions")
+
+// Options controls one import
+type Options struct {
+	// Format is export.FormatCSV or export.FormatNDJSON
+	Format string
+	// TTL is the dedup window: an impression within TTL of the last counted one of the same user
+	// and campaign is a duplicate
+	TTL time.Duration
+	// MaxLines bounds the impressions of a file, 0 means no bound
+	MaxLines int
+	// Progress, when set, is called while the file is read and after each campaign is applied
+	Progress func(entities.ImportProgress)
+}
+
+// Importer reads import files and applies their counts to the campaigns
+type Importer struct {
+	campaigns repositories.CampaignRepository
+	store     repositories.BackfillRepository
+}
+
+// NewImporter checks campaigns exist in campaigns and applies their counts to store
+func NewImporter(campaigns repositories.CampaignRepository, store repositories.BackfillRepository) *Importer {
+	return &Importer{campaigns: campaigns, store: store}
+}
+
+// record is one accepted impression, kept small as a whole file is held in memory to deduplicate it
+type record struct {
+	campaign int // index into run.ids
+	user     string
+	at       int64 // unix nanoseconds
+}
+
+// run is the state of one import
+type run struct {
+	opts     Options
+	now      time.Time
+	report   entities.ImportReport
+	ids      []string
+	known    map[string]int // campaign ID to its index in ids, -1 for unknown campaigns
+	records  []record
+	progress entities.ImportProgress
+}
+
+// Import reads r and applies the deduplicated counts one campaign at a time, in campaign ID order.
+// Rejected lines are listed in the report. When applying a campaign fails, the campaigns applied before
+// stay applied and the report returned with the error covers them; importing the same file again
+// applies the rest.
+func (i *Importer) Import(ctx context.Context, r io.Reader, opts Options) (entities.ImportReport, error) {
+	run := &run{
+		opts:   opts,
+		now:    time.Now(),
+		report: entities.ImportReport{Campaigns: []entities.ImportedCampaign{}, Errors: []entities.ImportLineError{}},
+		known:  make(map[string]int),
+	}
+
+	hash := sha256.New()
+	r = io.TeeReader(r, hash)
+
+	var err error
+	switch opts.Format {
+	case export.FormatCSV:
+		err = i.readCSV(ctx, run, r)
+	case export.FormatNDJSON:
+		err = i.readNDJSON(ctx, run, r)
+	default:
+		err = fmt.Errorf("%w: unsupported format %q", ErrInvalidFile, opts.Format)
+	}
+	if err != nil {
+		return run.report, err
+	}
+	run.report.ImportID = hex.EncodeToString(hash.Sum(nil))
+	run.reportProgress()
+
+	return run.report, i.apply(ctx, run)
+}
+
+// readCSV reads a CSV file whose header names at least the columns of export.Impressions, in any order
+func (i *Importer) readCSV(ctx context.Context, run *run, r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: header: %v", ErrInvalidFile, err)
+	}
+	columns := make(map[string]int, len(header))
+	for n, name := range header {
+		columns[name] = n
+	}
+	positions := make([]int, len(export.Impressions.Columns))
+	for n, name := range export.Impressions.Columns {
+		position, ok := columns[name]
+		if !ok {
+			return fmt.Errorf("%w: header lacks the %s column", ErrInvalidFile, name)
+		}
+		positions[n] = position
+	}
+
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := run.line(ctx); err != nil {
+				return err
+			}
+			run.reject(parseErr.StartLine, parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := cr.FieldPos(0)
+		if err := run.line(ctx); err != nil {
+			return err
+		}
+		impression := entities.Impression{
+			CampaignID: fields[positions[0]],
+			UserID:     export.UnescapeCell(fields[positions[2]]),
+			AdID:       export.UnescapeCell(fields[positions[3]]),
+		}
+		if raw := fields[positions[1]]; raw != "" {
+			if impression.Timestamp, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+				run.reject(line, errors.New("timestamp must be an RFC 3339 time"))
+				continue
+			}
+		}
+		if err := i.accept(ctx, run, line, impression); err != nil {
+			return err
+		}
+	}
+}
+
+// readNDJSON reads one JSON impression per line, skipping blank lines
+func (i *Importer) readNDJSON(ctx context.Context, run *run, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		if err := run.line(ctx); err != nil {
+			return err
+		}
+
+		var impression entities.Impression
+		if err := json.Unmarshal(raw, &impression); err != nil {
+			run.reject(line, fmt.Errorf("invalid JSON: %v", err))
+			continue
+		}
+		if err := i.accept(ctx, run, line, impression); err != nil {
+			return err
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("%w: line %d is longer than %d bytes", ErrInvalidFile, line+1, maxLineBytes)
+	}
+	return scanner.Err()
+}
+
+// accept validates one parsed impression and keeps it; only a failing campaign lookup is returned
+func (i *Importer) accept(ctx context.Context, run *run, line int, impression entities.Impression) error {
+	if err := validators.ValidateHistoricalImpression(impression, run.now); err != nil {
+		run.reject(line, err)
+		return nil
+	}
+
+	campaign, seen := run.known[impression.CampaignID]
+	if !seen {
+		_, err := i.campaigns.GetCampaign(ctx, impression.CampaignID)
+		switch {
+		case errors.Is(err, repositories.ErrCampaignNotFound):
+			campaign = -1
+		case err != nil:
+			return fmt.Errorf("look up campaign %s: %w", impression.CampaignID, err)
+		default:
+			campaign = len(run.ids)
+			run.ids = append(run.ids, impression.CampaignID)
+		}
+		run.known[impression.CampaignID] = campaign
+	}
+	if campaign < 0 {
+		run.reject(line, repositories.ErrCampaignNotFound)
+		return nil
+	}
+
+	run.records = append(run.records, record{campaign: campaign, user: impression.UserID, at: impression.Timestamp.UnixNano()})
+	return nil
+}
+
+// apply deduplicates the records of every campaign and applies their counts
+func (i *Importer) apply(ctx context.Context, run *run) error {
+	// Order the campaigns by ID, then each user's impressions by when they happened
+	rank := make([]int, len(run.ids))
+	order := make([]int, len(run.ids))
+	for n := range order {
+		order[n] = n
+	}
+	sort.Slice(order, func(a, b int) bool { return run.ids[order[a]] < run.ids[order[b]] })
+	for position, campaign := range order {
+		rank[campaign] = position
+	}
+	sort.Slice(run.records, func(a, b int) bool {
+		ra, rb := run.records[a], run.records[b]
+		if ra.campaign != rb.campaign {
+			return rank[ra.campaign] < rank[rb.campaign]
+		}
+		if ra.user != rb.user {
+			return ra.user < rb.user
+		}
+		return ra.at < rb.at
+	})
+
+	records := run.records
+	for len(records) > 0 {
+		campaign := records[0].campaign
+		end := sort.Search(len(records), func(n int) bool { return records[n].campaign != campaign })
+
+		b, imported := deduplicate(run.ids[campaign], records[:end], run.opts.TTL)
+		b.ImportID = run.report.ImportID
+		err := i.store.ApplyBackfill(ctx, b)
+		switch {
+		case errors.Is(err, repositories.ErrBackfillApplied):
+			imported = entities.ImportedCampaign{CampaignID: b.CampaignID, AlreadyApplied: true}
+		case err != nil:
+			return fmt.Errorf("apply campaign %s: %w", b.CampaignID, err)
+		}
+		run.report.Imported += imported.Imported
+		run.report.Duplicates += imported.Duplicates
+		run.report.Campaigns = append(run.report.Campaigns, imported)
+		run.progress.CampaignsApplied++
+		run.reportProgress()
+
+		records = records[end:]
+	}
+	return nil
+}
+
+// deduplicate counts the impressions of one campaign, sorted by user and time, into buckets
+func deduplicate(campaignID string, records []record, ttl time.Duration) (repositories.Backfill, entities.ImportedCampaign) {
+	b := repositories.Backfill{CampaignID: campaignID, Minutes: make(map[int64]int64), Hours: make(map[int64]int64)}
+	imported := entities.ImportedCampaign{CampaignID: campaignID}
+
+	var last record
+	for n, rec := range records {
+		if n > 0 && rec.user == last.user && time.Duration(rec.at-last.at) < ttl {
+			imported.Duplicates++
+			continue
+		}
+		last = rec
+
+		at := time.Unix(0, rec.at)
+		b.Total++
+		b.Minutes[repositories.MinuteOf(at)]++
+		b.Hours[repositories.HourOf(at)]++
+	}
+	imported.Imported = b.Total
+	return b, imported
+}
+
+// line counts one more impression line, reporting progress and checking for cancellation every
+// progressEvery lines
+func (run *run) line(ctx context.Context) error {
+	run.report.Lines++
+	if run.opts.MaxLines > 0 && run.report.Lines > int64(run.opts.MaxLines) {
+		return fmt.Errorf("%w: more than %d", ErrTooManyLines, run.opts.MaxLines)
+	}
+	if run.report.Lines%progressEvery == 0 {
+		run.reportProgress()
+		return ctx.Err()
+	}
+	return nil
+}
+
+// reject records why a line was not imported
+func (run *run) reject(line int, err error) {
+	run.report.Rejected++
+	if len(run.report.Errors) == MaxLineErrors {
+		run.report.ErrorsTruncated = true
+		return
+	}
+	run.report.Errors = append(run.report.Errors, entities.ImportLineError{Line: line, Error: err.Error()})
+}
+
+func (run *run) reportProgress() {
+	if run.opts.Progress == nil {
+		return
+	}
+	run.progress.Lines = run.report.Lines
+	run.progress.Rejected = run.report.Rejected
+	run.progress.Campaigns = len(run.ids)
+	run.opts.Progress(run.progress)
+}
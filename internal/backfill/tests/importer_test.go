@@ -0,0 +1,288 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learning/internal/backfill"
+	"learning/internal/entities"
+	"learning/internal/export"
+	"learning/internal/repositories"
+	"learning/internal/repositories/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+const unknownCampaign = "00000000-0000-4000-8000-000000000000"
+
+type fixture struct {
+	importer  *backfill.Importer
+	campaigns repositories.CampaignRepository
+	stats     repositories.StatsRepository
+}
+
+func newFixture() fixture {
+	server := memory.NewServer()
+	campaigns := memory.NewInMemoryCampaignRepository(server)
+	return fixture{
+		importer:  backfill.NewImporter(campaigns, memory.NewInMemoryImpressionRepository(server)),
+		campaigns: campaigns,
+		stats:     memory.NewInMemoryStatsRepository(server),
+	}
+}
+
+func (f fixture) createCampaign(t *testing.T, goal int64) string {
+	t.Helper()
+	campaign, err := f.campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{
+		Name:           "Backfilled",
+		StartTime:      time.Now().Add(-30 * 24 * time.Hour),
+		ImpressionGoal: goal,
+	})
+	if err != nil {
+		t.Fatalf("❌ Failed to create campaign: %v", err)
+	}
+	return campaign.ID
+}
+
+func (f fixture) total(t *testing.T, campaignID string) int64 {
+	t.Helper()
+	stats, err := f.stats.GetCampaignStats(context.Background(), campaignID)
+	if err != nil {
+		t.Fatalf("❌ Failed to get stats: %v", err)
+	}
+	return stats.TotalCount
+}
+
+func TestImportCSVDeduplicatesByEventTime(t *testing.T) {
+	f := newFixture()
+	campaignID := f.createCampaign(t, 0)
+	at := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	stamp := func(d time.Duration) string { return at.Add(d).Format(time.RFC3339) }
+
+	// Columns may come in any order; u1 is seen again within the hour TTL and once after it
+	file := strings.Join([]string{
+		"user_id,ad_id,campaign_id,timestamp",
+		"u1,ad," + campaignID + "," + stamp(0),
+		"u1,ad," + campaignID + "," + stamp(2*time.Hour),
+		"u1,ad," + campaignID + "," + stamp(10*time.Minute),
+		"u2,ad," + campaignID + "," + stamp(10*time.Minute),
+		"u3,ad," + campaignID + ",yesterday",
+		"u4,ad," + unknownCampaign + "," + stamp(0),
+		",ad," + campaignID + "," + stamp(0),
+		"u5,ad," + campaignID,
+	}, "\n") + "\n"
+
+	var progress []entities.ImportProgress
+	report, err := f.importer.Import(context.Background(), strings.NewReader(file), backfill.Options{
+		Format:   export.FormatCSV,
+		TTL:      time.Hour,
+		Progress: func(p entities.ImportProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("❌ Import failed: %v", err)
+	}
+
+	if report.Lines != 8 || report.Imported != 3 || report.Duplicates != 1 || report.Rejected != 4 {
+		t.Errorf("❌ Expected 8 lines, 3 imported, 1 duplicate and 4 rejected, got %+v", report)
+	}
+	var lines []int
+	for _, lineErr := range report.Errors {
+		lines = append(lines, lineErr.Line)
+	}
+	if fmt.Sprint(lines) != "[6 7 8 9]" {
+		t.Errorf("❌ Expected lines 6 to 9 to be rejected, got %+v", report.Errors)
+	}
+	if len(report.Campaigns) != 1 || report.Campaigns[0] != (entities.ImportedCampaign{CampaignID: campaignID, Imported: 3, Duplicates: 1}) {
+		t.Errorf("❌ Expected the campaign to be reported, got %+v", report.Campaigns)
+	}
+	if last := progress[len(progress)-1]; last.CampaignsApplied != 1 || last.Campaigns != 1 || last.Lines != 8 {
+		t.Errorf("❌ Expected the last progress report to cover the whole import, got %+v", last)
+	}
+
+	if total := f.total(t, campaignID); total != 3 {
+		t.Errorf("❌ Expected a total of 3, got %d", total)
+	}
+	points, err := f.stats.GetCampaignSeries(context.Background(), entities.SeriesQuery{
+		CampaignID: campaignID, Resolution: entities.ResolutionHour, From: at, To: at.Add(3 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("❌ Failed to get series: %v", err)
+	}
+	var counts []int64
+	for _, point := range points {
+		counts = append(counts, point.Count)
+	}
+	if fmt.Sprint(counts) != "[2 0 1]" {
+		t.Errorf("❌ Expected hourly counts [2 0 1], got %v", counts)
+	}
+}
+
+func TestImportNDJSONAppliesCampaignsInOrder(t *testing.T) {
+	f := newFixture()
+	goal, other := f.createCampaign(t, 2), f.createCampaign(t, 0)
+	first, second := goal, other
+	if first > second {
+		first, second = second, first
+	}
+	at := time.Now().UTC().Add(-48 * time.Hour)
+	line := func(campaignID, userID string) string {
+		return fmt.Sprintf(`{"campaign_id":%q,"timestamp":%q,"user_id":%q,"ad_id":"ad"}`, campaignID, at.Format(time.RFC3339Nano), userID)
+	}
+
+	file := strings.Join([]string{line(other, "u1"), "", line(goal, "u1"), "{not json", line(goal, "u2"), line(goal, "u3")}, "\n")
+	report, err := f.importer.Import(context.Background(), strings.NewReader(file), backfill.Options{Format: export.FormatNDJSON, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("❌ Import failed: %v", err)
+	}
+
+	if report.Lines != 5 || report.Rejected != 1 || len(report.Errors) != 1 || report.Errors[0].Line != 4 {
+		t.Errorf("❌ Expected 5 lines with line 4 rejected, got %+v", report)
+	}
+	if len(report.Campaigns) != 2 || report.Campaigns[0].CampaignID != first || report.Campaigns[1].CampaignID != second {
+		t.Errorf("❌ Expected both campaigns in ID order, got %+v", report.Campaigns)
+	}
+
+	// The goal of 2 is exceeded by the backfill, which completes the campaign
+	campaign, err := f.campaigns.GetCampaign(context.Background(), goal)
+	if err != nil {
+		t.Fatalf("❌ Failed to get campaign: %v", err)
+	}
+	if total := f.total(t, goal); total != 3 || campaign.Status != entities.CampaignCompleted {
+		t.Errorf("❌ Expected 3 impressions completing the campaign, got %d %s", total, campaign.Status)
+	}
+}
+
+// failingStore fails the backfill of one campaign until fail is cleared
+type failingStore struct {
+	repositories.BackfillRepository
+	campaignID string
+	fail       bool
+}
+
+func (s *failingStore) ApplyBackfill(ctx context.Context, b repositories.Backfill) error {
+	if s.fail && b.CampaignID == s.campaignID {
+		return errors.New("storage unavailable")
+	}
+	return s.BackfillRepository.ApplyBackfill(ctx, b)
+}
+
+func TestImportRetrySkipsAppliedCampaigns(t *testing.T) {
+	server := memory.NewServer()
+	campaigns := memory.NewInMemoryCampaignRepository(server)
+	f := fixture{campaigns: campaigns, stats: memory.NewInMemoryStatsRepository(server)}
+	first, second := f.createCampaign(t, 0), f.createCampaign(t, 0)
+	if first > second {
+		first, second = second, first
+	}
+	store := &failingStore{BackfillRepository: memory.NewInMemoryImpressionRepository(server), campaignID: second, fail: true}
+	importer := backfill.NewImporter(campaigns, store)
+
+	at := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	file := "campaign_id,timestamp,user_id,ad_id\n" + first + "," + at + ",u1,ad\n" + second + "," + at + ",u1,ad\n"
+	opts := backfill.Options{Format: export.FormatCSV, TTL: time.Hour}
+
+	// The second campaign fails, leaving the first applied
+	report, err := importer.Import(context.Background(), strings.NewReader(file), opts)
+	if err == nil || len(report.Campaigns) != 1 || report.ImportID == "" {
+		t.Fatalf("❌ Expected the import to fail after the first campaign, got %+v, %v", report, err)
+	}
+
+	// Retrying the same file applies the second campaign only
+	store.fail = false
+	retry, err := importer.Import(context.Background(), strings.NewReader(file), opts)
+	if err != nil {
+		t.Fatalf("❌ Retry failed: %v", err)
+	}
+	if retry.ImportID != report.ImportID || retry.Imported != 1 || len(retry.Campaigns) != 2 ||
+		!retry.Campaigns[0].AlreadyApplied || retry.Campaigns[1].AlreadyApplied {
+		t.Errorf("❌ Expected the retry to skip the first campaign, got %+v", retry)
+	}
+	if f.total(t, first) != 1 || f.total(t, second) != 1 {
+		t.Errorf("❌ Expected both campaigns counted once, got %d and %d", f.total(t, first), f.total(t, second))
+	}
+}
+
+func TestImportEmitsOneEventPerAppliedCampaign(t *testing.T) {
+	server := memory.NewServer()
+	campaigns := memory.NewInMemoryCampaignRepository(server)
+	f := fixture{campaigns: campaigns, stats: memory.NewInMemoryStatsRepository(server)}
+	first, second := f.createCampaign(t, 0), f.createCampaign(t, 0)
+
+	emitter := repositories.NewEmitter()
+	var events []repositories.Event
+	emitter.Subscribe(func(event repositories.Event) { events = append(events, event) })
+	importer := backfill.NewImporter(campaigns, repositories.NewEmittingBackfillRepository(memory.NewInMemoryImpressionRepository(server), emitter))
+
+	at := time.Now().Add(-2 * time.Hour)
+	file := "campaign_id,timestamp,user_id,ad_id\n"
+	for i := 0; i < 3; i++ {
+		file += fmt.Sprintf("%s,%s,u%d,ad\n%s,%s,u%d,ad\n", first, at.Format(time.RFC3339), i, second, at.Format(time.RFC3339), i)
+	}
+	opts := backfill.Options{Format: export.FormatCSV, TTL: time.Hour}
+	if _, err := importer.Import(context.Background(), strings.NewReader(file), opts); err != nil {
+		t.Fatalf("❌ Import failed: %v", err)
+	}
+
+	ids := map[string]bool{}
+	for _, event := range events {
+		if event.Type != repositories.EventImpressionsImported {
+			t.Errorf("❌ Expected only %s events, got %s", repositories.EventImpressionsImported, event.Type)
+		}
+		ids[event.CampaignID] = true
+	}
+	if len(events) != 2 || !ids[first] || !ids[second] {
+		t.Errorf("❌ Expected one event per campaign, got %+v", events)
+	}
+
+	// Applying the file again changes no total, so nothing is emitted
+	events = nil
+	if _, err := importer.Import(context.Background(), strings.NewReader(file), opts); err != nil {
+		t.Fatalf("❌ Import failed: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("❌ Expected no events for an import already applied, got %+v", events)
+	}
+}
+
+func TestImportRejectsInvalidFiles(t *testing.T) {
+	f := newFixture()
+	campaignID := f.createCampaign(t, 0)
+	row := campaignID + "," + time.Now().Add(-time.Hour).Format(time.RFC3339) + ",u1,ad\n"
+
+	tests := []struct {
+		name string
+		file string
+		opts backfill.Options
+		err  error
+	}{
+		{"Missing Column", "campaign_id,timestamp,user_id\n", backfill.Options{Format: export.FormatCSV}, backfill.ErrInvalidFile},
+		{"Unknown Format", row, backfill.Options{Format: "xml"}, backfill.ErrInvalidFile},
+		{"Too Many Lines", "campaign_id,timestamp,user_id,ad_id\n" + row + row + row, backfill.Options{Format: export.FormatCSV, MaxLines: 2}, backfill.ErrTooManyLines},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := f.importer.Import(context.Background(), strings.NewReader(test.file), test.opts)
+			if !errors.Is(err, test.err) {
+				t.Errorf("❌ Expected %v, got %v", test.err, err)
+			}
+		})
+	}
+	if total := f.total(t, campaignID); total != 0 {
+		t.Errorf("❌ Expected failed imports to apply nothing, got %d", total)
+	}
+}
+
+func TestImportTruncatesLineErrors(t *testing.T) {
+	f := newFixture()
+	file := "campaign_id,timestamp,user_id,ad_id\n" + strings.Repeat("not-a-uuid,,u1,ad\n", backfill.MaxLineErrors+50)
+
+	report, err := f.importer.Import(context.Background(), strings.NewReader(file), backfill.Options{Format: export.FormatCSV})
+	if err != nil {
+		t.Fatalf("❌ Import failed: %v", err)
+	}
+	if report.Rejected != backfill.MaxLineErrors+50 || len(report.Errors) != backfill.MaxLineErrors || !report.ErrorsTruncated {
+		t.Errorf("❌ Expected %d listed errors out of %d, got %d of %d", backfill.MaxLineErrors, backfill.MaxLineErrors+50, len(report.Errors), report.Rejected)
+	}
+}
@@ -0,0 +1,157 @@
+// Package cli implements the impressionctl commands on top of the API client
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+	"learning/internal/client"
+)
+
+// settings are read from the config file and the environment, then overridden by flags
+type settings struct {
+	Endpoint string        `yaml:"endpoint" env:"IMPRESSIONCTL_ENDPOINT" env-default:"http://localhost:8080" env-description:"Base URL of the service"`
+	APIKey   string        `yaml:"api_key" env:"IMPRESSIONCTL_API_KEY" env-description:"Sent as X-API-Key"`
+	Output   string        `yaml:"output" env:"IMPRESSIONCTL_OUTPUT" env-default:"table" env-description:"Output format (table, json)"`
+	Timeout  time.Duration `yaml:"timeout" env:"IMPRESSIONCTL_TIMEOUT" env-default:"30s" env-description:"Deadline of one command"`
+}
+
+// errUsage is returned for bad command lines, after the usage was printed
+var errUsage = errors.New("usage")
+
+// command is one subcommand; run gets the arguments after its name
+type command struct {
+	usage string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+var commands = map[string]command{
+	"campaigns": {"campaigns list | get ID | create -name NAME -start TIME [...] | update ID [-name NAME] [-end TIME]", runCampaigns},
+	"track":     {"track -campaign ID -user USER -ad AD [-timestamp TIME]", runTrack},
+	"stats":     {"stats ID", runStats},
+	"series":    {"series ID [-resolution minute|hour] [-from TIME] [-to TIME]", runSeries},
+	"export":    {"export campaigns | stats ID | series ID | impressions ID [-format csv|ndjson] [-out FILE]", runExport},
+	"import":    {"import [-format csv|ndjson] FILE", runImport},
+	"health":    {"health", runHealth},
+}
+
+// commandOrder lists the commands in the usage
+var commandOrder = []string{"campaigns", "track", "stats", "series", "export", "import", "health"}
+
+// app is what every command needs
+type app struct {
+	client *client.Client
+	output string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Run executes the command line args, without the program name, and returns the exit code: 0 on
+// success, 1 when the command failed and 2 for a bad command line
+func Run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("impressionctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", "", "config file (default $IMPRESSIONCTL_CONFIG, then impressionctl/config.yml in the user config directory)")
+	endpoint := flags.String("endpoint", "", "base URL of the service (default http://localhost:8080)")
+	apiKey := flags.String("api-key", "", "API key sent as X-API-Key")
+	output := flags.String("output", "", "output format: table or json (default table)")
+	timeout := flags.Duration("timeout", 0, "deadline of the command (default 30s)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: impressionctl [flags] COMMAND [args]\n\nCommands:")
+		for _, name := range commandOrder {
+			fmt.Fprintln(stderr, "  "+commands[name].usage)
+		}
+		fmt.Fprintln(stderr, "\nTimes are RFC 3339, like 2025-01-01T00:00:00Z.\n\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadSettings(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "impressionctl:", err)
+		return 1
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "endpoint":
+			cfg.Endpoint = *endpoint
+		case "api-key":
+			cfg.APIKey = *apiKey
+		case "output":
+			cfg.Output = *output
+		case "timeout":
+			cfg.Timeout = *timeout
+		}
+	})
+	if cfg.Output != "table" && cfg.Output != "json" {
+		fmt.Fprintf(stderr, "impressionctl: output must be table or json, got %q\n", cfg.Output)
+		return 2
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	a := &app{client: client.New(cfg.Endpoint, cfg.APIKey), output: cfg.Output, stdout: stdout, stderr: stderr}
+	switch err := cmd.run(ctx, a, flags.Args()[1:]); {
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(stderr, "usage: impressionctl "+cmd.usage)
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, "impressionctl:", err)
+		return 1
+	}
+	return 0
+}
+
+// loadSettings reads path, or the default config file when it exists, and applies the environment
+func loadSettings(path string) (settings, error) {
+	var cfg settings
+	if path == "" {
+		path = os.Getenv("IMPRESSIONCTL_CONFIG")
+	}
+	if path == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			if candidate := filepath.Join(dir, "impressionctl", "config.yml"); fileExists(candidate) {
+				path = candidate
+			}
+		}
+	}
+
+	if path == "" {
+		if err := cleanenv.ReadEnv(&cfg); err != nil {
+			return settings{}, fmt.Errorf("read settings from environment: %w", err)
+		}
+		return cfg, nil
+	}
+	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
+		return settings{}, fmt.Errorf("read config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,352 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"learning/internal/client"
+	"learning/internal/entities"
+	"learning/internal/export"
+)
+
+// timeFlag is an optional RFC 3339 time
+type timeFlag struct{ t *time.Time }
+
+func (f timeFlag) String() string {
+	if f.t == nil || f.t.IsZero() {
+		return ""
+	}
+	return f.t.Format(time.RFC3339)
+}
+
+func (f timeFlag) Set(s string) error {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return errors.New("must be an RFC 3339 time")
+	}
+	*f.t = t
+	return nil
+}
+
+// parseFlags parses args into flags, allowing the positional arguments before the flags
+func parseFlags(a *app, flags *flag.FlagSet, args []string) ([]string, error) {
+	flags.SetOutput(a.stderr)
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// oneID returns the single positional argument of a command taking a campaign ID
+func oneID(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errUsage
+	}
+	return args[0], nil
+}
+
+func runCampaigns(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("campaigns "+sub, flag.ContinueOnError)
+	switch sub {
+	case "list":
+		if _, err := parseFlags(a, flags, args); err != nil {
+			return err
+		}
+		campaigns, err := a.client.ListCampaigns(ctx)
+		if err != nil {
+			return err
+		}
+		return a.print(campaigns, func(t *table) { rows(t, export.Campaigns, campaigns...) })
+	case "get":
+		rest, err := parseFlags(a, flags, args)
+		if err != nil {
+			return err
+		}
+		id, err := oneID(rest)
+		if err != nil {
+			return err
+		}
+		campaign, err := a.client.GetCampaign(ctx, id)
+		if err != nil {
+			return err
+		}
+		return a.print(campaign, func(t *table) { rows(t, export.Campaigns, campaign) })
+	case "create":
+		var req entities.CreateCampaignRequest
+		var end time.Time
+		flags.StringVar(&req.Name, "name", "", "campaign name (required)")
+		flags.Var(timeFlag{&req.StartTime}, "start", "start of the flight (required)")
+		flags.Var(timeFlag{&end}, "end", "end of the flight")
+		flags.Int64Var(&req.ImpressionGoal, "goal", 0, "impressions after which the campaign completes")
+		flags.Float64Var(&req.Budget, "budget", 0, "spend after which the campaign completes, needs -cpm")
+		flags.Float64Var(&req.CPM, "cpm", 0, "price per thousand impressions")
+		rest, err := parseFlags(a, flags, args)
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 || req.Name == "" || req.StartTime.IsZero() {
+			flags.Usage()
+			return errUsage
+		}
+		if !end.IsZero() {
+			req.EndTime = &end
+		}
+		campaign, err := a.client.CreateCampaign(ctx, req)
+		if err != nil {
+			return err
+		}
+		return a.print(campaign, func(t *table) { rows(t, export.Campaigns, campaign) })
+	case "update":
+		var req entities.UpdateCampaignRequest
+		var name string
+		var end time.Time
+		flags.StringVar(&name, "name", "", "new name")
+		flags.Var(timeFlag{&end}, "end", "new end of the flight")
+		rest, err := parseFlags(a, flags, args)
+		if err != nil {
+			return err
+		}
+		id, err := oneID(rest)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			req.Name = &name
+		}
+		if !end.IsZero() {
+			req.EndTime = &end
+		}
+		campaign, err := a.client.UpdateCampaign(ctx, id, req)
+		if err != nil {
+			return err
+		}
+		return a.print(campaign, func(t *table) { rows(t, export.Campaigns, campaign) })
+	default:
+		return errUsage
+	}
+}
+
+func runTrack(ctx context.Context, a *app, args []string) error {
+	var req entities.TrackImpressionRequest
+	var at time.Time
+	flags := flag.NewFlagSet("track", flag.ContinueOnError)
+	flags.StringVar(&req.CampaignID, "campaign", "", "campaign ID (required)")
+	flags.StringVar(&req.UserID, "user", "", "user ID (required)")
+	flags.StringVar(&req.AdID, "ad", "", "ad ID (required)")
+	flags.Var(timeFlag{&at}, "timestamp", "when the impression happened (default now)")
+	rest, err := parseFlags(a, flags, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 || req.CampaignID == "" || req.UserID == "" || req.AdID == "" {
+		flags.Usage()
+		return errUsage
+	}
+	if !at.IsZero() {
+		req.Timestamp = &at
+	}
+
+	result, err := a.client.TrackImpression(ctx, req)
+	if err != nil {
+		return err
+	}
+	return a.print(result, func(t *table) {
+		t.row("STATUS", "COUNTED", "MESSAGE")
+		t.row(strconv.Itoa(result.Status), strconv.FormatBool(result.Counted), result.Message)
+	})
+}
+
+func runStats(ctx context.Context, a *app, args []string) error {
+	id, err := oneID(args)
+	if err != nil {
+		return err
+	}
+	stats, err := a.client.GetStats(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.print(stats, func(t *table) { rows(t, export.Stats, stats) })
+}
+
+// seriesFlags registers the flags selecting a time series
+func seriesFlags(flags *flag.FlagSet, q *client.SeriesQuery) {
+	flags.StringVar(&q.Resolution, "resolution", "", "minute or hour (default hour)")
+	flags.Var(timeFlag{&q.From}, "from", "first bucket (default a day of hours or an hour of minutes before -to)")
+	flags.Var(timeFlag{&q.To}, "to", "end of the series, exclusive (default now)")
+}
+
+func runSeries(ctx context.Context, a *app, args []string) error {
+	var q client.SeriesQuery
+	flags := flag.NewFlagSet("series", flag.ContinueOnError)
+	seriesFlags(flags, &q)
+	rest, err := parseFlags(a, flags, args)
+	if err != nil {
+		return err
+	}
+	id, err := oneID(rest)
+	if err != nil {
+		return err
+	}
+
+	series, err := a.client.GetSeries(ctx, id, q)
+	if err != nil {
+		return err
+	}
+	return a.print(series, func(t *table) { rows(t, export.SeriesPoints, series.Points...) })
+}
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	what, args := args[0], args[1:]
+
+	var q client.SeriesQuery
+	format, out := export.FormatCSV, ""
+	flags := flag.NewFlagSet("export "+what, flag.ContinueOnError)
+	flags.StringVar(&format, "format", format, "csv or ndjson")
+	flags.StringVar(&out, "out", "", "file to write (default standard output)")
+	if what == "series" {
+		seriesFlags(flags, &q)
+	}
+	rest, err := parseFlags(a, flags, args)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	switch what {
+	case "campaigns":
+		if len(rest) != 0 {
+			return errUsage
+		}
+		path = client.CampaignsExport
+	case "stats", "series", "impressions":
+		id, err := oneID(rest)
+		if err != nil {
+			return err
+		}
+		path = map[string]string{
+			"stats":       client.StatsExport(id),
+			"series":      client.SeriesExport(id, q),
+			"impressions": client.ImpressionsExport(id),
+		}[what]
+	default:
+		return errUsage
+	}
+
+	if out == "" {
+		return a.client.Export(ctx, path, format, a.stdout)
+	}
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := a.client.Export(ctx, path, format, file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	format := ""
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.StringVar(&format, "format", "", "csv or ndjson (default from the file extension)")
+	rest, err := parseFlags(a, flags, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return errUsage
+	}
+	path := rest[0]
+
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = export.FormatCSV
+		case ".ndjson", ".jsonl":
+			format = export.FormatNDJSON
+		default:
+			return fmt.Errorf("cannot tell the format of %s, pass -format", path)
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	report, err := a.client.Import(ctx, format, file)
+	if err != nil {
+		return err
+	}
+	return a.print(report, func(t *table) {
+		t.row("LINES", "IMPORTED", "DUPLICATES", "REJECTED")
+		t.row(strconv.FormatInt(report.Lines, 10), strconv.FormatInt(report.Imported, 10),
+			strconv.FormatInt(report.Duplicates, 10), strconv.FormatInt(report.Rejected, 10))
+		if len(report.Campaigns) > 0 {
+			t.row()
+			t.row("CAMPAIGN", "IMPORTED", "DUPLICATES")
+			for _, c := range report.Campaigns {
+				t.row(c.CampaignID, strconv.FormatInt(c.Imported, 10), strconv.FormatInt(c.Duplicates, 10))
+			}
+		}
+		if len(report.Errors) > 0 {
+			t.row()
+			t.row("LINE", "ERROR")
+			for _, lineErr := range report.Errors {
+				t.row(strconv.Itoa(lineErr.Line), lineErr.Error)
+			}
+			if report.ErrorsTruncated {
+				t.row("...", fmt.Sprintf("%d more rejected lines", report.Rejected-int64(len(report.Errors))))
+			}
+		}
+	})
+}
+
+func runHealth(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	health, err := a.client.Health(ctx)
+	if err != nil {
+		return err
+	}
+	return a.print(health, func(t *table) {
+		t.row("STATUS", "STORAGE", "INGESTION")
+		t.row(health.Status, health.Storage, health.Ingestion)
+	})
+}
+
+// rows lays out values with the columns of their CSV export
+func rows[T any](t *table, layout export.Table[T], values ...T) {
+	header := make([]string, len(layout.Columns))
+	for i, column := range layout.Columns {
+		header[i] = strings.ToUpper(strings.ReplaceAll(column, "_", " "))
+	}
+	t.row(header...)
+	for _, value := range values {
+		t.row(layout.Record(value)...)
+	}
+}
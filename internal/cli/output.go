@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"text/tabwriter"
+)
+
+// table aligns rows into columns
+type table struct {
+	w *tabwriter.Writer
+}
+
+func (t *table) row(cells ...string) {
+	for i, cell := range cells {
+		if cell == "" {
+			cells[i] = "-"
+		}
+	}
+	_, _ = t.w.Write([]byte(strings.Join(cells, "\t") + "\n"))
+}
+
+// print writes value as indented JSON, or as the table layout lays it out
+func (a *app) print(value any, layout func(t *table)) error {
+	if a.output == "json" {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	t := &table{w: tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)}
+	layout(t)
+	return t.w.Flush()
+}
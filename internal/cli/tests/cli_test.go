@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"learning/cmd/server"
+	"learning/internal/cli"
+	"learning/internal/entities"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ctl runs impressionctl against a fresh in-memory server, configured through a settings file
+type ctl struct {
+	t        *testing.T
+	endpoint string
+	config   string
+}
+
+func newCtl(t *testing.T) *ctl {
+	t.Helper()
+	handler, closeServer, err := server.SetupServer()
+	if err != nil {
+		t.Fatalf("❌ Failed to set up server: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		srv.Close()
+		_ = closeServer()
+	})
+
+	config := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(config, []byte("endpoint: "+srv.URL+"\napi_key: test-key\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to write settings: %v", err)
+	}
+	return &ctl{t: t, endpoint: srv.URL, config: config}
+}
+
+// run returns the exit code and the standard output and error of the command line args
+func (c *ctl) run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(append([]string{"-config", c.config}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// mustRun runs args and fails the test unless they succeed
+func (c *ctl) mustRun(args ...string) string {
+	c.t.Helper()
+	code, stdout, stderr := c.run(args...)
+	if code != 0 {
+		c.t.Fatalf("❌ %v exited with %d: %s", args, code, stderr)
+	}
+	return stdout
+}
+
+func TestCampaignCommands(t *testing.T) {
+	c := newCtl(t)
+
+	var created entities.Campaign
+	out := c.mustRun("-output", "json", "campaigns", "create", "-name", "CLI Campaign", "-start", "2025-01-01T00:00:00Z", "-goal", "100")
+	if err := json.Unmarshal([]byte(out), &created); err != nil || created.ID == "" || created.ImpressionGoal != 100 {
+		t.Fatalf("❌ Expected the created campaign as JSON, got %q, %v", out, err)
+	}
+
+	// Positional arguments may come before the flags
+	out = c.mustRun("campaigns", "update", created.ID, "-end", "2025-02-01T00:00:00Z")
+	if !strings.Contains(out, "2025-02-01T00:00:00Z") {
+		t.Errorf("❌ Expected the new end in the table, got %q", out)
+	}
+	out = c.mustRun("campaigns", "get", created.ID)
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], "ID ") || !strings.Contains(lines[1], "CLI Campaign") {
+		t.Errorf("❌ Expected a header and the campaign, got %q", out)
+	}
+	if out := c.mustRun("campaigns", "list"); !strings.Contains(out, created.ID) {
+		t.Errorf("❌ Expected the campaign in the listing, got %q", out)
+	}
+
+	// API errors exit with 1, bad command lines with 2
+	if code, _, stderr := c.run("campaigns", "get", "00000000-0000-0000-0000-000000000000"); code != 1 || !strings.Contains(stderr, "404") {
+		t.Errorf("❌ Expected a 404 with exit code 1, got %d: %q", code, stderr)
+	}
+	if code, _, stderr := c.run("campaigns", "update", created.ID, "-end", "2024-01-01T00:00:00Z"); code != 1 || !strings.Contains(stderr, "end_time") {
+		t.Errorf("❌ Expected the end before the start to be refused, got %d: %q", code, stderr)
+	}
+	if code, _, _ := c.run("campaigns", "create", "-start", "2025-01-01T00:00:00Z"); code != 2 {
+		t.Errorf("❌ Expected a missing name to be a usage error, got %d", code)
+	}
+	if code, _, _ := c.run("campaigns", "create", "-name", "Bad", "-start", "yesterday"); code != 2 {
+		t.Errorf("❌ Expected a bad time to be a usage error, got %d", code)
+	}
+}
+
+func TestTrackStatsAndExportCommands(t *testing.T) {
+	c := newCtl(t)
+
+	var campaign entities.Campaign
+	out := c.mustRun("-output", "json", "campaigns", "create", "-name", "Tracked", "-start", time.Now().Add(-time.Hour).Format(time.RFC3339))
+	if err := json.Unmarshal([]byte(out), &campaign); err != nil {
+		t.Fatalf("❌ Expected the created campaign as JSON, got %q, %v", out, err)
+	}
+
+	// The duplicate is acknowledged but not counted
+	for _, counted := range []string{"true", "false"} {
+		out := c.mustRun("track", "-campaign", campaign.ID, "-user", "user-1", "-ad", "ad-1")
+		if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || !strings.HasPrefix(strings.Join(strings.Fields(lines[1]), " "), "200 "+counted) {
+			t.Errorf("❌ Expected counted=%s, got %q", counted, out)
+		}
+	}
+
+	var stats entities.Stats
+	out = c.mustRun("-output", "json", "stats", campaign.ID)
+	if err := json.Unmarshal([]byte(out), &stats); err != nil || stats.TotalCount != 1 {
+		t.Errorf("❌ Expected a total of 1, got %q, %v", out, err)
+	}
+	if out := c.mustRun("series", campaign.ID, "-resolution", "minute"); len(strings.Split(strings.TrimSpace(out), "\n")) != 61 {
+		t.Errorf("❌ Expected a header and an hour of minutes, got %q", out)
+	}
+
+	file := filepath.Join(t.TempDir(), "stats.csv")
+	if out := c.mustRun("export", "stats", campaign.ID, "-out", file); out != "" {
+		t.Errorf("❌ Expected nothing on standard output, got %q", out)
+	}
+	if data, err := os.ReadFile(file); err != nil || !strings.Contains(string(data), campaign.ID+",1,1,1,") {
+		t.Errorf("❌ Expected the stats as CSV in the file, got %q, %v", data, err)
+	}
+	if out := c.mustRun("export", "campaigns", "-format", "ndjson"); !strings.Contains(out, `"id":"`+campaign.ID+`"`) {
+		t.Errorf("❌ Expected the campaigns as NDJSON, got %q", out)
+	}
+	if code, _, _ := c.run("export", "everything"); code != 2 {
+		t.Errorf("❌ Expected an unknown export to be a usage error, got %d", code)
+	}
+}
+
+func TestImportCommand(t *testing.T) {
+	c := newCtl(t)
+
+	var campaign entities.Campaign
+	out := c.mustRun("-output", "json", "campaigns", "create", "-name", "Imported", "-start", time.Now().Add(-48*time.Hour).Format(time.RFC3339))
+	if err := json.Unmarshal([]byte(out), &campaign); err != nil {
+		t.Fatalf("❌ Expected the created campaign as JSON, got %q, %v", out, err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "history.ndjson")
+	line := `{"campaign_id":"` + campaign.ID + `","timestamp":"` + time.Now().Add(-24*time.Hour).Format(time.RFC3339) + `","user_id":"u","ad_id":"a"}`
+	if err := os.WriteFile(file, []byte(line+"\nnot json\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to write the import: %v", err)
+	}
+
+	out = c.mustRun("import", file)
+	if !strings.Contains(out, campaign.ID) || !strings.Contains(out, "LINE") {
+		t.Errorf("❌ Expected the campaign and the rejected line in the report, got %q", out)
+	}
+	if lines := strings.Fields(strings.Split(out, "\n")[1]); len(lines) != 4 || lines[0] != "2" || lines[1] != "1" || lines[3] != "1" {
+		t.Errorf("❌ Expected 2 lines, 1 imported and 1 rejected, got %q", out)
+	}
+
+	unknown := filepath.Join(dir, "history.txt")
+	if err := os.WriteFile(unknown, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("❌ Failed to write the import: %v", err)
+	}
+	if code, _, stderr := c.run("import", unknown); code != 1 || !strings.Contains(stderr, "-format") {
+		t.Errorf("❌ Expected an unknown extension to ask for -format, got %d: %q", code, stderr)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	c := newCtl(t)
+
+	// Flags win over the environment, which wins over the settings file
+	t.Setenv("IMPRESSIONCTL_ENDPOINT", "http://127.0.0.1:1")
+	if code, _, _ := c.run("health"); code != 1 {
+		t.Errorf("❌ Expected the endpoint from the environment to be used, got %d", code)
+	}
+	if out := c.mustRun("-endpoint", c.endpoint, "health"); !strings.Contains(out, "ok") || !strings.Contains(out, "memory") {
+		t.Errorf("❌ Expected a healthy memory instance, got %q", out)
+	}
+
+	if code, _, stderr := c.run("-output", "yaml", "health"); code != 2 || !strings.Contains(stderr, "table or json") {
+		t.Errorf("❌ Expected an unknown output to be refused, got %d: %q", code, stderr)
+	}
+	if code, _, stderr := c.run("frobnicate"); code != 2 || !strings.Contains(stderr, "Commands:") {
+		t.Errorf("❌ Expected the usage for an unknown command, got %d: %q", code, stderr)
+	}
+	if code, _, stderr := c.run("-config", filepath.Join(t.TempDir(), "missing.yml"), "health"); code != 1 || !strings.Contains(stderr, "missing.yml") {
+		t.Errorf("❌ Expected a missing settings file to be reported, got %d: %q", code, stderr)
+	}
+}
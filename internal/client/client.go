@@ -0,0 +1,266 @@
+// Package client talks to the HTTP API of the service, unwrapping its response envelope into the
+// entities it carries
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/export"
+	"learning/internal/middleware"
+	"learning/internal/validators"
+)
+
+// APIError is a response with an error status
+type APIError struct {
+	Status  int
+	Message string
+	Fields  []validators.FieldError
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.Message)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// Client sends requests to the API at Endpoint, identified by APIKey when set
+type Client struct {
+	Endpoint string
+	APIKey   string
+	HTTP     *http.Client
+}
+
+func New(endpoint, apiKey string) *Client {
+	return &Client{Endpoint: strings.TrimRight(endpoint, "/"), APIKey: apiKey, HTTP: http.DefaultClient}
+}
+
+// envelope is utils.APIResponse with the data left for the caller to decode
+type envelope struct {
+	Success bool                    `json:"success"`
+	Message string                  `json:"message"`
+	Data    json.RawMessage         `json:"data"`
+	Errors  []validators.FieldError `json:"errors"`
+}
+
+// ListCampaigns returns every campaign in ID order
+func (c *Client) ListCampaigns(ctx context.Context) ([]entities.Campaign, error) {
+	var campaigns []entities.Campaign
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/campaigns", nil, &campaigns)
+	return campaigns, err
+}
+
+func (c *Client) GetCampaign(ctx context.Context, id string) (entities.Campaign, error) {
+	var campaign entities.Campaign
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/campaigns/"+url.PathEscape(id), nil, &campaign)
+	return campaign, err
+}
+
+func (c *Client) CreateCampaign(ctx context.Context, req entities.CreateCampaignRequest) (entities.Campaign, error) {
+	var campaign entities.Campaign
+	_, err := c.do(ctx, http.MethodPost, "/api/v1/campaigns", req, &campaign)
+	return campaign, err
+}
+
+func (c *Client) UpdateCampaign(ctx context.Context, id string, req entities.UpdateCampaignRequest) (entities.Campaign, error) {
+	var campaign entities.Campaign
+	_, err := c.do(ctx, http.MethodPatch, "/api/v1/campaigns/"+url.PathEscape(id), req, &campaign)
+	return campaign, err
+}
+
+// TrackResult is how the service answered an impression. Duplicates and invalid traffic are
+// acknowledged without being counted, so they are not errors.
+type TrackResult struct {
+	Status  int    `json:"status"`
+	Counted bool   `json:"counted"`
+	Message string `json:"message"`
+}
+
+func (c *Client) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (TrackResult, error) {
+	env, err := c.do(ctx, http.MethodPost, "/api/v1/impressions", req, nil)
+	if err != nil {
+		return TrackResult{}, err
+	}
+	return TrackResult{Status: env.status, Counted: env.Success, Message: env.Message}, nil
+}
+
+func (c *Client) GetStats(ctx context.Context, id string) (entities.Stats, error) {
+	var stats entities.Stats
+	_, err := c.do(ctx, http.MethodGet, "/api/v1/campaigns/stats/"+url.PathEscape(id), nil, &stats)
+	return stats, err
+}
+
+// SeriesQuery selects the buckets of a time series; zero fields take the server defaults
+type SeriesQuery struct {
+	Resolution string
+	From, To   time.Time
+}
+
+func (q SeriesQuery) values() url.Values {
+	values := url.Values{}
+	if q.Resolution != "" {
+		values.Set("resolution", q.Resolution)
+	}
+	if !q.From.IsZero() {
+		values.Set("from", q.From.Format(time.RFC3339Nano))
+	}
+	if !q.To.IsZero() {
+		values.Set("to", q.To.Format(time.RFC3339Nano))
+	}
+	return values
+}
+
+func (c *Client) GetSeries(ctx context.Context, id string, q SeriesQuery) (entities.Series, error) {
+	var series entities.Series
+	_, err := c.do(ctx, http.MethodGet, seriesPath(id, q), nil, &series)
+	return series, err
+}
+
+func seriesPath(id string, q SeriesQuery) string {
+	path := "/api/v1/campaigns/" + url.PathEscape(id) + "/series"
+	if values := q.values(); len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+	return path
+}
+
+// CampaignsExport is the Export path of the campaign list
+const CampaignsExport = "/api/v1/campaigns"
+
+// StatsExport is the Export path of the stats of a campaign
+func StatsExport(id string) string {
+	return "/api/v1/campaigns/stats/" + url.PathEscape(id)
+}
+
+// SeriesExport is the Export path of a time series
+func SeriesExport(id string, q SeriesQuery) string {
+	return seriesPath(id, q)
+}
+
+// ImpressionsExport is the Export path of the raw impressions of a campaign
+func ImpressionsExport(id string) string {
+	return "/api/v1/campaigns/" + url.PathEscape(id) + "/impressions"
+}
+
+// Export copies the listing at path in format, export.FormatCSV or export.FormatNDJSON, to w. A download
+// the server ended early is an error, once what it sent was copied.
+func (c *Client) Export(ctx context.Context, path, format string, w io.Writer) error {
+	if format != export.FormatCSV && format != export.FormatNDJSON {
+		return fmt.Errorf("cannot export as %q", format)
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, path+sep+"format="+format, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, err := decode(resp, nil)
+		return err
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return err
+	}
+	if reason := resp.Trailer.Get(export.ErrorTrailer); reason != "" {
+		return errors.New(reason)
+	}
+	return nil
+}
+
+// Import uploads a CSV or NDJSON file of historical impressions
+func (c *Client) Import(ctx context.Context, format string, r io.Reader) (entities.ImportReport, error) {
+	var report entities.ImportReport
+	resp, err := c.send(ctx, http.MethodPost, "/api/v1/imports?format="+url.QueryEscape(format), "application/octet-stream", r)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+	_, err = decode(resp, &report)
+	return report, err
+}
+
+func (c *Client) Health(ctx context.Context) (entities.Health, error) {
+	var health entities.Health
+	_, err := c.do(ctx, http.MethodGet, "/health", nil, &health)
+	return health, err
+}
+
+// response is an envelope with the status it came with
+type response struct {
+	envelope
+	status int
+}
+
+// do sends body as JSON and decodes the data of the response into out, when given
+func (c *Client) do(ctx context.Context, method, path string, body, out any) (response, error) {
+	var payload io.Reader
+	contentType := ""
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return response{}, err
+		}
+		payload, contentType = bytes.NewReader(encoded), "application/json"
+	}
+
+	resp, err := c.send(ctx, method, path, contentType, payload)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+func (c *Client) send(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.Endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.APIKey != "" {
+		req.Header.Set(middleware.APIKeyHeader, c.APIKey)
+	}
+	return c.HTTP.Do(req)
+}
+
+// decode reads the envelope of resp, turning error statuses into an *APIError
+func decode(resp *http.Response, out any) (response, error) {
+	r := response{status: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(&r.envelope); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			// Errors such as 405 are plain text
+			return r, &APIError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		}
+		return r, fmt.Errorf("decode %d response: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return r, &APIError{Status: resp.StatusCode, Message: r.Message, Fields: r.Errors}
+	}
+	if out != nil {
+		if len(r.Data) == 0 {
+			return r, errors.New("response carries no data")
+		}
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return r, fmt.Errorf("decode response data: %w", err)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"learning/cmd/server"
+	"learning/internal/client"
+	"learning/internal/entities"
+	"learning/internal/export"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newClient(t *testing.T) *client.Client {
+	t.Helper()
+	handler, closeServer, err := server.SetupServer()
+	if err != nil {
+		t.Fatalf("❌ Failed to set up server: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		srv.Close()
+		_ = closeServer()
+	})
+	return client.New(srv.URL+"/", "test-key")
+}
+
+func TestClientManagesCampaigns(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	created, err := c.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Client", StartTime: start, ImpressionGoal: 10})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+
+	name, end := "Renamed", start.Add(24*time.Hour)
+	if _, err := c.UpdateCampaign(ctx, created.ID, entities.UpdateCampaignRequest{Name: &name, EndTime: &end}); err != nil {
+		t.Fatalf("❌ UpdateCampaign failed: %v", err)
+	}
+	campaign, err := c.GetCampaign(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("❌ GetCampaign failed: %v", err)
+	}
+	if campaign.Name != "Renamed" || campaign.EndTime == nil || !campaign.EndTime.Equal(end) || campaign.ImpressionGoal != 10 {
+		t.Errorf("❌ Expected the updated campaign, got %+v", campaign)
+	}
+
+	campaigns, err := c.ListCampaigns(ctx)
+	if err != nil || len(campaigns) != 1 || campaigns[0].ID != created.ID {
+		t.Errorf("❌ Expected the campaign in the listing, got %+v, %v", campaigns, err)
+	}
+
+	// An end before the start is rejected with the offending field
+	before := start.Add(-time.Hour)
+	_, err = c.UpdateCampaign(ctx, created.ID, entities.UpdateCampaignRequest{EndTime: &before})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "end_time" {
+		t.Errorf("❌ Expected a 400 on end_time, got %v", err)
+	}
+	if _, err := c.GetCampaign(ctx, uuid.NewString()); !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("❌ Expected a 404, got %v", err)
+	}
+}
+
+func TestClientTracksAndReads(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	campaign, err := c.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Tracked", StartTime: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+
+	// The duplicate is acknowledged but not counted, which is no error
+	req := entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "user-1", AdID: "ad-1"}
+	for i, counted := range []bool{true, false} {
+		result, err := c.TrackImpression(ctx, req)
+		if err != nil || result.Status != http.StatusOK || result.Counted != counted {
+			t.Errorf("❌ Impression %d: expected counted=%v, got %+v, %v", i, counted, result, err)
+		}
+	}
+
+	stats, err := c.GetStats(ctx, campaign.ID)
+	if err != nil || stats.TotalCount != 1 {
+		t.Errorf("❌ Expected a total of 1, got %+v, %v", stats, err)
+	}
+	series, err := c.GetSeries(ctx, campaign.ID, client.SeriesQuery{Resolution: entities.ResolutionMinute})
+	if err != nil || len(series.Points) != 60 {
+		t.Errorf("❌ Expected an hour of minutes, got %d points, %v", len(series.Points), err)
+	}
+
+	var csv strings.Builder
+	if err := c.Export(ctx, client.StatsExport(campaign.ID), export.FormatCSV, &csv); err != nil {
+		t.Fatalf("❌ Export failed: %v", err)
+	}
+	if !strings.HasPrefix(csv.String(), "campaign_id,") || !strings.Contains(csv.String(), campaign.ID+",1,1,1,") {
+		t.Errorf("❌ Expected the stats as CSV, got %q", csv.String())
+	}
+
+	health, err := c.Health(ctx)
+	if err != nil || health.Status != "ok" || health.Storage != "memory" {
+		t.Errorf("❌ Expected a healthy memory instance, got %+v, %v", health, err)
+	}
+}
+
+func TestClientImports(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	campaign, err := c.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Imported", StartTime: time.Now().Add(-48 * time.Hour)})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+	file := `{"campaign_id":"` + campaign.ID + `","timestamp":"` + time.Now().Add(-24*time.Hour).Format(time.RFC3339) + `","user_id":"u","ad_id":"a"}` + "\n"
+
+	report, err := c.Import(ctx, export.FormatNDJSON, strings.NewReader(file))
+	if err != nil || report.Imported != 1 || len(report.Campaigns) != 1 {
+		t.Errorf("❌ Expected one imported impression, got %+v, %v", report, err)
+	}
+
+	var apiErr *client.APIError
+	if _, err := c.Import(ctx, "xml", strings.NewReader(file)); !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("❌ Expected a 400 for an unknown format, got %v", err)
+	}
+}
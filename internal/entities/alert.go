@@ -0,0 +1,28 @@
+package entities
+
+import "time"
+
+// Alert kinds raised by the anomaly detector
+const (
+	AlertSpike          = "spike"
+	AlertDrop           = "drop"
+	AlertDuplicateRatio = "duplicate_ratio"
+)
+
+// Alert is an anomaly currently seen in the traffic of one campaign
+type Alert struct {
+	CampaignID string `json:"campaign_id"`
+	Kind       string `json:"kind"`
+	Message    string `json:"message"`
+	// Value is the observation that raised the alert and Threshold the limit it crossed
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"`
+}
+
+// AlertEvent is delivered to the alert webhooks when an alert is raised or resolved
+type AlertEvent struct {
+	Event string    `json:"event"`
+	At    time.Time `json:"at"`
+	Alert Alert     `json:"alert"`
+}
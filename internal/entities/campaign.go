@@ -1,14 +1,74 @@
 package entities
 
-import "time"
+import (
+	"math"
+	"time"
+)
+
+// Campaign statuses
+const (
+	CampaignActive = "active"
+	// CampaignCompleted campaigns reached their impression goal or spent their budget and count no more impressions
+	CampaignCompleted = "completed"
+)
 
 type Campaign struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	StartTime time.Time `json:"start_time"`
+	// EndTime is nil for campaigns running until further notice
+	EndTime *time.Time `json:"end_time,omitempty"`
+	// ImpressionGoal is the number of impressions to deliver; 0 means unbounded
+	ImpressionGoal int64 `json:"impression_goal,omitempty"`
+	// Budget caps the spend, priced at CPM per thousand counted impressions; 0 means unbounded
+	Budget float64 `json:"budget,omitempty"`
+	CPM    float64 `json:"cpm,omitempty"`
+	Status string  `json:"status"`
+	// CompletedAt is when the impression that reached the limit was counted
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
 type CreateCampaignRequest struct {
-	Name      string    `json:"name" validate:"required"`
-	StartTime time.Time `json:"start_time" validate:"required"`
+	Name           string     `json:"name" validate:"required,min=1,max=255,printable"`
+	StartTime      time.Time  `json:"start_time" validate:"required"`
+	EndTime        *time.Time `json:"end_time,omitempty" validate:"omitempty,gtfield=StartTime"`
+	ImpressionGoal int64      `json:"impression_goal,omitempty" validate:"omitempty,gt=0"`
+	Budget         float64    `json:"budget,omitempty" validate:"omitempty,gt=0"`
+	CPM            float64    `json:"cpm,omitempty" validate:"required_with=Budget,omitempty,gt=0"`
+}
+
+// UpdateCampaignRequest renames a campaign or moves its end; omitted fields are kept. The goal, budget
+// and CPM cannot change, as impressions counted against them may already have completed the campaign.
+type UpdateCampaignRequest struct {
+	Name    *string    `json:"name,omitempty" validate:"omitempty,min=1,max=255,printable"`
+	EndTime *time.Time `json:"end_time,omitempty"`
+}
+
+// Apply sets the fields of req on c
+func (req UpdateCampaignRequest) Apply(c *Campaign) {
+	if req.Name != nil {
+		c.Name = *req.Name
+	}
+	if req.EndTime != nil {
+		c.EndTime = req.EndTime
+	}
+}
+
+// ImpressionLimit returns how many impressions the campaign may count: the lower of its goal and
+// what its budget buys at its CPM, or 0 when neither bounds it
+func (c Campaign) ImpressionLimit() int64 {
+	limit := c.ImpressionGoal
+	if c.Budget > 0 && c.CPM > 0 {
+		// The epsilon absorbs representation errors, so a budget of 0.3 at a CPM of 0.1 buys 3000
+		affordable := int64(math.Floor(c.Budget*1000/c.CPM + 1e-9))
+		if limit == 0 || affordable < limit {
+			limit = affordable
+		}
+	}
+	return limit
+}
+
+// Spend returns the cost of impressions at the campaign CPM, rounded to micro units
+func (c Campaign) Spend(impressions int64) float64 {
+	return math.Round(float64(impressions)*c.CPM*1000) / 1e6
 }
@@ -0,0 +1,8 @@
+package entities
+
+// Health is the state of an instance reported to operators and load balancers
+type Health struct {
+	Status    string `json:"status"`
+	Storage   string `json:"storage"`
+	Ingestion string `json:"ingestion"`
+}
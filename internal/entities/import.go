@@ -0,0 +1,40 @@
+package entities
+
+// ImportLineError reports why one line of an import file was rejected
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportedCampaign counts what an import applied to one campaign. AlreadyApplied is set, with no counts,
+// when an earlier import of the same file was applied to it.
+type ImportedCampaign struct {
+	CampaignID     string `json:"campaign_id"`
+	Imported       int64  `json:"imported"`
+	Duplicates     int64  `json:"duplicates"`
+	AlreadyApplied bool   `json:"already_applied,omitempty"`
+}
+
+// ImportReport sums up an import of historical impressions
+type ImportReport struct {
+	// ImportID is the SHA-256 of the file, recorded with every campaign it is applied to
+	ImportID string `json:"import_id,omitempty"`
+	// Lines counts the impression lines read, without the CSV header and blank lines
+	Lines      int64 `json:"lines"`
+	Imported   int64 `json:"imported"`
+	Duplicates int64 `json:"duplicates"`
+	Rejected   int64 `json:"rejected"`
+	// Campaigns lists the campaigns the counts were applied to, in ID order
+	Campaigns []ImportedCampaign `json:"campaigns"`
+	// Errors holds the first rejected lines; ErrorsTruncated is set when there were more
+	Errors          []ImportLineError `json:"errors"`
+	ErrorsTruncated bool              `json:"errors_truncated,omitempty"`
+}
+
+// ImportProgress is reported while an import reads its file and then applies each campaign
+type ImportProgress struct {
+	Lines            int64 `json:"lines"`
+	Rejected         int64 `json:"rejected"`
+	Campaigns        int   `json:"campaigns"`
+	CampaignsApplied int   `json:"campaigns_applied"`
+}
@@ -10,7 +10,32 @@ type Impression struct {
 }
 
 type TrackImpressionRequest struct {
-	CampaignID string `json:"campaign_id" validate:"required"`
-	UserID     string `json:"user_id" validate:"required"`
-	AdID       string `json:"ad_id" validate:"required"`
+	CampaignID string `json:"campaign_id" validate:"required,uuid"`
+	UserID     string `json:"user_id" validate:"required,max=128,identifier"`
+	AdID       string `json:"ad_id" validate:"required,max=128,identifier"`
+	// Timestamp is when the impression happened on the client; receipt time is used when it is absent
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+
+	// UserAgent and IP identify the client that sent the impression; they are captured from the request
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+	// InvalidReason is set by the invalid-traffic filters; such impressions are only counted as invalid
+	InvalidReason string `json:"-"`
+}
+
+// ImpressionQuery filters the raw impression log of one campaign. Zero values match everything.
+type ImpressionQuery struct {
+	CampaignID string
+	From       time.Time
+	To         time.Time
+	UserID     string
+	AdID       string
+	Cursor     string
+	Limit      int
+}
+
+// ImpressionPage is one page of raw impressions; NextCursor is empty on the last page
+type ImpressionPage struct {
+	Impressions []Impression `json:"impressions"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
 }
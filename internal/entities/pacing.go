@@ -0,0 +1,43 @@
+package entities
+
+import "time"
+
+// Pacing curves describe the share of the goal a campaign should have delivered at each point of its flight
+const (
+	// PacingEven spreads delivery evenly over the flight
+	PacingEven = "even"
+	// PacingFrontLoaded delivers faster early on: three quarters of the goal by the middle of the flight
+	PacingFrontLoaded = "front_loaded"
+)
+
+// Pacing statuses compare the delivery with the curve
+const (
+	PacingUnder   = "under"
+	PacingOnTrack = "on_track"
+	PacingOver    = "over"
+)
+
+// PacingQuery selects the campaign and curve of a pacing report
+type PacingQuery struct {
+	CampaignID string
+	Curve      string
+}
+
+// Pacing reports how the delivery of a campaign compares with its pacing curve
+type Pacing struct {
+	CampaignID string `json:"campaign_id"`
+	Curve      string `json:"curve"`
+	// Goal is the impression limit of the campaign, from its goal or its budget
+	Goal      int64 `json:"goal"`
+	Delivered int64 `json:"delivered"`
+	// Expected is what the curve asks to have delivered by AsOf
+	Expected        int64   `json:"expected"`
+	PercentToGoal   float64 `json:"percent_to_goal"`
+	PercentOfFlight float64 `json:"percent_of_flight"`
+	// HourlyRate is the recent delivery, averaged over the hour buckets of the last day within the flight
+	HourlyRate float64 `json:"hourly_rate"`
+	// ProjectedDelivery carries the recent rate along the curve to the end of the flight, up to the goal
+	ProjectedDelivery int64     `json:"projected_delivery"`
+	Status            string    `json:"status"`
+	AsOf              time.Time `json:"as_of"`
+}
@@ -0,0 +1,30 @@
+package entities
+
+import "time"
+
+// Series resolutions, matching the widths of the stats buckets
+const (
+	ResolutionMinute = "minute"
+	ResolutionHour   = "hour"
+)
+
+// SeriesQuery selects the buckets of one campaign starting in [From, To)
+type SeriesQuery struct {
+	CampaignID string
+	Resolution string
+	From       time.Time
+	To         time.Time
+}
+
+// SeriesPoint counts the impressions that happened in the bucket starting at Start
+type SeriesPoint struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// Series holds every bucket of the queried range, including the empty ones
+type Series struct {
+	CampaignID string        `json:"campaign_id"`
+	Resolution string        `json:"resolution"`
+	Points     []SeriesPoint `json:"points"`
+}
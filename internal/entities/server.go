@@ -10,4 +10,14 @@ type Server struct {
 	Campaigns   map[string]Campaign
 	Impressions map[string]map[string]time.Time
 	Stats       map[string]Stats
+	// Minutes and Hours count impressions per campaign and Unix minute or hour of the event
+	Minutes map[string]map[int64]int64
+	Hours   map[string]map[int64]int64
+	// Backfills holds the IDs of the imports applied to each campaign
+	Backfills map[string]map[string]time.Time
+	// Webhooks holds the webhook subscriptions by ID, WebhookEvents the events announced per campaign
+	// and DeadLetters the undeliverable payloads per campaign, oldest first
+	Webhooks      map[string]WebhookSubscription
+	WebhookEvents map[string]map[string]time.Time
+	DeadLetters   map[string][]DeadLetter
 }
@@ -5,4 +5,12 @@ type Stats struct {
 	LastHour   int64  `json:"last_hour"`
 	LastDay    int64  `json:"last_day"`
 	TotalCount int64  `json:"total"`
+	// Late counts impressions counted after ingestion.late_after had passed since they happened
+	Late int64 `json:"late"`
+	// TooOld counts impressions rejected for being older than ingestion.max_lateness
+	TooOld int64 `json:"too_old"`
+	// Invalid counts impressions rejected by the invalid-traffic filters, such as bots or bursts
+	Invalid int64 `json:"invalid"`
+	// Spend prices the total at the campaign CPM; it is omitted for campaigns without one
+	Spend float64 `json:"spend,omitempty"`
 }
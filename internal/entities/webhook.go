@@ -0,0 +1,48 @@
+package entities
+
+import "time"
+
+// Webhook event types a subscription can ask for
+const (
+	WebhookCampaignStarted = "campaign.started"
+	WebhookCampaignEnded   = "campaign.ended"
+	WebhookImpressions1K   = "impressions.1k"
+	WebhookImpressions10K  = "impressions.10k"
+	WebhookImpressions1M   = "impressions.1m"
+)
+
+// WebhookSubscription delivers the chosen events of one campaign to URL
+type WebhookSubscription struct {
+	ID         string   `json:"id"`
+	CampaignID string   `json:"campaign_id"`
+	URL        string   `json:"url"`
+	Events     []string `json:"events"`
+	// Secret signs every delivery; it is only returned when the subscription is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,max=2048,http_url"`
+	Events []string `json:"events" validate:"required,min=1,max=5,unique,dive,oneof=campaign.started campaign.ended impressions.1k impressions.10k impressions.1m"`
+}
+
+// WebhookPayload is the JSON body POSTed to a subscription; ID stays the same across retries
+type WebhookPayload struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	CampaignID string    `json:"campaign_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Impressions is the campaign total when a milestone was reached
+	Impressions int64 `json:"impressions,omitempty"`
+}
+
+// DeadLetter is a payload that could not be delivered within the allowed attempts
+type DeadLetter struct {
+	SubscriptionID string         `json:"subscription_id"`
+	URL            string         `json:"url"`
+	Payload        WebhookPayload `json:"payload"`
+	Attempts       int            `json:"attempts"`
+	LastError      string         `json:"last_error"`
+	FailedAt       time.Time      `json:"failed_at"`
+}
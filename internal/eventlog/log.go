@@ -0,0 +1,366 @@
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learning/internal/entities"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidCursor is returned by Query for a cursor it did not hand out
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrClosed is returned by Append once the log was closed
+var ErrClosed = errors.New("event log is closed")
+
+// segmentExt is the extension of segment files, which hold one JSON impression per line
+const segmentExt = ".ndjson"
+
+// Options controls segment rotation and retention
+type Options struct {
+	Dir             string
+	SegmentMaxBytes int64
+	SegmentMaxAge   time.Duration
+	Retention       time.Duration
+	// ClockSkew is how far event timestamps may lie ahead of the time they were appended
+	ClockSkew time.Duration
+}
+
+type segment struct {
+	id   uint64
+	path string
+}
+
+// Log is an append-only impression log split into numbered segment files.
+// Only the newest segment is written to; older segments are immutable until retention deletes them.
+type Log struct {
+	opts Options
+
+	mu           sync.Mutex
+	segments     []segment // oldest first
+	active       *os.File
+	activeSize   int64
+	activeOpened time.Time
+	nextID       uint64
+	closed       bool
+}
+
+// Open loads the segments found in opts.Dir, creating the directory if needed.
+// Appends always start a fresh segment, so a line torn by a crash is never extended.
+func Open(opts Options) (*Log, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{opts: opts, nextID: 1}
+	for _, entry := range entries {
+		id, ok := parseSegmentName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		l.segments = append(l.segments, segment{id: id, path: filepath.Join(opts.Dir, entry.Name())})
+		if id >= l.nextID {
+			l.nextID = id + 1
+		}
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].id < l.segments[j].id })
+	return l, nil
+}
+
+// Append writes events to the active segment, rotating it when it is too large or too old
+func (l *Log) Append(events ...entities.Impression) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		if l.active == nil || l.activeSize+int64(len(line)) > l.opts.SegmentMaxBytes || time.Since(l.activeOpened) >= l.opts.SegmentMaxAge {
+			if err := l.rotate(); err != nil {
+				return err
+			}
+		}
+
+		n, err := l.active.Write(line)
+		l.activeSize += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotate closes the active segment and starts the next one; the caller must hold mu
+func (l *Log) rotate() error {
+	if l.active != nil {
+		if err := l.active.Close(); err != nil {
+			return err
+		}
+		l.active = nil
+	}
+
+	seg := segment{id: l.nextID, path: filepath.Join(l.opts.Dir, fmt.Sprintf("%020d%s", l.nextID, segmentExt))}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	l.nextID++
+	l.segments = append(l.segments, seg)
+	l.active = f
+	l.activeSize = 0
+	l.activeOpened = time.Now()
+	return nil
+}
+
+// Query returns the impressions matching q in the order they were appended.
+// From is inclusive and To exclusive; a segment last written before From, give or take the clock skew,
+// is skipped without reading it.
+func (l *Log) Query(ctx context.Context, q entities.ImpressionQuery) (entities.ImpressionPage, error) {
+	startID, startOffset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return entities.ImpressionPage{}, err
+	}
+
+	l.mu.Lock()
+	segments := append([]segment(nil), l.segments...)
+	l.mu.Unlock()
+
+	page := entities.ImpressionPage{Impressions: []entities.Impression{}}
+	for _, seg := range segments {
+		if seg.id < startID {
+			continue
+		}
+		offset := int64(0)
+		if seg.id == startID {
+			offset = startOffset
+		}
+
+		next, err := l.scan(ctx, seg, offset, q, func(event entities.Impression) (bool, error) {
+			if len(page.Impressions) == q.Limit {
+				return false, nil
+			}
+			page.Impressions = append(page.Impressions, event)
+			return true, nil
+		})
+		if err != nil {
+			return entities.ImpressionPage{}, err
+		}
+		if next >= 0 {
+			page.NextCursor = encodeCursor(seg.id, next)
+			return page, nil
+		}
+	}
+	return page, nil
+}
+
+// Each calls fn with every impression matching q from its cursor on, ignoring its limit, in one pass
+// over the segments
+func (l *Log) Each(ctx context.Context, q entities.ImpressionQuery, fn func(entities.Impression) error) error {
+	startID, startOffset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	segments := append([]segment(nil), l.segments...)
+	l.mu.Unlock()
+
+	for _, seg := range segments {
+		if seg.id < startID {
+			continue
+		}
+		offset := int64(0)
+		if seg.id == startID {
+			offset = startOffset
+		}
+
+		_, err := l.scan(ctx, seg, offset, q, func(event entities.Impression) (bool, error) {
+			return true, fn(event)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan calls visit with the matches of one segment from offset on, until visit returns false. It
+// returns the offset of the match visit declined, or -1 when the segment was read to the end.
+func (l *Log) scan(ctx context.Context, seg segment, offset int64, q entities.ImpressionQuery, visit func(entities.Impression) (bool, error)) (int64, error) {
+	f, err := os.Open(seg.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Deleted by retention after the segment list was copied
+		return -1, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if !q.From.IsZero() && offset == 0 {
+		if info, err := f.Stat(); err == nil && info.ModTime().Add(l.opts.ClockSkew).Before(q.From) {
+			return -1, nil
+		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return -1, err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		if err := ctx.Err(); err != nil {
+			return -1, err
+		}
+
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A line without its newline is still being written, or was torn by a crash
+			return -1, nil
+		}
+		if err != nil {
+			return -1, err
+		}
+		start := offset
+		offset += int64(len(line))
+
+		var event entities.Impression
+		if json.Unmarshal(line, &event) != nil || !matches(event, q) {
+			continue
+		}
+		more, err := visit(event)
+		if err != nil {
+			return -1, err
+		}
+		if !more {
+			return start, nil
+		}
+	}
+}
+
+func matches(event entities.Impression, q entities.ImpressionQuery) bool {
+	switch {
+	case event.CampaignID != q.CampaignID:
+		return false
+	case q.UserID != "" && event.UserID != q.UserID:
+		return false
+	case q.AdID != "" && event.AdID != q.AdID:
+		return false
+	case !q.From.IsZero() && event.Timestamp.Before(q.From):
+		return false
+	case !q.To.IsZero() && !event.Timestamp.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// PurgeExpired deletes segments last written before the retention window and returns how many were removed
+func (l *Log) PurgeExpired(ctx context.Context) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-l.opts.Retention)
+	kept := l.segments[:0]
+	purged := 0
+	for i, seg := range l.segments {
+		if err := ctx.Err(); err != nil {
+			kept = append(kept, l.segments[i:]...)
+			l.segments = kept
+			return purged, err
+		}
+
+		info, err := os.Stat(seg.path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			kept = append(kept, seg)
+			continue
+		}
+
+		// An idle active segment can expire as well; the next append starts a new one
+		if i == len(l.segments)-1 && l.active != nil {
+			_ = l.active.Close()
+			l.active = nil
+		}
+		if err := os.Remove(seg.path); err != nil {
+			kept = append(kept, seg)
+			continue
+		}
+		purged++
+	}
+	l.segments = kept
+	return purged, nil
+}
+
+// Close flushes the active segment to disk; later appends fail with ErrClosed
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	if l.active == nil {
+		return nil
+	}
+	err := errors.Join(l.active.Sync(), l.active.Close())
+	l.active = nil
+	return err
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	if !strings.HasSuffix(name, segmentExt) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+	return id, err == nil
+}
+
+// encodeCursor makes an opaque token pointing at a line of a segment
+func encodeCursor(segmentID uint64, offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", segmentID, offset)))
+}
+
+func decodeCursor(cursor string) (uint64, int64, error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	segmentPart, offsetPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return 0, 0, ErrInvalidCursor
+	}
+	segmentID, err := strconv.ParseUint(segmentPart, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	offset, err := strconv.ParseInt(offsetPart, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, ErrInvalidCursor
+	}
+	return segmentID, offset, nil
+}
@@ -0,0 +1,73 @@
+package eventlog
+
+import (
+	"context"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Recorder wraps an impression repository and appends every counted impression to the log.
+// Duplicates and rejected impressions are not recorded.
+type Recorder struct {
+	repo   repositories.ImpressionRepository
+	log    *Log
+	logger *zap.Logger
+}
+
+// NewRecorder records the impressions accepted by repo into log
+func NewRecorder(repo repositories.ImpressionRepository, log *Log, logger *zap.Logger) *Recorder {
+	return &Recorder{repo: repo, log: log, logger: logger}
+}
+
+func (r *Recorder) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	err, status := r.repo.TrackImpression(ctx, req)
+	if err == nil {
+		r.record(event(req, time.Now()))
+	}
+	return err, status
+}
+
+// TrackImpressions forwards the batch to the wrapped repository, one request at a time if it cannot batch
+func (r *Recorder) TrackImpressions(ctx context.Context, reqs []entities.TrackImpressionRequest) []error {
+	var errs []error
+	if batcher, ok := r.repo.(repositories.BatchImpressionRepository); ok {
+		errs = batcher.TrackImpressions(ctx, reqs)
+	} else {
+		errs = make([]error, len(reqs))
+		for i, req := range reqs {
+			errs[i], _ = r.repo.TrackImpression(ctx, req)
+		}
+	}
+
+	now := time.Now()
+	events := make([]entities.Impression, 0, len(reqs))
+	for i, err := range errs {
+		if err == nil {
+			events = append(events, event(reqs[i], now))
+		}
+	}
+	r.record(events...)
+	return errs
+}
+
+// record appends events; the impressions are already counted, so a failure is only logged
+func (r *Recorder) record(events ...entities.Impression) {
+	if len(events) == 0 {
+		return
+	}
+	if err := r.log.Append(events...); err != nil {
+		r.logger.Error("failed to append impressions to the event log", zap.Int("count", len(events)), zap.Error(err))
+	}
+}
+
+// event stamps req with its own timestamp, or the time it was counted when the client sent none
+func event(req entities.TrackImpressionRequest, countedAt time.Time) entities.Impression {
+	at := countedAt
+	if req.Timestamp != nil {
+		at = *req.Timestamp
+	}
+	return entities.Impression{CampaignID: req.CampaignID, Timestamp: at.UTC(), UserID: req.UserID, AdID: req.AdID}
+}
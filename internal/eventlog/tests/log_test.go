@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learning/internal/entities"
+	"learning/internal/eventlog"
+	"learning/internal/repositories/memory"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const campaignID = "8b2c7a39-1111-4e1e-9a55-0c8d0f2d6a10"
+
+func openLog(t *testing.T, dir string, segmentMaxBytes int64) *eventlog.Log {
+	t.Helper()
+	log, err := eventlog.Open(eventlog.Options{
+		Dir:             dir,
+		SegmentMaxBytes: segmentMaxBytes,
+		SegmentMaxAge:   time.Hour,
+		Retention:       time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("❌ Failed to open event log: %v", err)
+	}
+	return log
+}
+
+func event(user int, at time.Time) entities.Impression {
+	return entities.Impression{CampaignID: campaignID, Timestamp: at, UserID: fmt.Sprintf("user%d", user), AdID: fmt.Sprintf("ad%d", user%2)}
+}
+
+// readAll follows next_cursor until the last page
+func readAll(t *testing.T, log *eventlog.Log, q entities.ImpressionQuery) ([]entities.Impression, int) {
+	t.Helper()
+	var all []entities.Impression
+	pages := 0
+	for {
+		page, err := log.Query(context.Background(), q)
+		if err != nil {
+			t.Fatalf("❌ Query failed: %v", err)
+		}
+		pages++
+		all = append(all, page.Impressions...)
+		if page.NextCursor == "" {
+			return all, pages
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func TestQueryFiltersAndPaginatesAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+	// Small segments force several rotations
+	log := openLog(t, dir, 512)
+	defer func() { _ = log.Close() }()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		if err := log.Append(event(i, start.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("❌ Append failed: %v", err)
+		}
+	}
+	_ = log.Append(entities.Impression{CampaignID: "other", Timestamp: start, UserID: "user1", AdID: "ad1"})
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if len(segments) < 3 {
+		t.Fatalf("❌ Expected several segments, got %d", len(segments))
+	}
+
+	all, pages := readAll(t, log, entities.ImpressionQuery{CampaignID: campaignID, Limit: 7})
+	if len(all) != 30 || pages != 5 {
+		t.Fatalf("❌ Expected 30 impressions in 5 pages, got %d in %d", len(all), pages)
+	}
+	for i, ev := range all {
+		if ev.UserID != fmt.Sprintf("user%d", i) {
+			t.Fatalf("❌ Impression %d out of order: %s", i, ev.UserID)
+		}
+	}
+
+	filtered, _ := readAll(t, log, entities.ImpressionQuery{
+		CampaignID: campaignID,
+		From:       start.Add(10 * time.Minute),
+		To:         start.Add(20 * time.Minute),
+		AdID:       "ad1",
+		Limit:      100,
+	})
+	if len(filtered) != 5 {
+		t.Errorf("❌ Expected 5 impressions of ad1 between minute 10 and 20, got %d", len(filtered))
+	}
+
+	byUser, _ := readAll(t, log, entities.ImpressionQuery{CampaignID: campaignID, UserID: "user3", Limit: 100})
+	if len(byUser) != 1 {
+		t.Errorf("❌ Expected 1 impression of user3, got %d", len(byUser))
+	}
+
+	// Each streams the matches from a cursor on in one pass, whatever the limit
+	page, err := log.Query(context.Background(), entities.ImpressionQuery{CampaignID: campaignID, Limit: 7})
+	if err != nil {
+		t.Fatalf("❌ Query failed: %v", err)
+	}
+	var rest []string
+	err = log.Each(context.Background(), entities.ImpressionQuery{CampaignID: campaignID, Limit: 7, Cursor: page.NextCursor}, func(ev entities.Impression) error {
+		rest = append(rest, ev.UserID)
+		return nil
+	})
+	if err != nil || len(rest) != 23 || rest[0] != "user7" {
+		t.Errorf("❌ Expected the 23 impressions after the first page, got %v (%v)", rest, err)
+	}
+}
+
+func TestReopenKeepsEventsAndSkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	log := openLog(t, dir, 1<<20)
+	_ = log.Append(event(1, time.Now()), event(2, time.Now()))
+	if err := log.Close(); err != nil {
+		t.Fatalf("❌ Close failed: %v", err)
+	}
+	if err := log.Append(event(3, time.Now())); !errors.Is(err, eventlog.ErrClosed) {
+		t.Errorf("❌ Expected ErrClosed after Close, got %v", err)
+	}
+
+	// Simulate a crash in the middle of a write
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	f, _ := os.OpenFile(segments[0], os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString(`{"campaign_id":"` + campaignID + `","user`)
+	_ = f.Close()
+
+	log = openLog(t, dir, 1<<20)
+	defer func() { _ = log.Close() }()
+	_ = log.Append(event(4, time.Now()))
+
+	all, _ := readAll(t, log, entities.ImpressionQuery{CampaignID: campaignID, Limit: 100})
+	if len(all) != 3 {
+		t.Errorf("❌ Expected 3 impressions after reopen, got %d", len(all))
+	}
+}
+
+func TestPurgeExpiredRemovesOldSegments(t *testing.T) {
+	dir := t.TempDir()
+	log := openLog(t, dir, 256)
+	defer func() { _ = log.Close() }()
+
+	for i := 0; i < 10; i++ {
+		_ = log.Append(event(i, time.Now()))
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+
+	// Age every segment but the newest beyond the retention window
+	old := time.Now().Add(-2 * time.Hour)
+	for _, path := range segments[:len(segments)-1] {
+		_ = os.Chtimes(path, old, old)
+	}
+
+	purged, err := log.PurgeExpired(context.Background())
+	if err != nil {
+		t.Fatalf("❌ Purge failed: %v", err)
+	}
+	if purged != len(segments)-1 {
+		t.Errorf("❌ Expected %d purged segments, got %d", len(segments)-1, purged)
+	}
+
+	all, _ := readAll(t, log, entities.ImpressionQuery{CampaignID: campaignID, Limit: 100})
+	if len(all) == 0 || len(all) >= 10 {
+		t.Errorf("❌ Expected only the newest segment to remain, got %d impressions", len(all))
+	}
+}
+
+func TestQueryRejectsForeignCursor(t *testing.T) {
+	log := openLog(t, t.TempDir(), 1<<20)
+	defer func() { _ = log.Close() }()
+
+	_, err := log.Query(context.Background(), entities.ImpressionQuery{CampaignID: campaignID, Limit: 10, Cursor: "not a cursor"})
+	if !errors.Is(err, eventlog.ErrInvalidCursor) {
+		t.Errorf("❌ Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestRecorderLogsOnlyCountedImpressions(t *testing.T) {
+	log := openLog(t, t.TempDir(), 1<<20)
+	defer func() { _ = log.Close() }()
+
+	memServer := memory.NewServer()
+	campaign, _ := memory.NewInMemoryCampaignRepository(memServer).CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Logged", StartTime: time.Now()})
+	recorder := eventlog.NewRecorder(memory.NewInMemoryImpressionRepository(memServer), log, zap.NewNop())
+
+	req := entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "user1", AdID: "ad1"}
+	_, _ = recorder.TrackImpression(context.Background(), req)
+	_, _ = recorder.TrackImpression(context.Background(), req)
+	recorder.TrackImpressions(context.Background(), []entities.TrackImpressionRequest{
+		req,
+		{CampaignID: campaign.ID, UserID: "user2", AdID: "ad1"},
+		{CampaignID: "00000000-0000-0000-0000-000000000000", UserID: "user3", AdID: "ad1"},
+	})
+
+	all, _ := readAll(t, log, entities.ImpressionQuery{CampaignID: campaign.ID, Limit: 100})
+	if len(all) != 2 {
+		t.Fatalf("❌ Expected 2 logged impressions, got %d", len(all))
+	}
+	if all[0].UserID != "user1" || all[1].UserID != "user2" || all[0].Timestamp.IsZero() {
+		t.Errorf("❌ Unexpected logged impressions %+v", all)
+	}
+}
@@ -0,0 +1,245 @@
+// Package export writes listings as CSV or newline-delimited JSON, one row at a time, so a response
+// never holds the whole listing in memory.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats a listing can be served in
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ErrNotAcceptable is returned by Negotiate when the client accepts none of the formats
+var ErrNotAcceptable = errors.New("none of the accepted media types can be served")
+
+// contentTypes maps every format to the media type it is served as
+var contentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// mediaTypes maps the media ranges of an Accept header to the format they select
+var mediaTypes = map[string]string{
+	"application/json":     FormatJSON,
+	"application/*":        FormatJSON,
+	"*/*":                  FormatJSON,
+	"text/csv":             FormatCSV,
+	"text/*":               FormatCSV,
+	"application/x-ndjson": FormatNDJSON,
+	"application/ndjson":   FormatNDJSON,
+}
+
+// flushEvery is how many rows are buffered before they are flushed to the client
+const flushEvery = 100
+
+// ErrorTrailer is the trailer set when a download ended before its last row, as the status was already
+// sent with the first one
+const ErrorTrailer = "X-Export-Error"
+
+// Negotiate picks the format of the response: the format query parameter when present, otherwise the
+// most preferred media type of the Accept header that can be served, and JSON without either
+func Negotiate(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if _, ok := contentTypes[format]; !ok {
+			return "", ErrNotAcceptable
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return FormatJSON, nil
+	}
+
+	type candidate struct {
+		format string
+		q      float64
+	}
+	var candidates []candidate
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		format, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{format: format, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", ErrNotAcceptable
+	}
+
+	// Equally preferred media types keep the order of the header
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].format, nil
+}
+
+// IsDownload tells whether r asks for a CSV or NDJSON download rather than a JSON response
+func IsDownload(r *http.Request) bool {
+	format, err := Negotiate(r)
+	return r.Method == http.MethodGet && err == nil && format != FormatJSON
+}
+
+// Table lays out rows of T as CSV: the header and the record of one row. NDJSON rows are the JSON
+// encoding of T, so both formats carry the same fields as the JSON API.
+type Table[T any] struct {
+	Columns []string
+	Record  func(T) []string
+}
+
+// Writer streams rows of T as CSV or NDJSON. The headers are sent with the first row, so an error
+// before it can still get its own response. Rows are then flushed to the client every flushEvery rows.
+type Writer[T any] struct {
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	contentType string
+	filename    string
+	table       Table[T]
+	csv         *csv.Writer
+	ndjson      *json.Encoder
+	rows        int
+	started     bool
+}
+
+// NewWriter prepares a CSV or NDJSON download named filename
+func NewWriter[T any](w http.ResponseWriter, format, filename string, table Table[T]) (*Writer[T], error) {
+	contentType, ok := contentTypes[format]
+	if !ok || format == FormatJSON {
+		return nil, ErrNotAcceptable
+	}
+
+	ew := &Writer[T]{w: w, rc: http.NewResponseController(w), contentType: contentType, filename: filename + "." + format, table: table}
+	if format == FormatNDJSON {
+		ew.ndjson = json.NewEncoder(w)
+	} else {
+		ew.csv = csv.NewWriter(w)
+	}
+	return ew, nil
+}
+
+// Started reports whether the headers have been sent
+func (ew *Writer[T]) Started() bool {
+	return ew.started
+}
+
+// Write adds one row
+func (ew *Writer[T]) Write(row T) error {
+	if err := ew.start(); err != nil {
+		return err
+	}
+
+	var err error
+	if ew.ndjson != nil {
+		err = ew.ndjson.Encode(row)
+	} else {
+		err = ew.csv.Write(escapeFormulas(ew.table.Record(row)))
+	}
+	if err != nil {
+		return err
+	}
+
+	if ew.rows++; ew.rows%flushEvery == 0 {
+		return ew.flush()
+	}
+	return nil
+}
+
+// Close sends the headers if no row did and flushes the remaining rows
+func (ew *Writer[T]) Close() error {
+	if err := ew.start(); err != nil {
+		return err
+	}
+	return ew.flush()
+}
+
+// Abort ends a started download early: it sets the ErrorTrailer to reason and adds a last row saying so,
+// an "error" object in NDJSON and a row of one "#error: " cell in CSV, so a truncated file does not pass
+// for a complete one
+func (ew *Writer[T]) Abort(reason string) error {
+	ew.w.Header().Set(ErrorTrailer, reason)
+	var err error
+	if ew.ndjson != nil {
+		err = ew.ndjson.Encode(map[string]string{"error": reason})
+	} else {
+		err = ew.csv.Write([]string{"#error: " + reason})
+	}
+	if err != nil {
+		return err
+	}
+	return ew.flush()
+}
+
+// start sends the headers and the CSV header row once
+func (ew *Writer[T]) start() error {
+	if ew.Started() {
+		return nil
+	}
+
+	ew.w.Header().Set("Content-Type", ew.contentType)
+	ew.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": ew.filename}))
+	ew.w.Header().Set("Trailer", ErrorTrailer)
+	ew.w.WriteHeader(http.StatusOK)
+	ew.started = true
+
+	if ew.csv != nil {
+		return ew.csv.Write(ew.table.Columns)
+	}
+	return nil
+}
+
+func (ew *Writer[T]) flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := ew.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// formulaPrefixes start the cells a spreadsheet would evaluate as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormulas prefixes the cells of record that a spreadsheet would evaluate, like a campaign named
+// =HYPERLINK(...), with a single quote, which makes them text
+func escapeFormulas(record []string) []string {
+	for i, cell := range record {
+		if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+			record[i] = "'" + cell
+		}
+	}
+	return record
+}
+
+// UnescapeCell undoes the quote escapeFormulas adds, for reading an exported CSV file back
+func UnescapeCell(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
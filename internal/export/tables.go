@@ -0,0 +1,85 @@
+package export
+
+import (
+	"strconv"
+	"time"
+
+	"learning/internal/entities"
+)
+
+// Campaigns lays out campaigns; an unbounded goal, budget or cpm is an empty cell
+var Campaigns = Table[entities.Campaign]{
+	Columns: []string{"id", "name", "start_time", "end_time", "impression_goal", "budget", "cpm", "status", "completed_at"},
+	Record: func(c entities.Campaign) []string {
+		return []string{
+			c.ID,
+			c.Name,
+			formatTime(c.StartTime),
+			formatOptionalTime(c.EndTime),
+			formatOptionalInt(c.ImpressionGoal),
+			formatOptionalFloat(c.Budget),
+			formatOptionalFloat(c.CPM),
+			c.Status,
+			formatOptionalTime(c.CompletedAt),
+		}
+	},
+}
+
+// Stats lays out the stats of campaigns
+var Stats = Table[entities.Stats]{
+	Columns: []string{"campaign_id", "last_hour", "last_day", "total", "late", "too_old", "invalid", "spend"},
+	Record: func(s entities.Stats) []string {
+		return []string{
+			s.CampaignID,
+			strconv.FormatInt(s.LastHour, 10),
+			strconv.FormatInt(s.LastDay, 10),
+			strconv.FormatInt(s.TotalCount, 10),
+			strconv.FormatInt(s.Late, 10),
+			strconv.FormatInt(s.TooOld, 10),
+			strconv.FormatInt(s.Invalid, 10),
+			formatOptionalFloat(s.Spend),
+		}
+	},
+}
+
+// SeriesPoints lays out the buckets of a time series
+var SeriesPoints = Table[entities.SeriesPoint]{
+	Columns: []string{"start", "count"},
+	Record: func(p entities.SeriesPoint) []string {
+		return []string{formatTime(p.Start), strconv.FormatInt(p.Count, 10)}
+	},
+}
+
+// Impressions lays out raw impressions
+var Impressions = Table[entities.Impression]{
+	Columns: []string{"campaign_id", "timestamp", "user_id", "ad_id"},
+	Record: func(i entities.Impression) []string {
+		return []string{i.CampaignID, formatTime(i.Timestamp), i.UserID, i.AdID}
+	},
+}
+
+// formatTime matches the JSON encoding of times
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+func formatOptionalInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func formatOptionalFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
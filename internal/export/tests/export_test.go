@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/csv"
+	"errors"
+	"learning/internal/entities"
+	"learning/internal/export"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		format string
+		err    error
+	}{
+		{"No Accept", "", "", export.FormatJSON, nil},
+		{"Any", "", "*/*", export.FormatJSON, nil},
+		{"CSV", "", "text/csv", export.FormatCSV, nil},
+		{"Any Text", "", "text/*", export.FormatCSV, nil},
+		{"NDJSON", "", "application/x-ndjson", export.FormatNDJSON, nil},
+		{"Parameters", "", "text/csv; charset=utf-8", export.FormatCSV, nil},
+		{"Header Order", "", "application/x-ndjson, text/csv", export.FormatNDJSON, nil},
+		{"Quality", "", "application/json;q=0.2, text/csv;q=0.8", export.FormatCSV, nil},
+		{"Unsupported Skipped", "", "image/png, text/csv", export.FormatCSV, nil},
+		{"Refused", "", "text/csv;q=0, image/png", "", export.ErrNotAcceptable},
+		{"Query Overrides Accept", "?format=ndjson", "text/csv", export.FormatNDJSON, nil},
+		{"Unknown Query Format", "?format=xml", "", "", export.ErrNotAcceptable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/export"+test.query, nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			format, err := export.Negotiate(req)
+			if format != test.format || !errors.Is(err, test.err) {
+				t.Errorf("❌ Expected %q %v, got %q %v", test.format, test.err, format, err)
+			}
+		})
+	}
+}
+
+func TestIsDownload(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		accept   string
+		download bool
+	}{
+		{"JSON", "GET", "/export", "application/json", false},
+		{"CSV", "GET", "/export", "text/csv", true},
+		{"NDJSON Query", "GET", "/export?format=ndjson", "", true},
+		{"Not Acceptable", "GET", "/export", "image/png", false},
+		{"POST", "POST", "/import?format=csv", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, nil)
+			req.Header.Set("Accept", test.accept)
+			if download := export.IsDownload(req); download != test.download {
+				t.Errorf("❌ Expected %v, got %v", test.download, download)
+			}
+		})
+	}
+}
+
+func TestWriterEscapesFormulasAndReportsAborts(t *testing.T) {
+	resp := httptest.NewRecorder()
+	ew, err := export.NewWriter(resp, export.FormatCSV, "campaigns", export.Campaigns)
+	if err != nil {
+		t.Fatalf("❌ NewWriter failed: %v", err)
+	}
+	for _, name := range []string{"=HYPERLINK(\"http://evil\")", "+1", "@SUM(A1)", "Plain - Name"} {
+		if err := ew.Write(entities.Campaign{ID: "id", Name: name}); err != nil {
+			t.Fatalf("❌ Write failed: %v", err)
+		}
+	}
+	if err := ew.Abort("export ended early: timed out"); err != nil {
+		t.Fatalf("❌ Abort failed: %v", err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(resp.Body.String()))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("❌ Response is not CSV: %v", err)
+	}
+	var names []string
+	for _, record := range records[1 : len(records)-1] {
+		names = append(names, record[1])
+	}
+	if strings.Join(names, "|") != `'=HYPERLINK("http://evil")|'+1|'@SUM(A1)|Plain - Name` {
+		t.Errorf("❌ Expected formulas to be quoted, got %q", names)
+	}
+	if export.UnescapeCell(names[0]) != `=HYPERLINK("http://evil")` || export.UnescapeCell(names[3]) != "Plain - Name" {
+		t.Errorf("❌ Expected UnescapeCell to undo the quote")
+	}
+
+	// The truncation shows in the last row and in the trailer
+	if last := records[len(records)-1]; len(last) != 1 || last[0] != "#error: export ended early: timed out" {
+		t.Errorf("❌ Expected an error row last, got %v", last)
+	}
+	if trailer := resp.Result().Trailer.Get(export.ErrorTrailer); trailer != "export ended early: timed out" {
+		t.Errorf("❌ Expected the %s trailer, got %q", export.ErrorTrailer, trailer)
+	}
+}
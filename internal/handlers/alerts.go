@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"learning/internal/entities"
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+// AlertSource lists the anomalies currently detected
+type AlertSource interface {
+	Alerts() []entities.Alert
+}
+
+// AlertsHandler serves the current alerts; Alerts is nil when anomaly detection is disabled
+type AlertsHandler struct {
+	Alerts AlertSource
+}
+
+func NewAlertsHandler(alerts AlertSource) *AlertsHandler {
+	return &AlertsHandler{Alerts: alerts}
+}
+
+// ListAlertsHandler serves GET /api/v1/alerts, optionally narrowed to one campaign with ?campaign_id=
+func (h *AlertsHandler) ListAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Alerts == nil {
+		utils.JSONError(w, "Anomaly detection is disabled", http.StatusNotImplemented)
+		return
+	}
+
+	campaignID := r.URL.Query().Get("campaign_id")
+	if campaignID != "" {
+		var err error
+		if campaignID, err = validators.ValidateCampaignID(campaignID); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+	}
+
+	alerts := []entities.Alert{}
+	for _, alert := range h.Alerts.Alerts() {
+		if campaignID == "" || alert.CampaignID == campaignID {
+			alerts = append(alerts, alert)
+		}
+	}
+	utils.JSONSuccess(w, alerts, http.StatusOK)
+}
@@ -1,6 +1,12 @@
 package handlers
 
 import (
+	"errors"
+
+	"go.uber.org/zap"
+	"learning/internal/entities"
+	"learning/internal/export"
+	"learning/internal/logger"
 	"learning/internal/repositories"
 	"learning/internal/utils"
 	"learning/internal/validators"
@@ -17,17 +23,65 @@ func NewCampaignHandler(repo repositories.CampaignRepository) *CampaignHandler {
 	return &CampaignHandler{Repo: repo}
 }
 
+// CampaignsHandler serves /api/v1/campaigns: GET lists the campaigns and POST creates one
+func (h *CampaignHandler) CampaignsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.ListCampaignsHandler(w, r)
+	case http.MethodPost:
+		h.CreateCampaignHandler(w, r)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// ListCampaignsHandler lists every campaign in ID order. JSON collects the list, while CSV and NDJSON
+// stream it from the repository.
+func (h *CampaignHandler) ListCampaignsHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	campaigns := []entities.Campaign{}
+	if format == export.FormatJSON {
+		err = h.Repo.ListCampaigns(r.Context(), func(c entities.Campaign) error {
+			campaigns = append(campaigns, c)
+			return nil
+		})
+	} else {
+		err = exportRows(w, r, format, "campaigns", export.Campaigns, func(emit func(entities.Campaign) error) error {
+			return h.Repo.ListCampaigns(r.Context(), emit)
+		})
+	}
+	switch {
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+	case err != nil:
+		logger.FromContext(r.Context()).Error("failed to list campaigns", zap.Error(err))
+		utils.JSONError(w, "Failed to list campaigns", http.StatusInternalServerError)
+	case format == export.FormatJSON:
+		utils.JSONSuccess(w, campaigns, http.StatusOK)
+	}
+}
+
 func (h *CampaignHandler) CreateCampaignHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate input
-	req, err := validators.ValidateCreateCampaign(r)
+	req, err := validators.ValidateCreateCampaign(w, r)
 	if err != nil {
-		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		writeValidationError(w, err)
 		return
 	}
 
 	// Call the repository to create a campaign
-	campaign, err := h.Repo.CreateCampaign(*req)
+	campaign, err := h.Repo.CreateCampaign(r.Context(), *req)
+	if isContextError(err) {
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
+		logger.FromContext(r.Context()).Error("failed to create campaign", zap.Error(err))
 		utils.JSONError(w, "Failed to create campaign", http.StatusInternalServerError)
 		return
 	}
@@ -35,3 +89,72 @@ func (h *CampaignHandler) CreateCampaignHandler(w http.ResponseWriter, r *http.R
 	// Return created campaign
 	utils.JSONSuccess(w, campaign, http.StatusCreated)
 }
+
+// CampaignHandler serves /api/v1/campaigns/{id}: GET reads the campaign and PATCH updates it
+func (h *CampaignHandler) CampaignHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetCampaignHandler(w, r)
+	case http.MethodPatch:
+		h.UpdateCampaignHandler(w, r)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CampaignHandler) GetCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	rawID, _ := campaignSubresource(r)
+	campaignID, err := validators.ValidateCampaignID(rawID)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	campaign, err := h.Repo.GetCampaign(r.Context(), campaignID)
+	if err != nil {
+		writeCampaignError(w, r, err, "get")
+		return
+	}
+	utils.JSONSuccess(w, campaign, http.StatusOK)
+}
+
+// UpdateCampaignHandler renames a campaign or moves its end
+func (h *CampaignHandler) UpdateCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	rawID, _ := campaignSubresource(r)
+	campaignID, req, err := validators.ValidateUpdateCampaign(w, r, rawID)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// The start never changes, so the new end can be checked against it up front
+	campaign, err := h.Repo.GetCampaign(r.Context(), campaignID)
+	if err != nil {
+		writeCampaignError(w, r, err, "update")
+		return
+	}
+	if err := validators.ValidateEndTime(campaign, req); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	campaign, err = h.Repo.UpdateCampaign(r.Context(), campaignID, *req)
+	if err != nil {
+		writeCampaignError(w, r, err, "update")
+		return
+	}
+	utils.JSONSuccess(w, campaign, http.StatusOK)
+}
+
+// writeCampaignError answers a failed campaign read or write; what names the operation in the response
+func writeCampaignError(w http.ResponseWriter, r *http.Request, err error, what string) {
+	switch {
+	case errors.Is(err, repositories.ErrCampaignNotFound):
+		utils.JSONError(w, "campaign not found", http.StatusNotFound)
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+	default:
+		logger.FromContext(r.Context()).Error("failed to "+what+" campaign", zap.Error(err))
+		utils.JSONError(w, "Failed to "+what+" campaign", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// campaignPrefix is the path under which per-campaign resources live
+const campaignPrefix = "/api/v1/campaigns/"
+
+// CampaignSubresources dispatches /api/v1/campaigns/{id}/{name} to the handler registered for name.
+// Like the ServeMux, a name ending in a slash also receives every path below it. The campaign ID stays
+// in the path and is validated by the handler.
+func CampaignSubresources(routes map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, name := campaignSubresource(r)
+		handler, ok := routes[name]
+		if resource, _, nested := strings.Cut(name, "/"); !ok && nested {
+			handler, ok = routes[resource+"/"]
+		}
+		if !ok {
+			NotFoundHandler(w, r)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// campaignSubresource splits the escaped request path into the raw campaign ID and the resource name
+func campaignSubresource(r *http.Request) (rawID, name string) {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), campaignPrefix)
+	rawID, name, _ = strings.Cut(rest, "/")
+	return rawID, name
+}
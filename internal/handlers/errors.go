@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+// writeValidationError maps a validator error to the matching JSON error response
+func writeValidationError(w http.ResponseWriter, err error) {
+	var validationErr *validators.ValidationError
+	switch {
+	case errors.Is(err, validators.ErrBodyTooLarge):
+		utils.JSONError(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &validationErr):
+		utils.JSONFieldErrors(w, validationErr.Message, validationErr.Fields, http.StatusBadRequest)
+	default:
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// isContextError reports whether err comes from a cancelled request or an expired route timeout
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+	"learning/internal/export"
+	"learning/internal/logger"
+	"learning/internal/utils"
+)
+
+// negotiate picks the format of a listing and answers 406 itself when none can be served
+func negotiate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format, err := export.Negotiate(r)
+	if err != nil {
+		utils.JSONError(w, "Supported formats are application/json, text/csv and application/x-ndjson", http.StatusNotAcceptable)
+		return "", false
+	}
+	return format, true
+}
+
+// exportRows streams the rows that produce passes to emit as a CSV or NDJSON download. The status is
+// sent with the first row: an error before it is returned for the handler to answer, while a later one
+// can only end the download early, which is logged and told to the client by export.Writer.Abort.
+func exportRows[T any](w http.ResponseWriter, r *http.Request, format, filename string, table export.Table[T], produce func(emit func(T) error) error) error {
+	ew, err := export.NewWriter(w, format, filename, table)
+	if err != nil {
+		return err
+	}
+	if err = produce(ew.Write); err == nil {
+		err = ew.Close()
+	}
+	if err != nil && ew.Started() {
+		logger.FromContext(r.Context()).Warn("export ended early", zap.String("file", filename), zap.Error(err))
+		reason := "internal error"
+		if isContextError(err) {
+			reason = "timed out"
+		}
+		_ = ew.Abort("export ended early: " + reason)
+		return nil
+	}
+	return err
+}
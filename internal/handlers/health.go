@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/logger"
+	"learning/internal/repositories"
+	"learning/internal/utils"
+)
+
+// HealthHandler reports whether the instance can reach its storage
+type HealthHandler struct {
+	Campaigns repositories.CampaignRepository
+}
+
+func NewHealthHandler(campaigns repositories.CampaignRepository) *HealthHandler {
+	return &HealthHandler{Campaigns: campaigns}
+}
+
+// GetHealthHandler serves GET /health. Looking up a campaign that cannot exist reaches the storage
+// the same way with every backend, so only ErrCampaignNotFound means it is healthy.
+func (h *HealthHandler) GetHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, err := h.Campaigns.GetCampaign(r.Context(), uuid.Nil.String())
+	switch {
+	case err == nil, errors.Is(err, repositories.ErrCampaignNotFound):
+		cfg := config.Current()
+		utils.JSONSuccess(w, entities.Health{Status: "ok", Storage: cfg.Storage.Driver, Ingestion: cfg.Ingestion.Mode}, http.StatusOK)
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+	default:
+		logger.FromContext(r.Context()).Error("storage health check failed", zap.Error(err))
+		utils.JSONError(w, "Storage unavailable", http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"learning/cmd/config"
+	"learning/internal/backfill"
+	"learning/internal/entities"
+	"learning/internal/logger"
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+// ImportHandler backfills historical impressions from uploaded files
+type ImportHandler struct {
+	Importer *backfill.Importer
+}
+
+func NewImportHandler(importer *backfill.Importer) *ImportHandler {
+	return &ImportHandler{Importer: importer}
+}
+
+// ImportImpressionsHandler serves POST /api/v1/imports with a CSV or NDJSON body. A client accepting
+// text/event-stream gets a "progress" event while the file is read and after each campaign is applied,
+// then a "result" event holding the response it would have got otherwise.
+func (h *ImportHandler) ImportImpressionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format, err := validators.ValidateImportFormat(r)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	cfg := config.Current()
+	maxUpload := int64(cfg.Import.MaxUploadMB) << 20
+	log := logger.FromContext(r.Context())
+
+	var events *eventWriter
+	if acceptsEventStream(r) {
+		events = newEventWriter(w)
+	}
+	report, err := h.Importer.Import(r.Context(), http.MaxBytesReader(w, r.Body, maxUpload), backfill.Options{
+		Format:   format,
+		TTL:      time.Duration(cfg.App.TTL) * time.Second,
+		MaxLines: cfg.Import.MaxLines,
+		Progress: func(p entities.ImportProgress) {
+			log.Info("import progress", zap.Int64("lines", p.Lines), zap.Int64("rejected", p.Rejected),
+				zap.Int("campaigns", p.Campaigns), zap.Int("campaigns_applied", p.CampaignsApplied))
+			if events != nil {
+				events.write("progress", p)
+			}
+		},
+	})
+
+	response, status := importResponse(log, cfg, report, err)
+	if events != nil {
+		events.write("result", struct {
+			Status int `json:"status"`
+			utils.APIResponse
+		}{status, response})
+		return
+	}
+	utils.JSONResponse(w, response, status)
+}
+
+// importResponse is the response to an import that ended with err
+func importResponse(log *zap.Logger, cfg *config.Config, report entities.ImportReport, err error) (utils.APIResponse, int) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		log.Info("import finished", zap.String("import_id", report.ImportID), zap.Int64("imported", report.Imported),
+			zap.Int64("duplicates", report.Duplicates), zap.Int64("rejected", report.Rejected))
+		return utils.APIResponse{Success: true, Message: "Request successful", Data: report}, http.StatusOK
+	case errors.As(err, &tooLarge):
+		return utils.APIResponse{Message: fmt.Sprintf("import file is larger than %d MB", cfg.Import.MaxUploadMB)}, http.StatusRequestEntityTooLarge
+	case errors.Is(err, backfill.ErrTooManyLines):
+		return utils.APIResponse{Message: err.Error()}, http.StatusRequestEntityTooLarge
+	case errors.Is(err, backfill.ErrInvalidFile):
+		return utils.APIResponse{Message: err.Error()}, http.StatusBadRequest
+	case isContextError(err):
+		return utils.APIResponse{Message: "Request timed out"}, http.StatusServiceUnavailable
+	default:
+		// Campaigns applied before the failure stay applied, and importing the file again skips them
+		log.Error("failed to import impressions", zap.Error(err), zap.String("import_id", report.ImportID),
+			zap.Int("campaigns_applied", len(report.Campaigns)), zap.Any("campaigns", report.Campaigns))
+		return utils.APIResponse{
+			Message: fmt.Sprintf("Failed to import impressions after applying %d campaigns, import the file again to apply the rest", len(report.Campaigns)),
+		}, http.StatusInternalServerError
+	}
+}
+
+// acceptsEventStream tells whether the Accept header of r lists text/event-stream
+func acceptsEventStream(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange)); err == nil && mediaType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// eventWriter sends Server-Sent Events, writing the headers with the first one. Once a write fails, as
+// when the client went away, the rest are dropped.
+type eventWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+	err     error
+}
+
+func newEventWriter(w http.ResponseWriter) *eventWriter {
+	return &eventWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+func (e *eventWriter) write(event string, v any) {
+	if e.err != nil {
+		return
+	}
+	if !e.started {
+		e.started = true
+		e.w.Header().Set("Content-Type", "text/event-stream")
+		e.w.Header().Set("Cache-Control", "no-cache")
+		e.w.Header().Set("X-Accel-Buffering", "no")
+		e.w.WriteHeader(http.StatusOK)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		e.err = err
+		return
+	}
+	if _, e.err = fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, data); e.err != nil {
+		return
+	}
+	e.err = e.rc.Flush()
+}
@@ -1,17 +1,42 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"go.uber.org/zap"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/logger"
 	"learning/internal/repositories"
+	"learning/internal/traffic"
+	"learning/internal/utils"
 	"learning/internal/validators"
 )
 
-// ImpressionHandler uses a generic repository
+// ImpressionQueue accepts validated impressions for asynchronous processing
+type ImpressionQueue interface {
+	Enqueue(req entities.TrackImpressionRequest) error
+}
+
+// CampaignLookup tells whether a campaign takes impressions, for impressions that are queued before
+// the repository sees them. Check returns repositories.ErrCampaignNotFound or
+// repositories.ErrCampaignCompleted when it does not.
+type CampaignLookup interface {
+	Check(ctx context.Context, campaignID string) error
+}
+
+// ImpressionHandler uses a generic repository, or a queue in front of it in async mode.
+// Impressions flagged by Filter are passed on as invalid so the repository counts them apart.
 type ImpressionHandler struct {
-	Repo repositories.ImpressionRepository
+	Repo      repositories.ImpressionRepository
+	Queue     ImpressionQueue
+	Campaigns CampaignLookup
+	Filter    traffic.Filter
 }
 
 // NewImpressionHandler Constructor function
@@ -19,28 +44,80 @@ func NewImpressionHandler(repo repositories.ImpressionRepository) *ImpressionHan
 	return &ImpressionHandler{Repo: repo}
 }
 
+// NewAsyncImpressionHandler answers 202 once the impression is queued instead of stored, and 404 or 410
+// up front for campaigns that campaigns does not know or knows as completed
+func NewAsyncImpressionHandler(repo repositories.ImpressionRepository, queue ImpressionQueue, campaigns CampaignLookup) *ImpressionHandler {
+	return &ImpressionHandler{Repo: repo, Queue: queue, Campaigns: campaigns}
+}
+
 func (h *ImpressionHandler) TrackImpressionHandler(w http.ResponseWriter, r *http.Request) {
+	// Request-scoped logger injected by the logging middleware
+	log := logger.FromContext(r.Context())
+
 	// Validate request using validators.ValidateTrackImpression
-	req, err := validators.ValidateTrackImpression(r)
+	req, err := validators.ValidateTrackImpression(w, r)
 	if err != nil {
-		log.Printf("❌ Request validation failed: %v", err)
-		http.Error(w, "Request validation failed: "+err.Error(), http.StatusBadRequest)
+		log.Info("request validation failed", zap.Error(err))
+		writeValidationError(w, err)
+		return
+	}
+
+	req.UserAgent = r.UserAgent()
+	req.IP = traffic.ClientIP(r, config.Current().Traffic.ForwardedHops())
+	if h.Filter != nil {
+		if req.InvalidReason = h.Filter.Check(*req); req.InvalidReason != "" {
+			log.Info("impression flagged as invalid traffic",
+				zap.String("reason", req.InvalidReason),
+				zap.String("user_agent", req.UserAgent),
+				zap.String("ip", req.IP),
+			)
+		}
+	}
+
+	if h.Queue != nil {
+		err := h.Campaigns.Check(r.Context(), req.CampaignID)
+		switch {
+		case isContextError(err):
+			utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+			return
+		case errors.Is(err, repositories.ErrCampaignNotFound):
+			log.Info("impression not queued", zap.Error(err))
+			utils.JSONError(w, "Impression set failed: "+repositories.ErrCampaignNotFound.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, repositories.ErrCampaignCompleted):
+			log.Info("impression not queued", zap.Error(err))
+			utils.JSONError(w, "Impression set failed: "+repositories.ErrCampaignCompleted.Error(), http.StatusGone)
+			return
+		case err != nil:
+			log.Error("failed to look up campaign", zap.Error(err))
+			utils.JSONError(w, "Failed to look up campaign", http.StatusInternalServerError)
+			return
+		}
+
+		// Stamp the receipt time so time spent in the queue does not shift the impression's bucket
+		if req.Timestamp == nil {
+			receivedAt := time.Now()
+			req.Timestamp = &receivedAt
+		}
+		if err := h.Queue.Enqueue(*req); err != nil {
+			log.Warn("impression not queued", zap.Error(err))
+			retryAfter := math.Ceil(config.Current().Ingestion.RetryAfter.Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+			utils.JSONError(w, "Impression queue is full, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		utils.JSONMessage(w, "Impression accepted for processing", http.StatusAccepted)
 		return
 	}
 
 	// Call the repository method to track the impression
-	err, status := h.Repo.TrackImpression(*req)
+	err, status := h.Repo.TrackImpression(r.Context(), *req)
 	if err != nil {
-		log.Printf("❌ Impression set failed: %v", err)
-		http.Error(w, "Impression set failed: "+err.Error(), status)
+		log.Info("impression not saved", zap.Error(err), zap.Int("status", status))
+		utils.JSONError(w, "Impression set failed: "+err.Error(), status)
 		return
 	}
 
 	// Send success response
-	response := map[string]string{"message": "Impression saved successfully"}
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("❌ Response encoding failed: %v", err)
-		http.Error(w, "Response failed: "+err.Error(), http.StatusInternalServerError)
-	}
+	utils.JSONMessage(w, "Impression saved successfully", http.StatusOK)
 }
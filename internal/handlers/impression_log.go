@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+	"learning/internal/entities"
+	"learning/internal/eventlog"
+	"learning/internal/export"
+	"learning/internal/logger"
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+// ImpressionReader lists raw impressions from the event log, a page at a time or all at once
+type ImpressionReader interface {
+	Query(ctx context.Context, q entities.ImpressionQuery) (entities.ImpressionPage, error)
+	Each(ctx context.Context, q entities.ImpressionQuery, fn func(entities.Impression) error) error
+}
+
+// ImpressionLogHandler serves the raw impression log; Events is nil when the log is disabled
+type ImpressionLogHandler struct {
+	Events ImpressionReader
+}
+
+func NewImpressionLogHandler(events ImpressionReader) *ImpressionLogHandler {
+	return &ImpressionLogHandler{Events: events}
+}
+
+// ListImpressionsHandler serves GET /api/v1/campaigns/{id}/impressions. JSON returns one page, while
+// CSV and NDJSON stream every matching impression from the cursor on in one pass over the log.
+func (h *ImpressionLogHandler) ListImpressionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Events == nil {
+		utils.JSONError(w, "Impression event log is disabled", http.StatusNotImplemented)
+		return
+	}
+
+	rawID, _ := campaignSubresource(r)
+	query, err := validators.ValidateImpressionQuery(rawID, r.URL.Query())
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	format, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+
+	var page entities.ImpressionPage
+	if format == export.FormatJSON {
+		page, err = h.Events.Query(r.Context(), *query)
+	} else {
+		err = exportRows(w, r, format, "impressions-"+query.CampaignID, export.Impressions, func(emit func(entities.Impression) error) error {
+			return h.Events.Each(r.Context(), *query, emit)
+		})
+	}
+	switch {
+	case errors.Is(err, eventlog.ErrInvalidCursor):
+		utils.JSONFieldErrors(w, "invalid query parameters", []validators.FieldError{{Field: "cursor", Message: "is not a cursor returned by this endpoint"}}, http.StatusBadRequest)
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+	case err != nil:
+		logger.FromContext(r.Context()).Error("failed to query impression log", zap.Error(err))
+		utils.JSONError(w, "Failed to query impressions", http.StatusInternalServerError)
+	case format == export.FormatJSON:
+		utils.JSONSuccess(w, page, http.StatusOK)
+	}
+}
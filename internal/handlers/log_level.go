@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+	"learning/cmd/config"
+	"learning/internal/logger"
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// LogLevelHandler reports the current log level on GET and changes it on PUT, in the logger and the
+// live config
+func LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		utils.JSONSuccess(w, logLevel{Level: logger.Level().String()}, http.StatusOK)
+	case http.MethodPut:
+		var req logLevel
+		r.Body = http.MaxBytesReader(w, r.Body, validators.MaxBodyBytes)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.JSONError(w, "invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		// The live config holds the level too, so a reload of an unchanged file keeps it. Both change
+		// under the config lock, so a concurrent reload sees either none or both.
+		err := config.Update(func(cfg *config.Config) error {
+			if err := logger.SetLevel(req.Level); err != nil {
+				return err
+			}
+			cfg.Logging.Level = logger.Level().String()
+			return nil
+		})
+		if err != nil {
+			utils.JSONFieldErrors(w, "validation failed", []validators.FieldError{{Field: "level", Message: err.Error()}}, http.StatusBadRequest)
+			return
+		}
+
+		logger.FromContext(r.Context()).Warn("log level changed", zap.String("level", logger.Level().String()))
+		utils.JSONSuccess(w, logLevel{Level: logger.Level().String()}, http.StatusOK)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"learning/internal/entities"
+	"learning/internal/logger"
+	"learning/internal/pacing"
+	"learning/internal/repositories"
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+// PacingHandler reports the delivery of campaigns against their flight
+type PacingHandler struct {
+	Campaigns repositories.CampaignRepository
+	Stats     repositories.StatsRepository
+}
+
+func NewPacingHandler(campaigns repositories.CampaignRepository, stats repositories.StatsRepository) *PacingHandler {
+	return &PacingHandler{Campaigns: campaigns, Stats: stats}
+}
+
+// GetPacingHandler serves GET /api/v1/campaigns/{id}/pacing?curve=even|front_loaded
+func (h *PacingHandler) GetPacingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rawID, _ := campaignSubresource(r)
+	query, err := validators.ValidatePacingQuery(rawID, r.URL.Query())
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	report, err := h.report(r.Context(), *query)
+	switch {
+	case err == nil:
+		utils.JSONSuccess(w, report, http.StatusOK)
+	case errors.Is(err, repositories.ErrCampaignNotFound):
+		utils.JSONError(w, "campaign not found", http.StatusNotFound)
+	case errors.Is(err, pacing.ErrNoEndTime), errors.Is(err, pacing.ErrNoGoal):
+		utils.JSONError(w, "Cannot pace campaign: "+err.Error(), http.StatusUnprocessableEntity)
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+	default:
+		logger.FromContext(r.Context()).Error("failed to compute campaign pacing", zap.Error(err))
+		utils.JSONError(w, "Failed to compute campaign pacing", http.StatusInternalServerError)
+	}
+}
+
+func (h *PacingHandler) report(ctx context.Context, query entities.PacingQuery) (entities.Pacing, error) {
+	campaign, err := h.Campaigns.GetCampaign(ctx, query.CampaignID)
+	if err != nil {
+		return entities.Pacing{}, err
+	}
+	stats, err := h.Stats.GetCampaignStats(ctx, query.CampaignID)
+	if err != nil {
+		return entities.Pacing{}, err
+	}
+	return pacing.Compute(campaign, stats, query.Curve, time.Now())
+}
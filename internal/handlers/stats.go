@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"go.uber.org/zap"
+	"learning/internal/entities"
+	"learning/internal/export"
+	"learning/internal/logger"
 	"learning/internal/repositories"
 	"learning/internal/utils"
+	"learning/internal/validators"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type StatsHandler struct {
@@ -22,19 +29,80 @@ func (h *StatsHandler) GetCampaignStatsHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	// Extract campaign ID from URL
-	campaignID := strings.TrimPrefix(r.URL.Path, "/api/v1/campaigns/stats/")
-	if campaignID == "" || campaignID == "/api/v1/campaigns/stats" {
-		utils.JSONError(w, "invalid campaign ID", http.StatusBadRequest)
+	campaignID, err := validators.ValidateCampaignID(strings.TrimPrefix(r.URL.EscapedPath(), "/api/v1/campaigns/stats/"))
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	format, ok := negotiate(w, r)
+	if !ok {
 		return
 	}
 
 	// Fetch stats
-	stats, exists := h.Repo.GetCampaignStats(campaignID)
-	if !exists {
-		utils.JSONError(w, "campaign not found", http.StatusNotFound)
+	stats, err := h.Repo.GetCampaignStats(r.Context(), campaignID)
+	if err != nil {
+		writeStatsError(w, r, err, "campaign stats")
+		return
+	}
+
+	// Return stats in the negotiated format
+	if format == export.FormatJSON {
+		utils.JSONSuccess(w, stats, http.StatusOK)
+		return
+	}
+	_ = exportRows(w, r, format, "stats-"+campaignID, export.Stats, func(emit func(entities.Stats) error) error {
+		return emit(stats)
+	})
+}
+
+// GetCampaignSeriesHandler serves GET /api/v1/campaigns/{id}/series?resolution=minute|hour&from=&to=
+func (h *StatsHandler) GetCampaignSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rawID, _ := campaignSubresource(r)
+	query, err := validators.ValidateSeriesQuery(rawID, r.URL.Query(), time.Now().UTC())
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	format, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+
+	points, err := h.Repo.GetCampaignSeries(r.Context(), *query)
+	if err != nil {
+		writeStatsError(w, r, err, "campaign series")
 		return
 	}
 
-	// Return stats as JSON response
-	utils.JSONSuccess(w, stats, http.StatusOK)
+	if format == export.FormatJSON {
+		utils.JSONSuccess(w, entities.Series{CampaignID: query.CampaignID, Resolution: query.Resolution, Points: points}, http.StatusOK)
+		return
+	}
+	_ = exportRows(w, r, format, "series-"+query.CampaignID, export.SeriesPoints, func(emit func(entities.SeriesPoint) error) error {
+		for _, p := range points {
+			if err := emit(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeStatsError answers a failed read of what
+func writeStatsError(w http.ResponseWriter, r *http.Request, err error, what string) {
+	switch {
+	case errors.Is(err, repositories.ErrCampaignNotFound):
+		utils.JSONError(w, "campaign not found", http.StatusNotFound)
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+	default:
+		logger.FromContext(r.Context()).Error("failed to fetch "+what, zap.Error(err))
+		utils.JSONError(w, "Failed to fetch "+what, http.StatusInternalServerError)
+	}
 }
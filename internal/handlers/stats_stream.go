@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/logger"
+	"learning/internal/repositories"
+	"learning/internal/stream"
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+// StatsStreamer fans campaign stats out to open streams
+type StatsStreamer interface {
+	Subscribe(campaignID string) (*stream.Subscription, error)
+	Unsubscribe(s *stream.Subscription)
+	Close()
+}
+
+// StatsStreamHandler pushes the stats of a campaign over Server-Sent Events
+type StatsStreamHandler struct {
+	Repo repositories.StatsRepository
+	Hub  StatsStreamer
+
+	shutdownOnce sync.Once
+}
+
+func NewStatsStreamHandler(repo repositories.StatsRepository, hub StatsStreamer) *StatsStreamHandler {
+	return &StatsStreamHandler{Repo: repo, Hub: hub}
+}
+
+// StreamStatsHandler serves GET /api/v1/campaigns/{id}/stats/stream: a "stats" event with the current
+// stats right away and another one whenever they change, plus a comment every stream.keep_alive
+func (h *StatsStreamHandler) StreamStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rawID, _ := campaignSubresource(r)
+	campaignID, err := validators.ValidateCampaignID(rawID)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Open streams would hold up a graceful shutdown, so they end as soon as it starts
+	if srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server); ok {
+		h.shutdownOnce.Do(func() { srv.RegisterOnShutdown(h.Hub.Close) })
+	}
+
+	// Subscribe before reading the current stats, so no change in between is missed
+	subscription, err := h.Hub.Subscribe(campaignID)
+	if err != nil {
+		if !errors.Is(err, stream.ErrTooManySubscribers) && !errors.Is(err, stream.ErrClosed) {
+			logger.FromContext(r.Context()).Error("failed to open stats stream", zap.Error(err))
+		}
+		utils.JSONError(w, "Too many open streams, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.Hub.Unsubscribe(subscription)
+
+	stats, err := h.Repo.GetCampaignStats(r.Context(), campaignID)
+	switch {
+	case errors.Is(err, repositories.ErrCampaignNotFound):
+		utils.JSONError(w, "campaign not found", http.StatusNotFound)
+		return
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		logger.FromContext(r.Context()).Error("failed to fetch campaign stats", zap.Error(err))
+		utils.JSONError(w, "Failed to fetch campaign stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep reverse proxies such as nginx from buffering the events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := writeStatsEvent(w, rc, stats); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(config.Current().Stream.KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case stats, ok := <-subscription.Updates():
+			if !ok {
+				return
+			}
+			if err := writeStatsEvent(w, rc, stats); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeStatsEvent(w http.ResponseWriter, rc *http.ResponseController, stats entities.Stats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: stats\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"learning/internal/entities"
+	"learning/internal/logger"
+	"learning/internal/repositories"
+	"learning/internal/utils"
+	"learning/internal/validators"
+	"learning/internal/webhooks"
+)
+
+// WebhookRegistry manages the webhook subscriptions of the campaigns
+type WebhookRegistry interface {
+	Subscribe(ctx context.Context, campaignID string, req entities.CreateWebhookRequest) (entities.WebhookSubscription, error)
+	Subscriptions(ctx context.Context, campaignID string) ([]entities.WebhookSubscription, error)
+	// Unsubscribe returns repositories.ErrSubscriptionNotFound when the campaign has no such subscription
+	Unsubscribe(ctx context.Context, campaignID, id string) error
+	DeadLetters(ctx context.Context, campaignID string) ([]entities.DeadLetter, error)
+}
+
+// WebhookHandler serves the webhook subscriptions of a campaign; Webhooks is nil when webhooks are disabled
+type WebhookHandler struct {
+	Webhooks WebhookRegistry
+}
+
+func NewWebhookHandler(webhooks WebhookRegistry) *WebhookHandler {
+	return &WebhookHandler{Webhooks: webhooks}
+}
+
+// WebhooksHandler serves /api/v1/campaigns/{id}/webhooks, /webhooks/{subscription_id} and /webhooks/dead-letters
+func (h *WebhookHandler) WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if h.Webhooks == nil {
+		utils.JSONError(w, "Webhooks are disabled", http.StatusNotImplemented)
+		return
+	}
+
+	rawID, name := campaignSubresource(r)
+	campaignID, err := validators.ValidateCampaignID(rawID)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	switch _, sub, _ := strings.Cut(name, "/"); {
+	case sub == "":
+		h.subscriptions(w, r, campaignID)
+	case sub == "dead-letters":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		letters, err := h.Webhooks.DeadLetters(r.Context(), campaignID)
+		if err != nil {
+			writeWebhookError(w, r, err, "read dead letters")
+			return
+		}
+		utils.JSONSuccess(w, letters, http.StatusOK)
+	default:
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := uuid.Parse(sub); err != nil {
+			utils.JSONError(w, "webhook subscription not found", http.StatusNotFound)
+			return
+		}
+		if err := h.Webhooks.Unsubscribe(r.Context(), campaignID, sub); err != nil {
+			writeWebhookError(w, r, err, "delete webhook subscription")
+			return
+		}
+		utils.JSONMessage(w, "Webhook subscription deleted", http.StatusOK)
+	}
+}
+
+func (h *WebhookHandler) subscriptions(w http.ResponseWriter, r *http.Request, campaignID string) {
+	switch r.Method {
+	case http.MethodGet:
+		subscriptions, err := h.Webhooks.Subscriptions(r.Context(), campaignID)
+		if err != nil {
+			writeWebhookError(w, r, err, "read webhook subscriptions")
+			return
+		}
+		utils.JSONSuccess(w, subscriptions, http.StatusOK)
+	case http.MethodPost:
+		req, err := validators.ValidateCreateWebhook(w, r)
+		if err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		subscription, err := h.Webhooks.Subscribe(r.Context(), campaignID, *req)
+		switch {
+		case errors.Is(err, repositories.ErrCampaignNotFound):
+			utils.JSONError(w, "campaign not found", http.StatusNotFound)
+			return
+		case errors.Is(err, webhooks.ErrTargetNotAllowed):
+			utils.JSONFieldErrors(w, "validation failed", []validators.FieldError{{Field: "url", Message: err.Error()}}, http.StatusBadRequest)
+			return
+		case isContextError(err):
+			utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			logger.FromContext(r.Context()).Error("failed to create webhook subscription", zap.Error(err))
+			utils.JSONError(w, "Failed to create webhook subscription", http.StatusInternalServerError)
+			return
+		}
+		utils.JSONSuccess(w, subscription, http.StatusCreated)
+	default:
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeWebhookError answers a failed attempt to do what with the stored webhooks
+func writeWebhookError(w http.ResponseWriter, r *http.Request, err error, what string) {
+	switch {
+	case errors.Is(err, repositories.ErrSubscriptionNotFound):
+		utils.JSONError(w, "webhook subscription not found", http.StatusNotFound)
+	case isContextError(err):
+		utils.JSONError(w, "Request timed out", http.StatusServiceUnavailable)
+	default:
+		logger.FromContext(r.Context()).Error("failed to "+what, zap.Error(err))
+		utils.JSONError(w, "Failed to "+what, http.StatusInternalServerError)
+	}
+}
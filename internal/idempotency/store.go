@@ -0,0 +1,98 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is the stored result of the first request made with a key
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Entry is what the store knows about a key; Response is nil while the first request is still running
+type Entry struct {
+	Fingerprint string
+	Response    *Response
+}
+
+// Store remembers responses by idempotency key
+type Store interface {
+	// Begin reserves key for a request with fingerprint until ttl passes. If the key is already
+	// known, the existing entry is returned and nothing is reserved.
+	Begin(key, fingerprint string, ttl time.Duration) (existing *Entry, reserved bool)
+	// Complete stores resp as the answer for a reserved key
+	Complete(key string, resp Response)
+	// Abort releases a reserved key so the request can be retried
+	Abort(key string)
+}
+
+type memoryEntry struct {
+	Entry
+	expires time.Time
+}
+
+// MemoryStore keeps entries in process memory, so retries must reach the same replica
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+func (s *MemoryStore) Begin(key, fingerprint string, ttl time.Duration) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		existing := e.Entry
+		return &existing, false
+	}
+
+	s.entries[key] = &memoryEntry{Entry: Entry{Fingerprint: fingerprint}, expires: now.Add(ttl)}
+	return nil, true
+}
+
+func (s *MemoryStore) Complete(key string, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.Response = &resp
+	}
+}
+
+func (s *MemoryStore) Abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.Response == nil {
+		delete(s.entries, key)
+	}
+}
+
+// PurgeExpired drops entries past their ttl and returns how many were removed
+func (s *MemoryStore) PurgeExpired(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	purged := 0
+	for key, e := range s.entries {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
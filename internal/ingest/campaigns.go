@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+// maxKnownCampaigns caps the cached campaigns; when it is reached the cache starts over
+const maxKnownCampaigns = 100_000
+
+// knownCampaign is a cached lookup; completed campaigns never become active again, so they do not expire
+type knownCampaign struct {
+	expiry    time.Time
+	completed bool
+}
+
+// KnownCampaigns tells the async handler whether a campaign takes impressions before one is queued, so
+// unknown and completed campaigns get the 404 and 410 they get in sync mode. Campaigns found are
+// remembered, active ones for ttl and completed ones for good; unknown ones are looked up every time, so
+// a campaign is accepted as soon as it was created.
+type KnownCampaigns struct {
+	repo repositories.CampaignRepository
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	known map[string]knownCampaign
+}
+
+func NewKnownCampaigns(repo repositories.CampaignRepository, ttl time.Duration) *KnownCampaigns {
+	return &KnownCampaigns{repo: repo, ttl: ttl, known: make(map[string]knownCampaign)}
+}
+
+// Check returns repositories.ErrCampaignNotFound or repositories.ErrCampaignCompleted when campaignID
+// takes no impressions, nil when it does and the lookup error otherwise
+func (k *KnownCampaigns) Check(ctx context.Context, campaignID string) error {
+	now := time.Now()
+	k.mu.Lock()
+	cached, ok := k.known[campaignID]
+	k.mu.Unlock()
+	switch {
+	case ok && cached.completed:
+		return repositories.ErrCampaignCompleted
+	case ok && now.Before(cached.expiry):
+		return nil
+	}
+
+	campaign, err := k.repo.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+	completed := campaign.Status == entities.CampaignCompleted
+	k.remember(campaignID, knownCampaign{expiry: now.Add(k.ttl), completed: completed})
+	if completed {
+		return repositories.ErrCampaignCompleted
+	}
+	return nil
+}
+
+// Completed records that campaignID completed, e.g. when a queued impression was refused for it, so
+// further impressions are answered 410 without waiting for the cached lookup to expire
+func (k *KnownCampaigns) Completed(campaignID string) {
+	k.remember(campaignID, knownCampaign{completed: true})
+}
+
+func (k *KnownCampaigns) remember(campaignID string, campaign knownCampaign) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.known[campaignID]; !ok && len(k.known) >= maxKnownCampaigns {
+		k.known = make(map[string]knownCampaign)
+	}
+	k.known[campaignID] = campaign
+}
@@ -0,0 +1,242 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ErrQueueFull is returned by Enqueue when the buffer has no free slot
+var ErrQueueFull = errors.New("impression queue is full")
+
+// ErrQueueClosed is returned by Enqueue once Close was called
+var ErrQueueClosed = errors.New("impression queue is closed")
+
+// Options sizes the queue and its workers
+type Options struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	// ApplyTimeout bounds how long a single batch may take against the repository
+	ApplyTimeout time.Duration
+}
+
+// Stats is a point-in-time snapshot of the queue counters
+type Stats struct {
+	Depth     int
+	Capacity  int
+	Enqueued  uint64
+	Rejected  uint64
+	Processed uint64
+	Failed    uint64
+}
+
+// Queue buffers validated impressions and applies them to the repository in batches
+type Queue struct {
+	repo   repositories.ImpressionRepository
+	opts   Options
+	logger *zap.Logger
+
+	items  chan entities.TrackImpressionRequest
+	mu     sync.RWMutex // guards closed against concurrent sends on items
+	closed bool
+	wg     sync.WaitGroup
+
+	enqueued  atomic.Uint64
+	rejected  atomic.Uint64
+	processed atomic.Uint64
+	failed    atomic.Uint64
+
+	onCompleted func(campaignID string)
+}
+
+// NewQueue creates a queue in front of repo. Call Start to launch the workers.
+func NewQueue(repo repositories.ImpressionRepository, opts Options, logger *zap.Logger) *Queue {
+	return &Queue{
+		repo:   repo,
+		opts:   opts,
+		logger: logger,
+		items:  make(chan entities.TrackImpressionRequest, opts.QueueSize),
+	}
+}
+
+// OnCampaignCompleted registers fn to be called with the campaign of every queued impression refused
+// because its campaign completed. Call it before Start.
+func (q *Queue) OnCampaignCompleted(fn func(campaignID string)) {
+	q.onCompleted = fn
+}
+
+// Start launches the worker goroutines
+func (q *Queue) Start() {
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// Enqueue hands req to the workers without blocking
+func (q *Queue) Enqueue(req entities.TrackImpressionRequest) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.items <- req:
+		q.enqueued.Add(1)
+		return nil
+	default:
+		q.rejected.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting impressions and waits until the workers drained the buffer or ctx ends
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current queue counters
+func (q *Queue) Stats() Stats {
+	return Stats{
+		Depth:     len(q.items),
+		Capacity:  cap(q.items),
+		Enqueued:  q.enqueued.Load(),
+		Rejected:  q.rejected.Load(),
+		Processed: q.processed.Load(),
+		Failed:    q.failed.Load(),
+	}
+}
+
+// Register exposes the queue counters on reg
+func (q *Queue) Register(reg prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "impression_queue_depth",
+			Help: "Impressions waiting in the ingestion queue.",
+		}, func() float64 { return float64(len(q.items)) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "impression_queue_capacity",
+			Help: "Size of the ingestion queue buffer.",
+		}, func() float64 { return float64(cap(q.items)) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "impression_queue_enqueued_total",
+			Help: "Impressions accepted into the ingestion queue.",
+		}, func() float64 { return float64(q.enqueued.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "impression_queue_rejected_total",
+			Help: "Impressions refused because the ingestion queue was full.",
+		}, func() float64 { return float64(q.rejected.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "impression_queue_processed_total",
+			Help: "Queued impressions applied to the repository, duplicates included.",
+		}, func() float64 { return float64(q.processed.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "impression_queue_failed_total",
+			Help: "Queued impressions the repository could not apply.",
+		}, func() float64 { return float64(q.failed.Load()) }),
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// work collects up to BatchSize impressions, waiting at most FlushInterval after the first one
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	batch := make([]entities.TrackImpressionRequest, 0, q.opts.BatchSize)
+	timer := time.NewTimer(q.opts.FlushInterval)
+	timer.Stop()
+
+	for {
+		select {
+		case req, ok := <-q.items:
+			if !ok {
+				q.apply(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(q.opts.FlushInterval)
+			}
+			batch = append(batch, req)
+			if len(batch) < q.opts.BatchSize {
+				continue
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+
+		q.apply(batch)
+		batch = batch[:0]
+	}
+}
+
+// apply writes batch through the batch interface when the backend has one
+func (q *Queue) apply(batch []entities.TrackImpressionRequest) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.opts.ApplyTimeout)
+	defer cancel()
+
+	var errs []error
+	if batcher, ok := q.repo.(repositories.BatchImpressionRepository); ok {
+		errs = batcher.TrackImpressions(ctx, batch)
+	} else {
+		errs = make([]error, len(batch))
+		for i, req := range batch {
+			errs[i], _ = q.repo.TrackImpression(ctx, req)
+		}
+	}
+
+	for i, err := range errs {
+		if errors.Is(err, repositories.ErrCampaignCompleted) && q.onCompleted != nil {
+			q.onCompleted(batch[i].CampaignID)
+		}
+		if err == nil || errors.Is(err, repositories.ErrDuplicateImpression) || errors.Is(err, repositories.ErrInvalidTraffic) ||
+			errors.Is(err, repositories.ErrCampaignCompleted) {
+			q.processed.Add(1)
+			continue
+		}
+		q.failed.Add(1)
+		q.logger.Warn("Queued impression failed",
+			zap.String("campaign_id", batch[i].CampaignID),
+			zap.String("user_id", batch[i].UserID),
+			zap.Error(err),
+		)
+	}
+}
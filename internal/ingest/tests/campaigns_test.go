@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"learning/internal/entities"
+	"learning/internal/ingest"
+	"learning/internal/repositories"
+	"learning/internal/repositories/memory"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// countingCampaigns counts the campaign lookups reaching the repository
+type countingCampaigns struct {
+	repositories.CampaignRepository
+	lookups int
+}
+
+func (c *countingCampaigns) GetCampaign(ctx context.Context, id string) (entities.Campaign, error) {
+	c.lookups++
+	return c.CampaignRepository.GetCampaign(ctx, id)
+}
+
+func TestKnownCampaignsRemembersOnlyExistingCampaigns(t *testing.T) {
+	ctx := context.Background()
+	memServer, campaignID := newCampaign(t)
+	repo := &countingCampaigns{CampaignRepository: memory.NewInMemoryCampaignRepository(memServer)}
+	known := ingest.NewKnownCampaigns(repo, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if err := known.Check(ctx, campaignID); err != nil {
+			t.Fatalf("❌ Expected the campaign to take impressions, got %v", err)
+		}
+	}
+	if repo.lookups != 1 {
+		t.Errorf("❌ Expected a known campaign to be looked up once, got %d lookups", repo.lookups)
+	}
+
+	// Unknown campaigns are not remembered, so they are found once created
+	unknown := uuid.NewString()
+	if err := known.Check(ctx, unknown); !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Fatalf("❌ Expected an unknown campaign, got %v", err)
+	}
+	created, _ := repo.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Late Campaign", StartTime: time.Now()})
+	if err := known.Check(ctx, created.ID); err != nil {
+		t.Errorf("❌ Expected a new campaign to take impressions right away, got %v", err)
+	}
+
+	// Once the TTL passed the campaign is looked up again
+	known = ingest.NewKnownCampaigns(repo, time.Nanosecond)
+	repo.lookups = 0
+	_ = known.Check(ctx, campaignID)
+	time.Sleep(time.Millisecond)
+	_ = known.Check(ctx, campaignID)
+	if repo.lookups != 2 {
+		t.Errorf("❌ Expected an expired campaign to be looked up again, got %d lookups", repo.lookups)
+	}
+}
+
+func TestKnownCampaignsRemembersCompletedCampaigns(t *testing.T) {
+	ctx := context.Background()
+	memServer := memory.NewServer()
+	repo := &countingCampaigns{CampaignRepository: memory.NewInMemoryCampaignRepository(memServer)}
+	impressions := memory.NewInMemoryImpressionRepository(memServer)
+	campaign, _ := repo.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Goal", StartTime: time.Now().Add(-time.Hour), ImpressionGoal: 1})
+	if err, _ := impressions.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "u", AdID: "a"}); err != nil {
+		t.Fatalf("❌ Failed to track impression: %v", err)
+	}
+
+	// Completion is final, so it outlives the TTL
+	known := ingest.NewKnownCampaigns(repo, time.Nanosecond)
+	for i := 0; i < 3; i++ {
+		if err := known.Check(ctx, campaign.ID); !errors.Is(err, repositories.ErrCampaignCompleted) {
+			t.Fatalf("❌ Expected a completed campaign, got %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if repo.lookups != 1 {
+		t.Errorf("❌ Expected a completed campaign to be looked up once, got %d lookups", repo.lookups)
+	}
+
+	// A campaign reported completed is refused before its cached lookup expires
+	active, _ := repo.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Active", StartTime: time.Now()})
+	known = ingest.NewKnownCampaigns(repo, time.Hour)
+	if err := known.Check(ctx, active.ID); err != nil {
+		t.Fatalf("❌ Expected the campaign to take impressions, got %v", err)
+	}
+	known.Completed(active.ID)
+	if err := known.Check(ctx, active.ID); !errors.Is(err, repositories.ErrCampaignCompleted) {
+		t.Errorf("❌ Expected the reported completion to be used, got %v", err)
+	}
+}
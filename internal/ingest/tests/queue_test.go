@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learning/internal/entities"
+	"learning/internal/ingest"
+	"learning/internal/repositories/memory"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+var defaultOptions = ingest.Options{
+	QueueSize:     100,
+	Workers:       2,
+	BatchSize:     10,
+	FlushInterval: 10 * time.Millisecond,
+	ApplyTimeout:  time.Second,
+}
+
+// newCampaign creates a campaign in a fresh in-memory store
+func newCampaign(t *testing.T) (*entities.Server, string) {
+	memServer := memory.NewServer()
+	campaign, err := memory.NewInMemoryCampaignRepository(memServer).CreateCampaign(context.Background(), entities.CreateCampaignRequest{
+		Name:      "Queued Campaign",
+		StartTime: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("❌ Failed to create campaign: %v", err)
+	}
+	return memServer, campaign.ID
+}
+
+func impression(campaignID string, user int) entities.TrackImpressionRequest {
+	return entities.TrackImpressionRequest{CampaignID: campaignID, UserID: fmt.Sprintf("user%d", user), AdID: "ad1"}
+}
+
+// sequentialRepo hides the batch method so the queue falls back to single writes
+type sequentialRepo struct {
+	inner *memory.InMemoryImpressionRepository
+	mu    sync.Mutex
+	calls int
+}
+
+func (r *sequentialRepo) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+	return r.inner.TrackImpression(ctx, req)
+}
+
+func TestQueueAppliesEveryImpressionOnClose(t *testing.T) {
+	memServer, campaignID := newCampaign(t)
+	queue := ingest.NewQueue(memory.NewInMemoryImpressionRepository(memServer), defaultOptions, zap.NewNop())
+	queue.Start()
+
+	for i := 0; i < 50; i++ {
+		if err := queue.Enqueue(impression(campaignID, i%40)); err != nil {
+			t.Fatalf("❌ Enqueue failed: %v", err)
+		}
+	}
+	if err := queue.Close(context.Background()); err != nil {
+		t.Fatalf("❌ Close failed: %v", err)
+	}
+
+	stats, _ := memory.NewInMemoryStatsRepository(memServer).GetCampaignStats(context.Background(), campaignID)
+	if stats.TotalCount != 40 {
+		t.Errorf("❌ Expected 40 unique impressions, got %d", stats.TotalCount)
+	}
+	if s := queue.Stats(); s.Enqueued != 50 || s.Processed != 50 || s.Failed != 0 || s.Depth != 0 {
+		t.Errorf("❌ Unexpected queue stats %+v", s)
+	}
+	if err := queue.Enqueue(impression(campaignID, 1)); !errors.Is(err, ingest.ErrQueueClosed) {
+		t.Errorf("❌ Expected ErrQueueClosed after Close, got %v", err)
+	}
+}
+
+func TestQueueFlushesPartialBatch(t *testing.T) {
+	memServer, campaignID := newCampaign(t)
+	opts := defaultOptions
+	opts.BatchSize = 1000
+	queue := ingest.NewQueue(memory.NewInMemoryImpressionRepository(memServer), opts, zap.NewNop())
+	queue.Start()
+	defer func() { _ = queue.Close(context.Background()) }()
+
+	_ = queue.Enqueue(impression(campaignID, 1))
+
+	// The batch never fills, so only the flush interval gets it applied
+	deadline := time.Now().Add(2 * time.Second)
+	for queue.Stats().Processed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("❌ Partial batch was not flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueueRejectsWhenFull(t *testing.T) {
+	memServer, campaignID := newCampaign(t)
+	opts := defaultOptions
+	opts.QueueSize = 3
+	// Workers are not started, so nothing drains the buffer
+	queue := ingest.NewQueue(memory.NewInMemoryImpressionRepository(memServer), opts, zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		if err := queue.Enqueue(impression(campaignID, i)); err != nil {
+			t.Fatalf("❌ Enqueue %d failed: %v", i, err)
+		}
+	}
+	if err := queue.Enqueue(impression(campaignID, 3)); !errors.Is(err, ingest.ErrQueueFull) {
+		t.Fatalf("❌ Expected ErrQueueFull, got %v", err)
+	}
+	if s := queue.Stats(); s.Depth != 3 || s.Capacity != 3 || s.Rejected != 1 {
+		t.Errorf("❌ Unexpected queue stats %+v", s)
+	}
+
+	queue.Start()
+	if err := queue.Close(context.Background()); err != nil {
+		t.Fatalf("❌ Close failed: %v", err)
+	}
+}
+
+func TestQueueFallsBackToSingleWrites(t *testing.T) {
+	memServer, campaignID := newCampaign(t)
+	repo := &sequentialRepo{inner: memory.NewInMemoryImpressionRepository(memServer)}
+	queue := ingest.NewQueue(repo, defaultOptions, zap.NewNop())
+	queue.Start()
+
+	for i := 0; i < 5; i++ {
+		_ = queue.Enqueue(impression(campaignID, i))
+	}
+	_ = queue.Enqueue(impression("00000000-0000-0000-0000-000000000000", 1))
+	_ = queue.Close(context.Background())
+
+	if repo.calls != 6 {
+		t.Errorf("❌ Expected 6 single writes, got %d", repo.calls)
+	}
+	if s := queue.Stats(); s.Processed != 5 || s.Failed != 1 {
+		t.Errorf("❌ Unexpected queue stats %+v", s)
+	}
+}
+
+func TestQueueMetrics(t *testing.T) {
+	memServer, campaignID := newCampaign(t)
+	opts := defaultOptions
+	opts.QueueSize = 2
+	queue := ingest.NewQueue(memory.NewInMemoryImpressionRepository(memServer), opts, zap.NewNop())
+
+	registry := prometheus.NewRegistry()
+	if err := queue.Register(registry); err != nil {
+		t.Fatalf("❌ Register failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		_ = queue.Enqueue(impression(campaignID, i))
+	}
+
+	expected := `
+# HELP impression_queue_depth Impressions waiting in the ingestion queue.
+# TYPE impression_queue_depth gauge
+impression_queue_depth 2
+# HELP impression_queue_rejected_total Impressions refused because the ingestion queue was full.
+# TYPE impression_queue_rejected_total counter
+impression_queue_rejected_total 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "impression_queue_depth", "impression_queue_rejected_total"); err != nil {
+		t.Error("❌", err)
+	}
+}
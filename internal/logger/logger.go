@@ -1,25 +1,31 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"learning/cmd/config"
+	"os"
+	"sync"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"sync"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var (
 	Log  *zap.Logger
 	once sync.Once
+
+	// level can be changed while the service runs
+	level = zap.NewAtomicLevelAt(zap.InfoLevel)
 )
 
+// InitLogger returns the shared logger, building a JSON logger to stderr if Configure was never called
 func InitLogger() *zap.Logger {
 	once.Do(func() {
-		config := zap.NewProductionConfig()
-
-		config.EncoderConfig.TimeKey = "timestamp"
-		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-
 		var err error
-		Log, err = config.Build()
+		Log, err = build(config.LoggingConfig{Level: "info", Encoding: "json", OutputPaths: []string{"stderr"}})
 		if err != nil {
 			panic("failed to initialize zap logger: " + err.Error())
 		}
@@ -28,6 +34,84 @@ func InitLogger() *zap.Logger {
 	return Log
 }
 
+// Configure rebuilds the shared logger from the logging config. It is meant to be called once at
+// startup; afterwards only the level can be changed, through SetLevel.
+func Configure(cfg config.LoggingConfig) error {
+	l, err := build(cfg)
+	if err != nil {
+		return err
+	}
+
+	once.Do(func() {})
+	Log = l
+	return nil
+}
+
+func build(cfg config.LoggingConfig) (*zap.Logger, error) {
+	minLevel, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("logging.level: %w", err)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch cfg.Encoding {
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	case "json", "":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log encoding %q", cfg.Encoding)
+	}
+
+	writers := make([]zapcore.WriteSyncer, 0, len(cfg.OutputPaths))
+	for _, path := range cfg.OutputPaths {
+		writer, err := openOutput(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, writer)
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(writers...), level)
+	if cfg.Sampling.Enabled {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+
+	level.SetLevel(minLevel)
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel), zap.ErrorOutput(zapcore.Lock(os.Stderr))), nil
+}
+
+// openOutput resolves stdout, stderr or a file path, rotating files when rotation is configured
+func openOutput(path string, cfg config.LoggingConfig) (zapcore.WriteSyncer, error) {
+	switch path {
+	case "stdout":
+		return zapcore.Lock(os.Stdout), nil
+	case "stderr":
+		return zapcore.Lock(os.Stderr), nil
+	}
+
+	if cfg.Rotation.MaxSizeMB > 0 {
+		return zapcore.AddSync(&lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    cfg.Rotation.MaxSizeMB,
+			MaxBackups: cfg.Rotation.MaxBackups,
+			MaxAge:     cfg.Rotation.MaxAgeDays,
+			Compress:   cfg.Rotation.Compress,
+		}), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open log output %s: %w", path, err)
+	}
+	return zapcore.Lock(file), nil
+}
+
 func Sync() {
 	if Log != nil {
 		err := Log.Sync()
@@ -36,3 +120,33 @@ func Sync() {
 		}
 	}
 }
+
+// SetLevel changes the minimum level of the shared logger at runtime
+func SetLevel(text string) error {
+	l, err := zapcore.ParseLevel(text)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(l)
+	return nil
+}
+
+// Level returns the current minimum level of the shared logger
+func Level() zapcore.Level {
+	return level.Level()
+}
+
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx carrying the request-scoped logger l
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the request-scoped logger, or the shared logger if ctx has none
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return InitLogger()
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"learning/cmd/config"
+	"learning/internal/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigureWritesToFileWithLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+
+	err := logger.Configure(config.LoggingConfig{Level: "warn", Encoding: "console", OutputPaths: []string{path}})
+	if err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+
+	logger.Log.Info("hidden message")
+	logger.Log.Warn("visible message")
+
+	if err := logger.SetLevel("debug"); err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+	logger.Log.Debug("debug message")
+	_ = logger.Log.Sync()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("❌ Failed to read log file: %v", err)
+	}
+	output := string(content)
+
+	if strings.Contains(output, "hidden message") {
+		t.Error("❌ Info entries must be dropped at warn level")
+	}
+	if !strings.Contains(output, "WARN") || !strings.Contains(output, "visible message") {
+		t.Errorf("❌ Expected a console-encoded warn entry, got %q", output)
+	}
+	if !strings.Contains(output, "debug message") {
+		t.Error("❌ Expected the runtime level change to enable debug entries")
+	}
+}
+
+func TestConfigureRejectsInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.LoggingConfig
+	}{
+		{"Unknown Level", config.LoggingConfig{Level: "loud", Encoding: "json", OutputPaths: []string{"stderr"}}},
+		{"Unknown Encoding", config.LoggingConfig{Level: "info", Encoding: "xml", OutputPaths: []string{"stderr"}}},
+		{"Unwritable Output", config.LoggingConfig{Level: "info", Encoding: "json", OutputPaths: []string{filepath.Join(t.TempDir(), "missing", "dir", "x.log")}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := logger.Configure(test.cfg); err == nil {
+				t.Error("❌ Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"learning/cmd/config"
+	"learning/internal/utils"
+)
+
+// AdminKeyHeader carries one of server.admin_keys on requests to the admin endpoints
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminOnly passes on only the requests carrying one of server.admin_keys, read from the live config.
+// Without configured keys the admin endpoints are disabled.
+func AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := config.Current().Server.AdminKeys
+		switch key := r.Header.Get(AdminKeyHeader); {
+		case len(keys) == 0:
+			utils.JSONError(w, "Admin endpoints are disabled, set server.admin_keys to enable them", http.StatusForbidden)
+		case key == "" || !knownKey(keys, key):
+			utils.JSONError(w, "Missing or unknown "+AdminKeyHeader, http.StatusUnauthorized)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
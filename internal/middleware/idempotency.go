@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+
+	"learning/cmd/config"
+	"learning/internal/idempotency"
+	"learning/internal/utils"
+	"learning/internal/validators"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a POST without applying it twice
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response served from the idempotency store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// validIdempotencyKey accepts keys of printable ASCII, which covers UUIDs and most client schemes
+var validIdempotencyKey = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`).MatchString
+
+// captureWriter forwards the response while keeping a copy of it for the idempotency store
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+		c.header = c.ResponseWriter.Header().Clone()
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Idempotency replays the stored response of a POST to route when the same client, identified like by
+// RateLimit, retries it with the same Idempotency-Key. Reusing a key with a different body is rejected with 422, and a retry arriving
+// while the first request is still running gets 409. Server errors are not stored, so they can be
+// retried with the same key. Requests without the header pass through untouched. Bodies larger than
+// validators.MaxBodyBytes, such as import uploads, are spooled to a temporary file to be fingerprinted;
+// only bodies beyond import.max_upload_mb, which every handler rejects, pass through without a check.
+func Idempotency(store idempotency.Store, route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validIdempotencyKey(key) {
+			utils.JSONFieldErrors(w, "Invalid idempotency key", []validators.FieldError{
+				{Field: IdempotencyKeyHeader, Message: "must be 1 to 255 printable ASCII characters"},
+			}, http.StatusBadRequest)
+			return
+		}
+
+		cfg := config.Current()
+		requestFingerprint, body, err := fingerprint(r, int64(cfg.Import.MaxUploadMB)<<20)
+		if err != nil && !errors.Is(err, errBodyTooLarge) {
+			utils.JSONError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+		r.Body = body
+		if err != nil {
+			// No route accepts the body, the handler rejects it and there is nothing worth storing
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Keys are chosen by clients, so the same key sent by two clients names two requests
+		storeKey := clientIdentity(r, cfg) + "\x00" + route + "\x00" + key
+		existing, reserved := store.Begin(storeKey, requestFingerprint, cfg.Idempotency.TTL)
+		if !reserved {
+			switch {
+			case existing.Fingerprint != requestFingerprint:
+				utils.JSONError(w, "Idempotency key was already used with a different request", http.StatusUnprocessableEntity)
+			case existing.Response == nil:
+				utils.JSONError(w, "A request with this idempotency key is still in progress", http.StatusConflict)
+			default:
+				replay(w, existing.Response)
+			}
+			return
+		}
+
+		capture := &captureWriter{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if !completed {
+				// The handler panicked, let the client retry
+				store.Abort(storeKey)
+			}
+		}()
+
+		next.ServeHTTP(capture, r)
+		completed = true
+
+		if capture.status == 0 {
+			capture.status = http.StatusOK
+			capture.header = w.Header().Clone()
+		}
+		if capture.status >= http.StatusInternalServerError {
+			store.Abort(storeKey)
+			return
+		}
+		capture.header.Del(RequestIDHeader)
+		store.Complete(storeKey, idempotency.Response{Status: capture.status, Header: capture.header, Body: capture.body.Bytes()})
+	})
+}
+
+// errBodyTooLarge is returned by fingerprint for bodies larger than any route accepts
+var errBodyTooLarge = errors.New("request body too large")
+
+// spooledBody replays a body spooled to a temporary file, which is removed on Close
+type spooledBody struct {
+	*os.File
+}
+
+func (s spooledBody) Close() error {
+	err := s.File.Close()
+	if removeErr := os.Remove(s.File.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// fingerprint identifies the request a key was first used with by hashing its method, path and body,
+// and returns a reader replaying the body. Bodies up to validators.MaxBodyBytes are kept in memory;
+// larger ones, such as import uploads, are spooled to a temporary file while they are hashed, up to
+// maxSpool bytes. Beyond that errBodyTooLarge is returned with a reader of the whole body.
+func fingerprint(r *http.Request, maxSpool int64) (string, io.ReadCloser, error) {
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+
+	head, err := io.ReadAll(io.LimitReader(r.Body, validators.MaxBodyBytes+1))
+	if err != nil {
+		return "", nil, err
+	}
+	sum.Write(head)
+	if int64(len(head)) <= validators.MaxBodyBytes {
+		return hex.EncodeToString(sum.Sum(nil)), io.NopCloser(bytes.NewReader(head)), nil
+	}
+
+	spool, err := os.CreateTemp("", "idempotency-body-*")
+	if err != nil {
+		return "", nil, err
+	}
+	body := spooledBody{spool}
+	if _, err := spool.Write(head); err != nil {
+		_ = body.Close()
+		return "", nil, err
+	}
+	spooled, err := io.Copy(io.MultiWriter(spool, sum), io.LimitReader(r.Body, maxSpool-int64(len(head))+1))
+	if err != nil {
+		_ = body.Close()
+		return "", nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		_ = body.Close()
+		return "", nil, err
+	}
+	if int64(len(head))+spooled > maxSpool {
+		return "", readCloser{io.MultiReader(spool, r.Body), body}, errBodyTooLarge
+	}
+	return hex.EncodeToString(sum.Sum(nil)), body, nil
+}
+
+// readCloser reads from Reader and closes Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func replay(w http.ResponseWriter, resp *idempotency.Response) {
+	for name, values := range resp.Header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
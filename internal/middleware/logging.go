@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"learning/internal/logger"
+)
+
+// statusRecorder captures the status code and body size written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// RequestLogger assigns or propagates X-Request-ID, injects a request-scoped logger into the
+// context and writes one access log line per request once the handler returns
+func RequestLogger(base *zap.Logger, route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := requestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+
+		reqLogger := base.With(
+			zap.String("request_id", id),
+			zap.String("method", r.Method),
+			zap.String("route", route),
+		)
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r.WithContext(logger.WithContext(r.Context(), reqLogger)))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		reqLogger.Info("request completed",
+			zap.String("path", r.URL.Path),
+			zap.Int("status", recorder.status),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", recorder.bytes),
+		)
+	})
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+
+	"learning/cmd/config"
+	"learning/internal/ratelimit"
+	"learning/internal/traffic"
+	"learning/internal/utils"
+)
+
+// APIKeyHeader identifies a client for rate limiting when it carries one of server.api_keys; other
+// clients are limited by IP
+const APIKeyHeader = "X-API-Key"
+
+// RateLimit rejects requests to route with 429 once the client used up its token bucket. The rate and
+// burst are read from the live config on every request, and routes without a rate are not limited.
+func RateLimit(limiter *ratelimit.Limiter, route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := config.Current()
+		limit := cfg.Server.Routes[route].RateLimit
+		if limit.Rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowed, wait := limiter.Allow(route, clientIdentity(r, cfg), limit.Rate, limit.Burst, cfg.Server.RateLimitMaxClients); !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+			utils.JSONError(w, "Rate limit exceeded, retry later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIdentity names the client sending r: its API key when it is one of server.api_keys, else its IP.
+// An unknown key would let a client pose as a new one on every request, so only configured keys count.
+func clientIdentity(r *http.Request, cfg *config.Config) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" && knownKey(cfg.Server.APIKeys, key) {
+		return "key:" + key
+	}
+	return "ip:" + traffic.ClientIP(r, cfg.Traffic.ForwardedHops())
+}
+
+// knownKey tells whether key is one of keys, comparing in constant time
+func knownKey(keys []string, key string) bool {
+	known := 0
+	for _, k := range keys {
+		known |= subtle.ConstantTimeCompare([]byte(k), []byte(key))
+	}
+	return known == 1
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID accepts IDs from upstream proxies as long as they are short and log-safe
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._:\-]{1,128}$`).MatchString
+
+// requestID returns the incoming ID if it is usable, otherwise a new random one
+func requestID(incoming string) string {
+	if validRequestID(incoming) {
+		return incoming
+	}
+	return uuid.NewString()
+}
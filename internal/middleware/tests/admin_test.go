@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"learning/cmd/config"
+	"learning/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminOnlyNeedsAConfiguredKey(t *testing.T) {
+	prev := config.Current()
+	defer config.Set(prev)
+
+	handler := middleware.AdminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(adminKey string) int {
+		req := httptest.NewRequest(http.MethodPut, "/admin/log/level", nil)
+		if adminKey != "" {
+			req.Header.Set(middleware.AdminKeyHeader, adminKey)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	tests := []struct {
+		name     string
+		keys     []string
+		adminKey string
+		expected int
+	}{
+		{"Disabled Without Keys", nil, "anything", http.StatusForbidden},
+		{"Missing Key", []string{"admin-1"}, "", http.StatusUnauthorized},
+		{"Unknown Key", []string{"admin-1"}, "admin-2", http.StatusUnauthorized},
+		{"Known Key", []string{"admin-1", "admin-2"}, "admin-2", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := *prev
+			cfg.Server.AdminKeys = test.keys
+			config.Set(&cfg)
+			if code := send(test.adminKey); code != test.expected {
+				t.Errorf("❌ Expected status %d, got %d", test.expected, code)
+			}
+		})
+	}
+}
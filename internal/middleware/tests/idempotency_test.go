@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"learning/cmd/config"
+	"learning/internal/idempotency"
+	"learning/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingHandler answers with the number of times it ran, or status when it is not zero
+func countingHandler(calls *atomic.Int32, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if status != 0 {
+			w.WriteHeader(status)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+		_, _ = w.Write([]byte(`{"call":` + strconv.Itoa(int(n)) + `}`))
+	})
+}
+
+func post(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/test", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+	}
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	var calls atomic.Int32
+	handler := middleware.Idempotency(idempotency.NewMemoryStore(), "/api/v1/test", countingHandler(&calls, 0))
+
+	first := post(handler, "key-1", `{"a":1}`)
+	retry := post(handler, "key-1", `{"a":1}`)
+
+	if calls.Load() != 1 {
+		t.Fatalf("❌ Expected the handler to run once, ran %d times", calls.Load())
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("❌ Expected the first response to be replayed, got %d %q", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(middleware.IdempotentReplayedHeader) != "true" || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("❌ Unexpected replay headers %v", retry.Header())
+	}
+	if first.Header().Get(middleware.IdempotentReplayedHeader) != "" {
+		t.Error("❌ The first response must not be marked as replayed")
+	}
+
+	// Other keys and requests without a key are not affected
+	post(handler, "key-2", `{"a":1}`)
+	post(handler, "", `{"a":1}`)
+	post(handler, "", `{"a":1}`)
+	if calls.Load() != 4 {
+		t.Errorf("❌ Expected 4 handler runs, got %d", calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsKeyReuseWithDifferentBody(t *testing.T) {
+	var calls atomic.Int32
+	handler := middleware.Idempotency(idempotency.NewMemoryStore(), "/api/v1/test", countingHandler(&calls, 0))
+
+	post(handler, "key-1", `{"a":1}`)
+	resp := post(handler, "key-1", `{"a":2}`)
+	if resp.Code != http.StatusUnprocessableEntity || calls.Load() != 1 {
+		t.Errorf("❌ Expected 422 without running the handler, got %d after %d runs", resp.Code, calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsInvalidKey(t *testing.T) {
+	var calls atomic.Int32
+	handler := middleware.Idempotency(idempotency.NewMemoryStore(), "/api/v1/test", countingHandler(&calls, 0))
+
+	for _, key := range []string{"has space", strings.Repeat("k", 256)} {
+		if resp := post(handler, key, `{}`); resp.Code != http.StatusBadRequest {
+			t.Errorf("❌ Expected 400 for key %q, got %d", key, resp.Code)
+		}
+	}
+	if calls.Load() != 0 {
+		t.Errorf("❌ Expected the handler not to run, ran %d times", calls.Load())
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	handler := middleware.Idempotency(idempotency.NewMemoryStore(), "/api/v1/test", countingHandler(&calls, http.StatusServiceUnavailable))
+
+	post(handler, "key-1", `{}`)
+	if resp := post(handler, "key-1", `{}`); resp.Header().Get(middleware.IdempotentReplayedHeader) != "" || calls.Load() != 2 {
+		t.Errorf("❌ Expected a failed request to be retried, got %d runs", calls.Load())
+	}
+}
+
+func TestIdempotencyConflictsWhileInProgress(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := middleware.Idempotency(store, "/api/v1/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(handler, "key-1", `{}`) }()
+	<-started
+
+	if resp := post(handler, "key-1", `{}`); resp.Code != http.StatusConflict {
+		t.Errorf("❌ Expected 409 while the first request runs, got %d", resp.Code)
+	}
+	close(release)
+	if resp := <-done; resp.Code != http.StatusCreated {
+		t.Errorf("❌ Expected the first request to finish with 201, got %d", resp.Code)
+	}
+	if resp := post(handler, "key-1", `{}`); resp.Code != http.StatusCreated {
+		t.Errorf("❌ Expected the stored 201 afterwards, got %d", resp.Code)
+	}
+}
+
+func TestMemoryStorePurgesExpiredKeys(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	store.Begin("expired", "fp", -1)
+	store.Begin("live", "fp", 1<<62)
+
+	purged, err := store.PurgeExpired(context.Background())
+	if err != nil || purged != 1 {
+		t.Fatalf("❌ Expected 1 purged key, got %d (%v)", purged, err)
+	}
+	if _, reserved := store.Begin("live", "fp", 1<<62); reserved {
+		t.Error("❌ Expected the live key to survive the purge")
+	}
+}
+
+func TestIdempotencyKeysAreScopedToTheClient(t *testing.T) {
+	prev := config.Current()
+	cfg := *prev
+	cfg.Server.APIKeys = []string{"publisher-1", "publisher-2"}
+	config.Set(&cfg)
+	defer config.Set(prev)
+
+	var calls atomic.Int32
+	handler := middleware.Idempotency(idempotency.NewMemoryStore(), "/api/v1/test", countingHandler(&calls, 0))
+	send := func(remote, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/test", strings.NewReader(body))
+		req.RemoteAddr = remote
+		req.Header.Set(middleware.IdempotencyKeyHeader, "shared-key")
+		if apiKey != "" {
+			req.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		remote   string
+		apiKey   string
+		body     string
+		expected string
+	}{
+		{"First Client", "198.51.100.1:1000", "", `{"a":1}`, `{"call":1}`},
+		{"Other IP Same Body", "198.51.100.2:1000", "", `{"a":1}`, `{"call":2}`},
+		{"Other IP Other Body", "198.51.100.3:1000", "", `{"a":2}`, `{"call":3}`},
+		{"First Client Retrying", "198.51.100.1:2000", "", `{"a":1}`, `{"call":1}`},
+		{"API Key", "198.51.100.1:1000", "publisher-1", `{"a":1}`, `{"call":4}`},
+		{"Same API Key From Other IP", "198.51.100.9:1000", "publisher-1", `{"a":1}`, `{"call":4}`},
+		{"Other API Key", "198.51.100.9:1000", "publisher-2", `{"a":1}`, `{"call":5}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if resp := send(test.remote, test.apiKey, test.body); resp.Code != http.StatusCreated || resp.Body.String() != test.expected {
+				t.Errorf("❌ Expected 201 %s, got %d %s", test.expected, resp.Code, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdempotencyCoversLargeBodies(t *testing.T) {
+	prev := config.Current()
+	cfg := *prev
+	cfg.Import.MaxUploadMB = 1
+	config.Set(&cfg)
+	defer config.Set(prev)
+	spool := t.TempDir()
+	t.Setenv("TMPDIR", spool)
+
+	// Answers with the size of the body it read, so a truncated replay of the spool shows
+	var calls atomic.Int32
+	handler := middleware.Idempotency(idempotency.NewMemoryStore(), "/api/v1/imports", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(strconv.Itoa(len(body))))
+	}))
+
+	upload := strings.Repeat("x", 512<<10)
+	first := post(handler, "import-1", upload)
+	retry := post(handler, "import-1", upload)
+	if calls.Load() != 1 || first.Body.String() != strconv.Itoa(len(upload)) || retry.Body.String() != first.Body.String() {
+		t.Fatalf("❌ Expected one run on the whole body and a replay, got %d runs, %q and %q", calls.Load(), first.Body.String(), retry.Body.String())
+	}
+	// Differing only past the first 64 KB still counts as another request
+	if resp := post(handler, "import-1", upload[:len(upload)-1]+"y"); resp.Code != http.StatusUnprocessableEntity || calls.Load() != 1 {
+		t.Errorf("❌ Expected 422 without running the handler, got %d after %d runs", resp.Code, calls.Load())
+	}
+
+	// Beyond import.max_upload_mb no handler accepts the body, so it passes through unchecked
+	tooLarge := strings.Repeat("x", 2<<20)
+	for i := 0; i < 2; i++ {
+		if resp := post(handler, "import-2", tooLarge); resp.Body.String() != strconv.Itoa(len(tooLarge)) {
+			t.Errorf("❌ Expected the whole body to reach the handler, got %q", resp.Body.String())
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("❌ Expected a body over the limit to run the handler every time, got %d runs", calls.Load())
+	}
+
+	if entries, err := os.ReadDir(spool); err != nil || len(entries) != 0 {
+		t.Errorf("❌ Expected the spooled bodies to be removed, got %d files, %v", len(entries), err)
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"learning/internal/logger"
+	"learning/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLoggerAssignsRequestID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	var contextLogger *zap.Logger
+	handler := middleware.RequestLogger(zap.New(core), "/api/v1/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contextLogger = logger.FromContext(r.Context())
+		contextLogger.Info("inside handler")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/test", nil)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	id := resp.Header().Get(middleware.RequestIDHeader)
+	if id == "" {
+		t.Fatal("❌ Expected a generated X-Request-ID response header")
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("❌ Expected 2 log entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.ContextMap()["request_id"] != id {
+			t.Errorf("❌ Expected every entry to carry request_id %q, got %v", id, entry.ContextMap())
+		}
+	}
+
+	access := entries[1].ContextMap()
+	if access["status"] != int64(http.StatusTeapot) || access["bytes"] != int64(5) || access["route"] != "/api/v1/test" || access["method"] != http.MethodPost {
+		t.Errorf("❌ Unexpected access log fields: %v", access)
+	}
+	if _, ok := access["latency"]; !ok {
+		t.Error("❌ Expected the access log to include latency")
+	}
+}
+
+func TestRequestLoggerPropagatesRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{"Valid ID", "abc-123", true},
+		{"Unsafe ID", "bad id\n", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := middleware.RequestLogger(zap.NewNop(), "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(middleware.RequestIDHeader, test.incoming)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			got := resp.Header().Get(middleware.RequestIDHeader)
+			if (got == test.incoming) != test.kept || got == "" {
+				t.Errorf("❌ Incoming ID %q produced %q", test.incoming, got)
+			}
+		})
+	}
+}
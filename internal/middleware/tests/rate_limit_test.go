@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"fmt"
+	"learning/cmd/config"
+	"learning/internal/middleware"
+	"learning/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitKeysClientsByKnownAPIKeyOrIP(t *testing.T) {
+	prev := config.Current()
+	cfg := *prev
+	cfg.Server.Routes = map[string]config.RouteConfig{"/limited": {RateLimit: config.RateLimitConfig{Rate: 0.01, Burst: 1}}}
+	cfg.Server.APIKeys = []string{"publisher-1"}
+	cfg.Server.RateLimitMaxClients = 100
+	config.Set(&cfg)
+	defer config.Set(prev)
+
+	limiter := ratelimit.NewLimiter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limited := middleware.RateLimit(limiter, "/limited", ok)
+	unlimited := middleware.RateLimit(limiter, "/unlimited", ok)
+
+	send := func(handler http.Handler, remote, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remote
+		if apiKey != "" {
+			req.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		remote   string
+		apiKey   string
+		expected int
+	}{
+		{"First From IP", limited, "198.51.100.1:1000", "", http.StatusOK},
+		{"Second From IP", limited, "198.51.100.1:2000", "", http.StatusTooManyRequests},
+		{"Other IP", limited, "198.51.100.2:1000", "", http.StatusOK},
+		{"API Key On Limited IP", limited, "198.51.100.1:1000", "publisher-1", http.StatusOK},
+		{"Same API Key From Other IP", limited, "198.51.100.3:1000", "publisher-1", http.StatusTooManyRequests},
+		{"Unknown API Key On Limited IP", limited, "198.51.100.1:1000", "random-1", http.StatusTooManyRequests},
+		{"Unknown API Key From New IP", limited, "198.51.100.4:1000", "random-2", http.StatusOK},
+		{"Other Unknown API Key From Same IP", limited, "198.51.100.4:1000", "random-3", http.StatusTooManyRequests},
+		{"Route Without Limit", unlimited, "198.51.100.1:1000", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := send(test.handler, test.remote, test.apiKey)
+			if resp.Code != test.expected {
+				t.Fatalf("❌ Expected status %d, got %d", test.expected, resp.Code)
+			}
+			if resp.Code == http.StatusTooManyRequests && resp.Header().Get("Retry-After") != "100" {
+				t.Errorf("❌ Expected Retry-After 100, got %q", resp.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresRotatedForwardedFor(t *testing.T) {
+	prev := config.Current()
+	cfg := *prev
+	cfg.Server.Routes = map[string]config.RouteConfig{"/limited": {RateLimit: config.RateLimitConfig{Rate: 0.01, Burst: 2}}}
+	cfg.Server.RateLimitMaxClients = 100
+	cfg.Traffic.TrustForwardedFor, cfg.Traffic.TrustedProxies = true, 1
+	config.Set(&cfg)
+	defer config.Set(prev)
+
+	limiter := ratelimit.NewLimiter()
+	limited := middleware.RateLimit(limiter, "/limited", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Every request claims another address, but the proxy appends the one it really came from
+	codes := make([]int, 0, 5)
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "10.0.0.2:4000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("192.0.2.%d, 198.51.100.7", i+1))
+		resp := httptest.NewRecorder()
+		limited.ServeHTTP(resp, req)
+		codes = append(codes, resp.Code)
+	}
+	expected := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	if fmt.Sprint(codes) != fmt.Sprint(expected) {
+		t.Errorf("❌ Expected statuses %v, got %v", expected, codes)
+	}
+	if clients := limiter.Clients(); clients != 1 {
+		t.Errorf("❌ Expected one tracked client, got %d", clients)
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"learning/cmd/config"
+	"learning/internal/export"
+)
+
+// Timeout bounds the request context by the configured deadline of route, or by server.export_timeout
+// for CSV and NDJSON downloads, which stream as long as the listing takes. The deadline is read from the
+// live config on every request, so reloading the config applies new timeouts immediately.
+func Timeout(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := config.Current()
+		timeout := cfg.RouteTimeout(route)
+		if export.IsDownload(r) {
+			timeout = cfg.Server.ExportTimeout
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,23 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI 3 document describing every route of the service
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Handler serves the embedded OpenAPI document
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(Spec)
+}
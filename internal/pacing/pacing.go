@@ -0,0 +1,109 @@
+package pacing
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+// ErrNoEndTime is returned for campaigns without an end_time, which have no flight to pace over
+var ErrNoEndTime = errors.New("campaign has no end_time")
+
+// ErrNoGoal is returned for campaigns with neither an impression goal nor a budget
+var ErrNoGoal = errors.New("campaign has no impression goal or budget")
+
+// Tolerance is how far, as a share of the expected delivery, a campaign may stray before it is under or over
+const Tolerance = 0.1
+
+// curve gives the share of the goal due by flight progress f in [0, 1], and its derivative, the pace at f
+type curve struct {
+	share func(f float64) float64
+	pace  func(f float64) float64
+}
+
+var curves = map[string]curve{
+	entities.PacingEven: {
+		share: func(f float64) float64 { return f },
+		pace:  func(f float64) float64 { return 1 },
+	},
+	entities.PacingFrontLoaded: {
+		share: func(f float64) float64 { return 1 - (1-f)*(1-f) },
+		pace:  func(f float64) float64 { return 2 * (1 - f) },
+	},
+}
+
+// Compute compares the delivery in stats with the named curve over the flight of campaign at now
+func Compute(campaign entities.Campaign, stats entities.Stats, curveName string, now time.Time) (entities.Pacing, error) {
+	if campaign.EndTime == nil {
+		return entities.Pacing{}, ErrNoEndTime
+	}
+	goal := campaign.ImpressionLimit()
+	if goal == 0 {
+		return entities.Pacing{}, ErrNoGoal
+	}
+	c, ok := curves[curveName]
+	if !ok {
+		return entities.Pacing{}, errors.New("unknown pacing curve " + curveName)
+	}
+
+	start, end := campaign.StartTime, *campaign.EndTime
+	flight := end.Sub(start)
+	progress := clamp(now.Sub(start).Seconds() / flight.Seconds())
+
+	report := entities.Pacing{
+		CampaignID:      campaign.ID,
+		Curve:           curveName,
+		Goal:            goal,
+		Delivered:       stats.TotalCount,
+		Expected:        int64(math.Round(float64(goal) * c.share(progress))),
+		PercentToGoal:   round2(100 * float64(stats.TotalCount) / float64(goal)),
+		PercentOfFlight: round2(100 * progress),
+		HourlyRate:      round2(hourlyRate(stats, start, end, now)),
+		AsOf:            now.UTC(),
+	}
+
+	// Following the curve, the rest of the flight delivers as much as (1 - share) / pace of the
+	// whole flight would at the current pace
+	projected := float64(stats.TotalCount)
+	if progress < 1 {
+		projected += report.HourlyRate * flight.Hours() * (1 - c.share(progress)) / c.pace(progress)
+	}
+	report.ProjectedDelivery = min(goal, int64(math.Round(projected)))
+
+	switch expected := float64(report.Expected); {
+	case float64(report.Delivered) < expected*(1-Tolerance):
+		report.Status = entities.PacingUnder
+	case float64(report.Delivered) > expected*(1+Tolerance):
+		report.Status = entities.PacingOver
+	default:
+		report.Status = entities.PacingOnTrack
+	}
+	return report, nil
+}
+
+// hourlyRate averages last_day over the part of its hour buckets that lies within the flight
+func hourlyRate(stats entities.Stats, start, end, now time.Time) float64 {
+	from := time.Unix((repositories.HourOf(now)-repositories.LastDayBuckets+1)*3600, 0)
+	if start.After(from) {
+		from = start
+	}
+	to := now
+	if end.Before(to) {
+		to = end
+	}
+	if hours := to.Sub(from).Hours(); hours > 0 {
+		return float64(stats.LastDay) / hours
+	}
+	return 0
+}
+
+func clamp(f float64) float64 {
+	return math.Max(0, math.Min(1, f))
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
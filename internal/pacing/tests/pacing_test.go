@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"errors"
+	"learning/internal/entities"
+	"learning/internal/pacing"
+	"testing"
+	"time"
+)
+
+// A ten day flight with a goal of 1000, paced at its midpoint. The midpoint is on the hour, so the
+// last_day window spans the 23 complete hour buckets before it.
+var (
+	start    = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end      = start.Add(10 * 24 * time.Hour)
+	midpoint = start.Add(5 * 24 * time.Hour)
+	flight   = entities.Campaign{ID: "c", StartTime: start, EndTime: &end, ImpressionGoal: 1000}
+)
+
+func compute(t *testing.T, campaign entities.Campaign, stats entities.Stats, curve string, now time.Time) entities.Pacing {
+	t.Helper()
+	report, err := pacing.Compute(campaign, stats, curve, now)
+	if err != nil {
+		t.Fatalf("❌ Compute failed: %v", err)
+	}
+	return report
+}
+
+func TestPacingStatusAgainstCurves(t *testing.T) {
+	tests := []struct {
+		name      string
+		curve     string
+		delivered int64
+		expected  int64
+		status    string
+	}{
+		{"Even On Track", entities.PacingEven, 520, 500, entities.PacingOnTrack},
+		{"Even Under", entities.PacingEven, 400, 500, entities.PacingUnder},
+		{"Even Over", entities.PacingEven, 600, 500, entities.PacingOver},
+		{"Front Loaded Under At Even Pace", entities.PacingFrontLoaded, 500, 750, entities.PacingUnder},
+		{"Front Loaded On Track", entities.PacingFrontLoaded, 740, 750, entities.PacingOnTrack},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := compute(t, flight, entities.Stats{TotalCount: test.delivered}, test.curve, midpoint)
+			if report.Expected != test.expected || report.Status != test.status {
+				t.Errorf("❌ Expected expected=%d status=%s, got %d %s", test.expected, test.status, report.Expected, report.Status)
+			}
+			if report.PercentOfFlight != 50 || report.PercentToGoal != float64(test.delivered)/10 {
+				t.Errorf("❌ Expected 50%% of the flight and %d%% of the goal, got %v %v", test.delivered/10, report.PercentOfFlight, report.PercentToGoal)
+			}
+		})
+	}
+}
+
+func TestPacingProjectsRecentRateAlongCurve(t *testing.T) {
+	// 46 impressions over the 23 hours of the last day: 2 per hour for the remaining 120 hours
+	stats := entities.Stats{TotalCount: 500, LastDay: 46}
+
+	if report := compute(t, flight, stats, entities.PacingEven, midpoint); report.HourlyRate != 2 || report.ProjectedDelivery != 740 {
+		t.Errorf("❌ Expected a rate of 2 projected to 740, got %v %d", report.HourlyRate, report.ProjectedDelivery)
+	}
+	// Front-loaded delivery halves its pace over the rest of the flight
+	if report := compute(t, flight, stats, entities.PacingFrontLoaded, midpoint); report.ProjectedDelivery != 620 {
+		t.Errorf("❌ Expected a front-loaded projection of 620, got %d", report.ProjectedDelivery)
+	}
+
+	// Delivery stops at the goal
+	stats.LastDay = 460
+	if report := compute(t, flight, stats, entities.PacingEven, midpoint); report.ProjectedDelivery != 1000 {
+		t.Errorf("❌ Expected the projection to stop at the goal, got %d", report.ProjectedDelivery)
+	}
+}
+
+func TestPacingOutsideTheFlight(t *testing.T) {
+	report := compute(t, flight, entities.Stats{}, entities.PacingEven, start.Add(-time.Hour))
+	if report.Expected != 0 || report.Status != entities.PacingOnTrack || report.HourlyRate != 0 || report.ProjectedDelivery != 0 {
+		t.Errorf("❌ Expected nothing due before the start, got %+v", report)
+	}
+
+	report = compute(t, flight, entities.Stats{TotalCount: 950, LastDay: 100}, entities.PacingFrontLoaded, end.Add(48*time.Hour))
+	if report.Expected != 1000 || report.PercentOfFlight != 100 || report.ProjectedDelivery != 950 || report.Status != entities.PacingOnTrack {
+		t.Errorf("❌ Expected the final delivery after the end, got %+v", report)
+	}
+}
+
+func TestPacingGoalFromBudget(t *testing.T) {
+	campaign := flight
+	campaign.ImpressionGoal = 0
+	campaign.Budget, campaign.CPM = 1, 2
+	if report := compute(t, campaign, entities.Stats{TotalCount: 250}, entities.PacingEven, midpoint); report.Goal != 500 || report.Expected != 250 {
+		t.Errorf("❌ Expected the budget to buy a goal of 500, got %+v", report)
+	}
+}
+
+func TestPacingNeedsFlightEndAndGoal(t *testing.T) {
+	open := flight
+	open.EndTime = nil
+	if _, err := pacing.Compute(open, entities.Stats{}, entities.PacingEven, midpoint); !errors.Is(err, pacing.ErrNoEndTime) {
+		t.Errorf("❌ Expected ErrNoEndTime, got %v", err)
+	}
+
+	unbounded := flight
+	unbounded.ImpressionGoal = 0
+	if _, err := pacing.Compute(unbounded, entities.Stats{}, entities.PacingEven, midpoint); !errors.Is(err, pacing.ErrNoGoal) {
+		t.Errorf("❌ Expected ErrNoGoal, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// bucket holds the tokens of one client on one route as of last; it is full again at full
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// overflowClient is the client sharing one bucket per route once the limiter holds as many buckets as
+// it may
+const overflowClient = "\x00overflow"
+
+// Limiter keeps a token bucket per route and client. Rate, burst and the bucket cap are passed on every
+// call, so a config reload applies to existing buckets right away.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	allowed    *prometheus.CounterVec
+	rejected   *prometheus.CounterVec
+	overflowed *prometheus.CounterVec
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		allowed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rate_limit_allowed_total",
+			Help: "Requests let through by the rate limiter.",
+		}, []string{"route"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rate_limit_rejected_total",
+			Help: "Requests rejected with 429 by the rate limiter.",
+		}, []string{"route"}),
+		overflowed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rate_limit_overflowed_total",
+			Help: "Requests of new clients limited through the shared bucket as all buckets were taken.",
+		}, []string{"route"}),
+	}
+}
+
+// Allow takes a token from the bucket of client on route, which refills at rate tokens per second up
+// to burst. When the bucket is empty it returns false and how long until the next token is available.
+// Once maxClients buckets are held, clients without one share a single bucket per route until
+// PurgeExpired frees some, so that a flood of new clients cannot grow the limiter without bound.
+func (l *Limiter) Allow(route, client string, rate float64, burst, maxClients int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	key := route + "\x00" + client
+	b, ok := l.buckets[key]
+	if !ok && len(l.buckets) >= maxClients {
+		l.overflowed.WithLabelValues(route).Inc()
+		key = route + "\x00" + overflowClient
+		b, ok = l.buckets[key]
+	}
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(seconds((float64(burst) - b.tokens) / rate))
+
+	if !allowed {
+		l.rejected.WithLabelValues(route).Inc()
+		return false, seconds((1 - b.tokens) / rate)
+	}
+	l.allowed.WithLabelValues(route).Inc()
+	return true, 0
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// PurgeExpired forgets buckets that have refilled completely, which behave like new ones, and
+// returns how many were removed
+func (l *Limiter) PurgeExpired(ctx context.Context) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	purged := 0
+	for key, b := range l.buckets {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		if !b.full.After(now) {
+			delete(l.buckets, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Clients returns the number of buckets currently tracked
+func (l *Limiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Register exports the limiter counters and the number of tracked clients on reg
+func (l *Limiter) Register(reg prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		l.allowed,
+		l.rejected,
+		l.overflowed,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "rate_limit_clients",
+			Help: "Clients with a token bucket held by the rate limiter.",
+		}, func() float64 { return float64(l.Clients()) }),
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"context"
+	"learning/internal/ratelimit"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// maxClients is a bucket cap the tests do not reach
+const maxClients = 100
+
+func TestLimiterAllowsBurstThenRefills(t *testing.T) {
+	limiter := ratelimit.NewLimiter()
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow("/r", "client", 20, 3, maxClients); !allowed {
+			t.Fatalf("❌ Expected request %d of the burst to be allowed", i+1)
+		}
+	}
+	allowed, wait := limiter.Allow("/r", "client", 20, 3, maxClients)
+	if allowed || wait <= 0 || wait > 50*time.Millisecond {
+		t.Fatalf("❌ Expected a rejection with a wait of at most 50ms, got %v %s", allowed, wait)
+	}
+
+	// Other clients and routes have buckets of their own
+	if allowed, _ := limiter.Allow("/r", "other", 20, 3, maxClients); !allowed {
+		t.Error("❌ Expected another client to be allowed")
+	}
+	if allowed, _ := limiter.Allow("/other", "client", 20, 3, maxClients); !allowed {
+		t.Error("❌ Expected the same client to be allowed on another route")
+	}
+
+	time.Sleep(wait + 10*time.Millisecond)
+	if allowed, _ := limiter.Allow("/r", "client", 20, 3, maxClients); !allowed {
+		t.Error("❌ Expected a token after the refill")
+	}
+}
+
+func TestLimiterPurgesRefilledBuckets(t *testing.T) {
+	limiter := ratelimit.NewLimiter()
+	limiter.Allow("/r", "fast", 1000, 1, maxClients)
+	limiter.Allow("/r", "slow", 0.001, 1, maxClients)
+
+	time.Sleep(5 * time.Millisecond)
+	purged, err := limiter.PurgeExpired(context.Background())
+	if err != nil || purged != 1 || limiter.Clients() != 1 {
+		t.Errorf("❌ Expected only the refilled bucket to be purged, got %d purged, %d left (%v)", purged, limiter.Clients(), err)
+	}
+}
+
+func TestLimiterSharesOneBucketBeyondMaxClients(t *testing.T) {
+	limiter := ratelimit.NewLimiter()
+	limiter.Allow("/r", "a", 1, 1, 2)
+	limiter.Allow("/r", "b", 1, 1, 2)
+
+	// Clients past the cap share one bucket, so a flood of new ones gets a single burst
+	if allowed, _ := limiter.Allow("/r", "c", 1, 1, 2); !allowed {
+		t.Error("❌ Expected the first client over the cap to be allowed")
+	}
+	if allowed, _ := limiter.Allow("/r", "d", 1, 1, 2); allowed {
+		t.Error("❌ Expected the second client over the cap to share the emptied bucket")
+	}
+	if limiter.Clients() != 3 {
+		t.Errorf("❌ Expected two buckets plus the shared one, got %d", limiter.Clients())
+	}
+}
+
+func TestLimiterMetrics(t *testing.T) {
+	limiter := ratelimit.NewLimiter()
+	registry := prometheus.NewRegistry()
+	if err := limiter.Register(registry); err != nil {
+		t.Fatalf("❌ Register failed: %v", err)
+	}
+
+	limiter.Allow("/r", "client", 1, 1, maxClients)
+	limiter.Allow("/r", "client", 1, 1, maxClients)
+
+	expected := `
+# HELP rate_limit_allowed_total Requests let through by the rate limiter.
+# TYPE rate_limit_allowed_total counter
+rate_limit_allowed_total{route="/r"} 1
+# HELP rate_limit_clients Clients with a token bucket held by the rate limiter.
+# TYPE rate_limit_clients gauge
+rate_limit_clients 1
+# HELP rate_limit_rejected_total Requests rejected with 429 by the rate limiter.
+# TYPE rate_limit_rejected_total counter
+rate_limit_rejected_total{route="/r"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Errorf("❌ Unexpected metrics: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+)
+
+// Backfill holds the deduplicated counts of historical impressions of one campaign. Minutes and Hours
+// are keyed by MinuteOf and HourOf of the event times. ImportID, when set, names the import the counts
+// come from, so that applying them again is refused.
+type Backfill struct {
+	CampaignID string
+	ImportID   string
+	Total      int64
+	Minutes    map[int64]int64
+	Hours      map[int64]int64
+}
+
+// BackfillRepository is implemented by backends that can add historical counts outside the real-time
+// path: no dedup TTL, lateness limit or impression limit applies, though a campaign whose total reaches
+// its limit is completed. Buckets past the retention of the backend are skipped. ApplyBackfill returns
+// ErrCampaignNotFound for unknown campaigns, ErrBackfillApplied when the ImportID of b was already applied
+// to the campaign, and applies all of b, along with its ImportID, or nothing.
+type BackfillRepository interface {
+	ApplyBackfill(ctx context.Context, b Backfill) error
+}
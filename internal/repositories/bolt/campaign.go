@@ -0,0 +1,176 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+type BoltCampaignRepository struct {
+	db *bbolt.DB
+}
+
+// NewBoltCampaignRepository stores campaigns in the shared database
+func NewBoltCampaignRepository(db *bbolt.DB) *BoltCampaignRepository {
+	return &BoltCampaignRepository{db: db}
+}
+
+// CreateCampaign stores the campaign and its empty impression and stats buckets in one transaction
+func (r *BoltCampaignRepository) CreateCampaign(ctx context.Context, req entities.CreateCampaignRequest) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign := entities.Campaign{
+		ID:             uuid.New().String(),
+		Name:           req.Name,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		ImpressionGoal: req.ImpressionGoal,
+		Budget:         req.Budget,
+		CPM:            req.CPM,
+		Status:         entities.CampaignActive,
+	}
+	value, err := json.Marshal(campaign)
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+
+	err = r.db.Update(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		id := []byte(campaign.ID)
+		if err := tx.Bucket(campaignsBucket).Put(id, value); err != nil {
+			return err
+		}
+		if _, err := tx.Bucket(impressionsBucket).CreateBucket(id); err != nil {
+			return err
+		}
+		_, err := tx.Bucket(statsBucket).CreateBucket(id)
+		return err
+	})
+	if err != nil {
+		return entities.Campaign{}, fmt.Errorf("store campaign: %w", err)
+	}
+
+	return campaign, nil
+}
+
+// GetCampaign decodes the stored campaign in a read-only transaction
+func (r *BoltCampaignRepository) GetCampaign(ctx context.Context, id string) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	var campaign entities.Campaign
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		campaign, err = loadCampaign(tx, []byte(id))
+		return err
+	})
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+	return campaign, nil
+}
+
+// UpdateCampaign rewrites the campaign in one transaction, so it cannot undo a concurrent completion
+func (r *BoltCampaignRepository) UpdateCampaign(ctx context.Context, id string, req entities.UpdateCampaignRequest) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	var campaign entities.Campaign
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if campaign, err = loadCampaign(tx, []byte(id)); err != nil {
+			return err
+		}
+		req.Apply(&campaign)
+		return storeCampaign(tx, campaign)
+	})
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+	return campaign, nil
+}
+
+// listBatch is how many campaigns ListCampaigns decodes per read-only transaction
+const listBatch = 100
+
+// ListCampaigns walks the campaigns bucket in batches, so fn never runs inside a transaction that
+// would hold back the remapping of a growing database
+func (r *BoltCampaignRepository) ListCampaigns(ctx context.Context, fn func(entities.Campaign) error) error {
+	var after []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch := make([]entities.Campaign, 0, listBatch)
+		err := r.db.View(func(tx *bbolt.Tx) error {
+			c := tx.Bucket(campaignsBucket).Cursor()
+			k, _ := c.First()
+			if after != nil {
+				if k, _ = c.Seek(after); k != nil && bytes.Equal(k, after) {
+					k, _ = c.Next()
+				}
+			}
+			for ; k != nil && len(batch) < listBatch; k, _ = c.Next() {
+				campaign, err := loadCampaign(tx, k)
+				if err != nil {
+					return err
+				}
+				batch = append(batch, campaign)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, campaign := range batch {
+			if err := fn(campaign); err != nil {
+				return err
+			}
+		}
+		if len(batch) < listBatch {
+			return nil
+		}
+		after = []byte(batch[len(batch)-1].ID)
+	}
+}
+
+// loadCampaign decodes a stored campaign; campaigns stored before they had a status are active
+func loadCampaign(tx *bbolt.Tx, id []byte) (entities.Campaign, error) {
+	value := tx.Bucket(campaignsBucket).Get(id)
+	if value == nil {
+		return entities.Campaign{}, repositories.ErrCampaignNotFound
+	}
+
+	var campaign entities.Campaign
+	if err := json.Unmarshal(value, &campaign); err != nil {
+		return entities.Campaign{}, fmt.Errorf("decode campaign: %w", err)
+	}
+	if campaign.Status == "" {
+		campaign.Status = entities.CampaignActive
+	}
+	return campaign, nil
+}
+
+// storeCampaign overwrites a stored campaign
+func storeCampaign(tx *bbolt.Tx, campaign entities.Campaign) error {
+	value, err := json.Marshal(campaign)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(campaignsBucket).Put([]byte(campaign.ID), value)
+}
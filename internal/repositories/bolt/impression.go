@@ -0,0 +1,310 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"net/http"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+type BoltImpressionRepository struct {
+	db *bbolt.DB
+}
+
+func NewBoltImpressionRepository(db *bbolt.DB) *BoltImpressionRepository {
+	return &BoltImpressionRepository{db: db}
+}
+
+// TrackImpression checks the dedup entry and bumps the counters in a single write transaction
+func (r *BoltImpressionRepository) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	if err := ctx.Err(); err != nil {
+		return err, http.StatusServiceUnavailable
+	}
+
+	status := http.StatusOK
+	var rejected error
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		// bbolt serializes writers, so the caller may have gone away while waiting
+		if err := ctx.Err(); err != nil {
+			status = http.StatusServiceUnavailable
+			return err
+		}
+
+		var err error
+		err, status = track(tx, req, time.Now(), time.Duration(config.Current().App.TTL)*time.Second)
+		if errors.Is(err, repositories.ErrImpressionTooOld) || errors.Is(err, repositories.ErrInvalidTraffic) {
+			// Commit the too_old or invalid counter
+			rejected = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err, status
+	}
+	if rejected != nil {
+		return rejected, status
+	}
+
+	return nil, http.StatusOK
+}
+
+// TrackImpressions applies a batch in one write transaction. Per-request outcomes such as
+// duplicates or unknown campaigns do not abort the transaction; storage failures fail the batch.
+func (r *BoltImpressionRepository) TrackImpressions(ctx context.Context, reqs []entities.TrackImpressionRequest) []error {
+	errs := make([]error, len(reqs))
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		ttl := time.Duration(config.Current().App.TTL) * time.Second
+		for i, req := range reqs {
+			err, status := track(tx, req, now, ttl)
+			if status == http.StatusInternalServerError {
+				return err
+			}
+			errs[i] = err
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// track records one impression inside tx
+func track(tx *bbolt.Tx, req entities.TrackImpressionRequest, now time.Time, ttl time.Duration) (error, int) {
+	id := []byte(req.CampaignID)
+	users := tx.Bucket(impressionsBucket).Bucket(id)
+	counters := tx.Bucket(statsBucket).Bucket(id)
+	if users == nil || counters == nil {
+		return repositories.ErrCampaignNotFound, http.StatusNotFound
+	}
+	campaign, err := loadCampaign(tx, id)
+	if errors.Is(err, repositories.ErrCampaignNotFound) {
+		return err, http.StatusNotFound
+	}
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if campaign.Status == entities.CampaignCompleted {
+		return repositories.ErrCampaignCompleted, http.StatusGone
+	}
+
+	if req.InvalidReason != "" {
+		if err := incrementKey(counters, invalidKey); err != nil {
+			return err, http.StatusInternalServerError
+		}
+		return repositories.ErrInvalidTraffic, http.StatusOK
+	}
+
+	arrival := repositories.ClassifyArrival(req, now)
+	if arrival.TooOld {
+		if err := incrementKey(counters, tooOldKey); err != nil {
+			return err, http.StatusInternalServerError
+		}
+		return repositories.ErrImpressionTooOld, http.StatusUnprocessableEntity
+	}
+
+	if last := users.Get([]byte(req.UserID)); last != nil {
+		if now.Sub(time.Unix(0, int64(decodeUint64(last)))) < ttl {
+			return repositories.ErrDuplicateImpression, http.StatusOK
+		}
+	}
+
+	if err := users.Put([]byte(req.UserID), encodeUint64(uint64(now.UnixNano()))); err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if err := countArrival(counters, arrival, now); err != nil {
+		return err, http.StatusInternalServerError
+	}
+
+	// The impression that reaches the limit completes the campaign
+	if limit := campaign.ImpressionLimit(); limit > 0 && int64(decodeUint64(counters.Get(totalKey))) >= limit {
+		completedAt := now.UTC()
+		campaign.Status = entities.CampaignCompleted
+		campaign.CompletedAt = &completedAt
+		if err := storeCampaign(tx, campaign); err != nil {
+			return err, http.StatusInternalServerError
+		}
+	}
+	return nil, http.StatusOK
+}
+
+// countArrival bumps the lifetime counters and the minute and hour buckets of the event time
+func countArrival(counters *bbolt.Bucket, arrival repositories.Arrival, now time.Time) error {
+	if err := incrementKey(counters, totalKey); err != nil {
+		return err
+	}
+	if arrival.Late {
+		if err := incrementKey(counters, lateKey); err != nil {
+			return err
+		}
+	}
+
+	if now.Sub(arrival.At) < minuteBucketRetention {
+		minutes, err := counters.CreateBucketIfNotExists(minutesKey)
+		if err != nil {
+			return err
+		}
+		if err := incrementKey(minutes, encodeUint64(uint64(repositories.MinuteOf(arrival.At)))); err != nil {
+			return err
+		}
+	}
+	hours, err := counters.CreateBucketIfNotExists(hoursKey)
+	if err != nil {
+		return err
+	}
+	return incrementKey(hours, encodeUint64(uint64(repositories.HourOf(arrival.At))))
+}
+
+func incrementKey(b *bbolt.Bucket, key []byte) error {
+	return b.Put(key, encodeUint64(decodeUint64(b.Get(key))+1))
+}
+
+// ApplyBackfill adds historical counts in one transaction, skipping buckets past their retention
+func (r *BoltImpressionRepository) ApplyBackfill(ctx context.Context, b repositories.Backfill) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		id := []byte(b.CampaignID)
+		counters := tx.Bucket(statsBucket).Bucket(id)
+		if counters == nil {
+			return repositories.ErrCampaignNotFound
+		}
+		campaign, err := loadCampaign(tx, id)
+		if err != nil {
+			return err
+		}
+		if b.ImportID != "" {
+			imports, err := counters.CreateBucketIfNotExists(importsKey)
+			if err != nil {
+				return err
+			}
+			if imports.Get([]byte(b.ImportID)) != nil {
+				return repositories.ErrBackfillApplied
+			}
+			if err := imports.Put([]byte(b.ImportID), encodeUint64(uint64(now.UnixNano()))); err != nil {
+				return err
+			}
+		}
+
+		total := decodeUint64(counters.Get(totalKey)) + uint64(b.Total)
+		if err := counters.Put(totalKey, encodeUint64(total)); err != nil {
+			return err
+		}
+		if err := addBuckets(counters, minutesKey, b.Minutes, repositories.MinuteOf(now.Add(-minuteBucketRetention))); err != nil {
+			return err
+		}
+		if err := addBuckets(counters, hoursKey, b.Hours, repositories.HourOf(now.Add(-hourBucketRetention))); err != nil {
+			return err
+		}
+
+		if limit := campaign.ImpressionLimit(); limit > 0 && int64(total) >= limit && campaign.Status != entities.CampaignCompleted {
+			completedAt := now.UTC()
+			campaign.Status = entities.CampaignCompleted
+			campaign.CompletedAt = &completedAt
+			return storeCampaign(tx, campaign)
+		}
+		return nil
+	})
+}
+
+// addBuckets adds the counts of the buckets from oldest on to the named time buckets of a campaign
+func addBuckets(counters *bbolt.Bucket, name []byte, counts map[int64]int64, oldest int64) error {
+	var buckets *bbolt.Bucket
+	for bucket, count := range counts {
+		if bucket < oldest {
+			continue
+		}
+		if buckets == nil {
+			var err error
+			if buckets, err = counters.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		key := encodeUint64(uint64(bucket))
+		if err := buckets.Put(key, encodeUint64(decodeUint64(buckets.Get(key))+uint64(count))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeExpired removes dedup entries older than the live TTL and time buckets past their retention,
+// and returns how many entries were deleted
+func (r *BoltImpressionRepository) PurgeExpired(ctx context.Context) (int, error) {
+	now := time.Now()
+	cutoff := now.Add(-time.Duration(config.Current().App.TTL) * time.Second).UnixNano()
+
+	purged := 0
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(statsBucket).ForEachBucket(func(campaignID []byte) error {
+			counters := tx.Bucket(statsBucket).Bucket(campaignID)
+			for _, retention := range []struct {
+				name   []byte
+				oldest uint64
+			}{
+				{minutesKey, uint64(repositories.MinuteOf(now.Add(-minuteBucketRetention)))},
+				{hoursKey, uint64(repositories.HourOf(now.Add(-hourBucketRetention)))},
+			} {
+				buckets := counters.Bucket(retention.name)
+				if buckets == nil {
+					continue
+				}
+				// Keys are big-endian bucket numbers, so the expired ones come first
+				c := buckets.Cursor()
+				for k, _ := c.First(); k != nil && decodeUint64(k) < retention.oldest; k, _ = c.First() {
+					if err := c.Delete(); err != nil {
+						return err
+					}
+					purged++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(impressionsBucket).ForEachBucket(func(campaignID []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			users := tx.Bucket(impressionsBucket).Bucket(campaignID)
+			var expired [][]byte
+			err := users.ForEach(func(userID, last []byte) error {
+				if int64(decodeUint64(last)) < cutoff {
+					expired = append(expired, append([]byte(nil), userID...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, userID := range expired {
+				if err := users.Delete(userID); err != nil {
+					return err
+				}
+			}
+			purged += len(expired)
+			return nil
+		})
+	})
+	return purged, err
+}
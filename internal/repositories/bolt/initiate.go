@@ -0,0 +1,79 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Bucket layout:
+//
+//	campaigns   campaign ID -> JSON entities.Campaign
+//	impressions campaign ID -> bucket of user ID -> last counted impression (unix nanos)
+//	stats       campaign ID -> bucket of counter name -> uint64, plus the nested buckets
+//	            m (Unix minute -> count) and h (Unix hour -> count) keyed by event time, and imports
+//	            (import ID -> when it was applied, unix nanos)
+//	webhooks    subscription ID -> JSON entities.WebhookSubscription
+//	webhook_events campaign ID -> bucket of announced event -> when it was announced (unix nanos)
+//	dead_letters   campaign ID -> bucket of sequence -> JSON entities.DeadLetter
+var (
+	campaignsBucket     = []byte("campaigns")
+	impressionsBucket   = []byte("impressions")
+	statsBucket         = []byte("stats")
+	webhooksBucket      = []byte("webhooks")
+	webhookEventsBucket = []byte("webhook_events")
+	deadLettersBucket   = []byte("dead_letters")
+)
+
+// Counter keys and time bucket names inside a campaign's stats bucket
+var (
+	totalKey   = []byte("total")
+	lateKey    = []byte("late")
+	tooOldKey  = []byte("too_old")
+	invalidKey = []byte("invalid")
+	minutesKey = []byte("m")
+	hoursKey   = []byte("h")
+	importsKey = []byte("imports")
+)
+
+// Time buckets older than these are deleted by PurgeExpired
+const (
+	minuteBucketRetention = 24 * time.Hour
+	hourBucketRetention   = 30 * 24 * time.Hour
+)
+
+// Open opens or creates the database file at path and makes sure every top-level bucket exists
+func Open(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{campaignsBucket, impressionsBucket, statsBucket, webhooksBucket, webhookEventsBucket, deadLettersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func encodeUint64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func decodeUint64(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
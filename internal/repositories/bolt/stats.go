@@ -0,0 +1,106 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+type BoltStatsRepository struct {
+	db *bbolt.DB
+}
+
+func NewBoltStatsRepository(db *bbolt.DB) *BoltStatsRepository {
+	return &BoltStatsRepository{db: db}
+}
+
+// GetCampaignStats reads the campaign counters and sums the time buckets in a read-only transaction
+func (r *BoltStatsRepository) GetCampaignStats(ctx context.Context, campaignID string) (entities.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Stats{}, err
+	}
+
+	stats := entities.Stats{CampaignID: campaignID}
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		counters := tx.Bucket(statsBucket).Bucket([]byte(campaignID))
+		if counters == nil {
+			return repositories.ErrCampaignNotFound
+		}
+
+		now := time.Now()
+		stats.LastHour = sumBuckets(counters.Bucket(minutesKey), repositories.MinuteOf(now), repositories.LastHourBuckets)
+		stats.LastDay = sumBuckets(counters.Bucket(hoursKey), repositories.HourOf(now), repositories.LastDayBuckets)
+		stats.TotalCount = int64(decodeUint64(counters.Get(totalKey)))
+		stats.Late = int64(decodeUint64(counters.Get(lateKey)))
+		stats.TooOld = int64(decodeUint64(counters.Get(tooOldKey)))
+		stats.Invalid = int64(decodeUint64(counters.Get(invalidKey)))
+
+		campaign, err := loadCampaign(tx, []byte(campaignID))
+		if err != nil {
+			return err
+		}
+		stats.Spend = campaign.Spend(stats.TotalCount)
+		return nil
+	})
+	if err != nil {
+		return entities.Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// GetCampaignSeries seeks to the first bucket of the query in the minute or hour buckets of the campaign
+func (r *BoltStatsRepository) GetCampaignSeries(ctx context.Context, q entities.SeriesQuery) ([]entities.SeriesPoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int64)
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		counters := tx.Bucket(statsBucket).Bucket([]byte(q.CampaignID))
+		if counters == nil {
+			return repositories.ErrCampaignNotFound
+		}
+
+		name := minutesKey
+		if q.Resolution == entities.ResolutionHour {
+			name = hoursKey
+		}
+		buckets := counters.Bucket(name)
+		first, last := repositories.SeriesBuckets(q)
+		if buckets == nil || last < first {
+			return nil
+		}
+
+		c := buckets.Cursor()
+		end := encodeUint64(uint64(last))
+		for k, v := c.Seek(encodeUint64(uint64(first))); k != nil && bytes.Compare(k, end) <= 0; k, v = c.Next() {
+			counts[int64(decodeUint64(k))] = int64(decodeUint64(v))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return repositories.DenseSeries(q, counts), nil
+}
+
+// sumBuckets adds the count buckets of the window ending with latest
+func sumBuckets(buckets *bbolt.Bucket, latest int64, count int) int64 {
+	if buckets == nil {
+		return 0
+	}
+
+	var sum int64
+	c := buckets.Cursor()
+	last := encodeUint64(uint64(latest))
+	for k, v := c.Seek(encodeUint64(uint64(latest - int64(count) + 1))); k != nil && bytes.Compare(k, last) <= 0; k, v = c.Next() {
+		sum += int64(decodeUint64(v))
+	}
+	return sum
+}
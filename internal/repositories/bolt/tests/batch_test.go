@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"learning/internal/repositories/bolt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBoltTrackImpressionsBatch(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "impressions.db"))
+	if err != nil {
+		t.Fatalf("❌ Failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	campaign, _ := bolt.NewBoltCampaignRepository(db).CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Batch", StartTime: time.Now()})
+
+	errs := bolt.NewBoltImpressionRepository(db).TrackImpressions(ctx, []entities.TrackImpressionRequest{
+		{CampaignID: campaign.ID, UserID: "user-1", AdID: "ad-1"},
+		{CampaignID: campaign.ID, UserID: "user-2", AdID: "ad-1"},
+		{CampaignID: campaign.ID, UserID: "user-1", AdID: "ad-1"},
+		{CampaignID: uuid.NewString(), UserID: "user-1", AdID: "ad-1"},
+	})
+
+	expected := []error{nil, nil, repositories.ErrDuplicateImpression, repositories.ErrCampaignNotFound}
+	for i, want := range expected {
+		if !errors.Is(errs[i], want) {
+			t.Errorf("❌ Request %d: expected %v, got %v", i, want, errs[i])
+		}
+	}
+
+	// Per-request outcomes do not roll back the rest of the batch
+	stats, _ := bolt.NewBoltStatsRepository(db).GetCampaignStats(ctx, campaign.ID)
+	if stats.TotalCount != 2 {
+		t.Errorf("❌ Expected total 2, got %d", stats.TotalCount)
+	}
+}
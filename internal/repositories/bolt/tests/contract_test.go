@@ -0,0 +1,25 @@
+package tests
+
+import (
+	"learning/internal/repositories/bolt"
+	"learning/internal/repositories/contract"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltRepositoryContract(t *testing.T) {
+	contract.Run(t, func(t *testing.T) contract.Repositories {
+		db, err := bolt.Open(filepath.Join(t.TempDir(), "impressions.db"))
+		if err != nil {
+			t.Fatalf("❌ Failed to open database: %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+
+		return contract.Repositories{
+			Campaigns:   bolt.NewBoltCampaignRepository(db),
+			Impressions: bolt.NewBoltImpressionRepository(db),
+			Stats:       bolt.NewBoltStatsRepository(db),
+			Webhooks:    bolt.NewBoltWebhookRepository(db),
+		}
+	})
+}
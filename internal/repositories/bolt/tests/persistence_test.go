@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/repositories/bolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltDataSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "impressions.db")
+	ctx := context.Background()
+
+	db, err := bolt.Open(path)
+	if err != nil {
+		t.Fatalf("❌ Failed to open database: %v", err)
+	}
+	campaign, err := bolt.NewBoltCampaignRepository(db).CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Persistent", StartTime: time.Now()})
+	if err != nil {
+		t.Fatalf("❌ Failed to create campaign: %v", err)
+	}
+	if err, _ := bolt.NewBoltImpressionRepository(db).TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "user-1", AdID: "ad-1"}); err != nil {
+		t.Fatalf("❌ Failed to track impression: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("❌ Failed to close database: %v", err)
+	}
+
+	db, err = bolt.Open(path)
+	if err != nil {
+		t.Fatalf("❌ Failed to reopen database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	stats, err := bolt.NewBoltStatsRepository(db).GetCampaignStats(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("❌ Failed to read stats: %v", err)
+	}
+	if stats.TotalCount != 1 {
+		t.Errorf("❌ Expected total 1 after reopen, got %d", stats.TotalCount)
+	}
+
+	// The dedup entry survives the restart too
+	if err, _ := bolt.NewBoltImpressionRepository(db).TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "user-1", AdID: "ad-1"}); err == nil {
+		t.Error("❌ Expected the impression to be deduplicated after reopen")
+	}
+}
+
+func TestBoltPurgeExpired(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "impressions.db"))
+	if err != nil {
+		t.Fatalf("❌ Failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	campaign, _ := bolt.NewBoltCampaignRepository(db).CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Purge", StartTime: time.Now()})
+	impressions := bolt.NewBoltImpressionRepository(db)
+	for _, user := range []string{"user-1", "user-2"} {
+		if err, _ := impressions.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: user, AdID: "ad-1"}); err != nil {
+			t.Fatalf("❌ Failed to track impression: %v", err)
+		}
+	}
+
+	if purged, err := impressions.PurgeExpired(ctx); err != nil || purged != 0 {
+		t.Fatalf("❌ Expected nothing to purge within the TTL, got %d (%v)", purged, err)
+	}
+
+	prev := config.Current()
+	next := *prev
+	next.App.TTL = 1
+	config.Set(&next)
+	defer config.Set(prev)
+	time.Sleep(1100 * time.Millisecond)
+
+	if purged, err := impressions.PurgeExpired(ctx); err != nil || purged != 2 {
+		t.Errorf("❌ Expected 2 purged entries, got %d (%v)", purged, err)
+	}
+}
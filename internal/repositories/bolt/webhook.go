@@ -0,0 +1,184 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+type BoltWebhookRepository struct {
+	db *bbolt.DB
+}
+
+// NewBoltWebhookRepository stores webhook subscriptions in the shared database
+func NewBoltWebhookRepository(db *bbolt.DB) *BoltWebhookRepository {
+	return &BoltWebhookRepository{db: db}
+}
+
+// CreateSubscription stores s after checking its campaign exists, in one transaction
+func (r *BoltWebhookRepository) CreateSubscription(ctx context.Context, s entities.WebhookSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		if _, err := loadCampaign(tx, []byte(s.CampaignID)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(webhooksBucket).Put([]byte(s.ID), value); err != nil {
+			return fmt.Errorf("store webhook subscription: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListSubscriptions decodes the matching subscriptions in a read-only transaction
+func (r *BoltWebhookRepository) ListSubscriptions(ctx context.Context, campaignID string) ([]entities.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	subscriptions := []entities.WebhookSubscription{}
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(webhooksBucket).ForEach(func(_, value []byte) error {
+			var s entities.WebhookSubscription
+			if err := json.Unmarshal(value, &s); err != nil {
+				return fmt.Errorf("decode webhook subscription: %w", err)
+			}
+			if campaignID == "" || s.CampaignID == campaignID {
+				subscriptions = append(subscriptions, s)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	repositories.SortSubscriptions(subscriptions)
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes the subscription when it belongs to the campaign
+func (r *BoltWebhookRepository) DeleteSubscription(ctx context.Context, campaignID, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(webhooksBucket)
+		value := bucket.Get([]byte(id))
+		if value == nil {
+			return repositories.ErrSubscriptionNotFound
+		}
+		var s entities.WebhookSubscription
+		if err := json.Unmarshal(value, &s); err != nil {
+			return fmt.Errorf("decode webhook subscription: %w", err)
+		}
+		if s.CampaignID != campaignID {
+			return repositories.ErrSubscriptionNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// ClaimWebhookEvent records the event unless the campaign's bucket already holds it. Bolt serializes
+// writers, so exactly one claim finds it missing.
+func (r *BoltWebhookRepository) ClaimWebhookEvent(ctx context.Context, campaignID, event string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var claimed bool
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		events, err := tx.Bucket(webhookEventsBucket).CreateBucketIfNotExists([]byte(campaignID))
+		if err != nil {
+			return err
+		}
+		if events.Get([]byte(event)) != nil {
+			return nil
+		}
+		claimed = true
+		return events.Put([]byte(event), encodeUint64(uint64(time.Now().UnixNano())))
+	})
+	if err != nil {
+		return false, err
+	}
+	return claimed, nil
+}
+
+// AddDeadLetter appends the letter under the next sequence of its campaign and deletes the oldest ones
+// beyond limit
+func (r *BoltWebhookRepository) AddDeadLetter(ctx context.Context, letter entities.DeadLetter, limit int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		letters, err := tx.Bucket(deadLettersBucket).CreateBucketIfNotExists([]byte(letter.Payload.CampaignID))
+		if err != nil {
+			return err
+		}
+		seq, err := letters.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := letters.Put(encodeUint64(seq), value); err != nil {
+			return err
+		}
+
+		// Keys are big-endian sequences, so the cursor walks them oldest first
+		cursor := letters.Cursor()
+		stored := 0
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			stored++
+		}
+		for key, _ := cursor.First(); key != nil && stored > limit; key, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			stored--
+		}
+		return nil
+	})
+}
+
+// ListDeadLetters decodes the letters of the campaign, newest first
+func (r *BoltWebhookRepository) ListDeadLetters(ctx context.Context, campaignID string) ([]entities.DeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	letters := []entities.DeadLetter{}
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(deadLettersBucket).Bucket([]byte(campaignID))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var letter entities.DeadLetter
+			if err := json.Unmarshal(value, &letter); err != nil {
+				return fmt.Errorf("decode dead letter: %w", err)
+			}
+			letters = append(letters, letter)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"time"
+)
+
+// Windowed stats are sums of time buckets keyed by the event time of each impression
+const (
+	LastHourBuckets = 60 // minute buckets summed into last_hour
+	LastDayBuckets  = 24 // hour buckets summed into last_day
+)
+
+// Arrival describes when an impression happened relative to when it was applied
+type Arrival struct {
+	// At is the event time used for bucketing; it is never after the time of application
+	At     time.Time
+	Late   bool
+	TooOld bool
+}
+
+// ClassifyArrival resolves the event time of req applied at now against the live lateness limits.
+// Timestamps slightly in the future, within the allowed clock skew, are attributed to now.
+func ClassifyArrival(req entities.TrackImpressionRequest, now time.Time) Arrival {
+	if req.Timestamp == nil || req.Timestamp.After(now) {
+		return Arrival{At: now}
+	}
+
+	limits := config.Current().Ingestion
+	age := now.Sub(*req.Timestamp)
+	return Arrival{
+		At:     *req.Timestamp,
+		Late:   age > limits.LateAfter,
+		TooOld: age > limits.MaxLateness,
+	}
+}
+
+// MinuteOf and HourOf number the bucket t falls into
+func MinuteOf(t time.Time) int64 {
+	return t.Unix() / 60
+}
+
+func HourOf(t time.Time) int64 {
+	return t.Unix() / 3600
+}
+
+// SeriesBuckets returns the numbers of the first and last bucket starting in the range of q
+func SeriesBuckets(q entities.SeriesQuery) (first, last int64) {
+	width := bucketWidth(q.Resolution) * int64(time.Second)
+	// A bucket is included when it starts within the range, so both ends round up
+	first = ceilDiv(q.From.UnixNano(), width)
+	last = ceilDiv(q.To.UnixNano(), width) - 1
+	return first, last
+}
+
+// DenseSeries lists every bucket of q, taking the non-empty ones from counts by bucket number
+func DenseSeries(q entities.SeriesQuery, counts map[int64]int64) []entities.SeriesPoint {
+	width := bucketWidth(q.Resolution)
+	first, last := SeriesBuckets(q)
+	points := make([]entities.SeriesPoint, 0, max(last-first+1, 0))
+	for b := first; b <= last; b++ {
+		points = append(points, entities.SeriesPoint{Start: time.Unix(b*width, 0).UTC(), Count: counts[b]})
+	}
+	return points
+}
+
+// bucketWidth is the width in seconds of the buckets of a series resolution
+func bucketWidth(resolution string) int64 {
+	if resolution == entities.ResolutionHour {
+		return 3600
+	}
+	return 60
+}
+
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
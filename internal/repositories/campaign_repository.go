@@ -1,7 +1,17 @@
 package repositories
 
-import "learning/internal/entities"
+import (
+	"context"
+	"learning/internal/entities"
+)
 
 type CampaignRepository interface {
-	CreateCampaign(req entities.CreateCampaignRequest) (entities.Campaign, error)
+	CreateCampaign(ctx context.Context, req entities.CreateCampaignRequest) (entities.Campaign, error)
+	// GetCampaign returns ErrCampaignNotFound for unknown campaigns
+	GetCampaign(ctx context.Context, id string) (entities.Campaign, error)
+	// UpdateCampaign applies the fields set in req to the campaign and returns it, or ErrCampaignNotFound
+	UpdateCampaign(ctx context.Context, id string, req entities.UpdateCampaignRequest) (entities.Campaign, error)
+	// ListCampaigns calls fn with every campaign in ID order, without holding them all in memory
+	// where the backend allows. It stops at the first error fn returns and returns it.
+	ListCampaigns(ctx context.Context, fn func(entities.Campaign) error) error
 }
@@ -0,0 +1,820 @@
+// Package contract is a backend-agnostic conformance suite for the repository interfaces.
+// A storage backend is certified by calling Run from one of its tests with a Factory that
+// returns fresh, empty repositories sharing one store.
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+// Repositories bundles one backend's implementation of every repository interface
+type Repositories struct {
+	Campaigns   repositories.CampaignRepository
+	Impressions repositories.ImpressionRepository
+	Stats       repositories.StatsRepository
+	Webhooks    repositories.WebhookRepository
+}
+
+// Factory returns fresh, empty repositories backed by the same store
+type Factory func(t *testing.T) Repositories
+
+// Run executes the whole conformance suite against the backend built by newRepos
+func Run(t *testing.T, newRepos Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repos Repositories)
+	}{
+		{"CreateCampaign", testCreateCampaign},
+		{"CampaignIDsAreUnique", testCampaignIDsAreUnique},
+		{"GetCampaign", testGetCampaign},
+		{"UpdateCampaign", testUpdateCampaign},
+		{"TrackImpressionUnknownCampaign", testTrackImpressionUnknownCampaign},
+		{"StatsUnknownCampaign", testStatsUnknownCampaign},
+		{"StatsCountUniqueUsers", testStatsCountUniqueUsers},
+		{"DedupWithinTTL", testDedupWithinTTL},
+		{"DedupIsPerCampaign", testDedupIsPerCampaign},
+		{"DedupExpiresAfterTTL", testDedupExpiresAfterTTL},
+		{"ClientTimestampBuckets", testClientTimestampBuckets},
+		{"TooOldImpression", testTooOldImpression},
+		{"InvalidTraffic", testInvalidTraffic},
+		{"CancelledContext", testCancelledContext},
+		{"ConcurrentTracking", testConcurrentTracking},
+		{"ImpressionGoal", testImpressionGoal},
+		{"BudgetUnderConcurrency", testBudgetUnderConcurrency},
+		{"ListCampaigns", testListCampaigns},
+		{"CampaignSeries", testCampaignSeries},
+		{"Backfill", testBackfill},
+		{"WebhookSubscriptions", testWebhookSubscriptions},
+		{"WebhookEvents", testWebhookEvents},
+		{"DeadLetters", testDeadLetters},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newRepos(t))
+		})
+	}
+}
+
+func createCampaign(t *testing.T, repos Repositories, name string) entities.Campaign {
+	t.Helper()
+
+	campaign, err := repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{
+		Name:      name,
+		StartTime: time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+	return campaign
+}
+
+func track(t *testing.T, repos Repositories, campaignID, userID string) (error, int) {
+	t.Helper()
+	return repos.Impressions.TrackImpression(context.Background(), entities.TrackImpressionRequest{
+		CampaignID: campaignID,
+		UserID:     userID,
+		AdID:       "ad-1",
+	})
+}
+
+func mustTrack(t *testing.T, repos Repositories, campaignID, userID string) {
+	t.Helper()
+	if err, status := track(t, repos, campaignID, userID); err != nil {
+		t.Fatalf("❌ TrackImpression(%s) failed with status %d: %v", userID, status, err)
+	}
+}
+
+func stats(t *testing.T, repos Repositories, campaignID string) entities.Stats {
+	t.Helper()
+
+	s, err := repos.Stats.GetCampaignStats(context.Background(), campaignID)
+	if err != nil {
+		t.Fatalf("❌ GetCampaignStats failed: %v", err)
+	}
+	return s
+}
+
+func expectCounts(t *testing.T, s entities.Stats, lastHour, lastDay, total int64) {
+	t.Helper()
+	if s.LastHour != lastHour || s.LastDay != lastDay || s.TotalCount != total {
+		t.Errorf("❌ Expected last_hour=%d last_day=%d total=%d, got %d %d %d",
+			lastHour, lastDay, total, s.LastHour, s.LastDay, s.TotalCount)
+	}
+}
+
+// withTTL swaps the live config for one with the given dedup TTL for the rest of the test
+func withTTL(t *testing.T, seconds int) {
+	prev := config.Current()
+	next := *prev
+	next.App.TTL = seconds
+	config.Set(&next)
+	t.Cleanup(func() { config.Set(prev) })
+}
+
+func testCreateCampaign(t *testing.T, repos Repositories) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	campaign, err := repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Contract", StartTime: start})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+
+	if _, err := uuid.Parse(campaign.ID); err != nil {
+		t.Errorf("❌ Expected a UUID campaign ID, got %q", campaign.ID)
+	}
+	if campaign.Name != "Contract" || !campaign.StartTime.Equal(start) {
+		t.Errorf("❌ Campaign fields not preserved: %+v", campaign)
+	}
+
+	s := stats(t, repos, campaign.ID)
+	if s.CampaignID != campaign.ID {
+		t.Errorf("❌ Expected stats for %s, got %s", campaign.ID, s.CampaignID)
+	}
+	expectCounts(t, s, 0, 0, 0)
+}
+
+func testCampaignIDsAreUnique(t *testing.T, repos Repositories) {
+	seen := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		campaign := createCampaign(t, repos, "Same Name")
+		if seen[campaign.ID] {
+			t.Fatalf("❌ Campaign ID %s returned twice", campaign.ID)
+		}
+		seen[campaign.ID] = true
+	}
+}
+
+func testGetCampaign(t *testing.T, repos Repositories) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(30 * 24 * time.Hour)
+	created, err := repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Flight", StartTime: start, EndTime: &end})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+
+	campaign, err := repos.Campaigns.GetCampaign(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("❌ GetCampaign failed: %v", err)
+	}
+	if campaign.ID != created.ID || campaign.Name != "Flight" || !campaign.StartTime.Equal(start) || campaign.EndTime == nil || !campaign.EndTime.Equal(end) {
+		t.Errorf("❌ Campaign fields not preserved: %+v", campaign)
+	}
+
+	open := createCampaign(t, repos, "Open Ended")
+	if campaign, err := repos.Campaigns.GetCampaign(context.Background(), open.ID); err != nil || campaign.EndTime != nil {
+		t.Errorf("❌ Expected an open-ended campaign, got %+v, %v", campaign, err)
+	}
+
+	if _, err := repos.Campaigns.GetCampaign(context.Background(), uuid.NewString()); !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+}
+
+func testUpdateCampaign(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	created, err := repos.Campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{
+		Name: "Before", StartTime: time.Now().UTC().Truncate(time.Second), ImpressionGoal: 1,
+	})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+	mustTrack(t, repos, created.ID, "user-1")
+
+	// Renaming keeps the other fields and the completion of the campaign
+	name := "After"
+	campaign, err := repos.Campaigns.UpdateCampaign(ctx, created.ID, entities.UpdateCampaignRequest{Name: &name})
+	if err != nil {
+		t.Fatalf("❌ UpdateCampaign failed: %v", err)
+	}
+	if campaign.Name != "After" || campaign.EndTime != nil || campaign.ImpressionGoal != 1 || campaign.Status != entities.CampaignCompleted || campaign.CompletedAt == nil {
+		t.Errorf("❌ Expected only the name to change, got %+v", campaign)
+	}
+
+	end := created.StartTime.Add(24 * time.Hour)
+	if _, err := repos.Campaigns.UpdateCampaign(ctx, created.ID, entities.UpdateCampaignRequest{EndTime: &end}); err != nil {
+		t.Fatalf("❌ UpdateCampaign failed: %v", err)
+	}
+	campaign, err = repos.Campaigns.GetCampaign(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("❌ GetCampaign failed: %v", err)
+	}
+	if campaign.Name != "After" || campaign.EndTime == nil || !campaign.EndTime.Equal(end) || campaign.Status != entities.CampaignCompleted {
+		t.Errorf("❌ Expected the new name and end to be stored, got %+v", campaign)
+	}
+
+	if _, err := repos.Campaigns.UpdateCampaign(ctx, uuid.NewString(), entities.UpdateCampaignRequest{Name: &name}); !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+}
+
+func testTrackImpressionUnknownCampaign(t *testing.T, repos Repositories) {
+	err, status := track(t, repos, uuid.NewString(), "user-1")
+	if !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+	if status != http.StatusNotFound {
+		t.Errorf("❌ Expected status %d, got %d", http.StatusNotFound, status)
+	}
+}
+
+func testStatsUnknownCampaign(t *testing.T, repos Repositories) {
+	_, err := repos.Stats.GetCampaignStats(context.Background(), uuid.NewString())
+	if !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+}
+
+func testStatsCountUniqueUsers(t *testing.T, repos Repositories) {
+	campaign := createCampaign(t, repos, "Counting")
+	other := createCampaign(t, repos, "Untouched")
+
+	for i := 0; i < 25; i++ {
+		mustTrack(t, repos, campaign.ID, fmt.Sprintf("user-%d", i))
+	}
+
+	expectCounts(t, stats(t, repos, campaign.ID), 25, 25, 25)
+	expectCounts(t, stats(t, repos, other.ID), 0, 0, 0)
+}
+
+func testDedupWithinTTL(t *testing.T, repos Repositories) {
+	withTTL(t, 3600)
+	campaign := createCampaign(t, repos, "Dedup")
+
+	mustTrack(t, repos, campaign.ID, "user-1")
+	err, status := track(t, repos, campaign.ID, "user-1")
+	if !errors.Is(err, repositories.ErrDuplicateImpression) {
+		t.Errorf("❌ Expected ErrDuplicateImpression, got %v", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("❌ Expected duplicates to be acknowledged with %d, got %d", http.StatusOK, status)
+	}
+	mustTrack(t, repos, campaign.ID, "user-2")
+
+	expectCounts(t, stats(t, repos, campaign.ID), 2, 2, 2)
+}
+
+func testDedupIsPerCampaign(t *testing.T, repos Repositories) {
+	withTTL(t, 3600)
+	first := createCampaign(t, repos, "First")
+	second := createCampaign(t, repos, "Second")
+
+	mustTrack(t, repos, first.ID, "user-1")
+	mustTrack(t, repos, second.ID, "user-1")
+
+	expectCounts(t, stats(t, repos, first.ID), 1, 1, 1)
+	expectCounts(t, stats(t, repos, second.ID), 1, 1, 1)
+}
+
+func testDedupExpiresAfterTTL(t *testing.T, repos Repositories) {
+	withTTL(t, 1)
+	campaign := createCampaign(t, repos, "Expiry")
+
+	mustTrack(t, repos, campaign.ID, "user-1")
+	time.Sleep(1100 * time.Millisecond)
+	mustTrack(t, repos, campaign.ID, "user-1")
+
+	expectCounts(t, stats(t, repos, campaign.ID), 2, 2, 2)
+}
+
+func trackAt(t *testing.T, repos Repositories, campaignID, userID string, at time.Time) (error, int) {
+	t.Helper()
+	return repos.Impressions.TrackImpression(context.Background(), entities.TrackImpressionRequest{
+		CampaignID: campaignID,
+		UserID:     userID,
+		AdID:       "ad-1",
+		Timestamp:  &at,
+	})
+}
+
+func testClientTimestampBuckets(t *testing.T, repos Repositories) {
+	campaign := createCampaign(t, repos, "Timestamps")
+
+	// Two hours late: outside last_hour, inside last_day, and counted as late
+	if err, status := trackAt(t, repos, campaign.ID, "user-1", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatalf("❌ Late impression failed with status %d: %v", status, err)
+	}
+	// A few seconds of delay is not late
+	if err, status := trackAt(t, repos, campaign.ID, "user-2", time.Now().Add(-5*time.Second)); err != nil {
+		t.Fatalf("❌ Recent impression failed with status %d: %v", status, err)
+	}
+
+	s := stats(t, repos, campaign.ID)
+	expectCounts(t, s, 1, 2, 2)
+	if s.Late != 1 || s.TooOld != 0 {
+		t.Errorf("❌ Expected late=1 too_old=0, got %d %d", s.Late, s.TooOld)
+	}
+}
+
+func testTooOldImpression(t *testing.T, repos Repositories) {
+	campaign := createCampaign(t, repos, "Too Old")
+
+	err, status := trackAt(t, repos, campaign.ID, "user-1", time.Now().Add(-config.Current().Ingestion.MaxLateness-time.Hour))
+	if !errors.Is(err, repositories.ErrImpressionTooOld) {
+		t.Errorf("❌ Expected ErrImpressionTooOld, got %v", err)
+	}
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("❌ Expected status %d, got %d", http.StatusUnprocessableEntity, status)
+	}
+
+	// The rejected impression did not claim the dedup slot
+	mustTrack(t, repos, campaign.ID, "user-1")
+
+	s := stats(t, repos, campaign.ID)
+	expectCounts(t, s, 1, 1, 1)
+	if s.TooOld != 1 || s.Late != 0 {
+		t.Errorf("❌ Expected too_old=1 late=0, got %d %d", s.TooOld, s.Late)
+	}
+}
+
+func testInvalidTraffic(t *testing.T, repos Repositories) {
+	campaign := createCampaign(t, repos, "Invalid Traffic")
+
+	req := entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "user-1", AdID: "ad-1", InvalidReason: "bot user agent"}
+	err, status := repos.Impressions.TrackImpression(context.Background(), req)
+	if !errors.Is(err, repositories.ErrInvalidTraffic) || status != http.StatusOK {
+		t.Errorf("❌ Expected ErrInvalidTraffic with status 200, got %v %d", err, status)
+	}
+
+	req.CampaignID = uuid.NewString()
+	if err, _ := repos.Impressions.TrackImpression(context.Background(), req); !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound for an unknown campaign, got %v", err)
+	}
+
+	// The filtered impression did not claim the dedup slot
+	mustTrack(t, repos, campaign.ID, "user-1")
+
+	s := stats(t, repos, campaign.ID)
+	expectCounts(t, s, 1, 1, 1)
+	if s.Invalid != 1 {
+		t.Errorf("❌ Expected invalid=1, got %d", s.Invalid)
+	}
+}
+
+func testCancelledContext(t *testing.T, repos Repositories) {
+	campaign := createCampaign(t, repos, "Cancelled")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repos.Campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "x", StartTime: time.Now()}); !errors.Is(err, context.Canceled) {
+		t.Errorf("❌ CreateCampaign: expected context.Canceled, got %v", err)
+	}
+	err, _ := repos.Impressions.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "user-1", AdID: "ad-1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("❌ TrackImpression: expected context.Canceled, got %v", err)
+	}
+	if _, err := repos.Stats.GetCampaignStats(ctx, campaign.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("❌ GetCampaignStats: expected context.Canceled, got %v", err)
+	}
+
+	expectCounts(t, stats(t, repos, campaign.ID), 0, 0, 0)
+}
+
+func testConcurrentTracking(t *testing.T, repos Repositories) {
+	withTTL(t, 3600)
+	campaign := createCampaign(t, repos, "Concurrent")
+
+	const users, repeats = 50, 4
+	var wg sync.WaitGroup
+	for i := 0; i < users; i++ {
+		for j := 0; j < repeats; j++ {
+			wg.Add(1)
+			go func(userID string) {
+				defer wg.Done()
+				err, _ := track(t, repos, campaign.ID, userID)
+				if err != nil && !errors.Is(err, repositories.ErrDuplicateImpression) {
+					t.Errorf("❌ TrackImpression failed: %v", err)
+				}
+			}(fmt.Sprintf("user-%d", i))
+		}
+
+		// Interleave reads and campaign creation with the writes
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = repos.Stats.GetCampaignStats(context.Background(), campaign.ID)
+			_, _ = repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Noise", StartTime: time.Now()})
+		}()
+	}
+	wg.Wait()
+
+	expectCounts(t, stats(t, repos, campaign.ID), users, users, users)
+}
+
+func testImpressionGoal(t *testing.T, repos Repositories) {
+	withTTL(t, 3600)
+	created, err := repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{
+		Name:           "Goal",
+		StartTime:      time.Now().UTC().Truncate(time.Second),
+		ImpressionGoal: 2,
+		CPM:            4,
+	})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+	if created.Status != entities.CampaignActive {
+		t.Errorf("❌ Expected a new campaign to be active, got %q", created.Status)
+	}
+
+	mustTrack(t, repos, created.ID, "user-1")
+	if campaign, err := repos.Campaigns.GetCampaign(context.Background(), created.ID); err != nil || campaign.Status != entities.CampaignActive || campaign.ImpressionGoal != 2 || campaign.CPM != 4 {
+		t.Errorf("❌ Expected an active campaign with its goal and CPM, got %+v, %v", campaign, err)
+	}
+	mustTrack(t, repos, created.ID, "user-2")
+
+	campaign, err := repos.Campaigns.GetCampaign(context.Background(), created.ID)
+	if err != nil || campaign.Status != entities.CampaignCompleted || campaign.CompletedAt == nil {
+		t.Errorf("❌ Expected the goal to complete the campaign, got %+v, %v", campaign, err)
+	}
+
+	// New users, returning users and filtered traffic are all turned away
+	for _, req := range []entities.TrackImpressionRequest{
+		{CampaignID: created.ID, UserID: "user-3", AdID: "ad-1"},
+		{CampaignID: created.ID, UserID: "user-1", AdID: "ad-1"},
+		{CampaignID: created.ID, UserID: "user-4", AdID: "ad-1", InvalidReason: "bot user agent"},
+	} {
+		err, status := repos.Impressions.TrackImpression(context.Background(), req)
+		if !errors.Is(err, repositories.ErrCampaignCompleted) || status != http.StatusGone {
+			t.Errorf("❌ Expected ErrCampaignCompleted with status 410 for %s, got %v %d", req.UserID, err, status)
+		}
+	}
+
+	s := stats(t, repos, created.ID)
+	expectCounts(t, s, 2, 2, 2)
+	if s.Invalid != 0 || s.Spend != 0.008 {
+		t.Errorf("❌ Expected invalid=0 spend=0.008, got %d %v", s.Invalid, s.Spend)
+	}
+}
+
+func testBudgetUnderConcurrency(t *testing.T, repos Repositories) {
+	withTTL(t, 3600)
+	// A budget of 0.05 at a CPM of 5 buys 10 impressions
+	campaign, err := repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{
+		Name:      "Budget",
+		StartTime: time.Now().UTC().Truncate(time.Second),
+		Budget:    0.05,
+		CPM:       5,
+	})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+
+	const users = 30
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	counted, completed := 0, 0
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			err, _ := track(t, repos, campaign.ID, userID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				counted++
+			case errors.Is(err, repositories.ErrCampaignCompleted):
+				completed++
+			default:
+				t.Errorf("❌ TrackImpression failed: %v", err)
+			}
+		}(fmt.Sprintf("user-%d", i))
+	}
+	wg.Wait()
+
+	if counted != 10 || completed != users-10 {
+		t.Errorf("❌ Expected 10 counted and %d rejected, got %d and %d", users-10, counted, completed)
+	}
+	s := stats(t, repos, campaign.ID)
+	expectCounts(t, s, 10, 10, 10)
+	if s.Spend != 0.05 {
+		t.Errorf("❌ Expected spend=0.05, got %v", s.Spend)
+	}
+}
+
+func testListCampaigns(t *testing.T, repos Repositories) {
+	created := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		created[createCampaign(t, repos, fmt.Sprintf("Listed %d", i)).ID] = true
+	}
+
+	var listed []entities.Campaign
+	err := repos.Campaigns.ListCampaigns(context.Background(), func(c entities.Campaign) error {
+		listed = append(listed, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("❌ ListCampaigns failed: %v", err)
+	}
+	if len(listed) != len(created) {
+		t.Fatalf("❌ Expected %d campaigns, got %d", len(created), len(listed))
+	}
+	for i, c := range listed {
+		if !created[c.ID] || c.Status != entities.CampaignActive {
+			t.Errorf("❌ Unexpected campaign %+v", c)
+		}
+		if i > 0 && listed[i-1].ID >= c.ID {
+			t.Errorf("❌ Expected campaigns in ID order, got %s before %s", listed[i-1].ID, c.ID)
+		}
+	}
+
+	// The first error of fn stops the listing
+	stop := errors.New("stop")
+	calls := 0
+	err = repos.Campaigns.ListCampaigns(context.Background(), func(entities.Campaign) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("❌ Expected the listing to stop with fn's error after one call, got %v after %d", err, calls)
+	}
+}
+
+func testCampaignSeries(t *testing.T, repos Repositories) {
+	campaign := createCampaign(t, repos, "Series")
+	now := time.Now().UTC()
+	mustTrack(t, repos, campaign.ID, "user-1")
+	if err, status := trackAt(t, repos, campaign.ID, "user-2", now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("❌ Late impression failed with status %d: %v", status, err)
+	}
+
+	hour := now.Truncate(time.Hour)
+	points, err := repos.Stats.GetCampaignSeries(context.Background(), entities.SeriesQuery{
+		CampaignID: campaign.ID,
+		Resolution: entities.ResolutionHour,
+		From:       hour.Add(-2 * time.Hour),
+		To:         hour.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("❌ GetCampaignSeries failed: %v", err)
+	}
+	if len(points) != 3 || points[0].Count != 1 || points[1].Count != 0 || points[2].Count != 1 {
+		t.Fatalf("❌ Expected hour counts [1 0 1], got %+v", points)
+	}
+	for i, p := range points {
+		if !p.Start.Equal(hour.Add(time.Duration(i-2) * time.Hour)) {
+			t.Errorf("❌ Expected point %d to start at %s, got %s", i, hour.Add(time.Duration(i-2)*time.Hour), p.Start)
+		}
+	}
+
+	// A sub-minute From leaves out the minute it falls in
+	minute := time.Now().UTC().Truncate(time.Minute)
+	points, err = repos.Stats.GetCampaignSeries(context.Background(), entities.SeriesQuery{
+		CampaignID: campaign.ID,
+		Resolution: entities.ResolutionMinute,
+		From:       minute.Add(-5*time.Minute + time.Second),
+		To:         minute.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("❌ GetCampaignSeries failed: %v", err)
+	}
+	var total int64
+	for _, p := range points {
+		total += p.Count
+	}
+	if len(points) != 5 || !points[0].Start.Equal(minute.Add(-4*time.Minute)) || total != 1 {
+		t.Errorf("❌ Expected 5 minutes from %s holding one impression, got %+v", minute.Add(-4*time.Minute), points)
+	}
+
+	_, err = repos.Stats.GetCampaignSeries(context.Background(), entities.SeriesQuery{
+		CampaignID: uuid.NewString(),
+		Resolution: entities.ResolutionHour,
+		From:       hour,
+		To:         hour.Add(time.Hour),
+	})
+	if !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+}
+
+// backfillOf counts impressions that happened at the given times
+func backfillOf(campaignID string, times ...time.Time) repositories.Backfill {
+	b := repositories.Backfill{CampaignID: campaignID, Minutes: map[int64]int64{}, Hours: map[int64]int64{}}
+	for _, at := range times {
+		b.Total++
+		b.Minutes[repositories.MinuteOf(at)]++
+		b.Hours[repositories.HourOf(at)]++
+	}
+	return b
+}
+
+func testBackfill(t *testing.T, repos Repositories) {
+	backfill, ok := repos.Impressions.(repositories.BackfillRepository)
+	if !ok {
+		t.Skip("backend does not implement BackfillRepository")
+	}
+
+	created, err := repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{
+		Name:           "Backfill",
+		StartTime:      time.Now().UTC().Add(-60 * 24 * time.Hour).Truncate(time.Second),
+		ImpressionGoal: 5,
+	})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+
+	// Months-old impressions only reach the total; their buckets are long past retention
+	now := time.Now()
+	err = backfill.ApplyBackfill(context.Background(), backfillOf(created.ID,
+		now.Add(-10*time.Minute), now.Add(-3*time.Hour), now.Add(-3*time.Hour), now.Add(-40*24*time.Hour)))
+	if err != nil {
+		t.Fatalf("❌ ApplyBackfill failed: %v", err)
+	}
+	s := stats(t, repos, created.ID)
+	expectCounts(t, s, 1, 3, 4)
+	if s.Late != 0 || s.TooOld != 0 {
+		t.Errorf("❌ Expected backfilled impressions not to count as late or too old, got %d %d", s.Late, s.TooOld)
+	}
+
+	// Reaching the goal completes the campaign
+	if err := backfill.ApplyBackfill(context.Background(), backfillOf(created.ID, now.Add(-50*24*time.Hour))); err != nil {
+		t.Fatalf("❌ ApplyBackfill failed: %v", err)
+	}
+	if campaign, err := repos.Campaigns.GetCampaign(context.Background(), created.ID); err != nil || campaign.Status != entities.CampaignCompleted {
+		t.Errorf("❌ Expected the backfill to complete the campaign, got %+v, %v", campaign, err)
+	}
+	if err, _ := track(t, repos, created.ID, "user-1"); !errors.Is(err, repositories.ErrCampaignCompleted) {
+		t.Errorf("❌ Expected ErrCampaignCompleted after the backfill, got %v", err)
+	}
+
+	if err := backfill.ApplyBackfill(context.Background(), backfillOf(uuid.NewString(), now)); !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+
+	// An import is applied to a campaign once; another import, or another campaign, still applies
+	other, err := repos.Campaigns.CreateCampaign(context.Background(), entities.CreateCampaignRequest{
+		Name:      "Backfill Retry",
+		StartTime: time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Second),
+	})
+	if err != nil {
+		t.Fatalf("❌ CreateCampaign failed: %v", err)
+	}
+	for i, apply := range []struct {
+		campaignID, importID string
+		err                  error
+	}{
+		{other.ID, "import-1", nil},
+		{other.ID, "import-1", repositories.ErrBackfillApplied},
+		{other.ID, "import-2", nil},
+		{created.ID, "import-1", nil},
+	} {
+		b := backfillOf(apply.campaignID, now.Add(-3*time.Hour))
+		b.ImportID = apply.importID
+		if err := backfill.ApplyBackfill(context.Background(), b); !errors.Is(err, apply.err) {
+			t.Errorf("❌ Backfill %d: expected %v, got %v", i, apply.err, err)
+		}
+	}
+	expectCounts(t, stats(t, repos, other.ID), 0, 2, 2)
+}
+
+func subscription(campaignID string, createdAt time.Time) entities.WebhookSubscription {
+	return entities.WebhookSubscription{
+		ID:         uuid.NewString(),
+		CampaignID: campaignID,
+		URL:        "https://example.com/hook",
+		Events:     []string{entities.WebhookCampaignStarted, entities.WebhookImpressions1K},
+		Secret:     "secret",
+		CreatedAt:  createdAt,
+	}
+}
+
+func testWebhookSubscriptions(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	campaign := createCampaign(t, repos, "Subscribed")
+	other := createCampaign(t, repos, "Other")
+
+	if err := repos.Webhooks.CreateSubscription(ctx, subscription(uuid.NewString(), time.Now())); !errors.Is(err, repositories.ErrCampaignNotFound) {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+
+	created := time.Now().UTC().Truncate(time.Microsecond)
+	newer := subscription(campaign.ID, created.Add(time.Millisecond))
+	older := subscription(campaign.ID, created)
+	for _, s := range []entities.WebhookSubscription{newer, older, subscription(other.ID, created)} {
+		if err := repos.Webhooks.CreateSubscription(ctx, s); err != nil {
+			t.Fatalf("❌ CreateSubscription failed: %v", err)
+		}
+	}
+
+	listed, err := repos.Webhooks.ListSubscriptions(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("❌ ListSubscriptions failed: %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != older.ID || listed[1].ID != newer.ID {
+		t.Fatalf("❌ Expected the two subscriptions of the campaign oldest first, got %+v", listed)
+	}
+	if got := listed[0]; got.CampaignID != older.CampaignID || got.URL != older.URL || got.Secret != older.Secret ||
+		len(got.Events) != 2 || got.Events[1] != entities.WebhookImpressions1K || !got.CreatedAt.Equal(older.CreatedAt) {
+		t.Errorf("❌ Subscription fields not preserved: %+v", got)
+	}
+	if all, err := repos.Webhooks.ListSubscriptions(ctx, ""); err != nil || len(all) != 3 {
+		t.Errorf("❌ Expected the subscriptions of every campaign, got %d, %v", len(all), err)
+	}
+
+	if err := repos.Webhooks.DeleteSubscription(ctx, other.ID, older.ID); !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("❌ Expected ErrSubscriptionNotFound through another campaign, got %v", err)
+	}
+	if err := repos.Webhooks.DeleteSubscription(ctx, campaign.ID, older.ID); err != nil {
+		t.Errorf("❌ DeleteSubscription failed: %v", err)
+	}
+	if err := repos.Webhooks.DeleteSubscription(ctx, campaign.ID, older.ID); !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("❌ Expected ErrSubscriptionNotFound once deleted, got %v", err)
+	}
+	if listed, _ := repos.Webhooks.ListSubscriptions(ctx, campaign.ID); len(listed) != 1 || listed[0].ID != newer.ID {
+		t.Errorf("❌ Expected only the newer subscription left, got %+v", listed)
+	}
+}
+
+func testWebhookEvents(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	campaign := createCampaign(t, repos, "Announced")
+	other := createCampaign(t, repos, "Other")
+
+	// Concurrent instances claim the same event, exactly one of them gets it
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claims := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := repos.Webhooks.ClaimWebhookEvent(ctx, campaign.ID, entities.WebhookImpressions1K)
+			if err != nil {
+				t.Errorf("❌ ClaimWebhookEvent failed: %v", err)
+			}
+			if claimed {
+				mu.Lock()
+				claims++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if claims != 1 {
+		t.Errorf("❌ Expected exactly one claim, got %d", claims)
+	}
+
+	for _, claim := range []struct{ campaignID, event string }{
+		{campaign.ID, entities.WebhookImpressions10K},
+		{other.ID, entities.WebhookImpressions1K},
+	} {
+		if claimed, err := repos.Webhooks.ClaimWebhookEvent(ctx, claim.campaignID, claim.event); err != nil || !claimed {
+			t.Errorf("❌ Expected %s of %s to be claimed, got %v, %v", claim.event, claim.campaignID, claimed, err)
+		}
+	}
+}
+
+func testDeadLetters(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	campaign := createCampaign(t, repos, "Undelivered")
+	other := createCampaign(t, repos, "Other")
+
+	letter := func(campaignID, event string) entities.DeadLetter {
+		return entities.DeadLetter{
+			SubscriptionID: uuid.NewString(),
+			URL:            "https://example.com/hook",
+			Payload:        entities.WebhookPayload{ID: uuid.NewString(), Event: event, CampaignID: campaignID, OccurredAt: time.Now().UTC().Truncate(time.Second)},
+			Attempts:       3,
+			LastError:      "unexpected status 500",
+			FailedAt:       time.Now().UTC().Truncate(time.Second),
+		}
+	}
+	for _, l := range []entities.DeadLetter{
+		letter(campaign.ID, entities.WebhookCampaignStarted),
+		letter(other.ID, entities.WebhookCampaignStarted),
+		letter(campaign.ID, entities.WebhookImpressions1K),
+		letter(campaign.ID, entities.WebhookCampaignEnded),
+	} {
+		if err := repos.Webhooks.AddDeadLetter(ctx, l, 2); err != nil {
+			t.Fatalf("❌ AddDeadLetter failed: %v", err)
+		}
+	}
+
+	letters, err := repos.Webhooks.ListDeadLetters(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("❌ ListDeadLetters failed: %v", err)
+	}
+	if len(letters) != 2 || letters[0].Payload.Event != entities.WebhookCampaignEnded || letters[1].Payload.Event != entities.WebhookImpressions1K {
+		t.Fatalf("❌ Expected the two newest letters of the campaign, newest first, got %+v", letters)
+	}
+	if got := letters[0]; got.Attempts != 3 || got.LastError != "unexpected status 500" || got.Payload.CampaignID != campaign.ID || got.FailedAt.IsZero() {
+		t.Errorf("❌ Dead letter fields not preserved: %+v", got)
+	}
+	if letters, err := repos.Webhooks.ListDeadLetters(ctx, other.ID); err != nil || len(letters) != 1 {
+		t.Errorf("❌ Expected the letter of the other campaign to be kept, got %+v, %v", letters, err)
+	}
+	if letters, err := repos.Webhooks.ListDeadLetters(ctx, uuid.NewString()); err != nil || letters == nil || len(letters) != 0 {
+		t.Errorf("❌ Expected an empty list for a campaign without letters, got %+v, %v", letters, err)
+	}
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import "errors"
+
+// ErrCampaignNotFound is returned by every backend when the campaign ID is unknown
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// ErrDuplicateImpression is returned when the user was already counted within the dedup TTL
+var ErrDuplicateImpression = errors.New("duplicate impression")
+
+// ErrImpressionTooOld is returned when the impression happened longer ago than ingestion.max_lateness
+var ErrImpressionTooOld = errors.New("impression is too old")
+
+// ErrInvalidTraffic is returned for impressions flagged by the invalid-traffic filters; they only bump the invalid counter
+var ErrInvalidTraffic = errors.New("invalid traffic")
+
+// ErrCampaignCompleted is returned for impressions of a campaign that reached its impression goal or spent its budget
+var ErrCampaignCompleted = errors.New("campaign completed")
+
+// ErrBackfillApplied is returned by ApplyBackfill when the import was already applied to the campaign
+var ErrBackfillApplied = errors.New("backfill already applied")
+
+// ErrSubscriptionNotFound is returned by DeleteSubscription when the campaign has no such webhook subscription
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
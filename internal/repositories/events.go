@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"learning/internal/entities"
+)
+
+// Event types emitted by the repository decorators
+const (
+	// EventImpressionCounted is emitted once for every impression added to a campaign total
+	EventImpressionCounted = "impression.counted"
+	// EventImpressionRejected is emitted for impressions counted apart, as too old or invalid traffic
+	EventImpressionRejected = "impression.rejected"
+	// EventCampaignUpdated is emitted when the name or flight of a campaign was changed
+	EventCampaignUpdated = "campaign.updated"
+	// EventImpressionsImported is emitted once for every campaign whose total an import added to
+	EventImpressionsImported = "impressions.imported"
+)
+
+// Event reports a change applied by a repository
+type Event struct {
+	Type       string
+	CampaignID string
+	At         time.Time
+}
+
+// Emitter hands repository events to its subscribers on the goroutine that applied the change, so a
+// subscriber must return quickly and never block
+type Emitter struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+func NewEmitter() *Emitter {
+	return &Emitter{}
+}
+
+// Subscribe calls fn with every event emitted from now on
+func (e *Emitter) Subscribe(fn func(Event)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subscribers = append(e.subscribers, fn)
+}
+
+func (e *Emitter) Emit(events ...Event) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, event := range events {
+		for _, fn := range e.subscribers {
+			fn(event)
+		}
+	}
+}
+
+// EmittingImpressionRepository wraps an impression repository and emits an event for every impression
+// that changed the stats of its campaign. Duplicates, unknown and completed campaigns emit nothing.
+type EmittingImpressionRepository struct {
+	repo    ImpressionRepository
+	emitter *Emitter
+}
+
+func NewEmittingImpressionRepository(repo ImpressionRepository, emitter *Emitter) *EmittingImpressionRepository {
+	return &EmittingImpressionRepository{repo: repo, emitter: emitter}
+}
+
+func (r *EmittingImpressionRepository) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	err, status := r.repo.TrackImpression(ctx, req)
+	if eventType, ok := outcomeEvent(err); ok {
+		r.emitter.Emit(Event{Type: eventType, CampaignID: req.CampaignID, At: time.Now()})
+	}
+	return err, status
+}
+
+// TrackImpressions forwards the batch to the wrapped repository, one request at a time if it cannot batch
+func (r *EmittingImpressionRepository) TrackImpressions(ctx context.Context, reqs []entities.TrackImpressionRequest) []error {
+	var errs []error
+	if batcher, ok := r.repo.(BatchImpressionRepository); ok {
+		errs = batcher.TrackImpressions(ctx, reqs)
+	} else {
+		errs = make([]error, len(reqs))
+		for i, req := range reqs {
+			errs[i], _ = r.repo.TrackImpression(ctx, req)
+		}
+	}
+
+	now := time.Now()
+	events := make([]Event, 0, len(reqs))
+	for i, err := range errs {
+		if eventType, ok := outcomeEvent(err); ok {
+			events = append(events, Event{Type: eventType, CampaignID: reqs[i].CampaignID, At: now})
+		}
+	}
+	r.emitter.Emit(events...)
+	return errs
+}
+
+// EmittingCampaignRepository wraps a campaign repository and emits an event for every campaign updated
+// through it, so followers holding a copy of the campaign can re-read it
+type EmittingCampaignRepository struct {
+	CampaignRepository
+	emitter *Emitter
+}
+
+func NewEmittingCampaignRepository(repo CampaignRepository, emitter *Emitter) *EmittingCampaignRepository {
+	return &EmittingCampaignRepository{CampaignRepository: repo, emitter: emitter}
+}
+
+func (r *EmittingCampaignRepository) UpdateCampaign(ctx context.Context, id string, req entities.UpdateCampaignRequest) (entities.Campaign, error) {
+	campaign, err := r.CampaignRepository.UpdateCampaign(ctx, id, req)
+	if err == nil {
+		r.emitter.Emit(Event{Type: EventCampaignUpdated, CampaignID: id, At: time.Now()})
+	}
+	return campaign, err
+}
+
+// EmittingBackfillRepository wraps a backfill repository and emits an event for every campaign a
+// backfill added impressions to, so an import reaches the same followers as counted impressions
+type EmittingBackfillRepository struct {
+	repo    BackfillRepository
+	emitter *Emitter
+}
+
+func NewEmittingBackfillRepository(repo BackfillRepository, emitter *Emitter) *EmittingBackfillRepository {
+	return &EmittingBackfillRepository{repo: repo, emitter: emitter}
+}
+
+func (r *EmittingBackfillRepository) ApplyBackfill(ctx context.Context, b Backfill) error {
+	err := r.repo.ApplyBackfill(ctx, b)
+	if err == nil && b.Total > 0 {
+		r.emitter.Emit(Event{Type: EventImpressionsImported, CampaignID: b.CampaignID, At: time.Now()})
+	}
+	return err
+}
+
+// outcomeEvent returns the event type of an impression outcome that changed the stats
+func outcomeEvent(err error) (string, bool) {
+	switch {
+	case err == nil:
+		return EventImpressionCounted, true
+	case errors.Is(err, ErrImpressionTooOld), errors.Is(err, ErrInvalidTraffic):
+		return EventImpressionRejected, true
+	default:
+		return "", false
+	}
+}
@@ -1,9 +1,16 @@
 package repositories
 
 import (
+	"context"
 	"learning/internal/entities"
 )
 
 type ImpressionRepository interface {
-	TrackImpression(req entities.TrackImpressionRequest) (error, int)
+	TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int)
+}
+
+// BatchImpressionRepository is implemented by backends that can apply many impressions in one
+// lock or transaction. The returned slice holds the outcome of each request, in order.
+type BatchImpressionRepository interface {
+	TrackImpressions(ctx context.Context, reqs []entities.TrackImpressionRequest) []error
 }
@@ -1,15 +1,16 @@
 package memory
 
 import (
-	"sync"
+	"context"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"learning/internal/entities"
+	"learning/internal/repositories"
 )
 
 type InMemoryCampaignRepository struct {
-	mu     sync.Mutex
 	server *entities.Server // Use shared server instance
 }
 
@@ -21,15 +22,25 @@ func NewInMemoryCampaignRepository(server *entities.Server) *InMemoryCampaignRep
 }
 
 // CreateCampaign Store campaigns in shared memory
-func (r *InMemoryCampaignRepository) CreateCampaign(req entities.CreateCampaignRequest) (entities.Campaign, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+func (r *InMemoryCampaignRepository) CreateCampaign(ctx context.Context, req entities.CreateCampaignRequest) (entities.Campaign, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	// Give up if the caller went away while waiting for the lock
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
 
 	id := uuid.New().String()
 	campaign := entities.Campaign{
-		ID:        id,
-		Name:      req.Name,
-		StartTime: req.StartTime,
+		ID:             id,
+		Name:           req.Name,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		ImpressionGoal: req.ImpressionGoal,
+		Budget:         req.Budget,
+		CPM:            req.CPM,
+		Status:         entities.CampaignActive,
 	}
 
 	// Store in shared memory
@@ -40,6 +51,61 @@ func (r *InMemoryCampaignRepository) CreateCampaign(req entities.CreateCampaignR
 	return campaign, nil
 }
 
+// GetCampaign Fetch a campaign from shared memory
+func (r *InMemoryCampaignRepository) GetCampaign(ctx context.Context, id string) (entities.Campaign, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign, exists := r.server.Campaigns[id]
+	if !exists {
+		return entities.Campaign{}, repositories.ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+// UpdateCampaign changes the campaign in shared memory
+func (r *InMemoryCampaignRepository) UpdateCampaign(ctx context.Context, id string, req entities.UpdateCampaignRequest) (entities.Campaign, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign, exists := r.server.Campaigns[id]
+	if !exists {
+		return entities.Campaign{}, repositories.ErrCampaignNotFound
+	}
+	req.Apply(&campaign)
+	r.server.Campaigns[id] = campaign
+	return campaign, nil
+}
+
+// ListCampaigns calls fn with a snapshot of the campaigns, so fn never runs under the shared lock
+func (r *InMemoryCampaignRepository) ListCampaigns(ctx context.Context, fn func(entities.Campaign) error) error {
+	r.server.Mu.Lock()
+	campaigns := make([]entities.Campaign, 0, len(r.server.Campaigns))
+	for _, campaign := range r.server.Campaigns {
+		campaigns = append(campaigns, campaign)
+	}
+	r.server.Mu.Unlock()
+
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].ID < campaigns[j].ID })
+	for _, campaign := range campaigns {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(campaign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetCampaigns Return campaigns from shared memory
 func (r *InMemoryCampaignRepository) GetCampaigns() map[string]entities.Campaign {
 	return r.server.Campaigns
@@ -1,17 +1,15 @@
 package memory
 
 import (
-	"errors"
+	"context"
 	"learning/cmd/config"
 	"learning/internal/entities"
-	logger2 "learning/internal/logger"
+	"learning/internal/repositories"
 	"net/http"
-	"sync"
 	"time"
 )
 
 type InMemoryImpressionRepository struct {
-	mu     sync.Mutex
 	server *entities.Server // Use shared server instance
 }
 
@@ -21,30 +19,73 @@ func NewInMemoryImpressionRepository(server *entities.Server) *InMemoryImpressio
 	}
 }
 
-func (r *InMemoryImpressionRepository) TrackImpression(req entities.TrackImpressionRequest) (error, int) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+func (r *InMemoryImpressionRepository) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
 
-	// Ensure campaign exists in shared storage
-	if _, exists := r.server.Campaigns[req.CampaignID]; !exists {
-		return errors.New("campaign not found"), http.StatusNotFound
+	// Give up if the caller went away while waiting for the lock
+	if err := ctx.Err(); err != nil {
+		return err, http.StatusServiceUnavailable
+	}
+
+	return r.track(req, time.Now(), time.Duration(config.Current().App.TTL)*time.Second)
+}
+
+// TrackImpressions applies a batch under a single acquisition of the shared lock
+func (r *InMemoryImpressionRepository) TrackImpressions(ctx context.Context, reqs []entities.TrackImpressionRequest) []error {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	errs := make([]error, len(reqs))
+	if err := ctx.Err(); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
 	}
 
 	now := time.Now()
-	lastImpression, seen := r.server.Impressions[req.CampaignID][req.UserID]
+	ttl := time.Duration(config.Current().App.TTL) * time.Second
+	for i, req := range reqs {
+		errs[i], _ = r.track(req, now, ttl)
+	}
+	return errs
+}
+
+// Buckets older than these are dropped whenever a new bucket is started
+const (
+	minuteBucketRetention = 24 * time.Hour
+	hourBucketRetention   = 30 * 24 * time.Hour
+)
 
-	cfg, err := config.LoadConfig()
-	logger := logger2.InitLogger()
-	if err != nil {
-		logger.Error(err.Error())
+// track records one impression; the caller must hold the shared lock
+func (r *InMemoryImpressionRepository) track(req entities.TrackImpressionRequest, now time.Time, ttl time.Duration) (error, int) {
+	// Ensure campaign exists in shared storage
+	campaign, exists := r.server.Campaigns[req.CampaignID]
+	if !exists {
+		return repositories.ErrCampaignNotFound, http.StatusNotFound
+	}
+	if campaign.Status == entities.CampaignCompleted {
+		return repositories.ErrCampaignCompleted, http.StatusGone
+	}
+
+	stats := r.server.Stats[req.CampaignID]
+	if req.InvalidReason != "" {
+		stats.Invalid++
+		r.server.Stats[req.CampaignID] = stats
+		return repositories.ErrInvalidTraffic, http.StatusOK
 	}
 
-	// Read port from config and convert to string
-	ttl := cfg.App.TTL
+	arrival := repositories.ClassifyArrival(req, now)
+	if arrival.TooOld {
+		stats.TooOld++
+		r.server.Stats[req.CampaignID] = stats
+		return repositories.ErrImpressionTooOld, http.StatusUnprocessableEntity
+	}
 
-	// Enforce TTL for impressions (1 hour)
-	if seen && now.Sub(lastImpression) < time.Duration(ttl)*time.Second {
-		return errors.New("duplicate impression"), http.StatusOK
+	// Enforce the deduplication window
+	if lastImpression, seen := r.server.Impressions[req.CampaignID][req.UserID]; seen && now.Sub(lastImpression) < ttl {
+		return repositories.ErrDuplicateImpression, http.StatusOK
 	}
 
 	// Store impression in shared memory
@@ -53,12 +94,94 @@ func (r *InMemoryImpressionRepository) TrackImpression(req entities.TrackImpress
 	}
 	r.server.Impressions[req.CampaignID][req.UserID] = now
 
-	// Update stats in shared memory
-	stats := r.server.Stats[req.CampaignID]
-	stats.LastHour++
-	stats.LastDay++
+	// Update stats in shared memory, bucketed by when the impression happened
 	stats.TotalCount++
+	if arrival.Late {
+		stats.Late++
+	}
 	r.server.Stats[req.CampaignID] = stats
 
+	// The impression that reaches the limit completes the campaign
+	if limit := campaign.ImpressionLimit(); limit > 0 && stats.TotalCount >= limit {
+		campaign.Status = entities.CampaignCompleted
+		completedAt := now.UTC()
+		campaign.CompletedAt = &completedAt
+		r.server.Campaigns[req.CampaignID] = campaign
+	}
+
+	if now.Sub(arrival.At) < minuteBucketRetention {
+		r.server.Minutes[req.CampaignID] = increment(r.server.Minutes[req.CampaignID], repositories.MinuteOf(arrival.At), repositories.MinuteOf(now.Add(-minuteBucketRetention)))
+	}
+	r.server.Hours[req.CampaignID] = increment(r.server.Hours[req.CampaignID], repositories.HourOf(arrival.At), repositories.HourOf(now.Add(-hourBucketRetention)))
+
 	return nil, http.StatusOK
 }
+
+// increment bumps one bucket, pruning buckets before oldest when a new bucket is started
+func increment(buckets map[int64]int64, bucket, oldest int64) map[int64]int64 {
+	if buckets == nil {
+		buckets = make(map[int64]int64)
+	}
+	if _, ok := buckets[bucket]; !ok {
+		for b := range buckets {
+			if b < oldest {
+				delete(buckets, b)
+			}
+		}
+	}
+	buckets[bucket]++
+	return buckets
+}
+
+// ApplyBackfill adds historical counts under the shared lock, skipping buckets past their retention
+func (r *InMemoryImpressionRepository) ApplyBackfill(ctx context.Context, b repositories.Backfill) error {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	campaign, exists := r.server.Campaigns[b.CampaignID]
+	if !exists {
+		return repositories.ErrCampaignNotFound
+	}
+
+	now := time.Now()
+	if b.ImportID != "" {
+		if _, applied := r.server.Backfills[b.CampaignID][b.ImportID]; applied {
+			return repositories.ErrBackfillApplied
+		}
+		if r.server.Backfills[b.CampaignID] == nil {
+			r.server.Backfills[b.CampaignID] = make(map[string]time.Time)
+		}
+		r.server.Backfills[b.CampaignID][b.ImportID] = now
+	}
+
+	stats := r.server.Stats[b.CampaignID]
+	stats.TotalCount += b.Total
+	r.server.Stats[b.CampaignID] = stats
+	if limit := campaign.ImpressionLimit(); limit > 0 && stats.TotalCount >= limit && campaign.Status != entities.CampaignCompleted {
+		campaign.Status = entities.CampaignCompleted
+		completedAt := now.UTC()
+		campaign.CompletedAt = &completedAt
+		r.server.Campaigns[b.CampaignID] = campaign
+	}
+
+	r.server.Minutes[b.CampaignID] = addBuckets(r.server.Minutes[b.CampaignID], b.Minutes, repositories.MinuteOf(now.Add(-minuteBucketRetention)))
+	r.server.Hours[b.CampaignID] = addBuckets(r.server.Hours[b.CampaignID], b.Hours, repositories.HourOf(now.Add(-hourBucketRetention)))
+	return nil
+}
+
+// addBuckets adds the counts of the buckets from oldest on
+func addBuckets(buckets, counts map[int64]int64, oldest int64) map[int64]int64 {
+	for bucket, count := range counts {
+		if bucket < oldest {
+			continue
+		}
+		if buckets == nil {
+			buckets = make(map[int64]int64)
+		}
+		buckets[bucket] += count
+	}
+	return buckets
+}
@@ -10,5 +10,12 @@ func NewServer() *entities.Server {
 		Campaigns:   make(map[string]entities.Campaign),
 		Impressions: make(map[string]map[string]time.Time),
 		Stats:       make(map[string]entities.Stats),
+		Minutes:     make(map[string]map[int64]int64),
+		Hours:       make(map[string]map[int64]int64),
+		Backfills:   make(map[string]map[string]time.Time),
+
+		Webhooks:      make(map[string]entities.WebhookSubscription),
+		WebhookEvents: make(map[string]map[string]time.Time),
+		DeadLetters:   make(map[string][]entities.DeadLetter),
 	}
 }
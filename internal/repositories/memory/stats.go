@@ -1,12 +1,13 @@
 package memory
 
 import (
+	"context"
 	"learning/internal/entities"
-	"sync"
+	"learning/internal/repositories"
+	"time"
 )
 
 type InMemoryStatsRepository struct {
-	mu     sync.Mutex
 	server *entities.Server // Add shared server instance
 }
 
@@ -18,10 +19,58 @@ func NewInMemoryStatsRepository(server *entities.Server) *InMemoryStatsRepositor
 }
 
 // GetCampaignStats Fetch stats from shared memory
-func (r *InMemoryStatsRepository) GetCampaignStats(campaignID string) (entities.Stats, bool) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+func (r *InMemoryStatsRepository) GetCampaignStats(ctx context.Context, campaignID string) (entities.Stats, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return entities.Stats{}, err
+	}
 
 	stats, exists := r.server.Stats[campaignID] // Fetch from shared memory
-	return stats, exists
+	if !exists {
+		return entities.Stats{}, repositories.ErrCampaignNotFound
+	}
+
+	// Sum the windows from the buckets, so old impressions age out
+	now := time.Now()
+	stats.LastHour = sumBuckets(r.server.Minutes[campaignID], repositories.MinuteOf(now), repositories.LastHourBuckets)
+	stats.LastDay = sumBuckets(r.server.Hours[campaignID], repositories.HourOf(now), repositories.LastDayBuckets)
+	stats.Spend = r.server.Campaigns[campaignID].Spend(stats.TotalCount)
+	return stats, nil
+}
+
+// GetCampaignSeries reads the minute or hour buckets of the query from shared memory
+func (r *InMemoryStatsRepository) GetCampaignSeries(ctx context.Context, q entities.SeriesQuery) ([]entities.SeriesPoint, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, exists := r.server.Stats[q.CampaignID]; !exists {
+		return nil, repositories.ErrCampaignNotFound
+	}
+
+	buckets := r.server.Minutes[q.CampaignID]
+	if q.Resolution == entities.ResolutionHour {
+		buckets = r.server.Hours[q.CampaignID]
+	}
+	counts := make(map[int64]int64)
+	first, last := repositories.SeriesBuckets(q)
+	for b, count := range buckets {
+		if b >= first && b <= last {
+			counts[b] = count
+		}
+	}
+	return repositories.DenseSeries(q, counts), nil
+}
+
+// sumBuckets adds the count buckets of the window ending with latest
+func sumBuckets(buckets map[int64]int64, latest int64, count int) int64 {
+	var sum int64
+	for b := latest - int64(count) + 1; b <= latest; b++ {
+		sum += buckets[b]
+	}
+	return sum
 }
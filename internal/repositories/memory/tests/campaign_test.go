@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"learning/internal/handlers"
 	"learning/internal/repositories/memory"
+	"learning/internal/validators"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -56,10 +57,18 @@ func TestCreateCampaignHandler(t *testing.T) {
 	}{
 		{"Valid Campaign", `{"name": "Test Campaign", "start_time": "2025-01-01T00:00:00Z"}`, http.StatusCreated, ""},
 		{"❌ Malformed JSON", `{"name": "Test Campaign", "start_time": "invalid-date", }`, http.StatusBadRequest, "invalid JSON payload"},
-		{"❌ Missing Name Field", `{"name": "", "start_time": "2025-01-01T00:00:00Z"}`, http.StatusBadRequest, "Field validation for 'Name' failed"},
+		{"❌ Missing Name Field", `{"name": "", "start_time": "2025-01-01T00:00:00Z"}`, http.StatusBadRequest, "validation failed"},
 		{"❌ Missing Start Time", `{"name": "Test Campaign", "start_time": ""}`, http.StatusBadRequest, "invalid JSON payload"},
-		{"❌ Empty JSON Body", `{}`, http.StatusBadRequest, "Field validation"},
+		{"❌ Empty JSON Body", `{}`, http.StatusBadRequest, "validation failed"},
 		{"❌ Unknown Field", `{"test": "test"}`, http.StatusBadRequest, "invalid JSON payload"},
+		{"❌ Name Too Long", `{"name": "` + strings.Repeat("a", 256) + `", "start_time": "2025-01-01T00:00:00Z"}`, http.StatusBadRequest, "validation failed"},
+		{"❌ Name With Control Characters", `{"name": "bad\u0007name", "start_time": "2025-01-01T00:00:00Z"}`, http.StatusBadRequest, "validation failed"},
+		{"❌ Empty Body", ``, http.StatusBadRequest, "empty request body"},
+		{"Valid Campaign With End Time", `{"name": "Flight", "start_time": "2025-01-01T00:00:00Z", "end_time": "2025-02-01T00:00:00Z"}`, http.StatusCreated, ""},
+		{"❌ End Time Before Start", `{"name": "Flight", "start_time": "2025-01-01T00:00:00Z", "end_time": "2024-12-01T00:00:00Z"}`, http.StatusBadRequest, "validation failed"},
+		{"Valid Campaign With Goal And Budget", `{"name": "Goal", "start_time": "2025-01-01T00:00:00Z", "impression_goal": 1000, "budget": 25, "cpm": 2.5}`, http.StatusCreated, ""},
+		{"❌ Negative Impression Goal", `{"name": "Goal", "start_time": "2025-01-01T00:00:00Z", "impression_goal": -5}`, http.StatusBadRequest, "validation failed"},
+		{"❌ Fractional Impression Goal", `{"name": "Goal", "start_time": "2025-01-01T00:00:00Z", "impression_goal": 1.5}`, http.StatusBadRequest, "invalid JSON payload"},
 	}
 
 	for _, test := range tests {
@@ -69,3 +78,57 @@ func TestCreateCampaignHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateCampaignFieldErrors(t *testing.T) {
+	handler := handlers.NewCampaignHandler(memory.NewInMemoryCampaignRepository(memory.NewServer()))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/campaigns", strings.NewReader(`{"name": "", "start_time": "0001-01-01T00:00:00Z"}`))
+	resp := httptest.NewRecorder()
+	handler.CreateCampaignHandler(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("❌ Expected status %d, got %d", http.StatusBadRequest, resp.Code)
+	}
+
+	fields := GetFieldErrors(resp, t)
+	if fields["name"] != "is required" {
+		t.Errorf("❌ Expected 'name' to be reported as required, got %q", fields["name"])
+	}
+	if fields["start_time"] != "is required" {
+		t.Errorf("❌ Expected 'start_time' to be reported as required, got %q", fields["start_time"])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/campaigns", strings.NewReader(`{"name": "Flight", "start_time": "2025-01-01T00:00:00Z", "end_time": "2025-01-01T00:00:00Z"}`))
+	resp = httptest.NewRecorder()
+	handler.CreateCampaignHandler(resp, req)
+	if fields := GetFieldErrors(resp, t); fields["end_time"] != "must be after start_time" {
+		t.Errorf("❌ Expected 'end_time' to be reported as before the start, got %q", fields["end_time"])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/campaigns", strings.NewReader(`{"name": "Budget", "start_time": "2025-01-01T00:00:00Z", "budget": 10}`))
+	resp = httptest.NewRecorder()
+	handler.CreateCampaignHandler(resp, req)
+	if fields := GetFieldErrors(resp, t); fields["cpm"] != "is required with budget" {
+		t.Errorf("❌ Expected 'cpm' to be reported as required with the budget, got %q", fields["cpm"])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/campaigns", strings.NewReader(`{"name": "Budget", "start_time": "2025-01-01T00:00:00Z", "budget": 0.001, "cpm": 2}`))
+	resp = httptest.NewRecorder()
+	handler.CreateCampaignHandler(resp, req)
+	if fields := GetFieldErrors(resp, t); fields["budget"] != "must buy at least one impression at the cpm" {
+		t.Errorf("❌ Expected 'budget' to be reported as too small, got %q", fields["budget"])
+	}
+}
+
+func TestCreateCampaignOversizedBody(t *testing.T) {
+	handler := handlers.NewCampaignHandler(memory.NewInMemoryCampaignRepository(memory.NewServer()))
+
+	body := `{"name": "` + strings.Repeat("a", int(validators.MaxBodyBytes)) + `", "start_time": "2025-01-01T00:00:00Z"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/campaigns", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	handler.CreateCampaignHandler(resp, req)
+
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("❌ Expected status %d, got %d", http.StatusRequestEntityTooLarge, resp.Code)
+	}
+}
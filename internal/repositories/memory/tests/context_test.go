@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"learning/internal/entities"
+	"learning/internal/handlers"
+	"learning/internal/repositories/memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlersHonourCancelledContext(t *testing.T) {
+	memServer := memory.NewServer()
+	campaignRepo := memory.NewInMemoryCampaignRepository(memServer)
+	campaignHandler := handlers.NewCampaignHandler(campaignRepo)
+	impressionHandler := handlers.NewImpressionHandler(memory.NewInMemoryImpressionRepository(memServer))
+	statsHandler := handlers.NewStatsHandler(memory.NewInMemoryStatsRepository(memServer))
+
+	campaign, err := campaignRepo.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Cancelled", StartTime: time.Now()})
+	if err != nil {
+		t.Fatalf("❌ Failed to create campaign: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	impression, _ := json.Marshal(entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "user1", AdID: "ad1"})
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		url     string
+		body    string
+	}{
+		{"Create Campaign", campaignHandler.CreateCampaignHandler, http.MethodPost, "/api/v1/campaigns", `{"name": "x", "start_time": "2025-01-01T00:00:00Z"}`},
+		{"Track Impression", impressionHandler.TrackImpressionHandler, http.MethodPost, "/api/v1/impressions", string(impression)},
+		{"Get Stats", statsHandler.GetCampaignStatsHandler, http.MethodGet, "/api/v1/campaigns/stats/" + campaign.ID, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body)).WithContext(ctx)
+			resp := httptest.NewRecorder()
+			test.handler(resp, req)
+
+			if resp.Code != http.StatusServiceUnavailable {
+				t.Errorf("❌ Expected status %d, got %d", http.StatusServiceUnavailable, resp.Code)
+			}
+		})
+	}
+
+	stats, err := memory.NewInMemoryStatsRepository(memServer).GetCampaignStats(context.Background(), campaign.ID)
+	if err != nil || stats.TotalCount != 0 {
+		t.Errorf("❌ Cancelled impression must not be counted, got %d (%v)", stats.TotalCount, err)
+	}
+}
@@ -0,0 +1,19 @@
+package tests
+
+import (
+	"learning/internal/repositories/contract"
+	"learning/internal/repositories/memory"
+	"testing"
+)
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	contract.Run(t, func(t *testing.T) contract.Repositories {
+		memServer := memory.NewServer()
+		return contract.Repositories{
+			Campaigns:   memory.NewInMemoryCampaignRepository(memServer),
+			Impressions: memory.NewInMemoryImpressionRepository(memServer),
+			Stats:       memory.NewInMemoryStatsRepository(memServer),
+			Webhooks:    memory.NewInMemoryWebhookRepository(memServer),
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"learning/cmd/config"
+	"learning/cmd/server"
+	"learning/internal/entities"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExportFormats(t *testing.T) {
+	prev := config.Current()
+	cfg := *prev
+	cfg.Events.Enabled = true
+	cfg.Events.Dir = t.TempDir()
+	config.Set(&cfg)
+	defer config.Set(prev)
+
+	handler, closeServer, err := server.SetupServer()
+	if err != nil {
+		t.Fatalf("❌ Failed to set up server: %v", err)
+	}
+	defer func() { _ = closeServer() }()
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("❌ GET %s: expected status 200, got %d %s", path, resp.Code, resp.Body.String())
+		}
+		return resp
+	}
+
+	var campaigns []entities.Campaign
+	for _, name := range []string{"Export A", "Export B"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/campaigns", strings.NewReader(`{"name": "`+name+`", "start_time": "2025-01-01T00:00:00Z", "impression_goal": 10}`))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		campaigns = append(campaigns, GetCampaignCreateResponse(resp, t))
+	}
+	campaign := campaigns[0]
+	for i := 1; i <= 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", strings.NewReader(fmt.Sprintf(`{"campaign_id": %q, "user_id": "user-%d", "ad_id": "ad"}`, campaign.ID, i)))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("❌ Failed to track impression: %d %s", resp.Code, resp.Body.String())
+		}
+	}
+
+	t.Run("Campaigns CSV", func(t *testing.T) {
+		resp := get("/api/v1/campaigns", "text/csv")
+		if contentType := resp.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+			t.Errorf("❌ Expected a CSV Content-Type, got %q", contentType)
+		}
+		if disposition := resp.Header().Get("Content-Disposition"); disposition != `attachment; filename=campaigns.csv` {
+			t.Errorf("❌ Expected a download named campaigns.csv, got %q", disposition)
+		}
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("❌ Response is not CSV: %v", err)
+		}
+		if len(records) != 3 || records[0][0] != "id" || records[0][4] != "impression_goal" {
+			t.Fatalf("❌ Expected a header and two campaigns, got %v", records)
+		}
+		for _, record := range records[1:] {
+			if record[4] != "10" || record[7] != entities.CampaignActive || record[3] != "" {
+				t.Errorf("❌ Unexpected campaign row %v", record)
+			}
+		}
+	})
+
+	t.Run("Impressions NDJSON", func(t *testing.T) {
+		resp := get("/api/v1/campaigns/"+campaign.ID+"/impressions?limit=1", "application/x-ndjson")
+		var users []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var impression entities.Impression
+			if err := json.Unmarshal(scanner.Bytes(), &impression); err != nil {
+				t.Fatalf("❌ Line is not an impression: %v (%q)", err, scanner.Text())
+			}
+			users = append(users, impression.UserID)
+		}
+		// Every page is exported, whatever the limit
+		if strings.Join(users, ",") != "user-1,user-2,user-3" {
+			t.Errorf("❌ Expected the three impressions in order, got %v", users)
+		}
+	})
+
+	t.Run("Stats Preferred Format", func(t *testing.T) {
+		resp := get("/api/v1/campaigns/stats/"+campaign.ID, "text/csv;q=0.5, application/x-ndjson")
+		var stats entities.Stats
+		if err := json.Unmarshal(resp.Body.Bytes(), &stats); err != nil || stats.TotalCount != 3 {
+			t.Errorf("❌ Expected a single NDJSON stats line with a total of 3, got %q", resp.Body.String())
+		}
+	})
+
+	t.Run("Series CSV", func(t *testing.T) {
+		resp := get("/api/v1/campaigns/"+campaign.ID+"/series?resolution=minute&format=csv", "application/json")
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("❌ Response is not CSV: %v", err)
+		}
+		total := 0
+		for _, record := range records[1:] {
+			count, _ := strconv.Atoi(record[1])
+			total += count
+		}
+		if len(records) != 61 || records[0][0] != "start" || total != 3 {
+			t.Errorf("❌ Expected a header and an hour of minutes holding the three impressions, got %v", records)
+		}
+	})
+}
@@ -2,16 +2,23 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"learning/internal/entities"
 	"learning/internal/handlers"
+	"learning/internal/ingest"
 	"learning/internal/repositories/memory"
+	"learning/internal/traffic"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 func TestTrackImpressionWithInvalidInput(t *testing.T) {
@@ -29,7 +36,10 @@ func TestTrackImpressionWithInvalidInput(t *testing.T) {
 		{`{}`, http.StatusBadRequest},
 		{`{"campaign_id": "", "user_id": "", "ad_id": ""}`, http.StatusBadRequest},
 		{`{"campaign_id": "123", "user_id": 456, "ad_id": true}`, http.StatusBadRequest},
-		{`{"campaign_id": "non-existent", "user_id": "user123", "ad_id": "ad456"}`, http.StatusNotFound},
+		{`{"campaign_id": "non-existent", "user_id": "user123", "ad_id": "ad456"}`, http.StatusBadRequest},
+		{`{"campaign_id": "` + uuid.NewString() + `", "user_id": "user 123", "ad_id": "ad456"}`, http.StatusBadRequest},
+		{`{"campaign_id": "` + uuid.NewString() + `", "user_id": "user123", "ad_id": "` + strings.Repeat("a", 129) + `"}`, http.StatusBadRequest},
+		{`{"campaign_id": "` + uuid.NewString() + `", "user_id": "user123", "ad_id": "ad456"}`, http.StatusNotFound},
 	}
 
 	for _, test := range invalidPayloads {
@@ -119,15 +129,15 @@ func TestConcurrentImpressionTracking(t *testing.T) {
 	// Step 2: Track multiple impressions concurrently
 	for i := 0; i < 10; i++ {
 		wg.Add(1)
-		go func() {
+		go func(userID string) {
 			defer wg.Done()
-			impReq := entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: fmt.Sprintf("user%d", i), AdID: "ad456"}
+			impReq := entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: userID, AdID: "ad456"}
 			jsonImp, _ := json.Marshal(impReq)
 			request := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", bytes.NewBuffer(jsonImp))
 			request.Header.Set("Content-Type", "application/json")
 			response := httptest.NewRecorder()
 			impressionHandler.TrackImpressionHandler(response, request)
-		}()
+		}(fmt.Sprintf("user%d", i))
 	}
 	wg.Wait()
 
@@ -153,7 +163,7 @@ func TestTrackImpressionForNonExistentCampaign(t *testing.T) {
 	impressionHandler := handlers.NewImpressionHandler(impressionRepo)
 
 	// Attempt to track an impression for a non-existent campaign
-	impReq := entities.TrackImpressionRequest{CampaignID: "non-existent-id", UserID: "user123", AdID: "ad456"}
+	impReq := entities.TrackImpressionRequest{CampaignID: uuid.NewString(), UserID: "user123", AdID: "ad456"}
 	jsonImp, _ := json.Marshal(impReq)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", bytes.NewBuffer(jsonImp))
@@ -165,3 +175,239 @@ func TestTrackImpressionForNonExistentCampaign(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.Code)
 	}
 }
+
+func TestTrackImpressionFieldErrors(t *testing.T) {
+	impressionHandler := handlers.NewImpressionHandler(memory.NewInMemoryImpressionRepository(memory.NewServer()))
+
+	payload := `{"campaign_id": "not-a-uuid", "user_id": "user/123", "ad_id": ""}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	impressionHandler.TrackImpressionHandler(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, resp.Code)
+	}
+
+	fields := GetFieldErrors(resp, t)
+	for _, field := range []string{"campaign_id", "user_id", "ad_id"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("expected a field error for %q, got %v", field, fields)
+		}
+	}
+}
+
+func TestAsyncImpressionTracking(t *testing.T) {
+	// Initialize shared in-memory server
+	memServer := memory.NewServer()
+
+	// Pass shared memory to repositories
+	campaignRepo := memory.NewInMemoryCampaignRepository(memServer)
+	impressionRepo := memory.NewInMemoryImpressionRepository(memServer)
+	statsRepo := memory.NewInMemoryStatsRepository(memServer)
+
+	// A single slot and no workers yet, so the second impression finds the queue full
+	queue := ingest.NewQueue(impressionRepo, ingest.Options{
+		QueueSize:     1,
+		Workers:       1,
+		BatchSize:     10,
+		FlushInterval: 10 * time.Millisecond,
+		ApplyTimeout:  time.Second,
+	}, zap.NewNop())
+	impressionHandler := handlers.NewAsyncImpressionHandler(impressionRepo, queue, ingest.NewKnownCampaigns(campaignRepo, time.Minute))
+
+	campaign, _ := campaignRepo.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Async Campaign", StartTime: time.Now()})
+
+	trackIn := func(campaignID, userID string) *httptest.ResponseRecorder {
+		jsonImp, _ := json.Marshal(entities.TrackImpressionRequest{CampaignID: campaignID, UserID: userID, AdID: "ad456"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", bytes.NewBuffer(jsonImp))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		impressionHandler.TrackImpressionHandler(resp, req)
+		return resp
+	}
+
+	track := func(userID string) *httptest.ResponseRecorder {
+		return trackIn(campaign.ID, userID)
+	}
+
+	// Unknown campaigns are answered like in sync mode and take no slot in the queue
+	if resp := trackIn(uuid.NewString(), "user1"); resp.Code != http.StatusNotFound {
+		t.Fatalf("❌ Expected status %d for an unknown campaign, got %d", http.StatusNotFound, resp.Code)
+	}
+
+	if resp := track("user1"); resp.Code != http.StatusAccepted {
+		t.Fatalf("❌ Expected status %d, got %d", http.StatusAccepted, resp.Code)
+	}
+
+	resp := track("user2")
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("❌ Expected status %d for a full queue, got %d", http.StatusServiceUnavailable, resp.Code)
+	}
+	if retryAfter := resp.Header().Get("Retry-After"); retryAfter == "" {
+		t.Error("❌ Expected a Retry-After header on a full queue")
+	}
+
+	// Draining the queue applies the accepted impression
+	queue.Start()
+	if err := queue.Close(context.Background()); err != nil {
+		t.Fatalf("❌ Failed to drain queue: %v", err)
+	}
+
+	stats, _ := statsRepo.GetCampaignStats(context.Background(), campaign.ID)
+	if stats.TotalCount != 1 {
+		t.Errorf("❌ Expected 1 impression after draining, got %d", stats.TotalCount)
+	}
+}
+
+func TestAsyncImpressionTrackingRefusesCompletedCampaigns(t *testing.T) {
+	memServer := memory.NewServer()
+	campaignRepo := memory.NewInMemoryCampaignRepository(memServer)
+	impressionRepo := memory.NewInMemoryImpressionRepository(memServer)
+
+	queue := ingest.NewQueue(impressionRepo, ingest.Options{
+		QueueSize:     10,
+		Workers:       1,
+		BatchSize:     10,
+		FlushInterval: time.Millisecond,
+		ApplyTimeout:  time.Second,
+	}, zap.NewNop())
+	known := ingest.NewKnownCampaigns(campaignRepo, time.Hour)
+	queue.OnCampaignCompleted(known.Completed)
+	queue.Start()
+	defer func() { _ = queue.Close(context.Background()) }()
+	impressionHandler := handlers.NewAsyncImpressionHandler(impressionRepo, queue, known)
+
+	track := func(campaignID, userID string) *httptest.ResponseRecorder {
+		jsonImp, _ := json.Marshal(entities.TrackImpressionRequest{CampaignID: campaignID, UserID: userID, AdID: "ad456"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", bytes.NewBuffer(jsonImp))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		impressionHandler.TrackImpressionHandler(resp, req)
+		return resp
+	}
+
+	// Completed before the handler first looked it up, so it is answered like in sync mode
+	completed, _ := campaignRepo.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Completed", StartTime: time.Now(), ImpressionGoal: 1})
+	if err, _ := impressionRepo.TrackImpression(context.Background(), entities.TrackImpressionRequest{CampaignID: completed.ID, UserID: "user1", AdID: "ad456"}); err != nil {
+		t.Fatalf("❌ Failed to track impression: %v", err)
+	}
+	if resp := track(completed.ID, "user2"); resp.Code != http.StatusGone {
+		t.Errorf("❌ Expected status %d for a completed campaign, got %d", http.StatusGone, resp.Code)
+	}
+
+	// Completed by queued impressions while its lookup is cached as active: the refusal of the queue
+	// is passed back, so the next impression is answered 410 as well
+	campaign, _ := campaignRepo.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Goal", StartTime: time.Now(), ImpressionGoal: 1})
+	for _, user := range []string{"user1", "user2"} {
+		if resp := track(campaign.ID, user); resp.Code != http.StatusAccepted {
+			t.Fatalf("❌ Expected status %d, got %d", http.StatusAccepted, resp.Code)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	resp := track(campaign.ID, "user3")
+	for resp.Code == http.StatusAccepted && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		resp = track(campaign.ID, "user3")
+	}
+	if resp.Code != http.StatusGone {
+		t.Errorf("❌ Expected status %d once the queue completed the campaign, got %d", http.StatusGone, resp.Code)
+	}
+}
+
+func TestTrackImpressionTimestamps(t *testing.T) {
+	// Initialize shared in-memory server
+	memServer := memory.NewServer()
+
+	// Pass shared memory to repositories
+	campaignRepo := memory.NewInMemoryCampaignRepository(memServer)
+	impressionHandler := handlers.NewImpressionHandler(memory.NewInMemoryImpressionRepository(memServer))
+	statsRepo := memory.NewInMemoryStatsRepository(memServer)
+
+	campaign, _ := campaignRepo.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Timestamp Campaign", StartTime: time.Now()})
+
+	tests := []struct {
+		name           string
+		timestamp      time.Time
+		expectedStatus int
+	}{
+		{"Recent", time.Now().Add(-time.Second), http.StatusOK},
+		{"Late", time.Now().Add(-3 * time.Hour), http.StatusOK},
+		{"Within Clock Skew", time.Now().Add(time.Minute), http.StatusOK},
+		{"Too Far In The Future", time.Now().Add(time.Hour), http.StatusBadRequest},
+		{"Too Old", time.Now().Add(-48 * time.Hour), http.StatusUnprocessableEntity},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := fmt.Sprintf(`{"campaign_id": %q, "user_id": "user%d", "ad_id": "ad1", "timestamp": %q}`, campaign.ID, i, test.timestamp.Format(time.RFC3339))
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			impressionHandler.TrackImpressionHandler(resp, req)
+
+			if resp.Code != test.expectedStatus {
+				t.Errorf("❌ Expected status %d, got %d: %s", test.expectedStatus, resp.Code, resp.Body.String())
+			}
+		})
+	}
+
+	stats, _ := statsRepo.GetCampaignStats(context.Background(), campaign.ID)
+	if stats.TotalCount != 3 || stats.LastHour != 2 || stats.Late != 1 || stats.TooOld != 1 {
+		t.Errorf("❌ Expected total=3 last_hour=2 late=1 too_old=1, got %+v", stats)
+	}
+}
+
+func TestTrackImpressionInvalidTraffic(t *testing.T) {
+	// Initialize shared in-memory server
+	memServer := memory.NewServer()
+
+	// Pass shared memory to repositories
+	campaignRepo := memory.NewInMemoryCampaignRepository(memServer)
+	impressionHandler := handlers.NewImpressionHandler(memory.NewInMemoryImpressionRepository(memServer))
+	statsRepo := memory.NewInMemoryStatsRepository(memServer)
+
+	denylist, err := traffic.IPDenylist([]string{"203.0.113.0/24"})
+	if err != nil {
+		t.Fatalf("❌ Failed to build the denylist: %v", err)
+	}
+	impressionHandler.Filter = traffic.Chain{traffic.BotUserAgents([]string{"bot"}), denylist, traffic.NewBurstDetector(2, time.Minute)}
+
+	campaign, _ := campaignRepo.CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Filtered Campaign", StartTime: time.Now()})
+
+	tests := []struct {
+		name      string
+		userID    string
+		userAgent string
+		remote    string
+	}{
+		{"Browser", "user1", "Mozilla/5.0", "198.51.100.7:5000"},
+		{"Known Bot", "user2", "Googlebot/2.1", "198.51.100.7:5000"},
+		{"Denied IP", "user3", "Mozilla/5.0", "203.0.113.9:5000"},
+		{"Burst Within Limit", "burst", "Mozilla/5.0", "198.51.100.7:5000"},
+		{"Burst Within Limit Again", "burst", "Mozilla/5.0", "198.51.100.7:5000"},
+		{"Burst Over Limit", "burst", "Mozilla/5.0", "198.51.100.7:5000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := fmt.Sprintf(`{"campaign_id": %q, "user_id": %q, "ad_id": "ad1"}`, campaign.ID, test.userID)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/impressions", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", test.userAgent)
+			req.RemoteAddr = test.remote
+			resp := httptest.NewRecorder()
+			impressionHandler.TrackImpressionHandler(resp, req)
+
+			if resp.Code != http.StatusOK {
+				t.Errorf("❌ Expected status %d, got %d: %s", http.StatusOK, resp.Code, resp.Body.String())
+			}
+		})
+	}
+
+	// The second burst impression is a duplicate, the third exceeds the burst limit
+	stats, _ := statsRepo.GetCampaignStats(context.Background(), campaign.ID)
+	if stats.TotalCount != 2 || stats.Invalid != 3 {
+		t.Errorf("❌ Expected total=2 invalid=3, got %+v", stats)
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"learning/cmd/config"
+	"learning/internal/handlers"
+	"learning/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// Run with -race: the level set through the endpoint must survive reloads running at the same time
+func TestLogLevelChangeRacesWithReload(t *testing.T) {
+	prev := config.Current()
+	defer config.Set(prev)
+	defer func() { _ = logger.SetLevel(prev.Logging.Level) }()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	write := func(ttl string) {
+		if err := os.WriteFile(path, []byte("app:\n  ttl: "+ttl+"\nlogging:\n  level: info\n"), 0o600); err != nil {
+			t.Fatalf("❌ Failed to write config file: %v", err)
+		}
+	}
+	write("60")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+	config.Set(cfg)
+	_ = logger.SetLevel("info")
+
+	// Applies the level of a reload the way main does
+	watcher := config.NewWatcher(path, zap.NewNop())
+	watcher.OnReload(func(prev, next *config.Config) {
+		if prev.Logging.Level != next.Logging.Level {
+			_ = logger.SetLevel(next.Logging.Level)
+		}
+	})
+
+	const rounds = 200
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			req := httptest.NewRequest(http.MethodPut, "/admin/log/level", strings.NewReader(`{"level":"debug"}`))
+			resp := httptest.NewRecorder()
+			handlers.LogLevelHandler(resp, req)
+			if resp.Code != http.StatusOK {
+				t.Errorf("❌ Expected status 200, got %d", resp.Code)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			write([]string{"120", "180"}[i%2])
+			_ = watcher.Reload()
+		}
+	}()
+	wg.Wait()
+
+	// The file never changes the level, so the reloads must neither undo it nor be undone by it
+	if level := config.Current().Logging.Level; level != "debug" || logger.Level().String() != "debug" {
+		t.Errorf("❌ Expected level debug in the config and the logger, got %s and %s", level, logger.Level())
+	}
+	if ttl := config.Current().App.TTL; ttl != 180 {
+		t.Errorf("❌ Expected the ttl of the last reload, 180, got %d", ttl)
+	}
+}
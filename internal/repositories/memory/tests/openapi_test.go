@@ -0,0 +1,465 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"learning/cmd/config"
+	"learning/cmd/server"
+	"learning/internal/entities"
+	"learning/internal/middleware"
+	"learning/internal/openapi"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// specDocument is the subset of an OpenAPI 3 document needed to check responses
+type specDocument struct {
+	Paths      map[string]map[string]specOperation `json:"paths"`
+	Components struct {
+		Schemas   map[string]map[string]any  `json:"schemas"`
+		Responses map[string]json.RawMessage `json:"responses"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+type specResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema map[string]any `json:"schema"`
+	} `json:"content"`
+}
+
+func loadSpec(t *testing.T) *specDocument {
+	var doc specDocument
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("❌ OpenAPI document is not valid JSON: %v", err)
+	}
+	return &doc
+}
+
+// responseSchema finds the JSON schema documented for a concrete request path, method and status
+func (d *specDocument) responseSchema(t *testing.T, path, method string, status int) map[string]any {
+	// A literal segment wins over a parameter, like /webhooks/dead-letters over /webhooks/{subscription_id}
+	var templates []string
+	for template := range d.Paths {
+		if matchPathTemplate(template, path) {
+			templates = append(templates, template)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+	})
+
+	for _, template := range templates {
+		operations := d.Paths[template]
+		op, ok := operations[strings.ToLower(method)]
+		if !ok {
+			t.Fatalf("❌ %s %s is not documented", method, template)
+		}
+		raw, ok := op.Responses[strconv.Itoa(status)]
+		if !ok {
+			t.Fatalf("❌ status %d of %s %s is not documented", status, method, template)
+		}
+
+		var resp specResponse
+		_ = json.Unmarshal(raw, &resp)
+		if resp.Ref != "" {
+			_ = json.Unmarshal(d.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")], &resp)
+		}
+		content, ok := resp.Content["application/json"]
+		if !ok {
+			t.Fatalf("❌ status %d of %s %s has no application/json content", status, method, template)
+		}
+		return content.Schema
+	}
+
+	t.Fatalf("❌ path %s is not documented", path)
+	return nil
+}
+
+func matchPathTemplate(template, path string) bool {
+	templateParts := strings.Split(template, "/")
+	pathParts := strings.Split(path, "/")
+	if len(templateParts) != len(pathParts) {
+		return false
+	}
+	for i, part := range templateParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks value against the supported subset of JSON schema keywords
+func (d *specDocument) validate(schema map[string]any, value any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return d.validate(d.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")], value, at)
+	}
+
+	var problems []string
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			problems = append(problems, d.validate(sub.(map[string]any), value, at)...)
+		}
+	}
+
+	if value == nil {
+		if schema["type"] != nil && schema["nullable"] != true {
+			problems = append(problems, at+": must not be null")
+		}
+		return problems
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return append(problems, at+": must be an object")
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, present := obj[name.(string)]; !present {
+					problems = append(problems, fmt.Sprintf("%s: missing required property %q", at, name))
+				}
+			}
+		}
+		for name, fieldValue := range obj {
+			fieldSchema, known := properties[name]
+			if !known {
+				if schema["additionalProperties"] == false {
+					problems = append(problems, fmt.Sprintf("%s: unexpected property %q", at, name))
+				}
+				continue
+			}
+			problems = append(problems, d.validate(fieldSchema.(map[string]any), fieldValue, at+"."+name)...)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return append(problems, at+": must be an array")
+		}
+		if itemSchema, ok := schema["items"].(map[string]any); ok {
+			for i, item := range items {
+				problems = append(problems, d.validate(itemSchema, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return append(problems, at+": must be a string")
+		}
+		problems = append(problems, validateString(schema, s, at)...)
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return append(problems, at+": must be an integer")
+		}
+		if minimum, ok := schema["minimum"].(float64); ok && n < minimum {
+			problems = append(problems, fmt.Sprintf("%s: must be >= %v", at, minimum))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problems = append(problems, at+": must be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, at+": must be a boolean")
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, option := range enum {
+			if option == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", at, value, enum))
+		}
+	}
+
+	return problems
+}
+
+func validateString(schema map[string]any, s, at string) []string {
+	var problems []string
+	if maxLength, ok := schema["maxLength"].(float64); ok && float64(len([]rune(s))) > maxLength {
+		problems = append(problems, fmt.Sprintf("%s: longer than %v", at, maxLength))
+	}
+	if minLength, ok := schema["minLength"].(float64); ok && float64(len([]rune(s))) < minLength {
+		problems = append(problems, fmt.Sprintf("%s: shorter than %v", at, minLength))
+	}
+	if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+		problems = append(problems, fmt.Sprintf("%s: does not match %s", at, pattern))
+	}
+	switch schema["format"] {
+	case "uuid":
+		if _, err := uuid.Parse(s); err != nil {
+			problems = append(problems, at+": must be a UUID")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			problems = append(problems, at+": must be an RFC 3339 date-time")
+		}
+	}
+	return problems
+}
+
+// checkAgainstSpec performs the request against the real router and validates the response body
+func checkAgainstSpec(t *testing.T, doc *specDocument, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return checkRequestAgainstSpec(t, doc, handler, req)
+}
+
+// checkRequestAgainstSpec is checkAgainstSpec for requests that need extra headers
+func checkRequestAgainstSpec(t *testing.T, doc *specDocument, handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	method, path := req.Method, req.URL.String()
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if contentType := resp.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("❌ %s %s: expected Content-Type application/json, got %q", method, path, contentType)
+	}
+
+	var value any
+	if err := json.Unmarshal(resp.Body.Bytes(), &value); err != nil {
+		t.Fatalf("❌ %s %s: response is not JSON: %v (%q)", method, path, err, resp.Body.String())
+	}
+
+	schema := doc.responseSchema(t, req.URL.Path, method, resp.Code)
+	for _, problem := range doc.validate(schema, value, "$") {
+		t.Errorf("❌ %s %s (%d): %s", method, path, resp.Code, problem)
+	}
+	return resp
+}
+
+func TestOpenAPIResponsesMatchSpec(t *testing.T) {
+	doc := loadSpec(t)
+
+	// Enable the event log and anomaly detection so the raw impression listing and the alerts are served
+	prev := config.Current()
+	cfg := *prev
+	cfg.Events.Enabled = true
+	cfg.Events.Dir = t.TempDir()
+	cfg.Anomaly.Enabled = true
+	cfg.Webhooks.Enabled = true
+	cfg.Server.AdminKeys = []string{"test-admin-key"}
+	config.Set(&cfg)
+	defer config.Set(prev)
+
+	handler, closeServer, err := server.SetupServer()
+	if err != nil {
+		t.Fatalf("❌ Failed to set up server: %v", err)
+	}
+	defer func() { _ = closeServer() }()
+
+	resp := checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/campaigns", `{"name": "Spec Campaign", "start_time": "2025-01-01T00:00:00Z"}`)
+	campaign := GetCampaignCreateResponse(resp, t)
+
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/campaigns", `{"name": ""}`)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/campaigns", `{"name": "`+strings.Repeat("a", 70000)+`"}`)
+
+	for _, body := range []string{`{"name": "Keyed Campaign", "start_time": "2025-01-01T00:00:00Z"}`, `{"name": "Keyed Campaign", "start_time": "2025-01-01T00:00:00Z"}`, `{"name": "Other"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/campaigns", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "spec-key")
+		checkRequestAgainstSpec(t, doc, handler, req)
+	}
+
+	impression := fmt.Sprintf(`{"campaign_id": %q, "user_id": "user123", "ad_id": "ad456"}`, campaign.ID)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", impression)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", impression)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", `{"campaign_id": "nope"}`)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", fmt.Sprintf(`{"campaign_id": %q, "user_id": "old", "ad_id": "ad456", "timestamp": "2020-01-01T00:00:00Z"}`, campaign.ID))
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", fmt.Sprintf(`{"campaign_id": %q, "user_id": "u", "ad_id": "a"}`, uuid.NewString()))
+
+	// The first impression completes a campaign with a goal of one, so the next one is rejected
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/campaigns", `{"name": "Goal", "start_time": "2025-01-01T00:00:00Z", "impression_goal": 1, "budget": 5, "cpm": 2.5}`)
+	goal := GetCampaignCreateResponse(resp, t)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", fmt.Sprintf(`{"campaign_id": %q, "user_id": "u1", "ad_id": "a"}`, goal.ID))
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", fmt.Sprintf(`{"campaign_id": %q, "user_id": "u2", "ad_id": "a"}`, goal.ID))
+	if resp.Code != http.StatusGone {
+		t.Errorf("❌ Expected status %d for a completed campaign, got %d", http.StatusGone, resp.Code)
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/stats/"+goal.ID, "")
+
+	end := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/campaigns", `{"name": "Paced", "start_time": "2025-01-01T00:00:00Z", "end_time": "`+end+`", "impression_goal": 1000}`)
+	paced := GetCampaignCreateResponse(resp, t)
+	resp = checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+paced.ID+"/pacing?curve=front_loaded", "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("❌ Expected a pacing report, got %d %s", resp.Code, resp.Body.String())
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+paced.ID+"/pacing?curve=late", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/pacing", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+uuid.NewString()+"/pacing", "")
+
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID, "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+uuid.NewString(), "")
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPatch, "/api/v1/campaigns/"+paced.ID, `{"name": "Renamed"}`)
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"name":"Renamed"`) || !strings.Contains(resp.Body.String(), `"end_time"`) {
+		t.Errorf("❌ Expected the renamed campaign with its end, got %d %s", resp.Code, resp.Body.String())
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodPatch, "/api/v1/campaigns/"+paced.ID, `{"end_time": "2024-01-01T00:00:00Z"}`)
+	checkAgainstSpec(t, doc, handler, http.MethodPatch, "/api/v1/campaigns/"+paced.ID, `{"impression_goal": 5}`)
+	checkAgainstSpec(t, doc, handler, http.MethodPatch, "/api/v1/campaigns/"+paced.ID, `{}`)
+	checkAgainstSpec(t, doc, handler, http.MethodPatch, "/api/v1/campaigns/"+uuid.NewString(), `{"name": "Ghost"}`)
+
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/stats/"+campaign.ID, "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/stats/not-a-uuid", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/stats/"+uuid.NewString(), "")
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/campaigns/stats/"+campaign.ID, nil)
+	req.Header.Set("Accept", "image/png")
+	if resp := checkRequestAgainstSpec(t, doc, handler, req); resp.Code != http.StatusNotAcceptable {
+		t.Errorf("❌ Expected status %d for an unsupported Accept, got %d", http.StatusNotAcceptable, resp.Code)
+	}
+
+	resp = checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns", "")
+	if !strings.Contains(resp.Body.String(), campaign.ID) {
+		t.Errorf("❌ Expected the campaign in the listing, got %s", resp.Body.String())
+	}
+	resp = checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/series?resolution=minute", "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("❌ Expected a time series, got %d %s", resp.Code, resp.Body.String())
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/series?resolution=second", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/series?format=xml", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+uuid.NewString()+"/series", "")
+
+	resp = checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/impressions?user_id=user123", "")
+	if !strings.Contains(resp.Body.String(), `"user_id":"user123"`) {
+		t.Errorf("❌ Expected the tracked impression in the listing, got %s", resp.Body.String())
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/impressions?limit=0&from=yesterday", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/impressions?cursor=bogus", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/not-a-uuid/impressions", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/not-a-uuid/stats/stream", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/"+uuid.NewString()+"/stats/stream", "")
+
+	webhooks := "/api/v1/campaigns/" + campaign.ID + "/webhooks"
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPost, webhooks, `{"url": "https://93.184.216.34/hook", "events": ["impressions.1k", "campaign.ended"]}`)
+	var created struct {
+		Data entities.WebhookSubscription `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil || created.Data.Secret == "" {
+		t.Errorf("❌ Expected the new subscription with its secret, got %s", resp.Body.String())
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodPost, webhooks, `{"url": "ftp://example.com", "events": ["impressions.2k"]}`)
+	if resp := checkAgainstSpec(t, doc, handler, http.MethodPost, webhooks, `{"url": "http://169.254.169.254/latest/meta-data", "events": ["campaign.ended"]}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("❌ Expected a link-local webhook target to be refused, got %d", resp.Code)
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/campaigns/"+uuid.NewString()+"/webhooks", `{"url": "https://example.com/hook", "events": ["campaign.started"]}`)
+	checkAgainstSpec(t, doc, handler, http.MethodGet, webhooks, "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, webhooks+"/dead-letters", "")
+	checkAgainstSpec(t, doc, handler, http.MethodDelete, webhooks+"/"+created.Data.ID, "")
+	checkAgainstSpec(t, doc, handler, http.MethodDelete, webhooks+"/"+created.Data.ID, "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/campaigns/not-a-uuid/webhooks", "")
+
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/alerts", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/alerts?campaign_id="+campaign.ID, "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/alerts?campaign_id=nope", "")
+
+	csvImport := "campaign_id,timestamp,user_id,ad_id\n" +
+		campaign.ID + ",2025-01-01T00:00:00Z,imported,ad456\n" +
+		uuid.NewString() + ",2025-01-01T00:00:00Z,imported,ad456\n"
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/imports?format=csv", csvImport)
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"imported":1`) {
+		t.Errorf("❌ Expected one imported impression, got %d %s", resp.Code, resp.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/imports", strings.NewReader(fmt.Sprintf(`{"campaign_id": %q, "timestamp": "2025-01-01T00:00:00Z", "user_id": "u", "ad_id": "a"}`, campaign.ID)))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	checkRequestAgainstSpec(t, doc, handler, req)
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/imports?format=csv", csvImport)
+	if !strings.Contains(resp.Body.String(), `"imported":0`) || !strings.Contains(resp.Body.String(), `"already_applied":true`) {
+		t.Errorf("❌ Expected the same file not to be applied twice, got %s", resp.Body.String())
+	}
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/imports", csvImport)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/imports?format=csv", "campaign_id,user_id\n")
+
+	// With an event stream the response is a result event wrapping the same envelope
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/imports?format=csv", strings.NewReader(csvImport))
+	req.Header.Set("Accept", "text/event-stream")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Header().Get("Content-Type") != "text/event-stream" || !strings.Contains(resp.Body.String(), "event: progress\n") ||
+		!strings.Contains(resp.Body.String(), "event: result\ndata: {\"status\":200,\"success\":true,") {
+		t.Errorf("❌ Expected progress and result events, got %q", resp.Body.String())
+	}
+
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/api/v1/openapi.json", "")
+	checkAgainstSpec(t, doc, handler, http.MethodGet, "/health", "")
+
+	// The admin endpoints need one of the admin keys
+	if resp := checkAgainstSpec(t, doc, handler, http.MethodPut, "/admin/log/level", `{"level": "debug"}`); resp.Code != http.StatusUnauthorized {
+		t.Errorf("❌ Expected a change without an admin key to be refused, got %d", resp.Code)
+	}
+	admin := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/log/level", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.AdminKeyHeader, "test-admin-key")
+		return checkRequestAgainstSpec(t, doc, handler, req)
+	}
+	admin(http.MethodGet, "")
+	admin(http.MethodPut, `{"level": "verbose"}`)
+	if resp := admin(http.MethodPut, `{"level": "info"}`); resp.Code != http.StatusOK || config.Current().Logging.Level != "info" {
+		t.Errorf("❌ Expected the level in the live config, got %d and %s", resp.Code, config.Current().Logging.Level)
+	}
+	withoutKeys := *config.Current()
+	withoutKeys.Server.AdminKeys = nil
+	config.Set(&withoutKeys)
+	if resp := checkAgainstSpec(t, doc, handler, http.MethodGet, "/admin/log/level", ""); resp.Code != http.StatusForbidden {
+		t.Errorf("❌ Expected the admin endpoints to be disabled without keys, got %d", resp.Code)
+	}
+	config.Set(&cfg)
+
+	// A burst of one lets the first impression through and limits the next
+	limited := cfg
+	limited.Server.Routes = map[string]config.RouteConfig{"/api/v1/impressions": {RateLimit: config.RateLimitConfig{Rate: 0.01, Burst: 1}}}
+	config.Set(&limited)
+	checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", impression)
+	resp = checkAgainstSpec(t, doc, handler, http.MethodPost, "/api/v1/impressions", impression)
+	if resp.Code != http.StatusTooManyRequests || resp.Header().Get("Retry-After") == "" {
+		t.Errorf("❌ Expected 429 with Retry-After, got %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestOpenAPISchemasCoverEntities(t *testing.T) {
+	doc := loadSpec(t)
+
+	var names []string
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range []string{"APIResponse", "Campaign", "CreateCampaignRequest", "Stats", "TrackImpressionRequest"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("❌ schema %q missing from the OpenAPI document, have %v", name, names)
+		}
+	}
+}
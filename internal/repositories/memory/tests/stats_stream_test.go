@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"learning/internal/entities"
+	"learning/internal/handlers"
+	"learning/internal/repositories"
+	"learning/internal/repositories/memory"
+	"learning/internal/stream"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// nextStats reads the stream up to the next stats event, skipping comments
+func nextStats(t *testing.T, events *bufio.Scanner) entities.Stats {
+	t.Helper()
+	for events.Scan() {
+		line := events.Text()
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var stats entities.Stats
+			if err := json.Unmarshal([]byte(data), &stats); err != nil {
+				t.Fatalf("❌ Event data is not Stats: %v (%q)", err, data)
+			}
+			return stats
+		}
+	}
+	t.Fatalf("❌ Stream ended before the next event: %v", events.Err())
+	return entities.Stats{}
+}
+
+func TestStreamStatsPushesChanges(t *testing.T) {
+	ctx := context.Background()
+	memServer := memory.NewServer()
+	campaign, _ := memory.NewInMemoryCampaignRepository(memServer).CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Live", StartTime: time.Now()})
+	statsRepo := memory.NewInMemoryStatsRepository(memServer)
+
+	hub := stream.NewHub(statsRepo, 10*time.Millisecond, 10, zap.NewNop())
+	hubCtx, stopHub := context.WithCancel(ctx)
+	defer stopHub()
+	go hub.Run(hubCtx)
+
+	emitter := repositories.NewEmitter()
+	emitter.Subscribe(hub.HandleEvent)
+	impressions := repositories.NewEmittingImpressionRepository(memory.NewInMemoryImpressionRepository(memServer), emitter)
+
+	streamHandler := handlers.NewStatsStreamHandler(statsRepo, hub)
+	server := httptest.NewServer(http.HandlerFunc(streamHandler.StreamStatsHandler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/campaigns/" + campaign.ID + "/stats/stream")
+	if err != nil {
+		t.Fatalf("❌ Failed to open the stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("❌ Expected an event stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := bufio.NewScanner(resp.Body)
+	if stats := nextStats(t, events); stats.CampaignID != campaign.ID || stats.TotalCount != 0 {
+		t.Errorf("❌ Expected the current stats first, got %+v", stats)
+	}
+
+	impressions.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: "u1", AdID: "ad"})
+	if stats := nextStats(t, events); stats.TotalCount != 1 {
+		t.Errorf("❌ Expected the pushed total of 1, got %+v", stats)
+	}
+
+	// Shutting the server down ends the stream instead of waiting for the client
+	shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(shutdownCtx); err != nil {
+		t.Errorf("❌ Expected open streams not to hold up the shutdown: %v", err)
+	}
+}
+
+func TestStreamStatsUnknownCampaign(t *testing.T) {
+	memServer := memory.NewServer()
+	statsRepo := memory.NewInMemoryStatsRepository(memServer)
+	hub := stream.NewHub(statsRepo, time.Second, 10, zap.NewNop())
+	streamHandler := handlers.NewStatsStreamHandler(statsRepo, hub)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/campaigns/2f6a2c0e-8a4e-4c59-9d63-4f1b8f0b6c1a/stats/stream", nil)
+	resp := httptest.NewRecorder()
+	streamHandler.StreamStatsHandler(resp, req)
+
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("❌ Expected status %d, got %d", http.StatusNotFound, resp.Code)
+	}
+	if hub.Subscribers() != 0 {
+		t.Errorf("❌ Expected the subscription to be released, got %d subscribers", hub.Subscribers())
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestGetCampaignStatsWithInvalidInput(t *testing.T) {
@@ -21,11 +23,12 @@ func TestGetCampaignStatsWithInvalidInput(t *testing.T) {
 		campaignID     string
 		expectedStatus int
 	}{
-		{"", http.StatusBadRequest},                          // Empty ID
-		{"non-existent-id", http.StatusNotFound},             // ID that doesn't exist
-		{"12345", http.StatusNotFound},                       // Too short ID
-		{"aaaaaaaa-bbbb-cccc", http.StatusNotFound},          // Non-existent valid format ID
-		{url.QueryEscape("!@#$%^&*()"), http.StatusNotFound}, // Invalid characters
+		{"", http.StatusBadRequest},                            // Empty ID
+		{"non-existent-id", http.StatusBadRequest},             // Not a UUID
+		{"12345", http.StatusBadRequest},                       // Too short ID
+		{"aaaaaaaa-bbbb-cccc", http.StatusBadRequest},          // Truncated UUID
+		{url.QueryEscape("!@#$%^&*()"), http.StatusBadRequest}, // Invalid characters
+		{uuid.NewString(), http.StatusNotFound},                // Valid UUID that doesn't exist
 	}
 
 	for _, test := range invalidCampaignIDs {
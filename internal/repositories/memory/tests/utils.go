@@ -3,6 +3,7 @@ package tests
 import (
 	"encoding/json"
 	"learning/internal/entities"
+	"learning/internal/validators"
 	"net/http/httptest"
 	"testing"
 )
@@ -35,3 +36,21 @@ func GetStatsResponse(resp *httptest.ResponseRecorder, t *testing.T) entities.St
 	stats := response.Data
 	return stats
 }
+
+func GetFieldErrors(resp *httptest.ResponseRecorder, t *testing.T) map[string]string {
+	var response struct {
+		Success bool                    `json:"success"`
+		Message string                  `json:"message"`
+		Errors  []validators.FieldError `json:"errors"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("❌ Failed to decode response: %v", err)
+	}
+
+	fields := make(map[string]string, len(response.Errors))
+	for _, fe := range response.Errors {
+		fields[fe.Field] = fe.Message
+	}
+	return fields
+}
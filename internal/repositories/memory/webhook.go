@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+type InMemoryWebhookRepository struct {
+	server *entities.Server
+}
+
+// NewInMemoryWebhookRepository keeps webhook subscriptions in the shared server
+func NewInMemoryWebhookRepository(server *entities.Server) *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{server: server}
+}
+
+// CreateSubscription stores a copy of s
+func (r *InMemoryWebhookRepository) CreateSubscription(ctx context.Context, s entities.WebhookSubscription) error {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := r.server.Campaigns[s.CampaignID]; !ok {
+		return repositories.ErrCampaignNotFound
+	}
+	s.Events = append([]string(nil), s.Events...)
+	r.server.Webhooks[s.ID] = s
+	return nil
+}
+
+// ListSubscriptions copies the matching subscriptions out of shared memory
+func (r *InMemoryWebhookRepository) ListSubscriptions(ctx context.Context, campaignID string) ([]entities.WebhookSubscription, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	subscriptions := []entities.WebhookSubscription{}
+	for _, s := range r.server.Webhooks {
+		if campaignID == "" || s.CampaignID == campaignID {
+			s.Events = append([]string(nil), s.Events...)
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	repositories.SortSubscriptions(subscriptions)
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes the subscription when it belongs to the campaign
+func (r *InMemoryWebhookRepository) DeleteSubscription(ctx context.Context, campaignID, id string) error {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s, ok := r.server.Webhooks[id]; !ok || s.CampaignID != campaignID {
+		return repositories.ErrSubscriptionNotFound
+	}
+	delete(r.server.Webhooks, id)
+	return nil
+}
+
+// ClaimWebhookEvent records the event unless it was recorded before
+func (r *InMemoryWebhookRepository) ClaimWebhookEvent(ctx context.Context, campaignID, event string) (bool, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	events, ok := r.server.WebhookEvents[campaignID]
+	if !ok {
+		events = make(map[string]time.Time)
+		r.server.WebhookEvents[campaignID] = events
+	}
+	if _, announced := events[event]; announced {
+		return false, nil
+	}
+	events[event] = time.Now()
+	return true, nil
+}
+
+// AddDeadLetter appends the letter and drops the oldest ones of its campaign beyond limit
+func (r *InMemoryWebhookRepository) AddDeadLetter(ctx context.Context, letter entities.DeadLetter, limit int) error {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	id := letter.Payload.CampaignID
+	letters := append(r.server.DeadLetters[id], letter)
+	if overflow := len(letters) - limit; overflow > 0 {
+		letters = append(letters[:0:0], letters[overflow:]...)
+	}
+	r.server.DeadLetters[id] = letters
+	return nil
+}
+
+// ListDeadLetters copies the letters of the campaign, newest first
+func (r *InMemoryWebhookRepository) ListDeadLetters(ctx context.Context, campaignID string) ([]entities.DeadLetter, error) {
+	r.server.Mu.Lock()
+	defer r.server.Mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stored := r.server.DeadLetters[campaignID]
+	letters := make([]entities.DeadLetter, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		letters = append(letters, stored[i])
+	}
+	return letters, nil
+}
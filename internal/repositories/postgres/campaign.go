@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+type PostgresCampaignRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresCampaignRepository stores campaigns in the shared connection pool
+func NewPostgresCampaignRepository(db *sql.DB) *PostgresCampaignRepository {
+	return &PostgresCampaignRepository{db: db}
+}
+
+// CreateCampaign inserts the campaign and its zeroed stats row in one transaction
+func (r *PostgresCampaignRepository) CreateCampaign(ctx context.Context, req entities.CreateCampaignRequest) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign := entities.Campaign{
+		ID:             uuid.New().String(),
+		Name:           req.Name,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		ImpressionGoal: req.ImpressionGoal,
+		Budget:         req.Budget,
+		CPM:            req.CPM,
+		Status:         entities.CampaignActive,
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO campaigns (id, name, start_time, end_time, impression_goal, budget, cpm, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		campaign.ID, campaign.Name, campaign.StartTime, campaign.EndTime, campaign.ImpressionGoal, campaign.Budget, campaign.CPM, campaign.Status,
+	); err != nil {
+		return entities.Campaign{}, fmt.Errorf("insert campaign: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO campaign_stats (campaign_id) VALUES ($1)",
+		campaign.ID,
+	); err != nil {
+		return entities.Campaign{}, fmt.Errorf("insert campaign stats: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	return campaign, nil
+}
+
+// selectCampaign lists the columns scanCampaign reads
+const selectCampaign = "SELECT id, name, start_time, end_time, impression_goal, budget, cpm, status, completed_at FROM campaigns"
+
+// GetCampaign reads one campaign row
+func (r *PostgresCampaignRepository) GetCampaign(ctx context.Context, id string) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign, err := scanCampaign(r.db.QueryRowContext(ctx, selectCampaign+" WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) || isUnknownCampaign(err) {
+		return entities.Campaign{}, repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+	return campaign, nil
+}
+
+// UpdateCampaign changes only the given columns, so it cannot undo a concurrent completion
+func (r *PostgresCampaignRepository) UpdateCampaign(ctx context.Context, id string, req entities.UpdateCampaignRequest) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign, err := scanCampaign(r.db.QueryRowContext(ctx,
+		"UPDATE campaigns SET name = COALESCE($2, name), end_time = COALESCE($3, end_time) WHERE id = $1 RETURNING id, name, start_time, end_time, impression_goal, budget, cpm, status, completed_at",
+		id, req.Name, req.EndTime,
+	))
+	if errors.Is(err, sql.ErrNoRows) || isUnknownCampaign(err) {
+		return entities.Campaign{}, repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return entities.Campaign{}, fmt.Errorf("update campaign: %w", err)
+	}
+	return campaign, nil
+}
+
+// ListCampaigns streams the campaign rows in ID order, calling fn for each while the result set is open
+func (r *PostgresCampaignRepository) ListCampaigns(ctx context.Context, fn func(entities.Campaign) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rows, err := r.db.QueryContext(ctx, selectCampaign+" ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(campaign); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// scanCampaign reads a row of selectCampaign from *sql.Row or *sql.Rows
+func scanCampaign(row interface{ Scan(...any) error }) (entities.Campaign, error) {
+	var campaign entities.Campaign
+	var endTime, completedAt sql.NullTime
+	err := row.Scan(&campaign.ID, &campaign.Name, &campaign.StartTime, &endTime,
+		&campaign.ImpressionGoal, &campaign.Budget, &campaign.CPM, &campaign.Status, &completedAt)
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+	if endTime.Valid {
+		campaign.EndTime = &endTime.Time
+	}
+	if completedAt.Valid {
+		campaign.CompletedAt = &completedAt.Time
+	}
+	return campaign, nil
+}
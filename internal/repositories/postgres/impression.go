@@ -0,0 +1,299 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"net/http"
+	"time"
+)
+
+type PostgresImpressionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresImpressionRepository(db *sql.DB) *PostgresImpressionRepository {
+	return &PostgresImpressionRepository{db: db}
+}
+
+// upsertDedup records the user as seen unless they were already seen after the cutoff.
+// It affects no row for a duplicate, and the row lock taken by ON CONFLICT makes the check
+// safe against concurrent requests for the same user.
+const upsertDedup = `
+INSERT INTO impression_dedup (campaign_id, user_id, last_seen)
+VALUES ($1, $2, $3)
+ON CONFLICT (campaign_id, user_id) DO UPDATE
+    SET last_seen = EXCLUDED.last_seen
+    WHERE impression_dedup.last_seen <= $4`
+
+// selectDelivery reads what bounds the delivery of a campaign
+const selectDelivery = `
+SELECT impression_goal, budget, cpm, status FROM campaigns WHERE id = $1`
+
+// incrementStats counts the impression unless the total already reached the limit in $3 (0 for
+// none) and returns the new total. The row lock makes concurrent requests re-check the limit
+// against the total they waited for.
+const incrementStats = `
+UPDATE campaign_stats
+    SET total = total + 1,
+        late  = late + $2
+WHERE campaign_id = $1 AND ($3::bigint = 0 OR total < $3::bigint)
+RETURNING total`
+
+const completeCampaign = `
+UPDATE campaigns SET status = 'completed', completed_at = $2 WHERE id = $1`
+
+// incrementBuckets bumps the minute bucket starting at $2 and the hour bucket starting at $3.
+// Bucket starts are computed in Go so they do not depend on the session time zone.
+const incrementBuckets = `
+INSERT INTO campaign_buckets (campaign_id, width, bucket, count)
+VALUES ($1, 60, $2, 1),
+       ($1, 3600, $3, 1)
+ON CONFLICT (campaign_id, width, bucket) DO UPDATE
+    SET count = campaign_buckets.count + 1`
+
+const incrementTooOld = `
+UPDATE campaign_stats SET too_old = too_old + 1 WHERE campaign_id = $1`
+
+const incrementInvalid = `
+UPDATE campaign_stats SET invalid = invalid + 1 WHERE campaign_id = $1`
+
+// TrackImpression checks the campaign is still delivering, then applies the dedup upsert and the
+// limited counter and bucket updates in one transaction
+func (r *PostgresImpressionRepository) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	if err := ctx.Err(); err != nil {
+		return err, http.StatusServiceUnavailable
+	}
+
+	now := time.Now().UTC()
+	cutoff := now.Add(-time.Duration(config.Current().App.TTL) * time.Second)
+
+	var campaign entities.Campaign
+	err := r.db.QueryRowContext(ctx, selectDelivery, req.CampaignID).
+		Scan(&campaign.ImpressionGoal, &campaign.Budget, &campaign.CPM, &campaign.Status)
+	if errors.Is(err, sql.ErrNoRows) || isUnknownCampaign(err) {
+		return repositories.ErrCampaignNotFound, http.StatusNotFound
+	}
+	if err != nil {
+		return err, statusFor(ctx)
+	}
+	if campaign.Status == entities.CampaignCompleted {
+		return repositories.ErrCampaignCompleted, http.StatusGone
+	}
+
+	if req.InvalidReason != "" {
+		if err, status := r.incrementCounter(ctx, incrementInvalid, req.CampaignID); err != nil {
+			return err, status
+		}
+		return repositories.ErrInvalidTraffic, http.StatusOK
+	}
+
+	arrival := repositories.ClassifyArrival(req, now)
+	if arrival.TooOld {
+		if err, status := r.incrementCounter(ctx, incrementTooOld, req.CampaignID); err != nil {
+			return err, status
+		}
+		return repositories.ErrImpressionTooOld, http.StatusUnprocessableEntity
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err, statusFor(ctx)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, upsertDedup, req.CampaignID, req.UserID, now, cutoff)
+	if isUnknownCampaign(err) {
+		return repositories.ErrCampaignNotFound, http.StatusNotFound
+	}
+	if err != nil {
+		return err, statusFor(ctx)
+	}
+	if counted, err := result.RowsAffected(); err != nil || counted == 0 {
+		if err != nil {
+			return err, http.StatusInternalServerError
+		}
+		return repositories.ErrDuplicateImpression, http.StatusOK
+	}
+
+	late := 0
+	if arrival.Late {
+		late = 1
+	}
+	limit := campaign.ImpressionLimit()
+	var total int64
+	err = tx.QueryRowContext(ctx, incrementStats, req.CampaignID, late, limit).Scan(&total)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Another request counted the last impression first; rolling back releases the dedup claim
+		return repositories.ErrCampaignCompleted, http.StatusGone
+	}
+	if err != nil {
+		return err, statusFor(ctx)
+	}
+	if limit > 0 && total >= limit {
+		if _, err := tx.ExecContext(ctx, completeCampaign, req.CampaignID, now); err != nil {
+			return err, statusFor(ctx)
+		}
+	}
+	at := arrival.At.UTC()
+	if _, err := tx.ExecContext(ctx, incrementBuckets, req.CampaignID, at.Truncate(time.Minute), at.Truncate(time.Hour)); err != nil {
+		return err, statusFor(ctx)
+	}
+	if err := tx.Commit(); err != nil {
+		return err, statusFor(ctx)
+	}
+
+	return nil, http.StatusOK
+}
+
+// incrementCounter runs one of the single counter updates, which match no row for an unknown campaign
+func (r *PostgresImpressionRepository) incrementCounter(ctx context.Context, query, campaignID string) (error, int) {
+	result, err := r.db.ExecContext(ctx, query, campaignID)
+	if isUnknownCampaign(err) {
+		return repositories.ErrCampaignNotFound, http.StatusNotFound
+	}
+	if err != nil {
+		return err, statusFor(ctx)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return repositories.ErrCampaignNotFound, http.StatusNotFound
+	}
+	return nil, http.StatusOK
+}
+
+// addStats adds a historical total and returns the new one, matching no row for an unknown campaign
+const addStats = `
+UPDATE campaign_stats SET total = total + $2 WHERE campaign_id = $1
+RETURNING total`
+
+// addBucket adds a historical count to one bucket of width $2 starting at $3
+const addBucket = `
+INSERT INTO campaign_buckets (campaign_id, width, bucket, count)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (campaign_id, width, bucket) DO UPDATE
+    SET count = campaign_buckets.count + EXCLUDED.count`
+
+// recordBackfill records that an import was applied to a campaign, matching no row when it already was
+const recordBackfill = `
+INSERT INTO campaign_backfills (campaign_id, import_id, applied_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (campaign_id, import_id) DO NOTHING`
+
+// ApplyBackfill adds historical counts in one transaction, skipping buckets past their retention
+func (r *PostgresImpressionRepository) ApplyBackfill(ctx context.Context, b repositories.Backfill) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var campaign entities.Campaign
+	err := r.db.QueryRowContext(ctx, selectDelivery, b.CampaignID).
+		Scan(&campaign.ImpressionGoal, &campaign.Budget, &campaign.CPM, &campaign.Status)
+	if errors.Is(err, sql.ErrNoRows) || isUnknownCampaign(err) {
+		return repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if b.ImportID != "" {
+		result, err := tx.ExecContext(ctx, recordBackfill, b.CampaignID, b.ImportID, now)
+		if isUnknownCampaign(err) {
+			return repositories.ErrCampaignNotFound
+		}
+		if err != nil {
+			return err
+		}
+		recorded, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if recorded == 0 {
+			return repositories.ErrBackfillApplied
+		}
+	}
+
+	var total int64
+	err = tx.QueryRowContext(ctx, addStats, b.CampaignID, b.Total).Scan(&total)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if limit := campaign.ImpressionLimit(); limit > 0 && total >= limit && campaign.Status != entities.CampaignCompleted {
+		if _, err := tx.ExecContext(ctx, completeCampaign, b.CampaignID, now); err != nil {
+			return err
+		}
+	}
+
+	for _, buckets := range []struct {
+		width  int64
+		counts map[int64]int64
+		oldest time.Time
+	}{
+		{60, b.Minutes, now.Add(-minuteBucketRetention)},
+		{3600, b.Hours, now.Add(-hourBucketRetention)},
+	} {
+		for bucket, count := range buckets.counts {
+			start := time.Unix(bucket*buckets.width, 0).UTC()
+			if start.Before(buckets.oldest) {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, addBucket, b.CampaignID, buckets.width, start, count); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Time buckets older than these are deleted by PurgeExpired
+const (
+	minuteBucketRetention = 24 * time.Hour
+	hourBucketRetention   = 30 * 24 * time.Hour
+)
+
+const deleteExpiredBuckets = `
+DELETE FROM campaign_buckets
+WHERE (width = 60 AND bucket < $1) OR (width = 3600 AND bucket < $2)`
+
+// PurgeExpired removes dedup rows older than the live TTL and time buckets past their retention,
+// and returns how many rows were deleted
+func (r *PostgresImpressionRepository) PurgeExpired(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	cutoff := now.Add(-time.Duration(config.Current().App.TTL) * time.Second)
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM impression_dedup WHERE last_seen < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err = r.db.ExecContext(ctx, deleteExpiredBuckets, now.Add(-minuteBucketRetention), now.Add(-hourBucketRetention))
+	if err != nil {
+		return int(purged), err
+	}
+	buckets, err := result.RowsAffected()
+	return int(purged + buckets), err
+}
+
+// statusFor distinguishes failures caused by the request context from database failures
+func statusFor(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
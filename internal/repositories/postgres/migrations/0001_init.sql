@@ -0,0 +1,23 @@
+CREATE TABLE campaigns (
+    id         UUID PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    start_time TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE campaign_stats (
+    campaign_id UUID PRIMARY KEY REFERENCES campaigns (id) ON DELETE CASCADE,
+    last_hour   BIGINT NOT NULL DEFAULT 0,
+    last_day    BIGINT NOT NULL DEFAULT 0,
+    total       BIGINT NOT NULL DEFAULT 0
+);
+
+-- One row per (campaign, user): the time the user was last counted
+CREATE TABLE impression_dedup (
+    campaign_id UUID        NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    user_id     TEXT        NOT NULL,
+    last_seen   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (campaign_id, user_id)
+);
+
+CREATE INDEX impression_dedup_last_seen_idx ON impression_dedup (last_seen);
@@ -0,0 +1,17 @@
+-- Windowed counts are now summed from time buckets instead of counters that never aged
+ALTER TABLE campaign_stats
+    DROP COLUMN last_hour,
+    DROP COLUMN last_day,
+    ADD COLUMN late    BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN too_old BIGINT NOT NULL DEFAULT 0;
+
+-- Impressions per campaign and event time bucket; width is 60 for minute and 3600 for hour buckets
+CREATE TABLE campaign_buckets (
+    campaign_id UUID        NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    width       INTEGER     NOT NULL,
+    bucket      TIMESTAMPTZ NOT NULL,
+    count       BIGINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (campaign_id, width, bucket)
+);
+
+CREATE INDEX campaign_buckets_bucket_idx ON campaign_buckets (bucket);
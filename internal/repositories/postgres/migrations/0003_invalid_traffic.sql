@@ -0,0 +1,3 @@
+-- Impressions flagged by the invalid-traffic filters are counted apart from the valid ones
+ALTER TABLE campaign_stats
+    ADD COLUMN invalid BIGINT NOT NULL DEFAULT 0;
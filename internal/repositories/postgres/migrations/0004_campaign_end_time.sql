@@ -0,0 +1,3 @@
+-- Campaigns may end at a fixed time; NULL runs until further notice
+ALTER TABLE campaigns
+    ADD COLUMN end_time TIMESTAMPTZ;
@@ -0,0 +1,8 @@
+-- Campaigns may stop at an impression goal or a budget priced at a CPM; 0 leaves them unbounded.
+-- Reaching either completes the campaign.
+ALTER TABLE campaigns
+    ADD COLUMN impression_goal BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN budget DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN cpm DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN completed_at TIMESTAMPTZ;
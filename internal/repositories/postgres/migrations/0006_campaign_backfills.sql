@@ -0,0 +1,7 @@
+-- Imports applied to each campaign, so that applying the same import again is refused
+CREATE TABLE campaign_backfills (
+    campaign_id UUID        NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    import_id   TEXT        NOT NULL,
+    applied_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (campaign_id, import_id)
+);
@@ -0,0 +1,26 @@
+-- Webhook subscriptions, the events announced for each campaign and the payloads that could not be delivered
+CREATE TABLE webhook_subscriptions (
+    id          UUID PRIMARY KEY,
+    campaign_id UUID        NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    url         TEXT        NOT NULL,
+    events      JSONB       NOT NULL,
+    secret      TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX webhook_subscriptions_campaign_idx ON webhook_subscriptions (campaign_id);
+
+CREATE TABLE webhook_events (
+    campaign_id  UUID        NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    event        TEXT        NOT NULL,
+    announced_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (campaign_id, event)
+);
+
+CREATE TABLE webhook_dead_letters (
+    id          BIGSERIAL PRIMARY KEY,
+    campaign_id UUID  NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    letter      JSONB NOT NULL
+);
+
+CREATE INDEX webhook_dead_letters_campaign_idx ON webhook_dead_letters (campaign_id, id);
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"time"
+)
+
+type PostgresStatsRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresStatsRepository(db *sql.DB) *PostgresStatsRepository {
+	return &PostgresStatsRepository{db: db}
+}
+
+// selectStats reads the counters of one campaign and sums its buckets of the last 60 minutes and 24 hours
+const selectStats = `
+SELECT s.total, s.late, s.too_old, s.invalid, c.cpm,
+       COALESCE((SELECT sum(count) FROM campaign_buckets b
+                 WHERE b.campaign_id = s.campaign_id AND b.width = 60 AND b.bucket >= $2), 0),
+       COALESCE((SELECT sum(count) FROM campaign_buckets b
+                 WHERE b.campaign_id = s.campaign_id AND b.width = 3600 AND b.bucket >= $3), 0)
+FROM campaign_stats s
+JOIN campaigns c ON c.id = s.campaign_id
+WHERE s.campaign_id = $1`
+
+// GetCampaignStats reads the aggregated counters of one campaign
+func (r *PostgresStatsRepository) GetCampaignStats(ctx context.Context, campaignID string) (entities.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Stats{}, err
+	}
+
+	now := time.Now().UTC()
+	firstMinute := now.Truncate(time.Minute).Add(-(repositories.LastHourBuckets - 1) * time.Minute)
+	firstHour := now.Truncate(time.Hour).Add(-(repositories.LastDayBuckets - 1) * time.Hour)
+
+	stats := entities.Stats{CampaignID: campaignID}
+	var campaign entities.Campaign
+	err := r.db.QueryRowContext(ctx, selectStats, campaignID, firstMinute, firstHour).
+		Scan(&stats.TotalCount, &stats.Late, &stats.TooOld, &stats.Invalid, &campaign.CPM, &stats.LastHour, &stats.LastDay)
+	if errors.Is(err, sql.ErrNoRows) || isUnknownCampaign(err) {
+		return entities.Stats{}, repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return entities.Stats{}, err
+	}
+	stats.Spend = campaign.Spend(stats.TotalCount)
+
+	return stats, nil
+}
+
+// selectSeries joins the buckets of the range onto the campaign row, so an unknown campaign returns no
+// rows and a known one without buckets a single row of NULLs
+const selectSeries = `
+SELECT b.bucket, b.count
+FROM campaigns c
+LEFT JOIN campaign_buckets b
+       ON b.campaign_id = c.id AND b.width = $2 AND b.bucket >= $3 AND b.bucket < $4
+WHERE c.id = $1`
+
+// GetCampaignSeries reads the minute or hour buckets of the query
+func (r *PostgresStatsRepository) GetCampaignSeries(ctx context.Context, q entities.SeriesQuery) ([]entities.SeriesPoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	width := int64(60)
+	if q.Resolution == entities.ResolutionHour {
+		width = 3600
+	}
+	first, last := repositories.SeriesBuckets(q)
+	from := time.Unix(first*width, 0).UTC()
+	to := time.Unix((last+1)*width, 0).UTC()
+
+	rows, err := r.db.QueryContext(ctx, selectSeries, q.CampaignID, width, from, to)
+	if isUnknownCampaign(err) {
+		return nil, repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := false
+	counts := make(map[int64]int64)
+	for rows.Next() {
+		found = true
+		var bucket sql.NullTime
+		var count sql.NullInt64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if bucket.Valid {
+			counts[bucket.Time.Unix()/width] = count.Int64
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, repositories.ErrCampaignNotFound
+	}
+
+	return repositories.DenseSeries(q, counts), nil
+}
@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+type PostgresWebhookRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresWebhookRepository stores webhook subscriptions in the shared connection pool
+func NewPostgresWebhookRepository(db *sql.DB) *PostgresWebhookRepository {
+	return &PostgresWebhookRepository{db: db}
+}
+
+// CreateSubscription inserts s; the foreign key refuses unknown campaigns
+func (r *PostgresWebhookRepository) CreateSubscription(ctx context.Context, s entities.WebhookSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	events, err := json.Marshal(s.Events)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO webhook_subscriptions (id, campaign_id, url, events, secret, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		s.ID, s.CampaignID, s.URL, string(events), s.Secret, s.CreatedAt,
+	)
+	if isUnknownCampaign(err) {
+		return repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("insert webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// ListSubscriptions reads the matching rows, oldest first
+func (r *PostgresWebhookRepository) ListSubscriptions(ctx context.Context, campaignID string) ([]entities.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id, campaign_id, url, events, secret, created_at FROM webhook_subscriptions"
+	var args []any
+	if campaignID != "" {
+		query += " WHERE campaign_id = $1"
+		args = append(args, campaignID)
+	}
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY created_at, id", args...)
+	if isUnknownCampaign(err) {
+		return []entities.WebhookSubscription{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []entities.WebhookSubscription{}
+	for rows.Next() {
+		var s entities.WebhookSubscription
+		var events []byte
+		if err := rows.Scan(&s.ID, &s.CampaignID, &s.URL, &events, &s.Secret, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(events, &s.Events); err != nil {
+			return nil, fmt.Errorf("decode webhook events: %w", err)
+		}
+		s.CreatedAt = s.CreatedAt.UTC()
+		subscriptions = append(subscriptions, s)
+	}
+	return subscriptions, rows.Err()
+}
+
+// DeleteSubscription deletes the row when it belongs to the campaign
+func (r *PostgresWebhookRepository) DeleteSubscription(ctx context.Context, campaignID, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1 AND campaign_id = $2", id, campaignID)
+	if isUnknownCampaign(err) {
+		return repositories.ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return repositories.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// ClaimWebhookEvent inserts the event; the primary key lets only the first insert through
+func (r *PostgresWebhookRepository) ClaimWebhookEvent(ctx context.Context, campaignID, event string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		"INSERT INTO webhook_events (campaign_id, event, announced_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		campaignID, event, time.Now(),
+	)
+	if isUnknownCampaign(err) {
+		return false, repositories.ErrCampaignNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// AddDeadLetter inserts the letter and deletes the oldest ones of its campaign beyond limit in one transaction
+func (r *PostgresWebhookRepository) AddDeadLetter(ctx context.Context, letter entities.DeadLetter, limit int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	campaignID := letter.Payload.CampaignID
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO webhook_dead_letters (campaign_id, letter) VALUES ($1, $2)",
+		campaignID, string(value),
+	); err != nil {
+		if isUnknownCampaign(err) {
+			return repositories.ErrCampaignNotFound
+		}
+		return fmt.Errorf("insert dead letter: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+DELETE FROM webhook_dead_letters
+WHERE campaign_id = $1 AND id NOT IN (
+    SELECT id FROM webhook_dead_letters WHERE campaign_id = $1 ORDER BY id DESC LIMIT $2
+)`, campaignID, limit); err != nil {
+		return fmt.Errorf("trim dead letters: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListDeadLetters reads the letters of the campaign, newest first
+func (r *PostgresWebhookRepository) ListDeadLetters(ctx context.Context, campaignID string) ([]entities.DeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT letter FROM webhook_dead_letters WHERE campaign_id = $1 ORDER BY id DESC", campaignID)
+	if isUnknownCampaign(err) {
+		return []entities.DeadLetter{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []entities.DeadLetter{}
+	for rows.Next() {
+		var value []byte
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		var letter entities.DeadLetter
+		if err := json.Unmarshal(value, &letter); err != nil {
+			return nil, fmt.Errorf("decode dead letter: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
@@ -0,0 +1,197 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+type RedisCampaignRepository struct {
+	client *goredis.Client
+	keys   Keys
+}
+
+// NewRedisCampaignRepository stores campaigns as JSON values under the key prefix
+func NewRedisCampaignRepository(client *goredis.Client, keys Keys) *RedisCampaignRepository {
+	return &RedisCampaignRepository{client: client, keys: keys}
+}
+
+// CreateCampaign stores the campaign; counters start implicitly at zero
+func (r *RedisCampaignRepository) CreateCampaign(ctx context.Context, req entities.CreateCampaignRequest) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign := entities.Campaign{
+		ID:             uuid.New().String(),
+		Name:           req.Name,
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		ImpressionGoal: req.ImpressionGoal,
+		Budget:         req.Budget,
+		CPM:            req.CPM,
+	}
+	value, err := json.Marshal(campaign)
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+
+	campaign.Status = entities.CampaignActive
+
+	created, err := r.client.SetNX(ctx, r.keys.Campaign(campaign.ID), value, 0).Result()
+	if err != nil {
+		return entities.Campaign{}, fmt.Errorf("store campaign: %w", err)
+	}
+	if !created {
+		return entities.Campaign{}, errors.New("store campaign: id already in use")
+	}
+
+	return campaign, nil
+}
+
+// GetCampaign decodes the stored campaign
+func (r *RedisCampaignRepository) GetCampaign(ctx context.Context, id string) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	values, err := r.client.MGet(ctx, r.keys.Campaign(id), r.keys.Completed(id)).Result()
+	if err != nil {
+		return entities.Campaign{}, err
+	}
+	return decodeCampaign(values[0], values[1])
+}
+
+// updateAttempts is how often UpdateCampaign retries when the campaign changes while it is updated
+const updateAttempts = 5
+
+// UpdateCampaign rewrites the stored JSON under WATCH. Completion lives in its own key, so it is kept.
+func (r *RedisCampaignRepository) UpdateCampaign(ctx context.Context, id string, req entities.UpdateCampaignRequest) (entities.Campaign, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Campaign{}, err
+	}
+
+	key := r.keys.Campaign(id)
+	var campaign entities.Campaign
+	update := func(tx *goredis.Tx) error {
+		values, err := tx.MGet(ctx, key, r.keys.Completed(id)).Result()
+		if err != nil {
+			return err
+		}
+		if campaign, err = decodeCampaign(values[0], values[1]); err != nil {
+			return err
+		}
+		req.Apply(&campaign)
+
+		// The status is derived on read, as when the campaign was created
+		stored := campaign
+		stored.Status, stored.CompletedAt = "", nil
+		value, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Set(ctx, key, value, 0)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		err := r.client.Watch(ctx, update, key)
+		if errors.Is(err, goredis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return entities.Campaign{}, err
+		}
+		return campaign, nil
+	}
+	return entities.Campaign{}, errors.New("update campaign: changed concurrently too often")
+}
+
+// listBatch is how many campaigns ListCampaigns reads per MGET
+const listBatch = 100
+
+// ListCampaigns scans the campaign keys, which holds only their IDs in memory, and reads the campaigns
+// in ID order a batch at a time
+func (r *RedisCampaignRepository) ListCampaigns(ctx context.Context, fn func(entities.Campaign) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	prefix := r.keys.Campaign("")
+	var ids []string
+	iter := r.client.Scan(ctx, 0, prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		id := strings.TrimPrefix(iter.Val(), prefix)
+		if !strings.HasSuffix(id, ":completed") {
+			ids = append(ids, id)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	sort.Strings(ids)
+
+	for len(ids) > 0 {
+		batch := ids[:min(listBatch, len(ids))]
+		ids = ids[len(batch):]
+
+		keys := make([]string, 0, 2*len(batch))
+		for _, id := range batch {
+			keys = append(keys, r.keys.Campaign(id), r.keys.Completed(id))
+		}
+		values, err := r.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			campaign, err := decodeCampaign(values[2*i], values[2*i+1])
+			if errors.Is(err, repositories.ErrCampaignNotFound) {
+				// Deleted since the scan
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(campaign); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeCampaign builds a campaign from the MGET values of its JSON and completion keys
+func decodeCampaign(value, completed any) (entities.Campaign, error) {
+	s, ok := value.(string)
+	if !ok {
+		return entities.Campaign{}, repositories.ErrCampaignNotFound
+	}
+
+	var campaign entities.Campaign
+	if err := json.Unmarshal([]byte(s), &campaign); err != nil {
+		return entities.Campaign{}, fmt.Errorf("decode campaign: %w", err)
+	}
+
+	campaign.Status = entities.CampaignActive
+	if at, ok := completed.(string); ok {
+		completedAt, err := time.Parse(time.RFC3339Nano, at)
+		if err != nil {
+			return entities.Campaign{}, fmt.Errorf("decode campaign completion: %w", err)
+		}
+		campaign.Status = entities.CampaignCompleted
+		campaign.CompletedAt = &completedAt
+	}
+	return campaign, nil
+}
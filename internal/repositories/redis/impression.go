@@ -0,0 +1,223 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"net/http"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// countScript counts one impression unless the campaign completed, and completes it when the new
+// total reaches the limit in ARGV[1] (0 for none). It returns the new total, or -1 for a completed
+// campaign. Running as one script keeps replicas from counting past the limit.
+//
+//	KEYS[1] total counter, KEYS[2] completion key; ARGV[2] completion time
+var countScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return -1
+end
+local total = redis.call('INCR', KEYS[1])
+local limit = tonumber(ARGV[1])
+if limit > 0 and total >= limit then
+	redis.call('SET', KEYS[2], ARGV[2])
+end
+return total`)
+
+type RedisImpressionRepository struct {
+	client          *goredis.Client
+	keys            Keys
+	bucketRetention time.Duration
+}
+
+// NewRedisImpressionRepository keeps hourly buckets for bucketRetention before Redis expires them
+func NewRedisImpressionRepository(client *goredis.Client, keys Keys, bucketRetention time.Duration) *RedisImpressionRepository {
+	return &RedisImpressionRepository{client: client, keys: keys, bucketRetention: bucketRetention}
+}
+
+// TrackImpression claims the dedup key with SET NX EX, so every replica sharing the server agrees on
+// who was counted, counts the impression against the campaign limit with countScript, then bumps
+// the other counters and the event time buckets in one MULTI/EXEC
+func (r *RedisImpressionRepository) TrackImpression(ctx context.Context, req entities.TrackImpressionRequest) (error, int) {
+	if err := ctx.Err(); err != nil {
+		return err, http.StatusServiceUnavailable
+	}
+
+	values, err := r.client.MGet(ctx, r.keys.Campaign(req.CampaignID), r.keys.Completed(req.CampaignID)).Result()
+	if err != nil {
+		return err, statusFor(ctx)
+	}
+	campaign, err := decodeCampaign(values[0], values[1])
+	if errors.Is(err, repositories.ErrCampaignNotFound) {
+		return err, http.StatusNotFound
+	}
+	if err != nil {
+		return err, http.StatusInternalServerError
+	}
+	if campaign.Status == entities.CampaignCompleted {
+		return repositories.ErrCampaignCompleted, http.StatusGone
+	}
+
+	if req.InvalidReason != "" {
+		if err := r.client.Incr(ctx, r.keys.Invalid(req.CampaignID)).Err(); err != nil {
+			return err, statusFor(ctx)
+		}
+		return repositories.ErrInvalidTraffic, http.StatusOK
+	}
+
+	now := time.Now()
+	arrival := repositories.ClassifyArrival(req, now)
+	if arrival.TooOld {
+		if err := r.client.Incr(ctx, r.keys.TooOld(req.CampaignID)).Err(); err != nil {
+			return err, statusFor(ctx)
+		}
+		return repositories.ErrImpressionTooOld, http.StatusUnprocessableEntity
+	}
+
+	ttl := time.Duration(config.Current().App.TTL) * time.Second
+	claimed, err := r.client.SetNX(ctx, r.keys.Dedup(req.CampaignID, req.UserID), 1, ttl).Result()
+	if err != nil {
+		return err, statusFor(ctx)
+	}
+	if !claimed {
+		return repositories.ErrDuplicateImpression, http.StatusOK
+	}
+
+	limit := campaign.ImpressionLimit()
+	total, err := countScript.Run(ctx, r.client,
+		[]string{r.keys.Total(req.CampaignID), r.keys.Completed(req.CampaignID)},
+		limit, now.UTC().Format(time.RFC3339Nano),
+	).Int64()
+	if err != nil || total < 0 {
+		// Release the claim so a retry is not mistaken for a duplicate
+		r.client.Del(context.WithoutCancel(ctx), r.keys.Dedup(req.CampaignID, req.UserID))
+		if err != nil {
+			return err, statusFor(ctx)
+		}
+		return repositories.ErrCampaignCompleted, http.StatusGone
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		if arrival.Late {
+			pipe.Incr(ctx, r.keys.Late(req.CampaignID))
+		}
+		// Buckets of the event time, skipped once they would already have expired
+		if age := now.Sub(arrival.At); age < minuteBucketTTL {
+			pipe.Incr(ctx, r.keys.Minute(req.CampaignID, arrival.At))
+			pipe.Expire(ctx, r.keys.Minute(req.CampaignID, arrival.At), minuteBucketTTL-age)
+		}
+		if age := now.Sub(arrival.At); age < r.bucketRetention {
+			pipe.Incr(ctx, r.keys.Hour(req.CampaignID, arrival.At))
+			pipe.Expire(ctx, r.keys.Hour(req.CampaignID, arrival.At), r.bucketRetention-age)
+		}
+		return nil
+	})
+	if err != nil {
+		// Take the impression back and release the claim so a retry is not mistaken for a duplicate
+		_, _ = r.client.TxPipelined(context.WithoutCancel(ctx), func(pipe goredis.Pipeliner) error {
+			pipe.Decr(ctx, r.keys.Total(req.CampaignID))
+			if limit > 0 && total >= limit {
+				pipe.Del(ctx, r.keys.Completed(req.CampaignID))
+			}
+			pipe.Del(ctx, r.keys.Dedup(req.CampaignID, req.UserID))
+			return nil
+		})
+		return err, statusFor(ctx)
+	}
+
+	return nil, http.StatusOK
+}
+
+// ApplyBackfill adds historical counts in one MULTI/EXEC, giving every bucket the expiry it would have
+// had if counted live and skipping those already expired, then completes the campaign if the new
+// total reached its limit. With an import ID, the hash of applied imports is watched, so that the same
+// import applied concurrently is counted once.
+func (r *RedisImpressionRepository) ApplyBackfill(ctx context.Context, b repositories.Backfill) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	values, err := r.client.MGet(ctx, r.keys.Campaign(b.CampaignID), r.keys.Completed(b.CampaignID)).Result()
+	if err != nil {
+		return err
+	}
+	campaign, err := decodeCampaign(values[0], values[1])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var total *goredis.IntCmd
+	add := func(pipe goredis.Pipeliner) error {
+		if b.ImportID != "" {
+			pipe.HSet(ctx, r.keys.Backfills(b.CampaignID), b.ImportID, now.UTC().Format(time.RFC3339Nano))
+		}
+		total = pipe.IncrBy(ctx, r.keys.Total(b.CampaignID), b.Total)
+		for bucket, count := range b.Minutes {
+			at := time.Unix(bucket*60, 0)
+			if age := now.Sub(at); age < minuteBucketTTL {
+				pipe.IncrBy(ctx, r.keys.Minute(b.CampaignID, at), count)
+				pipe.Expire(ctx, r.keys.Minute(b.CampaignID, at), minuteBucketTTL-age)
+			}
+		}
+		for bucket, count := range b.Hours {
+			at := time.Unix(bucket*3600, 0)
+			if age := now.Sub(at); age < r.bucketRetention {
+				pipe.IncrBy(ctx, r.keys.Hour(b.CampaignID, at), count)
+				pipe.Expire(ctx, r.keys.Hour(b.CampaignID, at), r.bucketRetention-age)
+			}
+		}
+		return nil
+	}
+	if err := r.addBackfill(ctx, b, add); err != nil {
+		return err
+	}
+
+	if limit := campaign.ImpressionLimit(); limit > 0 && total.Val() >= limit {
+		return r.client.SetNX(ctx, r.keys.Completed(b.CampaignID), now.UTC().Format(time.RFC3339Nano), 0).Err()
+	}
+	return nil
+}
+
+// addBackfill runs add in MULTI/EXEC, under WATCH of the applied imports when b has an import ID
+func (r *RedisImpressionRepository) addBackfill(ctx context.Context, b repositories.Backfill, add func(goredis.Pipeliner) error) error {
+	if b.ImportID == "" {
+		_, err := r.client.TxPipelined(ctx, add)
+		return err
+	}
+
+	key := r.keys.Backfills(b.CampaignID)
+	apply := func(tx *goredis.Tx) error {
+		applied, err := tx.HExists(ctx, key, b.ImportID).Result()
+		if err != nil {
+			return err
+		}
+		if applied {
+			return repositories.ErrBackfillApplied
+		}
+		_, err = tx.TxPipelined(ctx, add)
+		return err
+	}
+	for attempt := 0; attempt < backfillAttempts; attempt++ {
+		err := r.client.Watch(ctx, apply, key)
+		if !errors.Is(err, goredis.TxFailedErr) {
+			return err
+		}
+	}
+	return errors.New("apply backfill: imports changed concurrently too often")
+}
+
+// backfillAttempts is how often addBackfill retries when the applied imports change while it runs
+const backfillAttempts = 5
+
+// statusFor distinguishes failures caused by the request context from server failures
+func statusFor(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
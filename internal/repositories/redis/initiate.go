@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"learning/internal/repositories"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Key layout, every key starting with the configured prefix:
+//
+//	campaign:{id}                    JSON entities.Campaign
+//	campaign:{id}:completed          when the campaign reached its limit, set by the counting script
+//	dedup:{id}:{user}                set with NX and the dedup TTL as expiry
+//	stats:{id}:total                 lifetime counter
+//	stats:{id}:late                  impressions counted late
+//	stats:{id}:too_old               impressions rejected as too old
+//	stats:{id}:invalid               impressions rejected by the invalid-traffic filters
+//	stats:{id}:m:{unix minute}       per-minute counter of the event time, feeds last_hour
+//	stats:{id}:h:{unix hour}         per-hour counter of the event time, feeds last_day
+//	backfills:{id}                   hash of the imports applied to the campaign to when they were
+//	webhooks                         hash of webhook subscription ID to JSON entities.WebhookSubscription
+//	webhook_events:{id}              hash of the events announced for the campaign to when they were
+//	dead_letters:{id}                list of JSON entities.DeadLetter, newest first
+const minuteBucketTTL = 2 * time.Hour
+
+// Keys builds the key names of one deployment
+type Keys struct {
+	Prefix string
+}
+
+func (k Keys) Campaign(id string) string {
+	return k.Prefix + "campaign:" + id
+}
+
+func (k Keys) Completed(id string) string {
+	return k.Prefix + "campaign:" + id + ":completed"
+}
+
+func (k Keys) Dedup(campaignID, userID string) string {
+	return k.Prefix + "dedup:" + campaignID + ":" + userID
+}
+
+func (k Keys) Total(campaignID string) string {
+	return k.Prefix + "stats:" + campaignID + ":total"
+}
+
+func (k Keys) Late(campaignID string) string {
+	return k.Prefix + "stats:" + campaignID + ":late"
+}
+
+func (k Keys) TooOld(campaignID string) string {
+	return k.Prefix + "stats:" + campaignID + ":too_old"
+}
+
+func (k Keys) Invalid(campaignID string) string {
+	return k.Prefix + "stats:" + campaignID + ":invalid"
+}
+
+func (k Keys) Backfills(campaignID string) string {
+	return k.Prefix + "backfills:" + campaignID
+}
+
+func (k Keys) Webhooks() string {
+	return k.Prefix + "webhooks"
+}
+
+func (k Keys) WebhookEvents(campaignID string) string {
+	return k.Prefix + "webhook_events:" + campaignID
+}
+
+func (k Keys) DeadLetters(campaignID string) string {
+	return k.Prefix + "dead_letters:" + campaignID
+}
+
+func (k Keys) Minute(campaignID string, t time.Time) string {
+	return k.Prefix + "stats:" + campaignID + ":m:" + strconv.FormatInt(repositories.MinuteOf(t), 10)
+}
+
+func (k Keys) Hour(campaignID string, t time.Time) string {
+	return k.Prefix + "stats:" + campaignID + ":h:" + strconv.FormatInt(repositories.HourOf(t), 10)
+}
+
+// Open connects to the Redis server at addr and checks it answers
+func Open(ctx context.Context, addr, password string, db int) (*goredis.Client, error) {
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("ping %s: %w", addr, err)
+	}
+	return client, nil
+}
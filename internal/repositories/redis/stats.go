@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"context"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type RedisStatsRepository struct {
+	client *goredis.Client
+	keys   Keys
+}
+
+func NewRedisStatsRepository(client *goredis.Client, keys Keys) *RedisStatsRepository {
+	return &RedisStatsRepository{client: client, keys: keys}
+}
+
+// counters is the number of values read ahead of the time buckets: the campaign and its lifetime counters
+const counters = 5
+
+// GetCampaignStats reads the campaign and its counters and sums the last 60 minute buckets and the
+// last 24 hour buckets with a single MGET
+func (r *RedisStatsRepository) GetCampaignStats(ctx context.Context, campaignID string) (entities.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return entities.Stats{}, err
+	}
+
+	now := time.Now()
+	keys := make([]string, 0, counters+repositories.LastHourBuckets+repositories.LastDayBuckets)
+	keys = append(keys, r.keys.Campaign(campaignID), r.keys.Total(campaignID), r.keys.Late(campaignID), r.keys.TooOld(campaignID), r.keys.Invalid(campaignID))
+	for i := 0; i < repositories.LastHourBuckets; i++ {
+		keys = append(keys, r.keys.Minute(campaignID, now.Add(-time.Duration(i)*time.Minute)))
+	}
+	for i := 0; i < repositories.LastDayBuckets; i++ {
+		keys = append(keys, r.keys.Hour(campaignID, now.Add(-time.Duration(i)*time.Hour)))
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return entities.Stats{}, err
+	}
+
+	// The completion key does not matter for the stats
+	campaign, err := decodeCampaign(values[0], nil)
+	if err != nil {
+		return entities.Stats{}, err
+	}
+
+	stats := entities.Stats{
+		CampaignID: campaignID,
+		TotalCount: counter(values[1]),
+		Late:       counter(values[2]),
+		TooOld:     counter(values[3]),
+		Invalid:    counter(values[4]),
+	}
+	stats.Spend = campaign.Spend(stats.TotalCount)
+	for _, v := range values[counters : counters+repositories.LastHourBuckets] {
+		stats.LastHour += counter(v)
+	}
+	for _, v := range values[counters+repositories.LastHourBuckets:] {
+		stats.LastDay += counter(v)
+	}
+
+	return stats, nil
+}
+
+// GetCampaignSeries reads the campaign and the minute or hour buckets of the query with a single MGET.
+// Minute buckets expire after two hours, so older minutes read as zero.
+func (r *RedisStatsRepository) GetCampaignSeries(ctx context.Context, q entities.SeriesQuery) ([]entities.SeriesPoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	width, bucketKey := int64(60), r.keys.Minute
+	if q.Resolution == entities.ResolutionHour {
+		width, bucketKey = 3600, r.keys.Hour
+	}
+	first, last := repositories.SeriesBuckets(q)
+	keys := []string{r.keys.Campaign(q.CampaignID)}
+	for b := first; b <= last; b++ {
+		keys = append(keys, bucketKey(q.CampaignID, time.Unix(b*width, 0)))
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := values[0].(string); !ok {
+		return nil, repositories.ErrCampaignNotFound
+	}
+
+	counts := make(map[int64]int64)
+	for i, v := range values[1:] {
+		counts[first+int64(i)] = counter(v)
+	}
+	return repositories.DenseSeries(q, counts), nil
+}
+
+// counter parses an MGET value, treating missing keys as zero
+func counter(v any) int64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"context"
+	"learning/internal/repositories/contract"
+	"learning/internal/repositories/redis"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// startServer runs an in-process RESP server whose clock follows real time, so key expiry
+// behaves like a real Redis during the dedup TTL tests
+func startServer(t *testing.T) *miniredis.Miniredis {
+	server := miniredis.RunT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				server.FastForward(10 * time.Millisecond)
+			}
+		}
+	}()
+
+	return server
+}
+
+func TestRedisRepositoryContract(t *testing.T) {
+	contract.Run(t, func(t *testing.T) contract.Repositories {
+		server := startServer(t)
+		client, err := redis.Open(context.Background(), server.Addr(), "", 0)
+		if err != nil {
+			t.Fatalf("❌ Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+
+		keys := redis.Keys{Prefix: "test:"}
+		return contract.Repositories{
+			Campaigns:   redis.NewRedisCampaignRepository(client, keys),
+			Impressions: redis.NewRedisImpressionRepository(client, keys, 720*time.Hour),
+			Stats:       redis.NewRedisStatsRepository(client, keys),
+			Webhooks:    redis.NewRedisWebhookRepository(client, keys),
+		}
+	})
+}
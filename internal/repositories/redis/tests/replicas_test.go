@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"learning/internal/entities"
+	"learning/internal/repositories/redis"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Two service replicas with their own connections must count each user once
+func TestRedisReplicasShareDedup(t *testing.T) {
+	server := startServer(t)
+	keys := redis.Keys{Prefix: "test:"}
+	ctx := context.Background()
+
+	var replicas []*redis.RedisImpressionRepository
+	for i := 0; i < 2; i++ {
+		client, err := redis.Open(ctx, server.Addr(), "", 0)
+		if err != nil {
+			t.Fatalf("❌ Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+		replicas = append(replicas, redis.NewRedisImpressionRepository(client, keys, 720*time.Hour))
+	}
+
+	client, _ := redis.Open(ctx, server.Addr(), "", 0)
+	defer func() { _ = client.Close() }()
+	campaign, err := redis.NewRedisCampaignRepository(client, keys).CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Replicated", StartTime: time.Now()})
+	if err != nil {
+		t.Fatalf("❌ Failed to create campaign: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, replica := range replicas {
+			wg.Add(1)
+			go func(repo *redis.RedisImpressionRepository, userID string) {
+				defer wg.Done()
+				_, _ = repo.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: userID, AdID: "ad-1"})
+			}(replica, fmt.Sprintf("user-%d", i))
+		}
+	}
+	wg.Wait()
+
+	stats, err := redis.NewRedisStatsRepository(client, keys).GetCampaignStats(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("❌ Failed to read stats: %v", err)
+	}
+	if stats.TotalCount != 20 || stats.LastHour != 20 || stats.LastDay != 20 {
+		t.Errorf("❌ Expected every user counted once across replicas, got %+v", stats)
+	}
+}
@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+type RedisWebhookRepository struct {
+	client *goredis.Client
+	keys   Keys
+}
+
+// NewRedisWebhookRepository stores webhook subscriptions as JSON values under the key prefix
+func NewRedisWebhookRepository(client *goredis.Client, keys Keys) *RedisWebhookRepository {
+	return &RedisWebhookRepository{client: client, keys: keys}
+}
+
+// CreateSubscription stores s once its campaign is known to exist; campaigns are never deleted
+func (r *RedisWebhookRepository) CreateSubscription(ctx context.Context, s entities.WebhookSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	exists, err := r.client.Exists(ctx, r.keys.Campaign(s.CampaignID)).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return repositories.ErrCampaignNotFound
+	}
+	if err := r.client.HSet(ctx, r.keys.Webhooks(), s.ID, value).Err(); err != nil {
+		return fmt.Errorf("store webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// ListSubscriptions decodes every stored subscription and keeps the matching ones
+func (r *RedisWebhookRepository) ListSubscriptions(ctx context.Context, campaignID string) ([]entities.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	values, err := r.client.HVals(ctx, r.keys.Webhooks()).Result()
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := []entities.WebhookSubscription{}
+	for _, value := range values {
+		var s entities.WebhookSubscription
+		if err := json.Unmarshal([]byte(value), &s); err != nil {
+			return nil, fmt.Errorf("decode webhook subscription: %w", err)
+		}
+		if campaignID == "" || s.CampaignID == campaignID {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	repositories.SortSubscriptions(subscriptions)
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes the subscription when it belongs to the campaign. A subscription never
+// moves to another campaign, so the check needs no WATCH.
+func (r *RedisWebhookRepository) DeleteSubscription(ctx context.Context, campaignID, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	value, err := r.client.HGet(ctx, r.keys.Webhooks(), id).Result()
+	if errors.Is(err, goredis.Nil) {
+		return repositories.ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var s entities.WebhookSubscription
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return fmt.Errorf("decode webhook subscription: %w", err)
+	}
+	if s.CampaignID != campaignID {
+		return repositories.ErrSubscriptionNotFound
+	}
+	deleted, err := r.client.HDel(ctx, r.keys.Webhooks(), id).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return repositories.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// ClaimWebhookEvent records the event with HSETNX, which only the first caller gets to set
+func (r *RedisWebhookRepository) ClaimWebhookEvent(ctx context.Context, campaignID, event string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return r.client.HSetNX(ctx, r.keys.WebhookEvents(campaignID), event, strconv.FormatInt(time.Now().UnixNano(), 10)).Result()
+}
+
+// AddDeadLetter pushes the letter and trims the list of its campaign to limit in one transaction
+func (r *RedisWebhookRepository) AddDeadLetter(ctx context.Context, letter entities.DeadLetter, limit int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	key := r.keys.DeadLetters(letter.Payload.CampaignID)
+	_, err = r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.LPush(ctx, key, value)
+		pipe.LTrim(ctx, key, 0, int64(limit)-1)
+		return nil
+	})
+	return err
+}
+
+// ListDeadLetters decodes the list of the campaign, which is kept newest first
+func (r *RedisWebhookRepository) ListDeadLetters(ctx context.Context, campaignID string) ([]entities.DeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	values, err := r.client.LRange(ctx, r.keys.DeadLetters(campaignID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]entities.DeadLetter, 0, len(values))
+	for _, value := range values {
+		var letter entities.DeadLetter
+		if err := json.Unmarshal([]byte(value), &letter); err != nil {
+			return nil, fmt.Errorf("decode dead letter: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
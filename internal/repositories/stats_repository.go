@@ -1,7 +1,14 @@
 package repositories
 
-import "learning/internal/entities"
+import (
+	"context"
+	"learning/internal/entities"
+)
 
 type StatsRepository interface {
-	GetCampaignStats(campaignID string) (entities.Stats, bool)
+	// GetCampaignStats returns ErrCampaignNotFound for unknown campaigns
+	GetCampaignStats(ctx context.Context, campaignID string) (entities.Stats, error)
+	// GetCampaignSeries returns every bucket of the query, zero for empty ones and for those past
+	// their retention, or ErrCampaignNotFound for unknown campaigns
+	GetCampaignSeries(ctx context.Context, q entities.SeriesQuery) ([]entities.SeriesPoint, error)
 }
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"learning/internal/entities"
+	"sort"
+)
+
+// WebhookRepository stores the webhook subscriptions of the campaigns, the events already announced to
+// them and the payloads that could not be delivered, so every instance shares them across restarts
+type WebhookRepository interface {
+	// CreateSubscription stores s, or returns ErrCampaignNotFound for unknown campaigns
+	CreateSubscription(ctx context.Context, s entities.WebhookSubscription) error
+	// ListSubscriptions returns the subscriptions of a campaign, or of every campaign when campaignID is
+	// empty, oldest first and with their secrets
+	ListSubscriptions(ctx context.Context, campaignID string) ([]entities.WebhookSubscription, error)
+	// DeleteSubscription removes a subscription of a campaign, or returns ErrSubscriptionNotFound
+	DeleteSubscription(ctx context.Context, campaignID, id string) error
+	// ClaimWebhookEvent records that event was announced for a campaign and reports whether this call
+	// recorded it, which is true for exactly one caller
+	ClaimWebhookEvent(ctx context.Context, campaignID, event string) (bool, error)
+	// AddDeadLetter stores a payload that could not be delivered, keeping the newest limit of its campaign
+	AddDeadLetter(ctx context.Context, letter entities.DeadLetter, limit int) error
+	// ListDeadLetters returns the dead letters of a campaign, newest first
+	ListDeadLetters(ctx context.Context, campaignID string) ([]entities.DeadLetter, error)
+}
+
+// SortSubscriptions orders subscriptions oldest first, by ID when created at the same time
+func SortSubscriptions(subscriptions []entities.WebhookSubscription) {
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if !subscriptions[i].CreatedAt.Equal(subscriptions[j].CreatedAt) {
+			return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+		}
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+}
@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+// ErrTooManySubscribers is returned by Subscribe once the hub serves its maximum number of streams
+var ErrTooManySubscribers = errors.New("too many stats streams")
+
+// ErrClosed is returned by Subscribe after Close
+var ErrClosed = errors.New("stats stream hub closed")
+
+// Subscription receives the stats of one campaign. Only the latest stats are kept, so a slow reader
+// skips intermediate updates instead of holding up the others.
+type Subscription struct {
+	campaignID string
+	updates    chan entities.Stats
+}
+
+// Updates is closed when the hub closes
+func (s *Subscription) Updates() <-chan entities.Stats {
+	return s.updates
+}
+
+// Hub fans the stats of campaigns out to their stream subscribers. Repository events only mark a
+// campaign as changed; every interval the stats of the changed campaigns are read once and pushed to
+// all of their subscribers.
+type Hub struct {
+	stats          repositories.StatsRepository
+	interval       time.Duration
+	maxSubscribers int
+	logger         *zap.Logger
+
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{} // by campaign ID
+	count       int
+	dirty       map[string]struct{}
+	closed      bool
+}
+
+func NewHub(stats repositories.StatsRepository, interval time.Duration, maxSubscribers int, logger *zap.Logger) *Hub {
+	return &Hub{
+		stats:          stats,
+		interval:       interval,
+		maxSubscribers: maxSubscribers,
+		logger:         logger,
+		subscribers:    make(map[string]map[*Subscription]struct{}),
+		dirty:          make(map[string]struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(campaignID string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if h.count >= h.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+
+	s := &Subscription{campaignID: campaignID, updates: make(chan entities.Stats, 1)}
+	if h.subscribers[campaignID] == nil {
+		h.subscribers[campaignID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[campaignID][s] = struct{}{}
+	h.count++
+	return s, nil
+}
+
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.subscribers[s.campaignID]
+	if _, subscribed := subs[s]; !ok || !subscribed {
+		return
+	}
+	delete(subs, s)
+	h.count--
+	if len(subs) == 0 {
+		delete(h.subscribers, s.campaignID)
+		delete(h.dirty, s.campaignID)
+	}
+}
+
+// HandleEvent marks the campaign of an impression outcome as changed; it never blocks
+func (h *Hub) HandleEvent(event repositories.Event) {
+	if event.Type == repositories.EventCampaignUpdated {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, watched := h.subscribers[event.CampaignID]; watched {
+		h.dirty[event.CampaignID] = struct{}{}
+	}
+}
+
+// Flush pushes the current stats of every campaign changed since the previous flush
+func (h *Hub) Flush(ctx context.Context) {
+	h.mu.Lock()
+	changed := make([]string, 0, len(h.dirty))
+	for id := range h.dirty {
+		changed = append(changed, id)
+	}
+	clear(h.dirty)
+	h.mu.Unlock()
+
+	for _, id := range changed {
+		stats, err := h.stats.GetCampaignStats(ctx, id)
+		if err != nil {
+			h.logger.Warn("failed to read stats for stream subscribers", zap.String("campaign_id", id), zap.Error(err))
+			continue
+		}
+
+		h.mu.Lock()
+		for s := range h.subscribers[id] {
+			// Replace an update the subscriber did not read yet
+			select {
+			case <-s.updates:
+			default:
+			}
+			s.updates <- stats
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Run flushes every interval until ctx is done
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Flush(ctx)
+		}
+	}
+}
+
+// Close ends every subscription and rejects new ones; it may be called more than once
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for s := range subs {
+			close(s.updates)
+		}
+	}
+	clear(h.subscribers)
+	clear(h.dirty)
+	h.count = 0
+}
+
+// Subscribers returns the number of open streams
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Register exports the number of open streams on reg
+func (h *Hub) Register(reg prometheus.Registerer) error {
+	return reg.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "stats_stream_subscribers",
+		Help: "Open live stats streams.",
+	}, func() float64 { return float64(h.Subscribers()) }))
+}
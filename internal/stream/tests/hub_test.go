@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"learning/internal/repositories/memory"
+	"learning/internal/stream"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fixture struct {
+	hub         *stream.Hub
+	impressions repositories.ImpressionRepository
+	backfill    repositories.BackfillRepository
+	campaign    entities.Campaign
+}
+
+func newFixture(t *testing.T, maxSubscribers int) fixture {
+	memServer := memory.NewServer()
+	campaign, _ := memory.NewInMemoryCampaignRepository(memServer).CreateCampaign(context.Background(), entities.CreateCampaignRequest{Name: "Live", StartTime: time.Now()})
+	hub := stream.NewHub(memory.NewInMemoryStatsRepository(memServer), time.Hour, maxSubscribers, zap.NewNop())
+	t.Cleanup(hub.Close)
+
+	emitter := repositories.NewEmitter()
+	emitter.Subscribe(hub.HandleEvent)
+	impressions := memory.NewInMemoryImpressionRepository(memServer)
+	return fixture{
+		hub:         hub,
+		impressions: repositories.NewEmittingImpressionRepository(impressions, emitter),
+		backfill:    repositories.NewEmittingBackfillRepository(impressions, emitter),
+		campaign:    campaign,
+	}
+}
+
+func (f fixture) track(t *testing.T, userID string) {
+	t.Helper()
+	f.impressions.TrackImpression(context.Background(), entities.TrackImpressionRequest{CampaignID: f.campaign.ID, UserID: userID, AdID: "ad"})
+}
+
+func TestHubCoalescesChangesIntoOneUpdate(t *testing.T) {
+	f := newFixture(t, 10)
+	first, _ := f.hub.Subscribe(f.campaign.ID)
+	second, _ := f.hub.Subscribe(f.campaign.ID)
+
+	f.hub.Flush(context.Background())
+	select {
+	case stats := <-first.Updates():
+		t.Fatalf("❌ Expected no update without changes, got %+v", stats)
+	default:
+	}
+
+	f.track(t, "u1")
+	f.track(t, "u2")
+	f.track(t, "u2") // duplicate, changes nothing
+	f.hub.Flush(context.Background())
+
+	for _, s := range []*stream.Subscription{first, second} {
+		select {
+		case stats := <-s.Updates():
+			if stats.CampaignID != f.campaign.ID || stats.TotalCount != 2 {
+				t.Errorf("❌ Expected a total of 2, got %+v", stats)
+			}
+		default:
+			t.Fatal("❌ Expected an update for every subscriber")
+		}
+		select {
+		case stats := <-s.Updates():
+			t.Errorf("❌ Expected the changes to be coalesced, got a second update %+v", stats)
+		default:
+		}
+	}
+}
+
+func TestHubKeepsOnlyLatestUpdateForSlowSubscriber(t *testing.T) {
+	f := newFixture(t, 10)
+	slow, _ := f.hub.Subscribe(f.campaign.ID)
+
+	f.track(t, "u1")
+	f.hub.Flush(context.Background())
+	f.track(t, "u2")
+	f.hub.Flush(context.Background())
+
+	if stats := <-slow.Updates(); stats.TotalCount != 2 {
+		t.Errorf("❌ Expected only the latest stats, got %+v", stats)
+	}
+}
+
+func TestHubPushesImportedTotals(t *testing.T) {
+	f := newFixture(t, 10)
+	s, _ := f.hub.Subscribe(f.campaign.ID)
+
+	hour := time.Now().Add(-time.Hour)
+	err := f.backfill.ApplyBackfill(context.Background(), repositories.Backfill{
+		CampaignID: f.campaign.ID,
+		Total:      5,
+		Minutes:    map[int64]int64{repositories.MinuteOf(hour): 5},
+		Hours:      map[int64]int64{repositories.HourOf(hour): 5},
+	})
+	if err != nil {
+		t.Fatalf("❌ Backfill failed: %v", err)
+	}
+	f.hub.Flush(context.Background())
+
+	select {
+	case stats := <-s.Updates():
+		if stats.TotalCount != 5 {
+			t.Errorf("❌ Expected the imported total of 5, got %+v", stats)
+		}
+	default:
+		t.Fatal("❌ Expected an update after the import")
+	}
+}
+
+func TestHubLimitsSubscribersAndCloses(t *testing.T) {
+	f := newFixture(t, 1)
+	s, err := f.hub.Subscribe(f.campaign.ID)
+	if err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+	if _, err := f.hub.Subscribe(f.campaign.ID); !errors.Is(err, stream.ErrTooManySubscribers) {
+		t.Errorf("❌ Expected ErrTooManySubscribers, got %v", err)
+	}
+
+	f.hub.Unsubscribe(s)
+	f.hub.Unsubscribe(s)
+	if f.hub.Subscribers() != 0 {
+		t.Errorf("❌ Expected no subscribers, got %d", f.hub.Subscribers())
+	}
+
+	s, _ = f.hub.Subscribe(f.campaign.ID)
+	f.hub.Close()
+	if _, open := <-s.Updates(); open {
+		t.Error("❌ Expected Close to end the subscription")
+	}
+	if _, err := f.hub.Subscribe(f.campaign.ID); !errors.Is(err, stream.ErrClosed) {
+		t.Errorf("❌ Expected ErrClosed, got %v", err)
+	}
+	f.hub.Unsubscribe(s)
+}
@@ -0,0 +1,182 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"learning/internal/entities"
+)
+
+// Filter flags impressions that should not be counted. Check returns a short reason for invalid
+// traffic and an empty string for impressions that pass.
+type Filter interface {
+	Check(req entities.TrackImpressionRequest) string
+}
+
+// FilterFunc adapts a function to Filter
+type FilterFunc func(req entities.TrackImpressionRequest) string
+
+func (f FilterFunc) Check(req entities.TrackImpressionRequest) string {
+	return f(req)
+}
+
+// Chain runs its filters in order and reports the first reason found
+type Chain []Filter
+
+func (c Chain) Check(req entities.TrackImpressionRequest) string {
+	for _, filter := range c {
+		if reason := filter.Check(req); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// BotUserAgents flags user agents containing any of patterns, ignoring case
+func BotUserAgents(patterns []string) Filter {
+	lowered := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			lowered = append(lowered, p)
+		}
+	}
+
+	return FilterFunc(func(req entities.TrackImpressionRequest) string {
+		ua := strings.ToLower(req.UserAgent)
+		for _, p := range lowered {
+			if strings.Contains(ua, p) {
+				return "bot user agent"
+			}
+		}
+		return ""
+	})
+}
+
+// IPDenylist flags client IPs matching any of rules, each a single address or a CIDR range
+func IPDenylist(rules []string) (Filter, error) {
+	prefixes := make([]netip.Prefix, 0, len(rules))
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		prefix, err := parseRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denylist entry %q: %w", rule, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return FilterFunc(func(req entities.TrackImpressionRequest) string {
+		addr, err := netip.ParseAddr(req.IP)
+		if err != nil {
+			return ""
+		}
+		addr = addr.Unmap()
+		for _, prefix := range prefixes {
+			if prefix.Contains(addr) {
+				return "denied ip"
+			}
+		}
+		return ""
+	}), nil
+}
+
+func parseRule(rule string) (netip.Prefix, error) {
+	if strings.Contains(rule, "/") {
+		prefix, err := netip.ParsePrefix(rule)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(rule)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// burstWindow counts the impressions of one user in the current window
+type burstWindow struct {
+	start time.Time
+	count int
+}
+
+// BurstDetector flags users sending more than limit impressions, across all campaigns, within one window
+type BurstDetector struct {
+	limit  int
+	window time.Duration
+
+	mu    sync.Mutex
+	users map[string]*burstWindow
+}
+
+func NewBurstDetector(limit int, window time.Duration) *BurstDetector {
+	return &BurstDetector{limit: limit, window: window, users: make(map[string]*burstWindow)}
+}
+
+func (d *BurstDetector) Check(req entities.TrackImpressionRequest) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	w, ok := d.users[req.UserID]
+	if !ok || now.Sub(w.start) >= d.window {
+		w = &burstWindow{start: now}
+		d.users[req.UserID] = w
+	}
+	w.count++
+	if w.count > d.limit {
+		return "user burst"
+	}
+	return ""
+}
+
+// PurgeExpired forgets users whose window has passed and returns how many were removed
+func (d *BurstDetector) PurgeExpired(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	purged := 0
+	for user, w := range d.users {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		if now.Sub(w.start) >= d.window {
+			delete(d.users, user)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// ClientIP returns the address of the client that sent r. Behind trustedProxies proxies, each appending
+// the address it got the request from to X-Forwarded-For, the client is the entry that many places from
+// the right; entries before it were sent by the client and are ignored. With fewer entries the leftmost
+// is used, and with trustedProxies 0 the header is not read at all.
+func ClientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		var entries []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			entries = append(entries, strings.Split(header, ",")...)
+		}
+		if len(entries) > 0 {
+			client := entries[max(len(entries)-trustedProxies, 0)]
+			if addr, err := netip.ParseAddr(strings.TrimSpace(client)); err == nil {
+				return addr.Unmap().String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"context"
+	"learning/internal/entities"
+	"learning/internal/traffic"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBotUserAgents(t *testing.T) {
+	filter := traffic.BotUserAgents([]string{"bot", " Crawler ", ""})
+
+	tests := []struct {
+		userAgent string
+		invalid   bool
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64)", false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1)", true},
+		{"SomeCRAWLER/1.0", true},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		reason := filter.Check(entities.TrackImpressionRequest{UserAgent: test.userAgent})
+		if (reason != "") != test.invalid {
+			t.Errorf("❌ User agent %q: expected invalid=%v, got reason %q", test.userAgent, test.invalid, reason)
+		}
+	}
+}
+
+func TestIPDenylist(t *testing.T) {
+	filter, err := traffic.IPDenylist([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("❌ Failed to build the denylist: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		invalid bool
+	}{
+		{"10.1.2.3", true},
+		{"192.0.2.1", true},
+		{"192.0.2.2", false},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8::1", true},
+		{"not an ip", false},
+	}
+
+	for _, test := range tests {
+		reason := filter.Check(entities.TrackImpressionRequest{IP: test.ip})
+		if (reason != "") != test.invalid {
+			t.Errorf("❌ IP %q: expected invalid=%v, got reason %q", test.ip, test.invalid, reason)
+		}
+	}
+
+	if _, err := traffic.IPDenylist([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("❌ Expected an error for an invalid CIDR range")
+	}
+}
+
+func TestBurstDetector(t *testing.T) {
+	detector := traffic.NewBurstDetector(3, 50*time.Millisecond)
+	req := entities.TrackImpressionRequest{UserID: "user1"}
+
+	for i := 1; i <= 4; i++ {
+		reason := detector.Check(req)
+		if (reason != "") != (i > 3) {
+			t.Errorf("❌ Impression %d: unexpected reason %q", i, reason)
+		}
+	}
+	if reason := detector.Check(entities.TrackImpressionRequest{UserID: "user2"}); reason != "" {
+		t.Errorf("❌ Expected other users to be unaffected, got %q", reason)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if reason := detector.Check(req); reason != "" {
+		t.Errorf("❌ Expected a new window after the old one passed, got %q", reason)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	purged, err := detector.PurgeExpired(context.Background())
+	if err != nil || purged != 2 {
+		t.Errorf("❌ Expected 2 purged users, got %d (%v)", purged, err)
+	}
+}
+
+func TestChainReportsFirstReason(t *testing.T) {
+	chain := traffic.Chain{
+		traffic.FilterFunc(func(entities.TrackImpressionRequest) string { return "" }),
+		traffic.FilterFunc(func(entities.TrackImpressionRequest) string { return "first" }),
+		traffic.FilterFunc(func(entities.TrackImpressionRequest) string { return "second" }),
+	}
+	if reason := chain.Check(entities.TrackImpressionRequest{}); reason != "first" {
+		t.Errorf("❌ Expected reason %q, got %q", "first", reason)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", nil)
+	req.RemoteAddr = "10.0.0.2:4000"
+	// A client claiming to be 192.0.2.1, forwarded by a CDN and then by our load balancer
+	req.Header.Add("X-Forwarded-For", "192.0.2.1, 203.0.113.5")
+	req.Header.Add("X-Forwarded-For", "198.51.100.9")
+
+	tests := []struct {
+		name           string
+		trustedProxies int
+		expected       string
+	}{
+		{"Header Not Trusted", 0, "10.0.0.2"},
+		{"Behind One Proxy", 1, "198.51.100.9"},
+		{"Behind Two Proxies", 2, "203.0.113.5"},
+		{"Fewer Entries Than Proxies", 5, "192.0.2.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ip := traffic.ClientIP(req, test.trustedProxies); ip != test.expected {
+				t.Errorf("❌ Expected %q, got %q", test.expected, ip)
+			}
+		})
+	}
+}
+
+func TestClientIPIgnoresSpoofedForwardedFor(t *testing.T) {
+	// The client sends a denylisted address itself, our proxy appends the one it really came from
+	req := httptest.NewRequest("POST", "/", nil)
+	req.RemoteAddr = "10.0.0.2:4000"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.7")
+
+	denylist, err := traffic.IPDenylist([]string{"198.51.100.0/24"})
+	if err != nil {
+		t.Fatalf("❌ Unexpected error: %v", err)
+	}
+	ip := traffic.ClientIP(req, 1)
+	if ip != "198.51.100.7" {
+		t.Errorf("❌ Expected the address appended by the proxy, got %q", ip)
+	}
+	if reason := denylist.Check(entities.TrackImpressionRequest{IP: ip}); reason != "denied ip" {
+		t.Errorf("❌ Expected the spoofed header not to get past the denylist, got reason %q", reason)
+	}
+}
@@ -9,31 +9,54 @@ type APIResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
+	Errors  any    `json:"errors,omitempty"`
 }
 
 // JSONSuccess Success response
 func JSONSuccess(w http.ResponseWriter, data any, statusCode int) {
-	w.WriteHeader(statusCode)
-	err := json.NewEncoder(w).Encode(APIResponse{
+	writeJSON(w, APIResponse{
 		Success: true,
 		Message: "Request successful",
 		Data:    data,
-	})
-	if err != nil {
-		// TODO
-		return
-	}
+	}, statusCode)
+}
+
+// JSONMessage Success response carrying only a message
+func JSONMessage(w http.ResponseWriter, message string, statusCode int) {
+	writeJSON(w, APIResponse{
+		Success: true,
+		Message: message,
+	}, statusCode)
 }
 
 // JSONError Error response
 func JSONError(w http.ResponseWriter, message string, statusCode int) {
-	w.WriteHeader(statusCode)
-	err := json.NewEncoder(w).Encode(APIResponse{
+	writeJSON(w, APIResponse{
 		Success: false,
 		Message: message,
-	})
+	}, statusCode)
+}
+
+// JSONFieldErrors Error response listing the offending fields
+func JSONFieldErrors(w http.ResponseWriter, message string, fieldErrors any, statusCode int) {
+	writeJSON(w, APIResponse{
+		Success: false,
+		Message: message,
+		Errors:  fieldErrors,
+	}, statusCode)
+}
+
+// JSONResponse writes a response built by the caller
+func JSONResponse(w http.ResponseWriter, response APIResponse, statusCode int) {
+	writeJSON(w, response, statusCode)
+}
+
+func writeJSON(w http.ResponseWriter, response APIResponse, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(response)
 	if err != nil {
-		//TODO
+		// Headers are already sent, nothing useful can be written back
 		return
 	}
 }
@@ -1,26 +1,65 @@
 package validators
 
 import (
-	"encoding/json"
-	"errors"
 	"learning/internal/entities"
 	"net/http"
 )
 
-func ValidateCreateCampaign(r *http.Request) (*entities.CreateCampaignRequest, error) {
+func ValidateCreateCampaign(w http.ResponseWriter, r *http.Request) (*entities.CreateCampaignRequest, error) {
 	var req entities.CreateCampaignRequest
 
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-
-	if err := decoder.Decode(&req); err != nil {
-		return nil, errors.New("invalid JSON payload")
+	if err := decodeJSON(w, r, &req); err != nil {
+		return nil, err
 	}
 
 	// Use shared validate instance
-	if err := validate.Struct(req); err != nil {
+	if err := validateStruct(req); err != nil {
 		return nil, err
 	}
 
+	// A budget below the price of one impression would leave the campaign unbounded
+	if req.Budget > 0 && (entities.Campaign{Budget: req.Budget, CPM: req.CPM}).ImpressionLimit() == 0 {
+		return nil, &ValidationError{
+			Message: "validation failed",
+			Fields:  []FieldError{{Field: "budget", Message: "must buy at least one impression at the cpm"}},
+		}
+	}
+
 	return &req, nil
 }
+
+// ValidateUpdateCampaign checks the campaign ID in the path and the fields to change, of which there
+// must be at least one
+func ValidateUpdateCampaign(w http.ResponseWriter, r *http.Request, rawID string) (string, *entities.UpdateCampaignRequest, error) {
+	campaignID, err := ValidateCampaignID(rawID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var req entities.UpdateCampaignRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return "", nil, err
+	}
+	if err := validateStruct(req); err != nil {
+		return "", nil, err
+	}
+	if req.Name == nil && req.EndTime == nil {
+		return "", nil, &ValidationError{
+			Message: "nothing to update",
+			Fields:  []FieldError{{Field: "name", Message: "or end_time is required"}},
+		}
+	}
+
+	return campaignID, &req, nil
+}
+
+// ValidateEndTime checks the end_time of an update still follows the start_time of the campaign
+func ValidateEndTime(campaign entities.Campaign, req *entities.UpdateCampaignRequest) error {
+	if req.EndTime != nil && !req.EndTime.After(campaign.StartTime) {
+		return &ValidationError{
+			Message: "validation failed",
+			Fields:  []FieldError{{Field: "end_time", Message: "must be after start_time"}},
+		}
+	}
+	return nil
+}
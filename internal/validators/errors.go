@@ -0,0 +1,33 @@
+package validators
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrBodyTooLarge is returned when the request body exceeds MaxBodyBytes
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// FieldError describes a single invalid field in a request payload
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError groups every field error found while validating a request
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return e.Message + ": " + strings.Join(parts, "; ")
+}
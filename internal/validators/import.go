@@ -0,0 +1,63 @@
+package validators
+
+import (
+	"errors"
+	"learning/cmd/config"
+	"learning/internal/entities"
+	"learning/internal/export"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// ValidateImportFormat picks the format of an uploaded import file from the format query parameter,
+// or else from the Content-Type of the body
+func ValidateImportFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+		case "text/csv":
+			format = export.FormatCSV
+		case "application/x-ndjson", "application/ndjson":
+			format = export.FormatNDJSON
+		}
+	}
+	if format != export.FormatCSV && format != export.FormatNDJSON {
+		return "", &ValidationError{
+			Message: "unsupported import format",
+			Fields:  []FieldError{{Field: "format", Message: "must be csv or ndjson, or be given as a text/csv or application/x-ndjson Content-Type"}},
+		}
+	}
+	return format, nil
+}
+
+// ValidateHistoricalImpression checks one impression of an import file. Unlike a live impression it
+// needs a timestamp, which may be as old as it likes but not ahead of the allowed clock skew.
+func ValidateHistoricalImpression(impression entities.Impression, now time.Time) error {
+	var fields []FieldError
+	err := validateStruct(entities.TrackImpressionRequest{
+		CampaignID: impression.CampaignID,
+		UserID:     impression.UserID,
+		AdID:       impression.AdID,
+	})
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		fields = validationErr.Fields
+	case err != nil:
+		return err
+	}
+
+	skew := config.Current().Ingestion.MaxClockSkew
+	switch {
+	case impression.Timestamp.IsZero():
+		fields = append(fields, FieldError{Field: "timestamp", Message: "is required"})
+	case impression.Timestamp.Sub(now) > skew:
+		fields = append(fields, FieldError{Field: "timestamp", Message: "must not be more than " + skew.String() + " in the future"})
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Message: "validation failed", Fields: fields}
+	}
+	return nil
+}
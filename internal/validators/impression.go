@@ -1,38 +1,37 @@
 package validators
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
 	"net/http"
+	"time"
 
-	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"learning/cmd/config"
 	"learning/internal/entities"
+	"learning/internal/logger"
 )
 
 // ValidateTrackImpression extracts and validates the impression request
-func ValidateTrackImpression(r *http.Request) (*entities.TrackImpressionRequest, error) {
+func ValidateTrackImpression(w http.ResponseWriter, r *http.Request) (*entities.TrackImpressionRequest, error) {
 	var req entities.TrackImpressionRequest
 
-	// Ensure request body is not empty
-	if r.Body == nil {
-		return nil, errors.New("empty request body")
+	// Decode size-limited JSON and disallow unknown fields
+	if err := decodeJSON(w, r, &req); err != nil {
+		logger.FromContext(r.Context()).Debug("invalid impression payload", zap.Error(err))
+		return nil, err
 	}
 
-	// Decode JSON and disallow unknown fields
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-
-	if err := decoder.Decode(&req); err != nil {
-		fmt.Println("❌ Invalid JSON format:", err)
-		return nil, errors.New("invalid JSON format")
+	// Validate request struct
+	if err := validateStruct(req); err != nil {
+		logger.FromContext(r.Context()).Debug("impression validation failed", zap.Error(err))
+		return nil, err
 	}
 
-	// Validate request struct
-	validate := validator.New()
-	if err := validate.Struct(req); err != nil {
-		fmt.Println("❌ Validation failed:", err)
-		return nil, fmt.Errorf("validation failed: %v", err)
+	// Clients may run slightly ahead, but not beyond the configured clock skew
+	if skew := config.Current().Ingestion.MaxClockSkew; req.Timestamp != nil && time.Until(*req.Timestamp) > skew {
+		return nil, &ValidationError{
+			Message: "validation failed",
+			Fields:  []FieldError{{Field: "timestamp", Message: "must not be more than " + skew.String() + " in the future"}},
+		}
 	}
 
 	return &req, nil
@@ -0,0 +1,68 @@
+package validators
+
+import (
+	"fmt"
+	"learning/internal/entities"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultPageSize and MaxPageSize bound the limit of paginated listings
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// ValidateImpressionQuery checks the campaign ID and the filters of a raw impression listing
+func ValidateImpressionQuery(rawID string, values url.Values) (*entities.ImpressionQuery, error) {
+	campaignID, err := ValidateCampaignID(rawID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &entities.ImpressionQuery{
+		CampaignID: campaignID,
+		UserID:     values.Get("user_id"),
+		AdID:       values.Get("ad_id"),
+		Cursor:     values.Get("cursor"),
+		Limit:      DefaultPageSize,
+	}
+
+	var fields []FieldError
+	parseTime := func(name string) time.Time {
+		raw := values.Get(name)
+		if raw == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			fields = append(fields, FieldError{Field: name, Message: "must be an RFC 3339 timestamp"})
+		}
+		return t
+	}
+	query.From = parseTime("from")
+	query.To = parseTime("to")
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		fields = append(fields, FieldError{Field: "to", Message: "must be after from"})
+	}
+
+	for _, name := range []string{"user_id", "ad_id"} {
+		if value := values.Get(name); value != "" && validate.Var(value, "max=128,identifier") != nil {
+			fields = append(fields, FieldError{Field: name, Message: "must be a valid identifier of at most 128 characters"})
+		}
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxPageSize {
+			fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be a number between 1 and %d", MaxPageSize)})
+		}
+		query.Limit = limit
+	}
+
+	if len(fields) > 0 {
+		return nil, &ValidationError{Message: "invalid query parameters", Fields: fields}
+	}
+	return query, nil
+}
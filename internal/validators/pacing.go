@@ -0,0 +1,26 @@
+package validators
+
+import (
+	"learning/internal/entities"
+	"net/url"
+)
+
+// ValidatePacingQuery checks the campaign ID and the curve of a pacing report, which defaults to even
+func ValidatePacingQuery(rawID string, values url.Values) (*entities.PacingQuery, error) {
+	campaignID, err := ValidateCampaignID(rawID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &entities.PacingQuery{CampaignID: campaignID, Curve: entities.PacingEven}
+	if curve := values.Get("curve"); curve != "" {
+		if curve != entities.PacingEven && curve != entities.PacingFrontLoaded {
+			return nil, &ValidationError{
+				Message: "invalid query parameters",
+				Fields:  []FieldError{{Field: "curve", Message: "must be one of " + entities.PacingEven + ", " + entities.PacingFrontLoaded}},
+			}
+		}
+		query.Curve = curve
+	}
+	return query, nil
+}
@@ -0,0 +1,63 @@
+package validators
+
+import (
+	"fmt"
+	"learning/internal/entities"
+	"net/url"
+	"time"
+)
+
+// MaxSeriesPoints bounds the buckets of one time series, a day of minutes
+const MaxSeriesPoints = 1440
+
+// ValidateSeriesQuery checks the campaign ID, resolution and range of a time series. The resolution
+// defaults to hour, to to now and from to a day of hours or an hour of minutes before to.
+func ValidateSeriesQuery(rawID string, values url.Values, now time.Time) (*entities.SeriesQuery, error) {
+	campaignID, err := ValidateCampaignID(rawID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &entities.SeriesQuery{CampaignID: campaignID, Resolution: entities.ResolutionHour, To: now}
+	var fields []FieldError
+	width := time.Hour
+	switch resolution := values.Get("resolution"); resolution {
+	case "", entities.ResolutionHour:
+	case entities.ResolutionMinute:
+		query.Resolution, width = entities.ResolutionMinute, time.Minute
+	default:
+		fields = append(fields, FieldError{Field: "resolution", Message: "must be one of " + entities.ResolutionMinute + ", " + entities.ResolutionHour})
+	}
+
+	parseTime := func(name string, fallback time.Time) time.Time {
+		raw := values.Get(name)
+		if raw == "" {
+			return fallback
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			fields = append(fields, FieldError{Field: name, Message: "must be an RFC 3339 timestamp"})
+		}
+		return t
+	}
+	query.To = parseTime("to", now)
+	defaultSpan := 24 * time.Hour
+	if query.Resolution == entities.ResolutionMinute {
+		defaultSpan = time.Hour
+	}
+	query.From = parseTime("from", query.To.Add(-defaultSpan))
+
+	if len(fields) == 0 {
+		switch {
+		case !query.From.Before(query.To):
+			fields = append(fields, FieldError{Field: "to", Message: "must be after from"})
+		case query.To.Sub(query.From) > MaxSeriesPoints*width:
+			fields = append(fields, FieldError{Field: "from", Message: fmt.Sprintf("must be at most %d %ss before to", MaxSeriesPoints, query.Resolution)})
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, &ValidationError{Message: "invalid query parameters", Fields: fields}
+	}
+	return query, nil
+}
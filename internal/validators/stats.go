@@ -1,25 +1,24 @@
 package validators
 
 import (
-	"errors"
-	"net/http"
 	"net/url"
-	"regexp"
 )
 
-// Regular expression for valid campaign IDs
-var validIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9\-]+$`).MatchString
-
-func ValidateCampaignID(r *http.Request) (string, error) {
-	campaignID := r.URL.Path[len("/api/v1/campaigns/"):]
-
-	decodedID, err := url.QueryUnescape(campaignID)
+// ValidateCampaignID unescapes a campaign ID taken from the URL path and checks it is a UUID
+func ValidateCampaignID(rawID string) (string, error) {
+	decodedID, err := url.PathUnescape(rawID)
 	if err != nil {
-		return "", errors.New("invalid campaign ID format")
+		return "", &ValidationError{
+			Message: "invalid campaign ID",
+			Fields:  []FieldError{{Field: "campaign_id", Message: "is not a valid path segment"}},
+		}
 	}
 
-	if decodedID == "" || len(decodedID) < 8 || !validIDRegexp(decodedID) {
-		return "", errors.New("invalid campaign ID")
+	if err := validate.Var(decodedID, "required,uuid"); err != nil {
+		return "", &ValidationError{
+			Message: "invalid campaign ID",
+			Fields:  []FieldError{{Field: "campaign_id", Message: "must be a valid UUID"}},
+		}
 	}
 
 	return decodedID, nil
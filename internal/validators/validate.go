@@ -1,6 +1,165 @@
 package validators
 
-import "github.com/go-playground/validator/v10"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxBodyBytes is the largest request body accepted by the validators
+const MaxBodyBytes int64 = 64 << 10
+
+// identifierRegexp restricts user and ad identifiers to URL-safe characters
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z0-9._:\-]+$`)
 
 // Shared validator instance
-var validate = validator.New()
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON name so clients can match them to the payload
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	_ = v.RegisterValidation("identifier", func(fl validator.FieldLevel) bool {
+		return identifierRegexp.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("printable", func(fl validator.FieldLevel) bool {
+		for _, r := range fl.Field().String() {
+			if !unicode.IsPrint(r) {
+				return false
+			}
+		}
+		return true
+	})
+
+	return v
+}
+
+// decodeJSON reads a size-limited JSON body into dst, rejecting unknown fields
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return &ValidationError{Message: "empty request body"}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil {
+		// Trailing data after the first JSON value is not allowed
+		if _, extra := decoder.Token(); extra != io.EOF {
+			return &ValidationError{Message: "invalid JSON payload"}
+		}
+		return nil
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return ErrBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return &ValidationError{Message: "empty request body"}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return &ValidationError{
+			Message: "invalid JSON payload",
+			Fields:  []FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &ValidationError{
+			Message: "invalid JSON payload",
+			Fields:  []FieldError{{Field: field, Message: "unknown field"}},
+		}
+	default:
+		return &ValidationError{Message: "invalid JSON payload"}
+	}
+}
+
+// validateStruct runs the struct tags and converts failures into a ValidationError
+func validateStruct(s any) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, FieldError{Field: fe.Field(), Message: fieldMessage(fe)})
+	}
+	return &ValidationError{Message: "validation failed", Fields: fields}
+}
+
+// fieldMessage turns a validator tag failure into a human readable message
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at least %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters long", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "uuid":
+		return "must be a valid UUID"
+	case "identifier":
+		return "may only contain letters, digits, '.', '_', ':' and '-'"
+	case "printable":
+		return "must not contain control characters"
+	case "http_url":
+		return "must be an http or https URL"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "unique":
+		return "must not repeat values"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "required_with":
+		return "is required with " + snakeCase(fe.Param())
+	case "gtfield":
+		return "must be after " + snakeCase(fe.Param())
+	default:
+		return fmt.Sprintf("failed the '%s' check", fe.Tag())
+	}
+}
+
+// snakeCase converts a Go field name such as StartTime to its JSON name start_time
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
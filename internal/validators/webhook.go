@@ -0,0 +1,20 @@
+package validators
+
+import (
+	"learning/internal/entities"
+	"net/http"
+)
+
+func ValidateCreateWebhook(w http.ResponseWriter, r *http.Request) (*entities.CreateWebhookRequest, error) {
+	var req entities.CreateWebhookRequest
+
+	if err := decodeJSON(w, r, &req); err != nil {
+		return nil, err
+	}
+
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
@@ -0,0 +1,464 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"learning/internal/entities"
+	"learning/internal/repositories"
+)
+
+// Headers set on every delivery
+const (
+	EventHeader     = "X-Webhook-Event"
+	IDHeader        = "X-Webhook-ID"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// milestones are the impression totals announced to subscribers, in ascending order
+var milestones = []struct {
+	total int64
+	event string
+}{
+	{1_000, entities.WebhookImpressions1K},
+	{10_000, entities.WebhookImpressions10K},
+	{1_000_000, entities.WebhookImpressions1M},
+}
+
+// deliveryBacklog is how many payloads wait for a free worker before producers block
+const deliveryBacklog = 1024
+
+// Options controls when campaigns are checked and how deliveries are retried
+type Options struct {
+	// CheckInterval is how often flight boundaries and the stored totals of subscribed campaigns are checked
+	CheckInterval time.Duration
+	// Workers is the number of concurrent deliveries
+	Workers int
+	// Timeout bounds one delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is how often a payload is tried before it goes to the dead-letter list
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failure; it doubles up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DeadLetterLimit caps the dead-letter list of each campaign; the oldest entries are dropped first
+	DeadLetterLimit int
+	// AllowPrivateTargets lets subscriptions point at loopback, link-local and private addresses,
+	// for local development only
+	AllowPrivateTargets bool
+}
+
+type delivery struct {
+	subscription entities.WebhookSubscription
+	payload      entities.WebhookPayload
+	body         []byte
+	attempt      int
+}
+
+// Dispatcher delivers the events of the webhook subscriptions of every campaign. Subscriptions, the
+// events already announced and dead letters are kept in the webhook repository, so every instance
+// shares them and they survive restarts. Each check re-reads the campaigns with subscriptions and their
+// stored totals, so milestones count impressions of every instance and imports alike, and each event is
+// claimed in the repository before it is delivered, so only one instance announces it. Deliveries
+// waiting for a retry are kept in memory.
+type Dispatcher struct {
+	opts      Options
+	campaigns repositories.CampaignRepository
+	stats     repositories.StatsRepository
+	webhooks  repositories.WebhookRepository
+	client    *http.Client
+	logger    *zap.Logger
+
+	mu        sync.Mutex
+	announced map[string]bool // campaign ID and event known to be claimed, which is final
+
+	deliveries chan delivery
+	stop       chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup // workers and pending retries
+}
+
+// NewDispatcher starts the delivery workers; Close stops them
+func NewDispatcher(opts Options, campaigns repositories.CampaignRepository, stats repositories.StatsRepository, webhooks repositories.WebhookRepository, logger *zap.Logger) *Dispatcher {
+	d := &Dispatcher{
+		opts:       opts,
+		campaigns:  campaigns,
+		stats:      stats,
+		webhooks:   webhooks,
+		client:     NewClient(opts.Timeout, opts.AllowPrivateTargets),
+		logger:     logger,
+		announced:  make(map[string]bool),
+		deliveries: make(chan delivery, deliveryBacklog),
+		stop:       make(chan struct{}),
+	}
+	for i := 0; i < max(opts.Workers, 1); i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Subscribe registers a subscription to an existing campaign and returns it with its signing secret.
+// Milestones already passed and flight boundaries already crossed are not announced. A URL that does
+// not resolve to public addresses is refused with ErrTargetNotAllowed.
+func (d *Dispatcher) Subscribe(ctx context.Context, campaignID string, req entities.CreateWebhookRequest) (entities.WebhookSubscription, error) {
+	campaign, err := d.campaigns.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return entities.WebhookSubscription{}, err
+	}
+	if !d.opts.AllowPrivateTargets {
+		if err := CheckTarget(ctx, req.URL); err != nil {
+			return entities.WebhookSubscription{}, err
+		}
+	}
+	stats, err := d.stats.GetCampaignStats(ctx, campaignID)
+	if err != nil {
+		return entities.WebhookSubscription{}, err
+	}
+
+	// What already happened is claimed before the subscription is stored, so no check announces it
+	for _, payload := range d.due(campaign, stats.TotalCount, time.Now()) {
+		if _, err := d.claim(ctx, campaignID, payload.Event); err != nil {
+			return entities.WebhookSubscription{}, err
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return entities.WebhookSubscription{}, err
+	}
+	subscription := entities.WebhookSubscription{
+		ID:         uuid.New().String(),
+		CampaignID: campaignID,
+		URL:        req.URL,
+		Events:     req.Events,
+		Secret:     hex.EncodeToString(secret),
+		// Every backend keeps microseconds
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := d.webhooks.CreateSubscription(ctx, subscription); err != nil {
+		return entities.WebhookSubscription{}, err
+	}
+	return subscription, nil
+}
+
+// Subscriptions lists the subscriptions of a campaign, oldest first and without their secrets
+func (d *Dispatcher) Subscriptions(ctx context.Context, campaignID string) ([]entities.WebhookSubscription, error) {
+	subscriptions, err := d.webhooks.ListSubscriptions(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// Unsubscribe removes a subscription of a campaign, or returns repositories.ErrSubscriptionNotFound.
+// Deliveries already queued are still attempted.
+func (d *Dispatcher) Unsubscribe(ctx context.Context, campaignID, id string) error {
+	return d.webhooks.DeleteSubscription(ctx, campaignID, id)
+}
+
+// DeadLetters lists the payloads of a campaign that could not be delivered, newest first
+func (d *Dispatcher) DeadLetters(ctx context.Context, campaignID string) ([]entities.DeadLetter, error) {
+	return d.webhooks.ListDeadLetters(ctx, campaignID)
+}
+
+// Check announces the flight boundaries crossed by now and the milestones and impression limits the
+// stored totals reached, for every campaign with subscriptions and events left to announce
+func (d *Dispatcher) Check(ctx context.Context, now time.Time) {
+	subscriptions, err := d.webhooks.ListSubscriptions(ctx, "")
+	if err != nil {
+		d.logger.Warn("failed to list webhook subscriptions", zap.Error(err))
+		return
+	}
+	subscribers := make(map[string][]entities.WebhookSubscription)
+	for _, subscription := range subscriptions {
+		subscribers[subscription.CampaignID] = append(subscribers[subscription.CampaignID], subscription)
+	}
+
+	for id, subscriptions := range subscribers {
+		if ctx.Err() != nil {
+			return
+		}
+		if d.settled(id) {
+			continue
+		}
+
+		campaign, err := d.campaigns.GetCampaign(ctx, id)
+		if err != nil {
+			d.logger.Warn("failed to read campaign for webhooks", zap.String("campaign_id", id), zap.Error(err))
+			continue
+		}
+		stats, err := d.stats.GetCampaignStats(ctx, id)
+		if err != nil {
+			d.logger.Warn("failed to read campaign total for webhook milestones", zap.String("campaign_id", id), zap.Error(err))
+			continue
+		}
+
+		for _, payload := range d.due(campaign, stats.TotalCount, now) {
+			claimed, err := d.claim(ctx, id, payload.Event)
+			if err != nil {
+				d.logger.Warn("failed to claim webhook event", zap.String("campaign_id", id), zap.String("event", payload.Event), zap.Error(err))
+				continue
+			}
+			if claimed {
+				d.publish(payload, subscriptions)
+			}
+		}
+	}
+}
+
+// Run checks the campaigns every interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Check(ctx, now)
+		}
+	}
+}
+
+// Close stops the workers and pending retries, waiting for deliveries in flight until ctx is done.
+// Payloads still waiting for a retry are dropped.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// due returns the events of campaign that happened by now and are not known to be claimed, given its
+// stored total. The flight end takes precedence over reaching the impression limit, which ends the
+// campaign ahead of it.
+func (d *Dispatcher) due(campaign entities.Campaign, total int64, now time.Time) []entities.WebhookPayload {
+	var payloads []entities.WebhookPayload
+	if !now.Before(campaign.StartTime) {
+		payloads = append(payloads, d.payload(entities.WebhookCampaignStarted, campaign.ID, campaign.StartTime, 0))
+	}
+	limit := campaign.ImpressionLimit()
+	switch {
+	case campaign.EndTime != nil && !now.Before(*campaign.EndTime):
+		payloads = append(payloads, d.payload(entities.WebhookCampaignEnded, campaign.ID, *campaign.EndTime, 0))
+	case campaign.Status == entities.CampaignCompleted || (limit > 0 && total >= limit):
+		payloads = append(payloads, d.payload(entities.WebhookCampaignEnded, campaign.ID, now, total))
+	}
+	for _, milestone := range milestones {
+		if total >= milestone.total {
+			payloads = append(payloads, d.payload(milestone.event, campaign.ID, now, total))
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending := payloads[:0]
+	for _, payload := range payloads {
+		if !d.announced[campaign.ID+"/"+payload.Event] {
+			pending = append(pending, payload)
+		}
+	}
+	return pending
+}
+
+// settled reports whether every event of a campaign is known to be claimed, so it needs no more reads
+func (d *Dispatcher) settled(campaignID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.announced[campaignID+"/"+entities.WebhookCampaignStarted] || !d.announced[campaignID+"/"+entities.WebhookCampaignEnded] {
+		return false
+	}
+	for _, milestone := range milestones {
+		if !d.announced[campaignID+"/"+milestone.event] {
+			return false
+		}
+	}
+	return true
+}
+
+// claim records event as announced for the campaign and reports whether this instance gets to announce it
+func (d *Dispatcher) claim(ctx context.Context, campaignID, event string) (bool, error) {
+	claimed, err := d.webhooks.ClaimWebhookEvent(ctx, campaignID, event)
+	if err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	d.announced[campaignID+"/"+event] = true
+	d.mu.Unlock()
+	return claimed, nil
+}
+
+func (d *Dispatcher) payload(event, campaignID string, at time.Time, impressions int64) entities.WebhookPayload {
+	return entities.WebhookPayload{
+		ID:          uuid.New().String(),
+		Event:       event,
+		CampaignID:  campaignID,
+		OccurredAt:  at.UTC(),
+		Impressions: impressions,
+	}
+}
+
+// publish queues payload for every one of subscriptions that asked for its event
+func (d *Dispatcher) publish(payload entities.WebhookPayload, subscriptions []entities.WebhookSubscription) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		for _, wanted := range subscription.Events {
+			if wanted == payload.Event {
+				d.enqueue(delivery{subscription: subscription, payload: payload, body: body, attempt: 1})
+				break
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) enqueue(dl delivery) {
+	select {
+	case d.deliveries <- dl:
+	case <-d.stop:
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case dl := <-d.deliveries:
+			d.attempt(dl)
+		}
+	}
+}
+
+// attempt delivers once, then schedules a retry or moves the payload to the dead-letter list
+func (d *Dispatcher) attempt(dl delivery) {
+	err := d.post(dl)
+	if err == nil {
+		return
+	}
+
+	if dl.attempt >= d.opts.MaxAttempts {
+		d.logger.Warn("webhook delivery failed for good",
+			zap.String("subscription_id", dl.subscription.ID),
+			zap.String("event", dl.payload.Event),
+			zap.Int("attempts", dl.attempt),
+			zap.Error(err),
+		)
+		d.deadLetter(dl, err)
+		return
+	}
+
+	wait := d.backoff(dl.attempt)
+	dl.attempt++
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			d.enqueue(dl)
+		case <-d.stop:
+		}
+	}()
+}
+
+// backoff is the wait after the given failed attempt: InitialBackoff, doubled per attempt, at most MaxBackoff
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.InitialBackoff
+	for i := 1; i < attempt && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+func (d *Dispatcher) post(dl delivery) error {
+	req, err := http.NewRequest(http.MethodPost, dl.subscription.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, dl.payload.Event)
+	req.Header.Set(IDHeader, dl.payload.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(dl.subscription.Secret, timestamp, dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Dispatcher) deadLetter(dl delivery, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+
+	letter := entities.DeadLetter{
+		SubscriptionID: dl.subscription.ID,
+		URL:            dl.subscription.URL,
+		Payload:        dl.payload,
+		Attempts:       dl.attempt,
+		LastError:      err.Error(),
+		FailedAt:       time.Now().UTC(),
+	}
+	if err := d.webhooks.AddDeadLetter(ctx, letter, d.opts.DeadLetterLimit); err != nil {
+		d.logger.Error("failed to store webhook dead letter",
+			zap.String("subscription_id", dl.subscription.ID),
+			zap.String("event", dl.payload.Event),
+			zap.Error(err),
+		)
+	}
+}
+
+// Sign returns the signature header value of body sent at timestamp: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret, prefixed with "sha256="
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid signature of body sent at timestamp
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
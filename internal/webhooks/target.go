@@ -0,0 +1,96 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrTargetNotAllowed is returned when a webhook URL points at an address the server must not call
+var ErrTargetNotAllowed = errors.New("webhook target not allowed")
+
+// reserved are ranges that are neither private nor loopback by the net package but still not reachable
+// on the public internet
+var reserved = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4", "64:ff9b::/96"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// isPublic reports whether ip is a unicast address of the public internet
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsMulticast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range reserved {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckTarget resolves the host of rawURL and refuses it unless every address is public, so a
+// subscription cannot point the server at itself, the cloud metadata service or the internal network
+func CheckTarget(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTargetNotAllowed, err)
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublic(ip) {
+			return fmt.Errorf("%w: %s is not a public address", ErrTargetNotAllowed, ip)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %s cannot be resolved", ErrTargetNotAllowed, host)
+	}
+	for _, addr := range addrs {
+		if !isPublic(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s, which is not a public address", ErrTargetNotAllowed, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// publicOnly refuses connections to non-public addresses. It runs after the name was resolved, on the
+// address actually dialled, so a name re-pointed after CheckTarget or a redirect cannot get around it.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrTargetNotAllowed, host)
+	}
+	return nil
+}
+
+// NewClient returns the HTTP client of the deliveries; unless allowPrivate is set it only connects to
+// public addresses and ignores proxies, which would otherwise be dialled in place of the target
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	if allowPrivate {
+		return &http.Client{Timeout: timeout}
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
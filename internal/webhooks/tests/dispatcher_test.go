@@ -0,0 +1,460 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learning/internal/entities"
+	"learning/internal/repositories"
+	"learning/internal/repositories/bolt"
+	"learning/internal/repositories/memory"
+	"learning/internal/webhooks"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// receiver records the payloads POSTed to it and answers with the status returned by respond
+type receiver struct {
+	mu       sync.Mutex
+	payloads []entities.WebhookPayload
+	attempts int
+	secret   string
+	t        *testing.T
+}
+
+func newReceiver(t *testing.T, respond func(attempt int) int) (*receiver, *httptest.Server) {
+	rec := &receiver{t: t}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.attempts++
+		if rec.secret != "" && !webhooks.Verify(rec.secret, r.Header.Get(webhooks.TimestampHeader), body, r.Header.Get(webhooks.SignatureHeader)) {
+			t.Errorf("❌ Delivery signature %q does not verify", r.Header.Get(webhooks.SignatureHeader))
+		}
+
+		status := respond(rec.attempts)
+		if status < 300 {
+			var payload entities.WebhookPayload
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("❌ Delivery is not a webhook payload: %v", err)
+			}
+			if r.Header.Get(webhooks.EventHeader) != payload.Event || r.Header.Get(webhooks.IDHeader) != payload.ID {
+				t.Errorf("❌ Delivery headers %v do not match the payload %+v", r.Header, payload)
+			}
+			rec.payloads = append(rec.payloads, payload)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return rec, server
+}
+
+func (rec *receiver) events() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var events []string
+	for _, payload := range rec.payloads {
+		events = append(events, payload.Event)
+	}
+	return events
+}
+
+func alwaysOK(int) int { return http.StatusOK }
+
+// fixture is a dispatcher over its own storage
+type fixture struct {
+	dispatcher  *webhooks.Dispatcher
+	campaigns   *memory.InMemoryCampaignRepository
+	impressions *memory.InMemoryImpressionRepository
+	newReplica  func() *webhooks.Dispatcher // another dispatcher sharing the storage
+}
+
+func newFixture(t *testing.T, opts webhooks.Options) fixture {
+	memServer := memory.NewServer()
+	campaigns := memory.NewInMemoryCampaignRepository(memServer)
+	newReplica := func() *webhooks.Dispatcher {
+		dispatcher := webhooks.NewDispatcher(opts, campaigns, memory.NewInMemoryStatsRepository(memServer), memory.NewInMemoryWebhookRepository(memServer), zap.NewNop())
+		t.Cleanup(func() { _ = dispatcher.Close(context.Background()) })
+		return dispatcher
+	}
+	return fixture{
+		dispatcher:  newReplica(),
+		campaigns:   campaigns,
+		impressions: memory.NewInMemoryImpressionRepository(memServer),
+		newReplica:  newReplica,
+	}
+}
+
+var defaultOptions = webhooks.Options{
+	CheckInterval:   time.Hour,
+	Workers:         2,
+	Timeout:         time.Second,
+	MaxAttempts:     3,
+	InitialBackoff:  time.Millisecond,
+	MaxBackoff:      5 * time.Millisecond,
+	DeadLetterLimit: 10,
+	// The receivers listen on loopback
+	AllowPrivateTargets: true,
+}
+
+// eventually polls cond for up to two seconds
+func eventually(t *testing.T, cond func() bool, format string, args ...any) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcherAnnouncesImpressionMilestones(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	ctx := context.Background()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Milestones", StartTime: time.Now().Add(-time.Hour)})
+
+	rec, server := newReceiver(t, alwaysOK)
+	subscription, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookImpressions1K, entities.WebhookImpressions10K}})
+	if err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+	rec.mu.Lock()
+	rec.secret = subscription.Secret
+	rec.mu.Unlock()
+
+	for i := 0; i < 999; i++ {
+		f.impressions.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: fmt.Sprintf("user-%d", i), AdID: "ad"})
+	}
+	f.dispatcher.Check(ctx, time.Now())
+
+	// The 1000th impression arrives in a batch
+	f.impressions.TrackImpressions(ctx, []entities.TrackImpressionRequest{
+		{CampaignID: campaign.ID, UserID: "user-999", AdID: "ad"},
+		{CampaignID: campaign.ID, UserID: "user-999", AdID: "ad"},
+	})
+	f.dispatcher.Check(ctx, time.Now())
+	f.dispatcher.Check(ctx, time.Now())
+
+	eventually(t, func() bool { return len(rec.events()) == 1 }, "❌ Expected one milestone delivery, got %v", rec.events())
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if payload := rec.payloads[0]; payload.Event != entities.WebhookImpressions1K || payload.Impressions != 1000 || payload.CampaignID != campaign.ID {
+		t.Errorf("❌ Unexpected milestone payload: %+v", payload)
+	}
+}
+
+func TestDispatcherAnnouncesFlightBoundaries(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	ctx := context.Background()
+	start := time.Now().Add(time.Hour)
+	end := start.Add(time.Hour)
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Flight", StartTime: start, EndTime: &end})
+
+	rec, server := newReceiver(t, alwaysOK)
+	if _, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookCampaignStarted, entities.WebhookCampaignEnded}}); err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+	// Only interested in the end
+	if _, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookCampaignEnded}}); err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+
+	f.dispatcher.Check(ctx, time.Now())
+	f.dispatcher.Check(ctx, start.Add(time.Minute))
+	eventually(t, func() bool { return len(rec.events()) == 1 }, "❌ Expected the start to be announced once, got %v", rec.events())
+
+	f.dispatcher.Check(ctx, end)
+	f.dispatcher.Check(ctx, end.Add(time.Minute))
+	eventually(t, func() bool { return len(rec.events()) == 3 }, "❌ Expected the end to be announced to both subscriptions, got %v", rec.events())
+	if events := rec.events(); events[0] != entities.WebhookCampaignStarted || events[1] != entities.WebhookCampaignEnded || events[2] != entities.WebhookCampaignEnded {
+		t.Errorf("❌ Unexpected events: %v", events)
+	}
+}
+
+func TestDispatcherFollowsCampaignUpdates(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	ctx := context.Background()
+	now := time.Now()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Open ended", StartTime: now.Add(-time.Hour)})
+
+	rec, server := newReceiver(t, alwaysOK)
+	if _, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookCampaignEnded}}); err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+
+	// An end added after subscribing, then moved later, is announced at its final time only
+	end := now.Add(time.Hour)
+	if _, err := f.campaigns.UpdateCampaign(ctx, campaign.ID, entities.UpdateCampaignRequest{EndTime: &end}); err != nil {
+		t.Fatalf("❌ UpdateCampaign failed: %v", err)
+	}
+	f.dispatcher.Check(ctx, now)
+	moved := end.Add(time.Hour)
+	if _, err := f.campaigns.UpdateCampaign(ctx, campaign.ID, entities.UpdateCampaignRequest{EndTime: &moved}); err != nil {
+		t.Fatalf("❌ UpdateCampaign failed: %v", err)
+	}
+	f.dispatcher.Check(ctx, end)
+	f.dispatcher.Check(ctx, moved)
+
+	eventually(t, func() bool { return len(rec.events()) == 1 }, "❌ Expected the end to be announced once, got %v", rec.events())
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if payload := rec.payloads[0]; payload.Event != entities.WebhookCampaignEnded || !payload.OccurredAt.Equal(moved.UTC()) {
+		t.Errorf("❌ Expected the end at %s, got %+v", moved, payload)
+	}
+}
+
+func TestDispatcherAnnouncesCompletionAsEnd(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	ctx := context.Background()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Goal", StartTime: time.Now().Add(-time.Hour), ImpressionGoal: 2})
+
+	rec, server := newReceiver(t, alwaysOK)
+	if _, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookCampaignEnded}}); err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+
+	for _, userID := range []string{"u1", "u2", "u3"} {
+		f.impressions.TrackImpression(ctx, entities.TrackImpressionRequest{CampaignID: campaign.ID, UserID: userID, AdID: "ad"})
+	}
+	f.dispatcher.Check(ctx, time.Now())
+	f.dispatcher.Check(ctx, time.Now())
+
+	eventually(t, func() bool { return len(rec.events()) == 1 }, "❌ Expected the completion to be announced once, got %v", rec.events())
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if payload := rec.payloads[0]; payload.Event != entities.WebhookCampaignEnded || payload.Impressions != 2 {
+		t.Errorf("❌ Unexpected end payload: %+v", payload)
+	}
+}
+
+func TestDispatcherAnnouncesImpressionsOfEveryInstanceOnce(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	replica := f.newReplica()
+	ctx := context.Background()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Shared", StartTime: time.Now().Add(-time.Hour)})
+
+	rec, server := newReceiver(t, alwaysOK)
+	if _, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookImpressions1K}}); err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+
+	// Counted by an import, which emits nothing the dispatchers could follow
+	now := time.Now()
+	err := f.impressions.ApplyBackfill(ctx, repositories.Backfill{
+		CampaignID: campaign.ID,
+		Total:      1500,
+		Minutes:    map[int64]int64{repositories.MinuteOf(now): 1500},
+		Hours:      map[int64]int64{repositories.HourOf(now): 1500},
+	})
+	if err != nil {
+		t.Fatalf("❌ ApplyBackfill failed: %v", err)
+	}
+
+	// Both instances check the stored total, only one of them announces it
+	var wg sync.WaitGroup
+	for _, d := range []*webhooks.Dispatcher{f.dispatcher, replica, f.dispatcher, replica} {
+		wg.Add(1)
+		go func(d *webhooks.Dispatcher) {
+			defer wg.Done()
+			d.Check(ctx, time.Now())
+		}(d)
+	}
+	wg.Wait()
+
+	eventually(t, func() bool { return len(rec.events()) == 1 }, "❌ Expected the milestone to be announced once, got %v", rec.events())
+	time.Sleep(50 * time.Millisecond)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.payloads) != 1 || rec.payloads[0].Impressions != 1500 {
+		t.Errorf("❌ Expected one announcement of the imported total, got %+v", rec.payloads)
+	}
+}
+
+func TestDispatcherKeepsWebhooksAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "impressions.db")
+	opts := defaultOptions
+	opts.MaxAttempts = 1
+	ctx := context.Background()
+
+	// open starts an instance on the database and returns it with its repositories
+	open := func() (*webhooks.Dispatcher, *bolt.BoltCampaignRepository, func()) {
+		db, err := bolt.Open(path)
+		if err != nil {
+			t.Fatalf("❌ Failed to open database: %v", err)
+		}
+		campaigns := bolt.NewBoltCampaignRepository(db)
+		dispatcher := webhooks.NewDispatcher(opts, campaigns, bolt.NewBoltStatsRepository(db), bolt.NewBoltWebhookRepository(db), zap.NewNop())
+		return dispatcher, campaigns, func() {
+			_ = dispatcher.Close(context.Background())
+			_ = db.Close()
+		}
+	}
+
+	dispatcher, campaigns, stop := open()
+	start := time.Now().Add(time.Hour)
+	end := start.Add(time.Hour)
+	campaign, _ := campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Restarted", StartTime: start, EndTime: &end})
+	failing, failingServer := newReceiver(t, func(int) int { return http.StatusInternalServerError })
+	if _, err := dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: failingServer.URL, Events: []string{entities.WebhookCampaignStarted}}); err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+	rec, server := newReceiver(t, alwaysOK)
+	subscription, err := dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookCampaignStarted, entities.WebhookCampaignEnded}})
+	if err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+	dispatcher.Check(ctx, start)
+	eventually(t, func() bool {
+		letters, _ := dispatcher.DeadLetters(ctx, campaign.ID)
+		return len(rec.events()) == 1 && len(letters) == 1
+	}, "❌ Expected the start to be delivered and dead-lettered, got %v", rec.events())
+	stop()
+
+	dispatcher, _, stop = open()
+	defer stop()
+	if listed, err := dispatcher.Subscriptions(ctx, campaign.ID); err != nil || len(listed) != 2 || listed[1].ID != subscription.ID {
+		t.Errorf("❌ Expected both subscriptions after the restart, got %+v, %v", listed, err)
+	}
+	if letters, err := dispatcher.DeadLetters(ctx, campaign.ID); err != nil || len(letters) != 1 || letters[0].Payload.Event != entities.WebhookCampaignStarted {
+		t.Errorf("❌ Expected the dead letter after the restart, got %+v, %v", letters, err)
+	}
+
+	// The start is not announced again, the end still is
+	dispatcher.Check(ctx, end)
+	eventually(t, func() bool { return len(rec.events()) == 2 }, "❌ Expected the end to be announced, got %v", rec.events())
+	if events := rec.events(); events[1] != entities.WebhookCampaignEnded {
+		t.Errorf("❌ Unexpected events: %v", events)
+	}
+	failing.mu.Lock()
+	defer failing.mu.Unlock()
+	if failing.attempts != 1 {
+		t.Errorf("❌ Expected the start to be attempted once, got %d", failing.attempts)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	ctx := context.Background()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Flaky", StartTime: time.Now().Add(time.Hour)})
+
+	rec, server := newReceiver(t, func(attempt int) int {
+		if attempt < 3 {
+			return http.StatusBadGateway
+		}
+		return http.StatusNoContent
+	})
+	if _, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookCampaignStarted}}); err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+
+	f.dispatcher.Check(ctx, time.Now().Add(2*time.Hour))
+	eventually(t, func() bool { return len(rec.events()) == 1 }, "❌ Expected delivery on the third attempt, got %v", rec.events())
+	if letters, _ := f.dispatcher.DeadLetters(ctx, campaign.ID); len(letters) != 0 {
+		t.Errorf("❌ Expected no dead letters, got %+v", letters)
+	}
+}
+
+func TestDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	ctx := context.Background()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Broken", StartTime: time.Now().Add(time.Hour)})
+
+	rec, server := newReceiver(t, func(int) int { return http.StatusInternalServerError })
+	subscription, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: server.URL, Events: []string{entities.WebhookCampaignStarted}})
+	if err != nil {
+		t.Fatalf("❌ Subscribe failed: %v", err)
+	}
+
+	f.dispatcher.Check(ctx, time.Now().Add(2*time.Hour))
+	var letters []entities.DeadLetter
+	eventually(t, func() bool {
+		letters, _ = f.dispatcher.DeadLetters(ctx, campaign.ID)
+		return len(letters) == 1
+	}, "❌ Expected a dead letter")
+
+	letter := letters[0]
+	if letter.SubscriptionID != subscription.ID || letter.Attempts != 3 || letter.Payload.Event != entities.WebhookCampaignStarted || letter.LastError == "" {
+		t.Errorf("❌ Unexpected dead letter: %+v", letter)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.attempts != 3 {
+		t.Errorf("❌ Expected 3 attempts, got %d", rec.attempts)
+	}
+}
+
+func TestDispatcherSubscriptions(t *testing.T) {
+	f := newFixture(t, defaultOptions)
+	ctx := context.Background()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Listed", StartTime: time.Now()})
+
+	if _, err := f.dispatcher.Subscribe(ctx, "unknown", entities.CreateWebhookRequest{URL: "https://example.com", Events: []string{entities.WebhookCampaignEnded}}); err != repositories.ErrCampaignNotFound {
+		t.Errorf("❌ Expected ErrCampaignNotFound, got %v", err)
+	}
+
+	subscription, _ := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: "https://example.com", Events: []string{entities.WebhookCampaignEnded}})
+	listed, err := f.dispatcher.Subscriptions(ctx, campaign.ID)
+	if err != nil || len(listed) != 1 || listed[0].ID != subscription.ID || listed[0].Secret != "" {
+		t.Errorf("❌ Expected the subscription without its secret, got %+v, %v", listed, err)
+	}
+
+	if err := f.dispatcher.Unsubscribe(ctx, "other", subscription.ID); !errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		t.Errorf("❌ Expected a subscription not to be deleted through another campaign, got %v", err)
+	}
+	if err := f.dispatcher.Unsubscribe(ctx, campaign.ID, subscription.ID); err != nil {
+		t.Errorf("❌ Expected the subscription to be deleted, got %v", err)
+	}
+	if listed, _ := f.dispatcher.Subscriptions(ctx, campaign.ID); len(listed) != 0 {
+		t.Errorf("❌ Expected no subscriptions left, got %+v", listed)
+	}
+}
+
+func TestDispatcherRefusesNonPublicTargets(t *testing.T) {
+	opts := defaultOptions
+	opts.AllowPrivateTargets = false
+	f := newFixture(t, opts)
+	ctx := context.Background()
+	campaign, _ := f.campaigns.CreateCampaign(ctx, entities.CreateCampaignRequest{Name: "Guarded", StartTime: time.Now()})
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.7/hook",
+		"http://192.168.1.1/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: target, Events: []string{entities.WebhookCampaignEnded}})
+		if !errors.Is(err, webhooks.ErrTargetNotAllowed) {
+			t.Errorf("❌ Expected %s to be refused, got %v", target, err)
+		}
+	}
+	if _, err := f.dispatcher.Subscribe(ctx, campaign.ID, entities.CreateWebhookRequest{URL: "https://93.184.216.34/hook", Events: []string{entities.WebhookCampaignEnded}}); err != nil {
+		t.Errorf("❌ Expected a public address to be accepted, got %v", err)
+	}
+
+	// Deliveries check the address actually dialled, whatever the name resolved to when subscribing
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	if _, err := webhooks.NewClient(time.Second, false).Post(server.URL, "application/json", nil); !errors.Is(err, webhooks.ErrTargetNotAllowed) {
+		t.Errorf("❌ Expected the delivery to loopback to be refused, got %v", err)
+	}
+	resp, err := webhooks.NewClient(time.Second, true).Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("❌ Expected the delivery to be allowed, got %v", err)
+	}
+	_ = resp.Body.Close()
+}
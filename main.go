@@ -1,33 +1,119 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"learning/cmd/config"
 	"learning/cmd/server"
 	logger2 "learning/internal/logger"
 	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 func main() {
+	configPath := flag.String("config", "", "path to the YAML config file (default \"config.yml\" if present)")
+	flag.Parse()
 
 	logger := logger2.InitLogger()
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
-		logger.Error(err.Error())
+		logger.Fatal(err.Error())
 	}
+	config.Set(cfg)
+
+	// Rebuild the logger with the configured level, encoding, sampling and outputs
+	if err := logger2.Configure(cfg.Logging); err != nil {
+		logger.Fatal(err.Error())
+	}
+	logger = logger2.Log
+	defer logger2.Sync()
+
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Reload the config on SIGHUP or when the file changes
+	watcher := config.NewWatcher(*configPath, logger)
+	watcher.OnReload(func(prev, next *config.Config) {
+		if prev.Logging.Level != next.Logging.Level {
+			_ = logger2.SetLevel(next.Logging.Level)
+		}
+
+		prevLogging, nextLogging := prev.Logging, next.Logging
+		prevLogging.Level, nextLogging.Level = "", ""
+		if !reflect.DeepEqual(prevLogging, nextLogging) {
+			logger.Warn("logging changes other than the level take effect after a restart")
+		}
+		if !reflect.DeepEqual(prev.Storage, next.Storage) {
+			logger.Warn("storage changes take effect after a restart")
+		}
+		prevIngestion, nextIngestion := prev.Ingestion, next.Ingestion
+		prevIngestion.RetryAfter, nextIngestion.RetryAfter = 0, 0
+		if prevIngestion != nextIngestion {
+			logger.Warn("ingestion changes other than retry_after take effect after a restart")
+		}
+		if prev.Events != next.Events {
+			logger.Warn("event log changes take effect after a restart")
+		}
+		if !reflect.DeepEqual(prev.Anomaly, next.Anomaly) {
+			logger.Warn("anomaly detection changes take effect after a restart")
+		}
+		if prev.Webhooks != next.Webhooks {
+			logger.Warn("webhook changes take effect after a restart")
+		}
+		if prev.Stream.Interval != next.Stream.Interval || prev.Stream.MaxSubscribers != next.Stream.MaxSubscribers {
+			logger.Warn("stream interval and subscriber limit changes take effect after a restart")
+		}
+		prevTraffic, nextTraffic := prev.Traffic, next.Traffic
+		prevTraffic.TrustForwardedFor, nextTraffic.TrustForwardedFor = false, false
+		prevTraffic.TrustedProxies, nextTraffic.TrustedProxies = 0, 0
+		if !reflect.DeepEqual(prevTraffic, nextTraffic) {
+			logger.Warn("traffic filter changes other than trust_forwarded_for and trusted_proxies take effect after a restart")
+		}
+	})
+	go watcher.Run(ctx)
 
 	// Read port from config and convert to string
 	port := fmt.Sprintf(":%d", cfg.Server.Port)
 
 	// Set up the server
-	srv := server.SetupServer()
+	handler, closeStorage, err := server.SetupServer()
+	if err != nil {
+		logger.Fatal("failed to set up server", zap.Error(err))
+	}
+	defer func() {
+		if err := closeStorage(); err != nil {
+			logger.Error("failed to close storage", zap.Error(err))
+		}
+	}()
+
+	srv := &http.Server{Addr: port, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+
+		// Let in-flight requests finish before the storage is closed
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("graceful shutdown failed", zap.Error(err))
+		}
+	}()
 
 	// Start the server using config values
-	err = server.Run(func() error {
-		return http.ListenAndServe(port, srv)
-	})
+	err = server.Run(port, srv.ListenAndServe)
 	if err != nil {
-		logger.Fatal(err.Error())
+		logger.Error(err.Error())
 	}
+	stop()
+	<-shutdownDone
 }